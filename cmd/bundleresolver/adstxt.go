@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// adsTxtEntry is one authorized seller line of an app-ads.txt file.
+type adsTxtEntry struct {
+	Domain        string // advertising system, lower-cased
+	PublisherID   string
+	Relationship  string // DIRECT or RESELLER
+	CertAuthority string
+}
+
+// adsTxt is a parsed app-ads.txt file.
+type adsTxt struct {
+	Entries []adsTxtEntry
+	// Variables maps lower-cased keys such as contact or ownerdomain to
+	// their values in file order.
+	Variables map[string][]string
+	// Invalid lists the line numbers that are neither records nor variables.
+	Invalid []int
+	// SKAdNetworkIDs are the distinct SKAdNetwork identifiers mentioned
+	// anywhere in the file, comments included, in order of appearance. The
+	// spec has no record type for them, so publishers list them freely.
+	SKAdNetworkIDs []string
+}
+
+var reSKAdNetworkID = regexp.MustCompile(`(?i)\b[a-z0-9]{10}\.skadnetwork\b`)
+
+// parseAdsTxt parses the IAB ads.txt syntax shared by app-ads.txt.
+func parseAdsTxt(r io.Reader) (*adsTxt, error) {
+	f := &adsTxt{Variables: map[string][]string{}}
+	seenSKAN := map[string]bool{}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		for _, id := range reSKAdNetworkID.FindAllString(line, -1) {
+			id = strings.ToLower(id)
+			if !seenSKAN[id] {
+				seenSKAN[id] = true
+				f.SKAdNetworkIDs = append(f.SKAdNetworkIDs, id)
+			}
+		}
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+		if line == "" {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok && !strings.Contains(k, ",") {
+			key := strings.ToLower(strings.TrimSpace(k))
+			f.Variables[key] = append(f.Variables[key], strings.TrimSpace(v))
+			continue
+		}
+		e, ok := parseAdsTxtEntry(line, true)
+		if !ok {
+			f.Invalid = append(f.Invalid, n)
+			continue
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	return f, s.Err()
+}
+
+// parseAdsTxtEntry parses "<domain>, <publisher id>, <relationship>[, <cert id>]".
+// The relationship may be left out unless requireRelationship is set.
+func parseAdsTxtEntry(line string, requireRelationship bool) (adsTxtEntry, bool) {
+	parts := strings.Split(line, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	minParts := 2
+	if requireRelationship {
+		minParts = 3
+	}
+	if len(parts) < minParts || len(parts) > 4 || parts[0] == "" || parts[1] == "" {
+		return adsTxtEntry{}, false
+	}
+	e := adsTxtEntry{Domain: strings.ToLower(parts[0]), PublisherID: parts[1]}
+	if len(parts) > 2 {
+		e.Relationship = strings.ToUpper(parts[2])
+		if e.Relationship != "DIRECT" && e.Relationship != "RESELLER" {
+			return adsTxtEntry{}, false
+		}
+	}
+	if len(parts) > 3 {
+		e.CertAuthority = parts[3]
+	}
+	return e, true
+}
+
+// authorizes reports whether f lists seller. A seller without a
+// relationship matches either one.
+func (f *adsTxt) authorizes(seller adsTxtEntry) bool {
+	for _, e := range f.Entries {
+		if e.Domain == seller.Domain && e.PublisherID == seller.PublisherID &&
+			(seller.Relationship == "" || e.Relationship == seller.Relationship) {
+			return true
+		}
+	}
+	return false
+}
+
+// errNoAdsTxt means the developer domain serves no app-ads.txt.
+var errNoAdsTxt = errors.New("no app-ads.txt")
+
+// developerDomain returns the host of a developer website with a leading
+// "www." or "m." removed, where the IAB spec expects app-ads.txt.
+func developerDomain(website string) (string, error) {
+	if website == "" {
+		return "", errors.New("store listing has no developer website")
+	}
+	if !strings.Contains(website, "://") {
+		website = "https://" + website
+	}
+	u, err := url.Parse(website)
+	if err != nil {
+		return "", err
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return "", fmt.Errorf("developer website %q has no host", website)
+	}
+	for _, prefix := range []string{"www.", "m."} {
+		host = strings.TrimPrefix(host, prefix)
+	}
+	return host, nil
+}
+
+// fetchAdsTxt fetches app-ads.txt from domain over HTTPS, falling back to
+// plain HTTP as the spec allows.
+func fetchAdsTxt(ctx context.Context, domain string) (*adsTxt, error) {
+	var firstErr error
+	for _, scheme := range []string{"https", "http"} {
+		f, err := fetchAdsTxtURL(ctx, scheme+"://"+domain+"/app-ads.txt")
+		if err == nil {
+			return f, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+func fetchAdsTxtURL(ctx context.Context, u string) (*adsTxt, error) {
+	resp, err := httpGet(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode == 404 || resp.StatusCode == 410 {
+		return nil, errNoAdsTxt
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s: status %s", u, resp.Status)
+	}
+	// Sites without the file often answer every path with an HTML page.
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt == "text/html" {
+		return nil, fmt.Errorf("%w (%s is served as HTML)", errNoAdsTxt, u)
+	}
+	return parseAdsTxt(resp.Body)
+}
+
+// sellerList collects repeated --seller flags.
+type sellerList []adsTxtEntry
+
+func (l *sellerList) String() string {
+	parts := make([]string, len(*l))
+	for i, e := range *l {
+		parts[i] = formatSeller(e)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (l *sellerList) Set(v string) error {
+	e, ok := parseAdsTxtEntry(v, false)
+	if !ok {
+		return fmt.Errorf("want \"<ad system domain>, <publisher id>[, DIRECT|RESELLER]\", got %q", v)
+	}
+	*l = append(*l, e)
+	return nil
+}
+
+func formatSeller(e adsTxtEntry) string {
+	s := e.Domain + ", " + e.PublisherID
+	if e.Relationship != "" {
+		s += ", " + e.Relationship
+	}
+	return s
+}
+
+func setupAdsTxt(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var inputPath string
+	var opts adsTxtOptions
+	var sellers sellerList
+	fs.StringVar(&inputPath, "input", "", "Read ids from this file instead of STDIN")
+	fs.BoolVar(&opts.Header, "header", true, "Print header row as first line (use --header=false to disable)")
+	fs.Var(&sellers, "seller", "Report whether app-ads.txt authorizes this seller, as \"<ad system domain>, <publisher id>[, DIRECT|RESELLER]\" (repeatable)")
+	fs.BoolVar(&opts.SKAdNetwork, "skadnetwork", false, "Add a skadnetwork_ids column listing the SKAdNetwork ids in app-ads.txt of iOS apps")
+	fs.StringVar(&opts.SellersJSON, "sellers-json", "", "Verify each app's app-ads.txt entries for this ad system domain against its sellers.json, adding chain_status and chain_seller_ids columns")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q (ids are read from STDIN or --input)", args)
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		in := io.Reader(os.Stdin)
+		if inputPath != "" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		opts.Sellers = sellers
+		opts.SellersJSON = strings.ToLower(strings.TrimSpace(opts.SellersJSON))
+		return checkAdsTxt(ctx, in, os.Stdout, opts)
+	}
+}
+
+// adsTxtOptions controls the output of checkAdsTxt.
+type adsTxtOptions struct {
+	Header bool
+	// Sellers to report on, one row per id and seller.
+	Sellers []adsTxtEntry
+	// SKAdNetwork adds the skadnetwork_ids column.
+	SKAdNetwork bool
+	// SellersJSON, when set, is an ad system domain whose sellers.json
+	// confirms the app-ads.txt entries for it (see sellersJSON.chain).
+	SellersJSON string
+}
+
+// checkAdsTxt writes one TSV row per input id, or per id and seller when
+// sellers are given, describing the app-ads.txt of the app's developer.
+// status is ok, missing (no app-ads.txt), no-website or error; entries and
+// invalid count its seller records and malformed lines.
+func checkAdsTxt(ctx context.Context, r io.Reader, w io.Writer, opts adsTxtOptions) error {
+	sellers := opts.Sellers
+	var sj *sellersJSON
+	if opts.SellersJSON != "" {
+		var err error
+		if sj, err = fetchSellersJSON(ctx, opts.SellersJSON); err != nil {
+			return err
+		}
+	}
+	out := newRowWriter(w, nil, false, sanitizeStrip)
+	if opts.Header {
+		cols := []string{"input", "bundle", "domain", "status", "entries", "invalid"}
+		if opts.SKAdNetwork {
+			cols = append(cols, "skadnetwork_ids")
+		}
+		if sj != nil {
+			cols = append(cols, "chain_status", "chain_seller_ids")
+		}
+		if len(sellers) > 0 {
+			cols = append(cols, "seller", "authorized")
+		}
+		if err := out.writeValues(cols...); err != nil {
+			return err
+		}
+	}
+
+	// Developers publish many apps; fetch each domain's file once.
+	type domainResult struct {
+		file *adsTxt
+		err  error
+	}
+	byDomain := map[string]domainResult{}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		id := strings.TrimSpace(s.Text())
+		if id == "" {
+			continue
+		}
+		rec, err := resolveOne(ctx, id, "")
+		bundle := rec.Bundle
+		if bundle == "" {
+			bundle = id
+		}
+		var domain, status string
+		var file *adsTxt
+		if err == nil {
+			domain, err = developerDomain(rec.Website)
+			if err != nil {
+				status = "no-website"
+			}
+		}
+		if err == nil {
+			res, ok := byDomain[domain]
+			if !ok {
+				res.file, res.err = fetchAdsTxt(ctx, domain)
+				byDomain[domain] = res
+			}
+			file, err = res.file, res.err
+			if errors.Is(err, errNoAdsTxt) {
+				status = "missing"
+			}
+		}
+		switch {
+		case err == nil:
+			status = "ok"
+		case status == "":
+			status = "error"
+		}
+		if err != nil {
+			logger.Warn("app-ads.txt check failed", "id", id, "domain", domain, "err", err)
+		}
+
+		entries, invalid := "", ""
+		if file != nil {
+			entries, invalid = strconv.Itoa(len(file.Entries)), strconv.Itoa(len(file.Invalid))
+		}
+		cols := []string{id, bundle, domain, status, entries, invalid}
+		if opts.SKAdNetwork {
+			var ids string
+			// SKAdNetwork is iOS-only; Android apps of the same developer
+			// share the file but not the ids.
+			if file != nil && platformOf(id) == platformIOS {
+				ids = strings.Join(file.SKAdNetworkIDs, ",")
+			}
+			cols = append(cols, ids)
+		}
+		if sj != nil {
+			// Without a readable app-ads.txt, status tells why.
+			var chain string
+			var ids []string
+			if file != nil {
+				chain, ids = sj.chain(file, domain)
+			}
+			cols = append(cols, chain, strings.Join(ids, ","))
+		}
+		if len(sellers) == 0 {
+			if err := out.writeValues(cols...); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, seller := range sellers {
+			authorized := "false"
+			if file != nil && file.authorizes(seller) {
+				authorized = "true"
+			}
+			if err := out.writeValues(append(cols[:len(cols):len(cols)], formatSeller(seller), authorized)...); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return out.flush()
+}
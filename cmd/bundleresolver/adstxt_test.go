@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleAdsTxt = `# app-ads.txt for dev.example
+google.com, pub-1234567890, DIRECT, f08c47fec0942fa0
+AdNetwork.example, 42, reseller
+contact=ads@dev.example
+not a record
+# SKAdNetwork: cstr6suwn9.skadnetwork, 4FZDC2EVR5.SKAdNetwork
+skadnetwork=cstr6suwn9.skadnetwork
+`
+
+func TestParseAdsTxt(t *testing.T) {
+	f, err := parseAdsTxt(strings.NewReader(sampleAdsTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []adsTxtEntry{
+		{Domain: "google.com", PublisherID: "pub-1234567890", Relationship: "DIRECT", CertAuthority: "f08c47fec0942fa0"},
+		{Domain: "adnetwork.example", PublisherID: "42", Relationship: "RESELLER"},
+	}
+	if !reflect.DeepEqual(f.Entries, want) {
+		t.Errorf("entries = %+v, want %+v", f.Entries, want)
+	}
+	if got := f.Variables["contact"]; !reflect.DeepEqual(got, []string{"ads@dev.example"}) {
+		t.Errorf("contact = %q", got)
+	}
+	if !reflect.DeepEqual(f.Invalid, []int{5}) {
+		t.Errorf("invalid lines = %v, want [5]", f.Invalid)
+	}
+	if want := []string{"cstr6suwn9.skadnetwork", "4fzdc2evr5.skadnetwork"}; !reflect.DeepEqual(f.SKAdNetworkIDs, want) {
+		t.Errorf("SKAdNetwork ids = %q, want %q", f.SKAdNetworkIDs, want)
+	}
+
+	cases := []struct {
+		seller string
+		want   bool
+	}{
+		{"google.com, pub-1234567890", true},
+		{"Google.com, pub-1234567890, direct", true},
+		{"google.com, pub-1234567890, RESELLER", false},
+		{"google.com, pub-999", false},
+	}
+	for _, tc := range cases {
+		var l sellerList
+		if err := l.Set(tc.seller); err != nil {
+			t.Fatalf("Set(%q): %v", tc.seller, err)
+		}
+		if got := f.authorizes(l[0]); got != tc.want {
+			t.Errorf("authorizes(%q) = %v, want %v", tc.seller, got, tc.want)
+		}
+	}
+}
+
+func TestDeveloperDomain(t *testing.T) {
+	cases := map[string]string{
+		"https://www.dev.example/apps": "dev.example",
+		"http://m.Dev.Example":         "dev.example",
+		"games.dev.example":            "games.dev.example",
+	}
+	for in, want := range cases {
+		if got, err := developerDomain(in); err != nil || got != want {
+			t.Errorf("developerDomain(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := developerDomain(""); err == nil {
+		t.Error("expected an error without a website")
+	}
+}
+
+// fakeTransport answers requests from a map of URL to body, with the
+// Content-Type a server would sniff for it; other URLs get 404.
+type fakeTransport map[string]string
+
+func (f fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := f[req.URL.String()]
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusNotFound
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": {http.DetectContentType([]byte(body))}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestCheckAdsTxt(t *testing.T) {
+	originalResolve, originalClient := resolveFunc, httpClient
+	defer func() {
+		resolveFunc, httpClient = originalResolve, originalClient
+	}()
+	websites := map[string]string{"1": "https://www.dev.example/", "com.dev.game": "https://dev.example", "2": "https://nofile.example", "3": ""}
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return record{Bundle: id, Website: websites[id]}, nil
+	}
+	httpClient = &http.Client{Transport: fakeTransport{"https://dev.example/app-ads.txt": sampleAdsTxt}}
+
+	var l sellerList
+	l.Set("google.com, pub-1234567890, DIRECT")
+	var out strings.Builder
+	opts := adsTxtOptions{Header: true, Sellers: l}
+	if err := checkAdsTxt(context.Background(), strings.NewReader("1\ncom.dev.game\n\n2\n3\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "input\tbundle\tdomain\tstatus\tentries\tinvalid\tseller\tauthorized\n" +
+		"1\t1\tdev.example\tok\t2\t1\tgoogle.com, pub-1234567890, DIRECT\ttrue\n" +
+		"com.dev.game\tcom.dev.game\tdev.example\tok\t2\t1\tgoogle.com, pub-1234567890, DIRECT\ttrue\n" +
+		"2\t2\tnofile.example\tmissing\t\t\tgoogle.com, pub-1234567890, DIRECT\tfalse\n" +
+		"3\t3\t\tno-website\t\t\tgoogle.com, pub-1234567890, DIRECT\tfalse\n"
+	if got := out.String(); got != want {
+		t.Errorf("output mismatch:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestCheckAdsTxtSKAdNetwork(t *testing.T) {
+	originalResolve, originalClient := resolveFunc, httpClient
+	defer func() {
+		resolveFunc, httpClient = originalResolve, originalClient
+	}()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return record{Bundle: id, Website: "https://dev.example"}, nil
+	}
+	httpClient = &http.Client{Transport: fakeTransport{"https://dev.example/app-ads.txt": sampleAdsTxt}}
+
+	var out strings.Builder
+	opts := adsTxtOptions{SKAdNetwork: true}
+	if err := checkAdsTxt(context.Background(), strings.NewReader("1\ncom.dev.game\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\t1\tdev.example\tok\t2\t1\tcstr6suwn9.skadnetwork,4fzdc2evr5.skadnetwork\n" +
+		"com.dev.game\tcom.dev.game\tdev.example\tok\t2\t1\t\n"
+	if got := out.String(); got != want {
+		t.Errorf("output mismatch:\n got: %q\nwant: %q", got, want)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// assetStatement is one entry of a Digital Asset Links assetlinks.json file.
+type assetStatement struct {
+	Relation []string `json:"relation"`
+	Target   struct {
+		Namespace    string   `json:"namespace"`
+		PackageName  string   `json:"package_name"`
+		Fingerprints []string `json:"sha256_cert_fingerprints"`
+	} `json:"target"`
+}
+
+// errNoAssetLinks means the domain serves no assetlinks.json.
+var errNoAssetLinks = errors.New("no assetlinks.json")
+
+// fetchAssetLinks fetches /.well-known/assetlinks.json from domain. Android
+// only accepts it over HTTPS.
+func fetchAssetLinks(ctx context.Context, domain string) ([]assetStatement, error) {
+	u := "https://" + domain + "/.well-known/assetlinks.json"
+	resp, err := httpGet(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode == 404 || resp.StatusCode == 410 {
+		return nil, errNoAssetLinks
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s: status %s", u, resp.Status)
+	}
+	var statements []assetStatement
+	if err := json.NewDecoder(resp.Body).Decode(&statements); err != nil {
+		return nil, fmt.Errorf("%s: invalid JSON: %w", u, err)
+	}
+	return statements, nil
+}
+
+func setupAppLinks(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var inputPath string
+	var showHeader bool
+	fs.StringVar(&inputPath, "input", "", "Read ids from this file instead of STDIN")
+	fs.BoolVar(&showHeader, "header", true, "Print header row as first line (use --header=false to disable)")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q (ids are read from STDIN or --input)", args)
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		in := io.Reader(os.Stdin)
+		if inputPath != "" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		return checkAppLinks(ctx, in, os.Stdout, showHeader)
+	}
+}
+
+// checkAppLinks writes, for every Android id, one TSV row per package and
+// signing certificate fingerprint declared in its developer domain's
+// assetlinks.json. self tells whether the package is the app itself, which
+// flags look-alike apps claiming the same site. Ids without declarations
+// get a single row whose status says why: no-statements, missing,
+// no-website, not-android or error.
+func checkAppLinks(ctx context.Context, r io.Reader, w io.Writer, header bool) error {
+	out := newRowWriter(w, nil, false, sanitizeStrip)
+	if header {
+		if err := out.writeValues("input", "bundle", "domain", "status", "package", "sha256_cert_fingerprint", "relation", "self"); err != nil {
+			return err
+		}
+	}
+
+	type domainResult struct {
+		statements []assetStatement
+		err        error
+	}
+	byDomain := map[string]domainResult{}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		id := strings.TrimSpace(s.Text())
+		if id == "" {
+			continue
+		}
+		var rec record
+		var domain, status string
+		var apps []assetStatement
+		var err error
+		if platformOf(id) != platformAndroid {
+			status = "not-android"
+		} else if rec, err = resolveOne(ctx, id, ""); err == nil {
+			domain, err = developerDomain(rec.Website)
+			if err != nil {
+				status = "no-website"
+			}
+		}
+		if status == "" && err == nil {
+			res, ok := byDomain[domain]
+			if !ok {
+				res.statements, res.err = fetchAssetLinks(ctx, domain)
+				byDomain[domain] = res
+			}
+			err = res.err
+			if errors.Is(err, errNoAssetLinks) {
+				status = "missing"
+			}
+			for _, st := range res.statements {
+				if st.Target.Namespace == "android_app" {
+					apps = append(apps, st)
+				}
+			}
+			if err == nil && len(apps) == 0 {
+				status = "no-statements"
+			}
+		}
+		if err != nil {
+			logger.Warn("app links check failed", "id", id, "domain", domain, "err", err)
+			if status == "" {
+				status = "error"
+			}
+		}
+
+		bundle := rec.Bundle
+		if bundle == "" {
+			bundle = id
+		}
+		if len(apps) == 0 {
+			if err := out.writeValues(id, bundle, domain, status, "", "", "", ""); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, st := range apps {
+			self := "false"
+			if st.Target.PackageName == bundle {
+				self = "true"
+			}
+			fingerprints := st.Target.Fingerprints
+			if len(fingerprints) == 0 {
+				fingerprints = []string{""}
+			}
+			for _, fp := range fingerprints {
+				if err := out.writeValues(id, bundle, domain, "ok", st.Target.PackageName, strings.ToUpper(fp), strings.Join(st.Relation, ","), self); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return out.flush()
+}
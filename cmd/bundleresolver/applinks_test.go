@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const sampleAssetLinks = `[
+  {
+    "relation": ["delegate_permission/common.handle_all_urls"],
+    "target": {
+      "namespace": "android_app",
+      "package_name": "com.dev.app",
+      "sha256_cert_fingerprints": ["14:6d:e9:83", "AA:BB:CC:DD"]
+    }
+  },
+  {"relation": ["delegate_permission/common.get_login_creds"], "target": {"namespace": "web", "site": "https://dev.example"}},
+  {"relation": ["delegate_permission/common.handle_all_urls"], "target": {"namespace": "android_app", "package_name": "com.dev.other", "sha256_cert_fingerprints": ["11:22"]}}
+]`
+
+func TestCheckAppLinks(t *testing.T) {
+	originalResolve, originalClient := resolveFunc, httpClient
+	defer func() {
+		resolveFunc, httpClient = originalResolve, originalClient
+	}()
+	websites := map[string]string{"com.dev.app": "https://dev.example", "com.nosite.app": ""}
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return record{Bundle: id, Website: websites[id]}, nil
+	}
+	httpClient = &http.Client{Transport: fakeTransport{"https://dev.example/.well-known/assetlinks.json": sampleAssetLinks}}
+
+	var out strings.Builder
+	if err := checkAppLinks(context.Background(), strings.NewReader("com.dev.app\ncom.nosite.app\n123\n"), &out, true); err != nil {
+		t.Fatal(err)
+	}
+	const rel = "delegate_permission/common.handle_all_urls"
+	want := "input\tbundle\tdomain\tstatus\tpackage\tsha256_cert_fingerprint\trelation\tself\n" +
+		"com.dev.app\tcom.dev.app\tdev.example\tok\tcom.dev.app\t14:6D:E9:83\t" + rel + "\ttrue\n" +
+		"com.dev.app\tcom.dev.app\tdev.example\tok\tcom.dev.app\tAA:BB:CC:DD\t" + rel + "\ttrue\n" +
+		"com.dev.app\tcom.dev.app\tdev.example\tok\tcom.dev.other\t11:22\t" + rel + "\tfalse\n" +
+		"com.nosite.app\tcom.nosite.app\t\tno-website\t\t\t\t\n" +
+		"123\t123\t\tnot-android\t\t\t\t\n"
+	if got := out.String(); got != want {
+		t.Errorf("output mismatch:\n got: %q\nwant: %q", got, want)
+	}
+}
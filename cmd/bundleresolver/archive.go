@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// archiveLookups, set by --archive, looks apps the stores no longer list
+// up in the Wayback Machine.
+var archiveLookups bool
+
+// waybackAvailableURL is the Wayback Machine availability API.
+var waybackAvailableURL = "https://archive.org/wayback/available"
+
+// Values of the source field: where a record's data came from.
+const (
+	sourceStore   = "store"
+	sourceArchive = "archive"
+	sourcePlugin  = "plugin"
+)
+
+// errNotArchived means the Wayback Machine has no usable snapshot.
+var errNotArchived = errors.New("no archived store page")
+
+// archivedPageURLs are the store page URLs an app may have been archived
+// under, most likely first: App Store pages moved from itunes.apple.com to
+// apps.apple.com, and both carry a storefront.
+func archivedPageURLs(platform, id string) []string {
+	if platform == platformAndroid {
+		return []string{"play.google.com/store/apps/details?id=" + id}
+	}
+	country := urlCountry
+	if country == "" {
+		country = "us"
+	}
+	return []string{
+		"apps.apple.com/" + country + "/app/id" + id,
+		"itunes.apple.com/" + country + "/app/id" + id,
+	}
+}
+
+// waybackSnapshot asks the Wayback Machine for the newest snapshot of
+// pageURL that answered 200, and returns the URL of its original HTML,
+// without the archive's toolbar.
+func waybackSnapshot(ctx context.Context, pageURL string) (string, error) {
+	resp, err := httpGet(ctx, waybackAvailableURL+"?url="+url.QueryEscape(pageURL))
+	if err != nil {
+		return "", err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return "", statusError(resp)
+	}
+	var payload struct {
+		ArchivedSnapshots struct {
+			Closest struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+				Timestamp string `json:"timestamp"`
+				Status    string `json:"status"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("wayback availability: invalid JSON: %w", err)
+	}
+	c := payload.ArchivedSnapshots.Closest
+	if !c.Available || c.Status != "200" || c.Timestamp == "" {
+		return "", errNotArchived
+	}
+	// The id_ flag serves the page as archived.
+	snapshot := strings.Replace(c.URL, "/"+c.Timestamp+"/", "/"+c.Timestamp+"id_/", 1)
+	return strings.Replace(snapshot, "http://", "https://", 1), nil
+}
+
+// appStoreNameAndPublisher returns the app and developer names of an App
+// Store page, as archived over the years: the product header, else the
+// page title and its JSON-LD description.
+func appStoreNameAndPublisher(doc *goquery.Document) (name, publisher string) {
+	header := doc.Find("h1.product-header__title").First().Clone()
+	header.Find("span").Remove() // the age rating badge
+	name = strings.TrimSpace(header.Text())
+	publisher = strings.TrimSpace(doc.Find(".product-header__identity a").First().Text())
+	if name == "" {
+		name, _ = doc.Find("meta[property='og:title']").Attr("content")
+		if name == "" {
+			name = doc.Find("title").First().Text()
+		}
+		// Titles start with a left-to-right mark.
+		name = strings.Trim(strings.TrimSpace(name), "\u200e")
+		name = strings.TrimSpace(strings.TrimSuffix(name, " on the App Store"))
+	}
+	if publisher == "" {
+		doc.Find("script[type='application/ld+json']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			var ld struct {
+				Author struct {
+					Name string `json:"name"`
+				} `json:"author"`
+			}
+			if json.Unmarshal([]byte(s.Text()), &ld) == nil && ld.Author.Name != "" {
+				publisher = strings.TrimSpace(ld.Author.Name)
+				return false
+			}
+			return true
+		})
+	}
+	return name, publisher
+}
+
+// fetchArchived recovers the name and publisher of an app gone from its
+// store from the newest archived copy of its store page.
+func fetchArchived(ctx context.Context, platform, id string) (record, error) {
+	var snapshot string
+	err := errNotArchived
+	for _, pageURL := range archivedPageURLs(platform, id) {
+		if snapshot, err = waybackSnapshot(ctx, pageURL); err == nil {
+			break
+		}
+		if !errors.Is(err, errNotArchived) {
+			return record{}, err
+		}
+	}
+	if err != nil {
+		return record{}, err
+	}
+	resp, err := httpGet(ctx, snapshot)
+	if err != nil {
+		return record{}, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return record{}, statusError(resp)
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return record{}, err
+	}
+	var name, publisher string
+	if platform == platformAndroid {
+		name, publisher = playNameAndPublisher(doc)
+	} else {
+		name, publisher = appStoreNameAndPublisher(doc)
+	}
+	if name == "" {
+		return record{}, fmt.Errorf("%s: %w", snapshot, errNotArchived)
+	}
+	return record{
+		Bundle:      id,
+		Name:        name,
+		Publisher:   publisher,
+		URL:         storeURL(platform, id),
+		ResolvedURL: snapshot,
+		Source:      sourceArchive,
+		Fallbacks:   []string{fallbackArchive},
+	}, nil
+}
+
+// addArchived replaces the result of a lookup that found no app with its
+// archived store page. Other failures, and ids the archive has nothing
+// for, keep their result.
+func addArchived(ctx context.Context, platform, id string, rec record, err error) (record, error) {
+	// ios:<bundle id> inputs have no store page URL to look for.
+	if err == nil || !isNotFoundError(err) || (platform == platformIOS && !reIOS.MatchString(id)) {
+		return rec, err
+	}
+	archived, archiveErr := fetchArchived(ctx, platform, id)
+	if archiveErr != nil {
+		logger.Debug("archive lookup failed", "id", id, "err", archiveErr)
+		return rec, err
+	}
+	lookupRetries.Add(1)
+	return archived, nil
+}
+
+// sourceValue renders the source field; failed lookups have none.
+func (rec record) sourceValue() string {
+	switch {
+	case rec.Source != "":
+		return rec.Source
+	case rec.Name == "":
+		return ""
+	case slices.Contains(rec.Fallbacks, fallbackPlugin):
+		return sourcePlugin
+	}
+	return sourceStore
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestAddArchived(t *testing.T) {
+	originalClient, originalArchive, originalCountry := httpClient, archiveLookups, urlCountry
+	defer func() {
+		httpClient, archiveLookups, urlCountry = originalClient, originalArchive, originalCountry
+	}()
+	archiveLookups, urlCountry = true, ""
+	available := func(page string) string {
+		return waybackAvailableURL + "?url=" + url.QueryEscape(page)
+	}
+	httpClient = &http.Client{Transport: fakeTransport{
+		available("apps.apple.com/us/app/id123"):                                                       `{"archived_snapshots":{}}`,
+		available("itunes.apple.com/us/app/id123"):                                                     `{"archived_snapshots":{"closest":{"available":true,"status":"200","timestamp":"20190301000000","url":"http://web.archive.org/web/20190301000000/https://itunes.apple.com/us/app/old-game/id123"}}}`,
+		"https://web.archive.org/web/20190301000000id_/https://itunes.apple.com/us/app/old-game/id123": `<html><h1 class="product-header__title">Old Game <span class="badge">4+</span></h1><h2 class="product-header__identity"><a href="/developer">Old Studio</a></h2></html>`,
+		available("play.google.com/store/apps/details?id=com.never.archived"):                          `{"archived_snapshots":{}}`,
+	}}
+	notFound := errors.New("not found")
+
+	rec, err := addArchived(context.Background(), platformIOS, "123", record{Bundle: "123"}, notFound)
+	if err != nil {
+		t.Fatalf("archived app: %v", err)
+	}
+	if rec.Name != "Old Game" || rec.Publisher != "Old Studio" || rec.value(FieldSource) != "archive" || rec.ResolvedURL != "https://web.archive.org/web/20190301000000id_/https://itunes.apple.com/us/app/old-game/id123" {
+		t.Errorf("archived record = %+v", rec)
+	}
+	if got := rec.confidence(); got != "0.50" {
+		t.Errorf("confidence = %q, want 0.50", got)
+	}
+
+	rec, err = addArchived(context.Background(), platformAndroid, "com.never.archived", record{Bundle: "com.never.archived"}, notFound)
+	if err != notFound || rec.value(FieldSource) != "" {
+		t.Errorf("unarchived app = %+v, %v; want the not found error", rec, err)
+	}
+	if _, err := addArchived(context.Background(), platformIOS, "123", record{}, errors.New("timeout")); err == nil {
+		t.Error("a failed lookup other than not found was replaced")
+	}
+}
+
+func TestAppStoreNameAndPublisher(t *testing.T) {
+	html := `<html><head><title>` + "\u200e" + `Old Game on the App Store</title>
+<script type="application/ld+json">{"@type":"SoftwareApplication","author":{"@type":"Person","name":"Old Studio"}}</script></head></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, publisher := appStoreNameAndPublisher(doc); name != "Old Game" || publisher != "Old Studio" {
+		t.Errorf("appStoreNameAndPublisher = %q, %q", name, publisher)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// assetOptions controls --download-assets.
+type assetOptions struct {
+	Dir         string
+	Screenshots bool
+}
+
+// downloadAssets saves the icon of rec, and its screenshots if asked, as
+// <dir>/<bundle>/icon.<ext> and <dir>/<bundle>/screenshot-NN.<ext>, so
+// re-runs overwrite the same files.
+func downloadAssets(ctx context.Context, opts assetOptions, rec record) error {
+	if rec.IconURL == "" && (!opts.Screenshots || len(rec.Screenshots) == 0) {
+		return nil
+	}
+	dir := filepath.Join(opts.Dir, safeFilename(rec.Bundle))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if rec.IconURL != "" {
+		if err := downloadAsset(ctx, rec.IconURL, filepath.Join(dir, "icon")); err != nil {
+			return fmt.Errorf("icon: %w", err)
+		}
+	}
+	if !opts.Screenshots {
+		return nil
+	}
+	for i, u := range rec.Screenshots {
+		if err := downloadAsset(ctx, u, filepath.Join(dir, fmt.Sprintf("screenshot-%02d", i+1))); err != nil {
+			return fmt.Errorf("screenshot %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// downloadAsset fetches u into base plus an extension chosen from the
+// response's content type (or the URL), writing through a temporary file
+// so an interrupted download leaves no partial image behind.
+func downloadAsset(ctx context.Context, u, base string) error {
+	resp, err := httpGet(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	name := base + assetExt(resp.Header.Get("Content-Type"), u)
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+var imageExts = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+func assetExt(contentType, u string) string {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if ext, ok := imageExts[mt]; ok {
+		return ext
+	}
+	if parsed, err := url.Parse(u); err == nil {
+		if ext := path.Ext(parsed.Path); ext != "" && len(ext) <= 5 {
+			return ext
+		}
+	}
+	return ".img"
+}
+
+// playIcon returns the icon URL of a Play details page.
+func playIcon(doc *goquery.Document) string {
+	if src, ok := doc.Find("img[itemprop='image']").First().Attr("src"); ok {
+		return src
+	}
+	src, _ := doc.Find("meta[property='og:image']").First().Attr("content")
+	return src
+}
+
+// playScreenshots returns the screenshot URLs of a Play details page in
+// page order.
+func playScreenshots(doc *goquery.Document) []string {
+	var urls []string
+	doc.Find("img[alt='Screenshot image'], img[data-screenshot-index]").Each(func(_ int, s *goquery.Selection) {
+		src, ok := s.Attr("src")
+		if !ok {
+			src, ok = s.Attr("data-src")
+		}
+		if ok && src != "" && !containsString(urls, src) {
+			urls = append(urls, src)
+		}
+	})
+	return urls
+}
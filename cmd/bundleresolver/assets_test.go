@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestDownloadAssets(t *testing.T) {
+	originalClient := httpClient
+	defer func() {
+		httpClient = originalClient
+	}()
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://cdn.example/icon.png":  "icon",
+		"https://cdn.example/shot1.jpg": "one",
+		"https://cdn.example/shot2":     "two",
+	}}
+
+	dir := t.TempDir()
+	rec := record{
+		Bundle:      "com.example.app",
+		IconURL:     "https://cdn.example/icon.png",
+		Screenshots: []string{"https://cdn.example/shot1.jpg", "https://cdn.example/shot2"},
+	}
+	if err := downloadAssets(context.Background(), assetOptions{Dir: dir}, rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := downloadAssets(context.Background(), assetOptions{Dir: dir, Screenshots: true}, rec); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"icon.png": "icon", "screenshot-01.jpg": "one", "screenshot-02.img": "two"}
+	entries, err := os.ReadDir(filepath.Join(dir, "com.example.app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, e := range entries {
+		data, _ := os.ReadFile(filepath.Join(dir, "com.example.app", e.Name()))
+		got[e.Name()] = string(data)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+
+	rec.IconURL = "https://cdn.example/missing.png"
+	if err := downloadAssets(context.Background(), assetOptions{Dir: dir}, rec); err == nil {
+		t.Error("missing icon reported no error")
+	}
+}
+
+func TestPlayArtwork(t *testing.T) {
+	html := `<html><head><meta property="og:image" content="https://play-lh.example/og"></head><body>
+<img itemprop="image" src="https://play-lh.example/icon=s180">
+<div role="list"><img alt="Screenshot image" src="https://play-lh.example/s1"><img alt="Screenshot image" src="https://play-lh.example/s2"></div>
+</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := playIcon(doc); got != "https://play-lh.example/icon=s180" {
+		t.Errorf("icon = %q", got)
+	}
+	if got, want := playScreenshots(doc), []string{"https://play-lh.example/s1", "https://play-lh.example/s2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("screenshots = %q, want %q", got, want)
+	}
+}
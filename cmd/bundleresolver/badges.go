@@ -0,0 +1,52 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Badge labels, spelled the same for both stores.
+const (
+	badgeEditorsChoice   = "Editors' Choice"
+	badgeTeacherApproved = "Teacher Approved"
+)
+
+// chartBadgeRe matches chart placements shown as badges: "#3 in Puzzle" on
+// the App Store, "#1 top free in Games" on Play.
+var chartBadgeRe = regexp.MustCompile(`^#\d+ (top (free|paid|grossing) )?in \S.*$`)
+
+// parseBadges returns the merchandising badges of a store page in page
+// order, such as Editors' Choice and chart placements, or nil if it shows
+// none. Both stores render a badge as a short text of its own, so leaf
+// elements whose whole text is a known label are taken.
+func parseBadges(doc *goquery.Document) []string {
+	var badges []string
+	doc.Find("a, span, div, li, p").Each(func(_ int, s *goquery.Selection) {
+		if s.Children().Length() > 0 {
+			return
+		}
+		badge := normalizeBadge(s.Text())
+		if badge != "" && !containsString(badges, badge) {
+			badges = append(badges, badge)
+		}
+	})
+	return badges
+}
+
+// normalizeBadge returns the badge a text stands for, or "" if it is none.
+func normalizeBadge(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	// The App Store spells it with a typographic apostrophe.
+	switch strings.ToLower(strings.ReplaceAll(text, "’", "'")) {
+	case "editors' choice", "editor's choice":
+		return badgeEditorsChoice
+	case "teacher approved":
+		return badgeTeacherApproved
+	}
+	if chartBadgeRe.MatchString(text) {
+		return text
+	}
+	return ""
+}
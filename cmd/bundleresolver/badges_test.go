@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseBadges(t *testing.T) {
+	cases := map[string]string{
+		// App Store header
+		`<ul class="product-header__list"><li><ul><li><a href="/charts">#3 in Puzzle</a></li></ul></li></ul><div class="editors-choice"><span>Editors’ Choice</span></div>`: "#3 in Puzzle,Editors' Choice",
+		// Play details page
+		`<div><span>Editors' Choice</span></div><div><span>#1 top free in Games</span></div><div><span>Editors' Choice</span></div>`: "Editors' Choice,#1 top free in Games",
+		`<div><span>Teacher   Approved</span></div>`:                                          "Teacher Approved",
+		`<p>Our #1 in class support is the reason we won Editors' Choice</p><span>4.5</span>`: "",
+	}
+	for html, want := range cases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := (record{Badges: parseBadges(doc)}).value(FieldBadges); got != want {
+			t.Errorf("badges of %s = %q, want %q", html, got, want)
+		}
+	}
+}
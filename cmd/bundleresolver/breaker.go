@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen is returned without contacting a store whose breaker is open.
+var errCircuitOpen = errors.New("store paused after repeated failures")
+
+// circuitBreaker stops requests to one store after threshold consecutive
+// failures until cooldown has passed. Then a single trial request is let
+// through: success closes the breaker, failure opens it again. A nil or
+// zero-threshold breaker allows everything.
+type circuitBreaker struct {
+	store     string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func newCircuitBreaker(store string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{store: store, threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// storeBreakers holds the breaker of each store, installed by
+// commonFlags.setup. Stores without one are never paused.
+var storeBreakers = map[string]*circuitBreaker{}
+
+// allow reports errCircuitOpen while the breaker is open.
+func (b *circuitBreaker) allow() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if now.Before(b.openUntil) {
+		return fmt.Errorf("%w: %s requests resume at %s", errCircuitOpen, b.store, b.openUntil.Format(time.TimeOnly))
+	}
+	if b.failures >= b.threshold {
+		// Half-open: hold back everyone else while the trial runs.
+		b.openUntil = now.Add(b.cooldown)
+	}
+	return nil
+}
+
+// done records the outcome of an allowed request. Not-found answers prove the
+// store is up and count as successes.
+func (b *circuitBreaker) done(err error) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var geoErr *geoBlockedError
+	if err == nil || isNotFoundError(err) || errors.As(err, &geoErr) {
+		if b.failures >= b.threshold {
+			logger.Info("store recovered, resuming requests", "platform", b.store)
+		}
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+		logger.Warn("store failing, pausing requests", "platform", b.store,
+			"consecutive_failures", b.failures, "cooldown", b.cooldown, "err", err)
+	}
+}
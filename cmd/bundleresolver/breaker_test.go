@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker(platformAndroid, 2, time.Minute)
+	b.now = func() time.Time { return now }
+	timeout := errors.New("context deadline exceeded")
+
+	for i := 0; i < 2; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("request %d refused: %v", i, err)
+		}
+		b.done(timeout)
+	}
+	if err := b.allow(); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("after 2 failures allow() = %v, want errCircuitOpen", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.allow(); err != nil {
+		t.Fatalf("trial request after cooldown refused: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("second request during the trial allowed: %v", err)
+	}
+	b.done(timeout)
+	if err := b.allow(); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("failed trial should reopen the breaker, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.allow(); err != nil {
+		t.Fatalf("trial request refused: %v", err)
+	}
+	b.done(errors.New("not found"))
+	for i := 0; i < 3; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("breaker should be closed after a successful trial: %v", err)
+		}
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	var nilBreaker *circuitBreaker
+	b := newCircuitBreaker(platformIOS, 0, time.Minute)
+	for i := 0; i < 5; i++ {
+		b.done(errors.New("status 503 Service Unavailable"))
+		if err := b.allow(); err != nil {
+			t.Fatalf("disabled breaker refused a request: %v", err)
+		}
+		if err := nilBreaker.allow(); err != nil {
+			t.Fatalf("nil breaker refused a request: %v", err)
+		}
+	}
+}
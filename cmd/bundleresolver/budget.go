@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// storeRequests counts the HTTP requests sent, retries and redirects
+// included, for --max-requests.
+var storeRequests atomic.Int64
+
+// countingTransport counts every request into storeRequests and, with
+// --request-stats, activeRequestStats.
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	storeRequests.Add(1)
+	stats := activeRequestStats.Load()
+	if stats == nil {
+		return t.base.RoundTrip(req)
+	}
+	started := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	stats.record(req, resp, err, time.Since(started))
+	return resp, err
+}
+
+// runBudget stops a run from starting lookups once it has sent
+// maxRequests requests or seen maxErrors failed lookups; zero limits are
+// unlimited. Lookups already running finish, so a run may go a little over.
+type runBudget struct {
+	maxRequests int64
+	maxErrors   int64
+	// baseRequests is storeRequests at the start of the run.
+	baseRequests int64
+	errors       atomic.Int64
+}
+
+func newRunBudget(maxRequests, maxErrors int) *runBudget {
+	return &runBudget{maxRequests: int64(maxRequests), maxErrors: int64(maxErrors), baseRequests: storeRequests.Load()}
+}
+
+// failed records a failed lookup.
+func (b *runBudget) failed() {
+	b.errors.Add(1)
+}
+
+// exceeded returns the flag whose limit the run reached, or "".
+func (b *runBudget) exceeded() string {
+	switch {
+	case b.maxRequests > 0 && storeRequests.Load()-b.baseRequests >= b.maxRequests:
+		return fmt.Sprintf("--max-requests %d", b.maxRequests)
+	case b.maxErrors > 0 && b.errors.Load() >= b.maxErrors:
+		return fmt.Sprintf("--max-errors %d", b.maxErrors)
+	}
+	return ""
+}
+
+// budgetError reports a run stopped by its budget.
+type budgetError struct {
+	limit   string
+	skipped int
+}
+
+func (e *budgetError) Error() string {
+	return fmt.Sprintf("stopped by %s: %d lines not looked up", e.limit, e.skipped)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProcessMaxErrors(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	resolveFunc = func(ctx context.Context, id string) (record, error) {
+		if strings.HasPrefix(id, "com.bad.") {
+			return record{Bundle: id}, statusError(&http.Response{StatusCode: 503, Status: "503 Service Unavailable"})
+		}
+		return record{Bundle: id, Name: "App"}, nil
+	}
+
+	var out, failed strings.Builder
+	input := strings.NewReader("com.bad.a\ncom.good.a\ncom.bad.b\n\ncom.good.b\ncom.good.c\n")
+	opts := options{Fields: []Field{FieldBundle}, SkipErrors: true, Concurrency: 1, MaxErrors: 2, FailedOut: &failed}
+	err := process(input, &out, opts)
+	var stopped *budgetError
+	if !errors.As(err, &stopped) || stopped.skipped != 2 {
+		t.Fatalf("process returned %v, want a budget error skipping 2 lines", err)
+	}
+	if out.String() != "com.good.a\n" {
+		t.Errorf("output = %q", out.String())
+	}
+	if want := "com.bad.a\ncom.bad.b\ncom.good.b\ncom.good.c\n"; failed.String() != want {
+		t.Errorf("failed-out = %q, want %q", failed.String(), want)
+	}
+}
+
+func TestProcessMaxRequests(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	var looked []string
+	resolveFunc = func(ctx context.Context, id string) (record, error) {
+		storeRequests.Add(2) // a lookup and a details page
+		looked = append(looked, id)
+		return record{Bundle: id}, nil
+	}
+
+	var out strings.Builder
+	input := strings.NewReader("com.example.a\ncom.example.b\ncom.example.c\ncom.example.d\n")
+	opts := options{Fields: []Field{FieldBundle}, Concurrency: 1, MaxRequests: 3}
+	err := process(input, &out, opts)
+	if err == nil || err.Error() != "stopped by --max-requests 3: 2 lines not looked up" {
+		t.Fatalf("process returned %v", err)
+	}
+	if strings.Join(looked, ",") != "com.example.a,com.example.b" {
+		t.Errorf("looked up %v", looked)
+	}
+}
+
+func TestCountingTransport(t *testing.T) {
+	client := &http.Client{Transport: &countingTransport{base: fakeTransport{}}}
+	before := storeRequests.Load()
+	resp, err := client.Get("https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := storeRequests.Load() - before; got != 1 {
+		t.Errorf("counted %d requests, want 1", got)
+	}
+}
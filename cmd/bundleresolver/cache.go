@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// cacheExt is the extension of the cached responses in --cache-dir.
+const cacheExt = ".http"
+
+// cacheTempPrefix starts the names of entries being written; those left
+// by an interrupted run are removed by compaction once older than
+// cacheTempMaxAge.
+const (
+	cacheTempPrefix = ".entry."
+	cacheTempMaxAge = time.Hour
+)
+
+// cacheValidatedHeader records in each entry when it was last known to be
+// current, for --cache-ttl. An entry's modification time is when it was last
+// used, for --cache-max-size.
+const cacheValidatedHeader = "X-Bundleresolver-Validated"
+
+// cacheSealedMagic starts the entries encrypted with
+// --cache-encryption-key: it is followed by the nonce and the AES-GCM
+// sealed response.
+const cacheSealedMagic = "BRC1"
+
+// cacheLowWater is the share of --cache-max-size eviction shrinks the cache
+// to, so that it does not run again on the next few stores.
+const cacheLowWater = 0.9
+
+// cacheTransport keeps the successful GET responses of base in dir, as the
+// raw HTTP responses the stores sent, and revalidates them with
+// If-None-Match and If-Modified-Since: a 304 answer is served from the
+// cache, saving the body's bandwidth. Entries stored or revalidated within
+// ttl are served without asking the store at all, and entries not known to
+// be current for maxAge are downloaded again in full. With a maxSize, the least
+// recently used entries are evicted to keep the cache below it. With an
+// aead, entries are encrypted at rest.
+type cacheTransport struct {
+	base    http.RoundTripper
+	dir     string
+	ttl     time.Duration
+	maxAge  time.Duration
+	maxSize int64
+	aead    cipher.AEAD
+
+	mu   sync.Mutex
+	size int64 // of the entries in dir, kept while maxSize is set
+}
+
+// newCacheTransport opens the cache in dir, creating it if needed, and
+// compacts it. A non-nil key, from loadCacheKey, encrypts the entries.
+func newCacheTransport(base http.RoundTripper, dir string, ttl, maxAge time.Duration, maxSize int64, key []byte) (*cacheTransport, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	t := &cacheTransport{base: base, dir: dir, ttl: ttl, maxAge: maxAge, maxSize: maxSize}
+	if key != nil {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if t.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := t.compact(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// cachePath returns the file holding the cached response of url.
+func (t *cacheTransport) cachePath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:])+cacheExt)
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if cacheOnly(req.Context()) {
+		return t.cached(req)
+	}
+	// Authenticated responses, such as the Play Developer API's, are the
+	// caller's own and are not stored.
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	path := t.cachePath(req.URL.String())
+	cached, body, validated := t.load(path, req)
+	if cached != nil && t.stale(validated) {
+		cached = nil
+	}
+	if cached != nil && time.Since(validated) < t.ttl {
+		t.touch(path)
+		cacheHits.Add(1)
+		noteCachedResponse(req.Context(), validated)
+		cached.Body = io.NopCloser(bytes.NewReader(body))
+		return cached, nil
+	}
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		drainAndClose(resp.Body)
+		cacheRevalidations.Add(1)
+		if err := t.store(path, cached, body); err != nil {
+			logger.Debug("caching response failed", "url", req.URL.String(), "err", err)
+		}
+		cached.Body = io.NopCloser(bytes.NewReader(body))
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.store(path, resp, data); err != nil {
+		logger.Debug("caching response failed", "url", req.URL.String(), "err", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// cacheHits counts the responses --cache-dir served without a request,
+// and cacheRevalidations those a 304 Not Modified confirmed.
+var cacheHits, cacheRevalidations atomic.Int64
+
+// errNotCached answers the requests of the cache source that --cache-dir
+// has no entry for.
+var errNotCached = errors.New("not in --cache-dir")
+
+// stale reports whether an entry last known to be current at validated is
+// older than --max-age.
+func (t *cacheTransport) stale(validated time.Time) bool {
+	return t.maxAge > 0 && time.Since(validated) >= t.maxAge
+}
+
+// cached serves req from the cache alone, however old its entry within
+// --max-age.
+func (t *cacheTransport) cached(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Authorization") != "" {
+		return nil, errNotCached
+	}
+	path := t.cachePath(req.URL.String())
+	cached, body, validated := t.load(path, req)
+	if cached == nil || t.stale(validated) {
+		return nil, errNotCached
+	}
+	t.touch(path)
+	cacheHits.Add(1)
+	noteCachedResponse(req.Context(), validated)
+	cached.Body = io.NopCloser(bytes.NewReader(body))
+	return cached, nil
+}
+
+// load returns the cached response of req, its body and when it was last
+// known to be current, or a nil response if there is none or it cannot be
+// read. Entries without cacheValidatedHeader count from their modification
+// time.
+func (t *cacheTransport) load(path string, req *http.Request) (*http.Response, []byte, time.Time) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, time.Time{}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, time.Time{}
+	}
+	if t.aead != nil {
+		if data, err = t.open(path, data); err != nil {
+			// Written in plain text or with another key: downloaded again
+			// and overwritten.
+			logger.Debug("ignoring unreadable cache entry", "path", path, "err", err)
+			return nil, nil, time.Time{}
+		}
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		logger.Debug("ignoring unreadable cache entry", "path", path, "err", err)
+		return nil, nil, time.Time{}
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		logger.Debug("ignoring unreadable cache entry", "path", path, "err", err)
+		return nil, nil, time.Time{}
+	}
+	validated, err := http.ParseTime(resp.Header.Get(cacheValidatedHeader))
+	if err != nil {
+		validated = info.ModTime()
+	}
+	resp.Header.Del(cacheValidatedHeader)
+	return resp, body, validated
+}
+
+// seal encrypts the entry data to be stored at path. The file name, the
+// hash of the URL, is authenticated with it so entries cannot be swapped.
+func (t *cacheTransport) seal(path string, data []byte) ([]byte, error) {
+	nonce := make([]byte, t.aead.NonceSize(), len(cacheSealedMagic)+t.aead.NonceSize()+len(data)+t.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append([]byte(cacheSealedMagic), nonce...)
+	return t.aead.Seal(sealed, nonce, data, []byte(filepath.Base(path))), nil
+}
+
+// open decrypts the entry data read from path.
+func (t *cacheTransport) open(path string, data []byte) ([]byte, error) {
+	sealed, ok := bytes.CutPrefix(data, []byte(cacheSealedMagic))
+	if !ok || len(sealed) < t.aead.NonceSize() {
+		return nil, errors.New("entry is not encrypted")
+	}
+	nonce, sealed := sealed[:t.aead.NonceSize()], sealed[t.aead.NonceSize():]
+	return t.aead.Open(nil, nonce, sealed, []byte(filepath.Base(path)))
+}
+
+// touch marks the entry at path as just used.
+func (t *cacheTransport) touch(path string) {
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
+
+// store writes resp with body to path, validated now, replacing any
+// previous entry at once so concurrent lookups never read half an entry.
+func (t *cacheTransport) store(path string, resp *http.Response, body []byte) error {
+	// The body is stored as read: uncompressed, with its actual length.
+	header := resp.Header.Clone()
+	header.Del("Content-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Set(cacheValidatedHeader, time.Now().UTC().Format(http.TimeFormat))
+	entry := &http.Response{
+		Status:        resp.Status,
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		ContentLength: int64(len(body)),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+	}
+	var buf bytes.Buffer
+	if err := entry.Write(&buf); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if t.aead != nil {
+		var err error
+		if data, err = t.seal(path, data); err != nil {
+			return err
+		}
+	}
+	f, err := os.CreateTemp(t.dir, cacheTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if t.maxSize <= 0 {
+		return os.Rename(f.Name(), path)
+	}
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var previous int64
+	if old, err := os.Stat(path); err == nil {
+		previous = old.Size()
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	t.size += info.Size() - previous
+	if t.size > t.maxSize {
+		return t.evictLocked()
+	}
+	return nil
+}
+
+// cacheEntry is a file of the cache directory.
+type cacheEntry struct {
+	path string
+	size int64
+	used time.Time
+}
+
+// compact removes the entries interrupted runs left half written and, with
+// a maxSize, evicts entries until the cache fits. It returns the number of
+// files removed; t.size is then the size of the cache.
+func (t *cacheTransport) compact() (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	files, err := os.ReadDir(t.dir)
+	if err != nil {
+		return 0, err
+	}
+	var removed int
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name(), cacheTempPrefix) {
+			continue
+		}
+		if info, err := f.Info(); err == nil && time.Since(info.ModTime()) > cacheTempMaxAge {
+			if os.Remove(filepath.Join(t.dir, f.Name())) == nil {
+				removed++
+			}
+		}
+	}
+	limit := t.maxSize
+	if limit <= 0 {
+		limit = math.MaxInt64
+	}
+	evicted, err := t.evictTo(limit)
+	return removed + evicted, err
+}
+
+// evictLocked evicts the least recently used entries until the cache is
+// below cacheLowWater of maxSize. t.mu must be held.
+func (t *cacheTransport) evictLocked() error {
+	_, err := t.evictTo(int64(float64(t.maxSize) * cacheLowWater))
+	return err
+}
+
+// evictTo removes the least recently used entries until those left take at
+// most limit bytes, and recounts t.size. t.mu must be held.
+func (t *cacheTransport) evictTo(limit int64) (int, error) {
+	entries, err := t.entries()
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, e := range entries {
+		size += e.size
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].used.Before(entries[j].used) })
+	var evicted int
+	for _, e := range entries {
+		if size <= limit {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return evicted, err
+		}
+		size -= e.size
+		evicted++
+	}
+	t.size = size
+	if evicted > 0 {
+		logger.Debug("evicted cache entries", "dir", t.dir, "entries", evicted, "bytes", size)
+	}
+	return evicted, nil
+}
+
+// entries lists the cached responses in t.dir.
+func (t *cacheTransport) entries() ([]cacheEntry, error) {
+	files, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []cacheEntry
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), cacheExt) || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		entries = append(entries, cacheEntry{path: filepath.Join(t.dir, f.Name()), size: info.Size(), used: info.ModTime()})
+	}
+	return entries, nil
+}
+
+// loadCacheKey returns the AES-256 key of --cache-encryption-key or, read
+// from a file, --cache-encryption-key-file: 64 hex digits, as printed by
+// "openssl rand -hex 32". It returns nil if neither is set.
+func loadCacheKey(key, keyFile string) ([]byte, error) {
+	flagName := "--cache-encryption-key"
+	switch {
+	case key != "" && keyFile != "":
+		return nil, errors.New("--cache-encryption-key and --cache-encryption-key-file are mutually exclusive")
+	case keyFile != "":
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --cache-encryption-key-file: %w", err)
+		}
+		key, flagName = strings.TrimSpace(string(data)), "--cache-encryption-key-file"
+	case key == "":
+		return nil, nil
+	}
+	b, err := hex.DecodeString(key)
+	if err != nil || len(b) != 32 {
+		return nil, fmt.Errorf("invalid %s: want 64 hex digits (a 256-bit key)", flagName)
+	}
+	return b, nil
+}
+
+// parseSize parses a --cache-max-size such as 2GB, 500M or 1048576; the
+// units are powers of 1024. "" is 0, no limit.
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	num := strings.TrimSpace(strings.ToUpper(s))
+	units := []struct {
+		suffix string
+		shift  uint
+	}{{"TB", 40}, {"GB", 30}, {"MB", 20}, {"KB", 10}, {"T", 40}, {"G", 30}, {"M", 20}, {"K", 10}, {"B", 0}}
+	var shift uint
+	for _, u := range units {
+		if rest, ok := strings.CutSuffix(num, u.suffix); ok {
+			num, shift = strings.TrimSpace(rest), u.shift
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("%q is not a size such as 500MB or 2GB", s)
+	}
+	return int64(v * float64(int64(1)<<shift)), nil
+}
+
+// warmCache resolves every id of r, one at a time and paced by pace, so
+// their store responses land in the cache of httpClient. It reports how
+// many it cached to w.
+func warmCache(ctx context.Context, r io.Reader, w io.Writer, pace *storeLimiter) error {
+	var warmed, failed int
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		id := strings.TrimSpace(s.Text())
+		if id == "" {
+			continue
+		}
+		if _, err := pace.acquire(ctx); err != nil {
+			return err
+		}
+		if _, err := resolveOne(ctx, id, ""); err != nil {
+			failed++
+			continue
+		}
+		warmed++
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "cached %d ids, %d failed\n", warmed, failed)
+	return err
+}
+
+// compactCache runs the compaction pass over the cache in dir, evicting
+// down to maxSize if set, and reports it to w.
+func compactCache(dir string, maxSize int64, w io.Writer) error {
+	t := &cacheTransport{dir: dir, maxSize: maxSize}
+	removed, err := t.compact()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "removed %d files, %d bytes left\n", removed, t.size)
+	return err
+}
+
+func setupCache(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var inputPath string
+	var fieldsCSV string
+	var rate float64
+	fs.StringVar(&inputPath, "input", "", "Read ids from this file instead of STDIN")
+	fs.StringVar(&fieldsCSV, "fields", defaultFields, "Fetch the pages these fields need, as resolve would")
+	fs.StringVar(&fieldsCSV, "f", defaultFields, "Alias of --fields")
+	fs.Float64Var(&rate, "rate", 1, "Look up at most this many ids per second")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) != 1 || (args[0] != "warm" && args[0] != "compact") {
+			return errors.New("cache requires a subcommand: warm or compact")
+		}
+		if common.cacheDir == "" {
+			return fmt.Errorf("cache %s requires --cache-dir", args[0])
+		}
+		if args[0] == "compact" {
+			limit, err := parseSize(common.cacheMaxSize)
+			if err != nil {
+				return fmt.Errorf("invalid --cache-max-size: %w", err)
+			}
+			return compactCache(common.cacheDir, limit, os.Stdout)
+		}
+		if rate <= 0 {
+			return fmt.Errorf("invalid --rate %g (must be positive)", rate)
+		}
+		fields, err := parseFields(fieldsCSV)
+		if err != nil {
+			return fmt.Errorf("invalid --fields: %w", err)
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		selectFields(fields)
+
+		in := io.Reader(os.Stdin)
+		if inputPath != "" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return warmCache(ctx, in, os.Stdout, newStoreLimiter(0, rate, common.rateJitter))
+	}
+}
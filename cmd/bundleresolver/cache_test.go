@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheTransport(t *testing.T) {
+	body := "page v1"
+	var full, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cache, err := newCacheTransport(http.DefaultTransport, dir, 0, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: cache}
+	get := func() string {
+		t.Helper()
+		resp, err := client.Get(srv.URL + "/details?id=com.example.app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d", resp.StatusCode)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	if got := get(); got != "page v1" || full != 1 {
+		t.Fatalf("first get = %q after %d downloads", got, full)
+	}
+	if got := get(); got != "page v1" || full != 1 || notModified != 1 {
+		t.Errorf("revalidated get = %q after %d downloads and %d 304s", got, full, notModified)
+	}
+	body = "page v2"
+	if got := get(); got != "page v2" || full != 2 {
+		t.Errorf("changed page = %q after %d downloads", got, full)
+	}
+	entries, _ := filepath.Glob(filepath.Join(dir, "*"+cacheExt))
+	if len(entries) != 1 {
+		t.Errorf("cache holds %d entries, want 1", len(entries))
+	}
+}
+
+func TestCacheTransportSkipsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	dir := t.TempDir()
+	cache, err := newCacheTransport(http.DefaultTransport, dir, 0, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: cache}).Get(srv.URL + "/gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if entries, _ := os.ReadDir(dir); resp.StatusCode != http.StatusNotFound || len(entries) != 0 {
+		t.Errorf("status %d left %d cache entries, want a 404 and none", resp.StatusCode, len(entries))
+	}
+}
+
+func TestCacheTransportTTL(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		io.WriteString(w, "page")
+	}))
+	defer srv.Close()
+	cache, err := newCacheTransport(http.DefaultTransport, t.TempDir(), time.Hour, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: cache}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL + "/lookup?id=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != "page" {
+			t.Fatalf("get %d = %q", i, b)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("server hit %d times, want once within the TTL", hits)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	var hits, conditional int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") != "" {
+			conditional++
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "page")
+	}))
+	defer srv.Close()
+	get := func(ctx context.Context, client *http.Client) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/lookup?id=1", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainAndClose(resp.Body)
+	}
+
+	// Within the TTL, served entries date the lookup back to when they
+	// were stored.
+	dir := t.TempDir()
+	cache, err := newCacheTransport(http.DefaultTransport, dir, time.Hour, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(context.Background(), &http.Client{Transport: cache})
+	ctx, fresh := withFreshness(context.Background())
+	get(ctx, &http.Client{Transport: cache})
+	later := time.Now().Add(time.Hour)
+	if at, _ := time.Parse(time.RFC3339, fresh.resolvedAt(later)); !at.Before(later.Add(-time.Minute)) {
+		t.Errorf("resolved_at = %v, want the time the entry was stored", at)
+	}
+
+	// Past --max-age, they are downloaded again in full despite the TTL.
+	hits = 0
+	cache, err = newCacheTransport(http.DefaultTransport, dir, time.Hour, time.Nanosecond, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(context.Background(), &http.Client{Transport: cache})
+	if hits != 1 || conditional != 0 {
+		t.Errorf("server hit %d times (%d conditional), want one full download", hits, conditional)
+	}
+	if _, err := (&http.Client{Transport: cache}).Get(srv.URL + "/lookup?id=1"); err != nil {
+		t.Fatal(err)
+	}
+	offline, _ := http.NewRequestWithContext(withCacheOnly(context.Background()), http.MethodGet, srv.URL+"/lookup?id=1", nil)
+	if _, err := cache.RoundTrip(offline); !errors.Is(err, errNotCached) {
+		t.Errorf("cache source err = %v, want a stale entry refused", err)
+	}
+}
+
+func TestWarmCache(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	var looked []string
+	resolveFunc = func(ctx context.Context, id string) (record, error) {
+		looked = append(looked, id)
+		if id == "com.gone.app" {
+			return record{}, errors.New("not found")
+		}
+		return record{Bundle: id}, nil
+	}
+	var out strings.Builder
+	if err := warmCache(context.Background(), strings.NewReader("com.example.a\n\ncom.gone.app\ncom.example.b\n"), &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(looked) != 3 || out.String() != "cached 2 ids, 1 failed\n" {
+		t.Errorf("looked up %v, reported %q", looked, out.String())
+	}
+}
+
+func TestCacheTransportMaxSize(t *testing.T) {
+	page := strings.Repeat("x", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, page)
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	cache, err := newCacheTransport(http.DefaultTransport, dir, time.Hour, 0, 3000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: cache}
+	get := func(id string) {
+		t.Helper()
+		resp, err := client.Get(srv.URL + "/lookup?id=" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	age := func(id string, d time.Duration) {
+		t.Helper()
+		at := time.Now().Add(-d)
+		if err := os.Chtimes(cache.cachePath(srv.URL+"/lookup?id="+id), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get("a")
+	get("b")
+	age("a", 2*time.Minute)
+	age("b", time.Minute)
+	get("a") // served from the cache, and now the most recently used
+	get("c")
+	for id, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, err := os.Stat(cache.cachePath(srv.URL + "/lookup?id=" + id)); (err == nil) != want {
+			t.Errorf("entry %s kept = %v, want %v", id, err == nil, want)
+		}
+	}
+	if cache.size <= 0 || cache.size > 3000 {
+		t.Errorf("size = %d, want at most 3000", cache.size)
+	}
+}
+
+func TestCompactCache(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, size int, age time.Duration) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		at := time.Now().Add(-age)
+		os.Chtimes(path, at, at)
+	}
+	write("old"+cacheExt, 600, 3*time.Hour)
+	write("new"+cacheExt, 600, time.Minute)
+	write(cacheTempPrefix+"123", 100, 2*time.Hour)
+	write(cacheTempPrefix+"456", 100, 0) // still being written
+
+	var out strings.Builder
+	if err := compactCache(dir, 1000, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "removed 2 files, 600 bytes left\n" {
+		t.Errorf("reported %q", out.String())
+	}
+	var left []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if strings.Join(left, " ") != cacheTempPrefix+"456 new"+cacheExt {
+		t.Errorf("left %v", left)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{"": 0, "1048576": 1 << 20, "2GB": 2 << 30, "500M": 500 << 20, "1.5 kb": 1536, "10B": 10} {
+		if got, err := parseSize(s); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"big", "-1GB", "GB"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("parseSize(%q) succeeded", s)
+		}
+	}
+}
+
+func TestCacheTransportEncryption(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		io.WriteString(w, "secret page")
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	get := func(key []byte) string {
+		t.Helper()
+		cache, err := newCacheTransport(http.DefaultTransport, dir, time.Hour, 0, 0, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&http.Client{Transport: cache}).Get(srv.URL + "/lookup?id=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+	key := make([]byte, 32)
+	other := bytes.Repeat([]byte{1}, 32)
+
+	if got := get(key); got != "secret page" || hits != 1 {
+		t.Fatalf("first get = %q after %d hits", got, hits)
+	}
+	entries, _ := filepath.Glob(filepath.Join(dir, "*"+cacheExt))
+	if len(entries) != 1 {
+		t.Fatalf("cache holds %d entries", len(entries))
+	}
+	data, _ := os.ReadFile(entries[0])
+	if !bytes.HasPrefix(data, []byte(cacheSealedMagic)) || bytes.Contains(data, []byte("secret page")) || bytes.Contains(data, []byte("HTTP/1.1")) {
+		t.Errorf("entry is not encrypted: %q", data)
+	}
+	if got := get(key); got != "secret page" || hits != 1 {
+		t.Errorf("cached get = %q after %d hits, want it decrypted from the cache", got, hits)
+	}
+	if got := get(other); got != "secret page" || hits != 2 {
+		t.Errorf("get with another key = %q after %d hits, want a fresh download", got, hits)
+	}
+	if got := get(nil); got != "secret page" || hits != 3 {
+		t.Errorf("get without a key = %q after %d hits, want a fresh download", got, hits)
+	}
+}
+
+func TestLoadCacheKey(t *testing.T) {
+	hexKey := strings.Repeat("0f", 32)
+	file := filepath.Join(t.TempDir(), "cache.key")
+	if err := os.WriteFile(file, []byte(hexKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ key, file string }{{hexKey, ""}, {"", file}} {
+		key, err := loadCacheKey(tc.key, tc.file)
+		if err != nil || len(key) != 32 || key[0] != 0x0f {
+			t.Errorf("loadCacheKey(%q, %q) = %x, %v", tc.key, tc.file, key, err)
+		}
+	}
+	if key, err := loadCacheKey("", ""); key != nil || err != nil {
+		t.Errorf("no key = %x, %v; want nil", key, err)
+	}
+	for _, tc := range []struct{ key, file string }{{"abcd", ""}, {"zz" + hexKey[2:], ""}, {hexKey, file}, {"", file + ".missing"}} {
+		if _, err := loadCacheKey(tc.key, tc.file); err == nil {
+			t.Errorf("loadCacheKey(%q, %q) succeeded", tc.key, tc.file)
+		}
+	}
+}
+
+func TestMaxAgeFlag(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--max-age", "7d"}, "--max-age requires --cache-dir"},
+		{[]string{"--max-age", "soon"}, "invalid --max-age"},
+		{[]string{"--max-age", "soon", "--cache-dir", t.TempDir()}, "invalid --max-age"},
+	} {
+		var c commonFlags
+		fs := flag.NewFlagSet("resolve", flag.ContinueOnError)
+		c.register(fs)
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.setup(io.Discard); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: err = %v, want %q", tc.args, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// chartKinds are the charts the charts command can list.
+var chartKinds = []string{"free", "paid", "grossing"}
+
+// maxIOSChartLimit is the most entries Apple's chart feeds return.
+const maxIOSChartLimit = 200
+
+func setupCharts(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var fieldsCSV string
+	var showHeader bool
+	var outputCSV bool
+	var sanitizeFlag string
+	var normalize bool
+	var platform string
+	var chart string
+	var country string
+	var category string
+	var limit int
+
+	fs.StringVar(&fieldsCSV, "fields", defaultFields, fieldsUsage())
+	fs.StringVar(&fieldsCSV, "f", defaultFields, "Alias of --fields")
+	fs.BoolVar(&showHeader, "header", true, "Print header row as first line (use --header=false to disable)")
+	fs.BoolVar(&outputCSV, "csv", false, "Emit output as CSV (RFC4180 with quoting) instead of TSV")
+	fs.StringVar(&sanitizeFlag, "sanitize", "strip", "How tabs and newlines inside values are written: strip (replace with a space), quote (keep, RFC4180-quoted) or escape (as \\t, \\n)")
+	fs.BoolVar(&normalize, "normalize-unicode", false, "NFC-normalize values and strip invisible filler characters (bidi and zero-width controls are always stripped)")
+	fs.StringVar(&platform, "platform", platformIOS, "Store whose chart to list: ios or android")
+	fs.StringVar(&chart, "chart", "free", "Chart to list: free, paid or grossing (Android: free only)")
+	fs.StringVar(&country, "country", "us", "Two-letter country code of the storefront")
+	fs.StringVar(&category, "category", "", "Limit the chart to a category: an App Store genre id (e.g. 6014 for Games) or a Play category (e.g. GAME_PUZZLE)")
+	fs.IntVar(&limit, "limit", 100, "Maximum number of apps to list")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q", args)
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		fields, err := parseFields(fieldsCSV)
+		if err != nil {
+			return fmt.Errorf("invalid --fields: %w", err)
+		}
+		selectFields(fields)
+		mode, err := parseSanitizeMode(sanitizeFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --sanitize: %w", err)
+		}
+		if !containsString(chartKinds, chart) {
+			return fmt.Errorf("invalid --chart %q (want free, paid or grossing)", chart)
+		}
+		if len(country) != 2 {
+			return fmt.Errorf("invalid --country %q (want a two-letter code such as us)", country)
+		}
+		if limit < 1 {
+			return fmt.Errorf("invalid --limit %d (must be at least 1)", limit)
+		}
+		if platform != platformIOS && platform != platformAndroid {
+			return fmt.Errorf("invalid --platform %q (want ios or android)", platform)
+		}
+
+		recs, err := fetchChart(ctx, platform, chart, strings.ToLower(country), category, limit)
+		if err != nil {
+			return err
+		}
+		out := newRowWriter(os.Stdout, fields, outputCSV, mode)
+		out.normalize = normalize
+		if showHeader {
+			if err := out.writeHeader(); err != nil {
+				return err
+			}
+		}
+		for _, rec := range recs {
+			if err := out.writeRecord(rec); err != nil {
+				return err
+			}
+		}
+		return out.flush()
+	}
+}
+
+// fetchChart returns the apps of a top chart in chart order, each with its
+// position (from 1) as Input.
+func fetchChart(ctx context.Context, platform, chart, country, category string, limit int) ([]record, error) {
+	b := storeBreakers[platform]
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	var recs []record
+	var err error
+	if platform == platformIOS {
+		recs, err = fetchIOSChart(ctx, chart, country, category, limit)
+	} else {
+		recs, err = fetchAndroidChart(ctx, chart, country, category, limit)
+	}
+	b.done(err)
+	for i := range recs {
+		recs[i].Input = strconv.Itoa(i + 1)
+	}
+	return recs, err
+}
+
+// iosChartURL returns the iTunes RSS feed of a chart.
+func iosChartURL(chart, country, genre string, limit int) string {
+	u := fmt.Sprintf("https://itunes.apple.com/%s/rss/top%sapplications/limit=%d", country, chart, min(limit, maxIOSChartLimit))
+	if genre != "" {
+		u += "/genre=" + url.PathEscape(genre)
+	}
+	return u + "/json"
+}
+
+func fetchIOSChart(ctx context.Context, chart, country, genre string, limit int) ([]record, error) {
+	if genre != "" {
+		if _, err := strconv.Atoi(genre); err != nil {
+			return nil, fmt.Errorf("invalid --category %q (App Store categories are numeric genre ids, e.g. 6014)", genre)
+		}
+	}
+	resp, err := httpGet(ctx, iosChartURL(chart, country, genre, limit))
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	type label struct {
+		Label string `json:"label"`
+	}
+	var payload struct {
+		Feed struct {
+			// A feed with a single entry holds an object instead of a list.
+			Entry json.RawMessage `json:"entry"`
+		} `json:"feed"`
+	}
+	if err := decodeJSONResponse(resp, &payload); err != nil {
+		return nil, err
+	}
+	type entry struct {
+		Name   label `json:"im:name"`
+		Artist label `json:"im:artist"`
+		Price  label `json:"im:price"`
+		ID     struct {
+			Attributes struct {
+				ID       string `json:"im:id"`
+				BundleID string `json:"im:bundleId"`
+			} `json:"attributes"`
+		} `json:"id"`
+	}
+	var entries []entry
+	if raw := payload.Feed.Entry; len(raw) > 0 {
+		if raw[0] == '{' {
+			raw = append(append([]byte("["), raw...), ']')
+		}
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, err
+		}
+	}
+	recs := make([]record, 0, len(entries))
+	for _, e := range entries {
+		id := e.ID.Attributes.ID
+		rec := record{
+			Bundle:    id,
+			Name:      e.Name.Label,
+			Publisher: e.Artist.Label,
+			URL:       buildAppStoreURL(id),
+			Price:     e.Price.Label,
+			BundleID:  e.ID.Attributes.BundleID,
+		}
+		if rec.Price == "Get" {
+			rec.Price = "Free"
+		}
+		addAppStorePageFields(ctx, &rec)
+		recs = append(recs, rec)
+	}
+	if len(recs) > limit {
+		recs = recs[:limit]
+	}
+	return recs, nil
+}
+
+// androidChartURL returns the Play top charts page, optionally of one
+// category.
+func androidChartURL(country, category string) string {
+	path := "/store/apps/top"
+	if category != "" {
+		path += "/category/" + url.PathEscape(category)
+	}
+	return "https://play.google.com" + path + "?hl=en&gl=" + strings.ToUpper(country)
+}
+
+// fetchAndroidChart lists the Play top free chart. The paid and grossing
+// tabs are filled in by the Play web client and cannot be fetched.
+func fetchAndroidChart(ctx context.Context, chart, country, category string, limit int) ([]record, error) {
+	if chart != "free" {
+		return nil, fmt.Errorf("the %s chart is not available for Android (only free)", chart)
+	}
+	resp, err := httpGet(ctx, androidChartURL(country, strings.ToUpper(category)))
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	doc, err := parseHTMLResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := detailsPackages(doc)
+	if len(pkgs) == 0 {
+		return nil, errors.New("no apps found on the top charts page")
+	}
+	if len(pkgs) > limit {
+		pkgs = pkgs[:limit]
+	}
+	recs := make([]record, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		// The chart markup carries no reliable publisher; fetch each details page.
+		rec, err := fetchAndroidDirect(ctx, pkg)
+		if err != nil {
+			logger.Warn("resolve failed", "id", pkg, "err", err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const sampleIOSChart = `{"feed":{"entry":[
+{"im:name":{"label":"First"},"im:artist":{"label":"Acme"},"im:price":{"label":"Get"},"id":{"label":"https://apps.apple.com/us/app/first/id111","attributes":{"im:id":"111","im:bundleId":"com.acme.first"}}},
+{"im:name":{"label":"Second"},"im:artist":{"label":"Globex"},"im:price":{"label":"$0.99"},"id":{"attributes":{"im:id":"222","im:bundleId":"com.globex.second"}}}
+]}}`
+
+func TestFetchIOSChart(t *testing.T) {
+	originalClient, originalFields := httpClient, selectedFields
+	defer func() {
+		httpClient, selectedFields = originalClient, originalFields
+	}()
+	selectFields(nil)
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://itunes.apple.com/jp/rss/topgrossingapplications/limit=2/genre=6014/json": sampleIOSChart,
+		"https://itunes.apple.com/us/rss/topfreeapplications/limit=1/json":                `{"feed":{"entry":{"im:name":{"label":"Only"},"id":{"attributes":{"im:id":"333"}}}}}`,
+	}}
+
+	recs, err := fetchChart(context.Background(), platformIOS, "grossing", "jp", "6014", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []record{
+		{Bundle: "111", Name: "First", Publisher: "Acme", URL: "https://apps.apple.com/app/id111", Price: "Free", BundleID: "com.acme.first", Input: "1"},
+		{Bundle: "222", Name: "Second", Publisher: "Globex", URL: "https://apps.apple.com/app/id222", Price: "$0.99", BundleID: "com.globex.second", Input: "2"},
+	}
+	if !reflect.DeepEqual(recs, want) {
+		t.Errorf("chart = %+v, want %+v", recs, want)
+	}
+
+	recs, err = fetchChart(context.Background(), platformIOS, "free", "us", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].Bundle != "333" {
+		t.Errorf("single-entry chart = %+v", recs)
+	}
+
+	if _, err := fetchChart(context.Background(), platformIOS, "free", "us", "Games", 10); err == nil {
+		t.Error("non-numeric genre accepted")
+	}
+}
+
+func TestAndroidChartURL(t *testing.T) {
+	if got, want := androidChartURL("jp", "GAME_PUZZLE"), "https://play.google.com/store/apps/top/category/GAME_PUZZLE?hl=en&gl=JP"; got != want {
+		t.Errorf("url = %q, want %q", got, want)
+	}
+	if _, err := fetchAndroidChart(context.Background(), "paid", "us", "", 10); err == nil {
+		t.Error("paid Android chart accepted")
+	}
+}
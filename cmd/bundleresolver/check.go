@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func setupCheck(fs *flag.FlagSet) func(context.Context, []string) error {
+	var inputPath string
+	fs.StringVar(&inputPath, "input", "", "Read ids from this file instead of STDIN")
+	var lenient bool
+	fs.BoolVar(&lenient, "lenient", false, "Accept Android package names whose segments start with a digit or underscore")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q (ids are read from STDIN or --input)", args)
+		}
+		lenientIDs = lenient
+		in := io.Reader(os.Stdin)
+		if inputPath != "" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		invalid, err := check(in, os.Stdout)
+		if err != nil {
+			return err
+		}
+		if invalid > 0 {
+			return fmt.Errorf("%d invalid ids", invalid)
+		}
+		return nil
+	}
+}
+
+// check writes "<id>\t<platform>" for every non-blank input line, where
+// platform is "unknown" for ids no store would accept, and returns how many
+// such ids it saw.
+func check(r io.Reader, w io.Writer) (invalid int, err error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		id := strings.TrimSpace(s.Text())
+		if id == "" {
+			continue
+		}
+		platform := platformOf(id)
+		if platform == platformUnknown {
+			invalid++
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", sanitize(id), platform); err != nil {
+			return invalid, err
+		}
+	}
+	return invalid, s.Err()
+}
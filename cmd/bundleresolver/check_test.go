@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	var out strings.Builder
+	invalid, err := check(strings.NewReader("123456789\n\ncom.example.app\nnot an id\n"), &out)
+	if err != nil {
+		t.Fatalf("check returned error: %v", err)
+	}
+	if invalid != 1 {
+		t.Errorf("invalid = %d, want 1", invalid)
+	}
+	want := "123456789\tios\ncom.example.app\tandroid\nnot an id\tunknown\n"
+	if got := out.String(); got != want {
+		t.Fatalf("check output mismatch:\n got: %q\nwant: %q", got, want)
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errUsage reports invalid command-line usage; the flag package has already
+// printed the details.
+var errUsage = errors.New("invalid usage")
+
+// command is one CLI subcommand.
+type command struct {
+	name    string
+	args    string // synopsis after the options, e.g. "<query>"
+	summary string
+	// setup registers the command's flags on fs and returns the function
+	// that runs the command with the remaining arguments once fs is parsed.
+	setup func(fs *flag.FlagSet) func(ctx context.Context, args []string) error
+}
+
+// defaultCommand runs when the first argument is not a command name, so
+// `bundleresolver --fields name < ids.txt` keeps working.
+const defaultCommand = "resolve"
+
+var commands []*command
+
+func init() {
+	// Assigned in init so command setups may refer back to the list.
+	commands = []*command{
+		{name: "resolve", args: "< ids.txt", summary: "Resolve ids (one per line) into TSV/CSV records (default)", setup: setupResolve},
+		{name: "search", args: "<query>", summary: "Search the stores by keyword", setup: setupSearch},
+		{name: "reviews", args: "< ids.txt", summary: "Export recent customer reviews of each app as JSON lines", setup: setupReviews},
+		{name: "rank", args: "<keyword> < ids.txt", summary: "Report the search rank of each app for a keyword", setup: setupRank},
+		{name: "charts", summary: "List a store's top free, paid or grossing chart as records", setup: setupCharts},
+		{name: "serve", summary: "Serve lookups over HTTP", setup: setupServe},
+		{name: "check", args: "< ids.txt", summary: "Classify ids without contacting the stores", setup: setupCheck},
+		{name: "selftest", summary: "Resolve well-known apps and report which extraction paths are broken", setup: setupSelftest},
+		{name: "adstxt", args: "< ids.txt", summary: "Check the app-ads.txt of each app's developer", setup: setupAdsTxt},
+		{name: "universal-links", args: "< ids.txt", summary: "Show the universal link paths each iOS app's developer domain maps to it", setup: setupUniversalLinks},
+		{name: "app-links", args: "< ids.txt", summary: "Show the packages and signing certificates each Android app's developer domain declares", setup: setupAppLinks},
+		{name: "enrich", args: "< report.csv", summary: "Add app names and publishers to an ads or attribution report", setup: setupEnrich},
+		{name: "diff", args: "old.jsonl [new.jsonl]", summary: "Report catalog changes between two snapshots, or since a snapshot", setup: setupDiff},
+		{name: "cache", args: "warm|compact", summary: "Pre-populate (warm) or compact the --cache-dir response cache", setup: setupCache},
+		{name: "monitor", summary: "Re-resolve a watch-list on an interval and alert on changes", setup: setupMonitor},
+		{name: "version", summary: "Print version and exit", setup: setupVersion},
+		{name: "completion", args: "bash|zsh|fish", summary: "Print a shell completion script", setup: setupCompletion},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func progName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// runCLI dispatches args (without the program name) to a subcommand.
+func runCLI(ctx context.Context, args []string) error {
+	name := defaultCommand
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		return runHelp(os.Stdout, args)
+	}
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printCommands(os.Stderr)
+		return errUsage
+	}
+
+	fs := newCommandFlagSet(cmd)
+	run := cmd.setup(fs)
+	annotateEnv(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if err := loadSettings(fs); err != nil {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+	return run(ctx, fs.Args())
+}
+
+// annotateEnv appends the environment variable of every configurable flag
+// to its help text.
+func annotateEnv(fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		if unconfigurableFlags[f.Name] || flagAliases[f.Name] != "" {
+			return
+		}
+		f.Usage += " [$" + envName(f.Name) + "]"
+	})
+}
+
+func newCommandFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "%s\n\n", cmd.summary)
+		synopsis := fmt.Sprintf("%s %s [options]", progName(), cmd.name)
+		if cmd.args != "" {
+			synopsis += " " + cmd.args
+		}
+		fmt.Fprintf(out, "Usage: %s\n\nOptions:\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func runHelp(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		printCommands(w)
+		return nil
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	fs := newCommandFlagSet(cmd)
+	fs.SetOutput(w)
+	cmd.setup(fs)
+	annotateEnv(fs)
+	fs.Usage()
+	return nil
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintf(w, "Bundle Resolver\n\n")
+	fmt.Fprintf(w, "Usage: %s <command> [options]\n\nCommands:\n", progName())
+	width := 0
+	for _, c := range commands {
+		width = max(width, len(c.name))
+	}
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-*s %s\n", width, c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nWithout a command, %q is assumed. Run \"%s help <command>\" for its options.\n", defaultCommand, progName())
+}
+
+// knownFlag reports whether any command defines the flag, so a config file
+// shared by all commands may set options that only some of them use.
+func knownFlag(name string) bool {
+	for _, c := range commands {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.setup(fs)
+		if fs.Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSettings applies the config file, profile and environment to the
+// flags of a parsed command (see applySettings for precedence). Commands
+// without --config only read the environment. With --replay-run, the
+// replayed run's flags are applied instead.
+func loadSettings(fs *flag.FlagSet) error {
+	if r := fs.Lookup("replay-run"); r != nil && r.Value.String() != "" {
+		p, err := loadProvenance(r.Value.String())
+		if err != nil {
+			return err
+		}
+		return applyReplay(fs, p)
+	}
+	if fs.Lookup("config") == nil {
+		return applySettings(fs, nil, os.Getenv, knownFlag)
+	}
+	cfgPath, required := configPath(fs.Lookup("config").Value.String())
+	cfg, err := loadConfig(cfgPath, required)
+	if err != nil {
+		return err
+	}
+	profile := fs.Lookup("profile").Value.String()
+	if profile == "" {
+		profile = os.Getenv(envName("profile"))
+	}
+	settings, err := cfg.settings(profile)
+	if err != nil {
+		return err
+	}
+	return applySettings(fs, settings, os.Getenv, knownFlag)
+}
+
+// commonFlags are shared by every command that talks to the stores.
+type commonFlags struct {
+	configFile   string
+	profile      string
+	logLevel     string
+	logFormat    string
+	logFile      string
+	quiet        bool
+	debugHTTP    string
+	cacheDir     string
+	cacheTTL     time.Duration
+	maxAgeFlag   string
+	maxAge       time.Duration
+	cacheMaxSize string
+	cacheKey     string
+	cacheKeyFile string
+	cookieJar    string
+	maxBodySize  string
+	lenient      bool
+	redirects    int
+	transport    transportOptions
+
+	breakerThreshold int
+	breakerCooldown  time.Duration
+
+	iosConcurrency, androidConcurrency int
+	iosRate, androidRate               float64
+	rateJitter                         float64
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.configFile, "config", "", "Read option defaults from this YAML file (default ~/.config/bundleresolver/config.yaml)")
+	fs.StringVar(&c.profile, "profile", "", "Apply the named profile from the config file on top of its top-level settings")
+	fs.StringVar(&c.logLevel, "log-level", "info", "Minimum level of diagnostics written to STDERR (debug, info, warn, error)")
+	fs.StringVar(&c.logFormat, "log-format", "text", "Format of diagnostics written to STDERR (text or json)")
+	fs.StringVar(&c.logFile, "log-file", "", "Append diagnostics to this file instead of STDERR")
+	fs.BoolVar(&c.quiet, "quiet", false, "Suppress non-fatal diagnostics (only errors are logged) and the progress bar")
+	fs.StringVar(&c.cacheDir, "cache-dir", "", "Keep store responses in this directory and revalidate them with ETag and Last-Modified, downloading only pages that changed")
+	fs.DurationVar(&c.cacheTTL, "cache-ttl", 0, "Serve --cache-dir responses this recent without revalidating them (0 always revalidates)")
+	fs.StringVar(&c.maxAgeFlag, "max-age", "", "Download --cache-dir responses not known to be current for this long again (e.g. 7d or 36h), however long --cache-ttl is")
+	fs.StringVar(&c.cacheMaxSize, "cache-max-size", "", "Evict the least recently used --cache-dir responses to keep the cache below this size (e.g. 2GB)")
+	fs.StringVar(&c.cacheKey, "cache-encryption-key", "", "Encrypt --cache-dir responses at rest with this AES-256 key (64 hex digits; prefer --cache-encryption-key-file or the environment)")
+	fs.StringVar(&c.cacheKeyFile, "cache-encryption-key-file", "", "Read the --cache-encryption-key from this file")
+	fs.StringVar(&c.cookieJar, "cookie-jar", "", "Keep Google Play's cookies (NID, consent) in this file and send them on every Play request, across runs")
+	fs.StringVar(&c.maxBodySize, "max-body-size", defaultMaxBodySize, "Refuse store responses larger than this (e.g. 32MB; 0 means unlimited)")
+	fs.StringVar(&c.debugHTTP, "debug-http", "", "Write raw requests and responses of failed lookups to this directory")
+	fs.BoolVar(&c.lenient, "lenient", false, "Accept Android package names whose segments start with a digit or underscore")
+	fs.StringVar(&c.transport.caCert, "ca-cert", "", "Also trust the PEM certificates in this file (e.g. a corporate proxy's CA)")
+	fs.BoolVar(&c.transport.insecureSkipVerify, "insecure-skip-verify", false, "Do not verify TLS certificates (insecure; prefer --ca-cert)")
+	fs.StringVar(&c.transport.ipVersion, "ip-version", "any", "Connect to the stores over IPv4 (4), IPv6 (6) or either (any)")
+	fs.StringVar(&c.transport.iosProxy, "ios-proxy", "", "Send App Store requests through this proxy (socks5://, http:// or https:// URL)")
+	fs.StringVar(&c.transport.androidProxy, "android-proxy", "", "Send Google Play requests through this proxy (socks5://, http:// or https:// URL)")
+	fs.StringVar(&c.transport.tor, "tor", "", "Send every request through the Tor SOCKS port at this address (e.g. 127.0.0.1:9050), on a new circuit per run")
+	fs.StringVar(&c.transport.dnsServer, "dns-server", "", "Resolve store host names with this DNS server (host[:port]) instead of the system resolver")
+	fs.DurationVar(&c.transport.connectTimeout, "connect-timeout", 10*time.Second, "Give up connecting to one address of a store host after this long and try its next one")
+	fs.DurationVar(&c.transport.dnsCacheTTL, "dns-cache-ttl", 5*time.Minute, "With --ip-version or --dns-server, cache DNS answers this long (0 disables)")
+	fs.IntVar(&c.transport.maxConnsPerHost, "max-conns-per-host", 0, "Limit open connections per store host (0 means unlimited)")
+	fs.IntVar(&c.transport.maxIdleConnsPerHost, "max-idle-conns-per-host", defaultMaxIdleConnsPerHost, "Idle connections kept per store host for reuse")
+	fs.DurationVar(&c.transport.idleConnTimeout, "idle-conn-timeout", 90*time.Second, "Close idle connections after this long")
+	fs.BoolVar(&c.transport.keepAlive, "keep-alive", true, "Reuse connections between requests (use --keep-alive=false to open one per request)")
+	fs.IntVar(&c.breakerThreshold, "breaker-threshold", 10, "Pause requests to a store after this many consecutive failures (0 disables)")
+	fs.DurationVar(&c.breakerCooldown, "breaker-cooldown", time.Minute, "How long requests to a failing store stay paused")
+	fs.IntVar(&c.iosConcurrency, "ios-concurrency", 0, "Run at most this many App Store lookups at a time (0 means only --concurrency limits them)")
+	fs.IntVar(&c.androidConcurrency, "android-concurrency", 0, "Run at most this many Google Play lookups at a time (0 means only --concurrency limits them)")
+	fs.Float64Var(&c.iosRate, "ios-rate", 0, "Start at most this many App Store lookups per second (0 means unlimited)")
+	fs.Float64Var(&c.androidRate, "android-rate", 0, "Start at most this many Google Play lookups per second (0 means unlimited)")
+	fs.Float64Var(&c.rateJitter, "rate-jitter", 0, "Randomize the gaps between lookups of --ios-rate and --android-rate by up to this fraction (0 to 1)")
+	fs.IntVar(&c.redirects, "max-redirects", defaultMaxRedirects, "Follow at most this many HTTP redirects per request (0 reports the redirect itself)")
+}
+
+// setup installs the logger, writing to out unless --log-file is set, and
+// enables HTTP debug capture. The returned function releases resources.
+func (c *commonFlags) setup(out io.Writer) (cleanup func(), err error) {
+	cleanup = func() {}
+	if c.logFile != "" {
+		f, err := os.OpenFile(c.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --log-file: %w", err)
+		}
+		cleanup = func() { f.Close() }
+		out = f
+	}
+	level := c.logLevel
+	if c.quiet {
+		level = "error"
+	}
+	l, err := newLogger(out, level, c.logFormat)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("invalid logging options: %w", err)
+	}
+	if c.redirects < 0 {
+		cleanup()
+		return nil, fmt.Errorf("invalid --max-redirects %d", c.redirects)
+	}
+	for name, v := range map[string]int{"ios-concurrency": c.iosConcurrency, "android-concurrency": c.androidConcurrency} {
+		if v < 0 {
+			cleanup()
+			return nil, fmt.Errorf("invalid --%s %d", name, v)
+		}
+	}
+	for name, v := range map[string]float64{"ios-rate": c.iosRate, "android-rate": c.androidRate} {
+		if v < 0 {
+			cleanup()
+			return nil, fmt.Errorf("invalid --%s %g", name, v)
+		}
+	}
+	if c.rateJitter < 0 || c.rateJitter > 1 {
+		cleanup()
+		return nil, fmt.Errorf("invalid --rate-jitter %g (must be between 0 and 1)", c.rateJitter)
+	}
+	if c.rateJitter > 0 && c.iosRate == 0 && c.androidRate == 0 {
+		cleanup()
+		return nil, errors.New("--rate-jitter requires --ios-rate or --android-rate")
+	}
+	if c.maxAge, err = parseAge(c.maxAgeFlag); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("invalid --max-age: %w", err)
+	}
+	if c.maxAgeFlag != "" && c.cacheDir == "" {
+		cleanup()
+		return nil, errors.New("--max-age requires --cache-dir")
+	}
+	logger = l
+	lenientIDs = c.lenient
+	httpClient.CheckRedirect = redirectPolicy(c.redirects)
+	for _, store := range []string{platformIOS, platformAndroid} {
+		storeBreakers[store] = newCircuitBreaker(store, c.breakerThreshold, c.breakerCooldown)
+	}
+	storeLimiters[platformIOS] = newStoreLimiter(c.iosConcurrency, c.iosRate, c.rateJitter)
+	storeLimiters[platformAndroid] = newStoreLimiter(c.androidConcurrency, c.androidRate, c.rateJitter)
+
+	transport, err := newTransport(c.transport)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	if c.transport.insecureSkipVerify {
+		logger.Warn("TLS certificate verification is disabled")
+	}
+	maxBody, err := parseSize(c.maxBodySize)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("invalid --max-body-size: %w", err)
+	}
+	httpClient.Transport = &guardTransport{base: &countingTransport{base: transport}, maxBody: maxBody}
+	if c.cacheDir != "" {
+		maxSize, err := parseSize(c.cacheMaxSize)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("invalid --cache-max-size: %w", err)
+		}
+		key, err := loadCacheKey(c.cacheKey, c.cacheKeyFile)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		cache, err := newCacheTransport(httpClient.Transport, c.cacheDir, c.cacheTTL, c.maxAge, maxSize, key)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("invalid --cache-dir: %w", err)
+		}
+		httpClient.Transport = cache
+	}
+	httpClient.Jar = nil
+	if c.cookieJar != "" {
+		if c.transport.tor != "" {
+			cleanup()
+			return nil, errors.New("--cookie-jar cannot be combined with --tor: the cookies would link its batches")
+		}
+		jar := newPlayCookieJar()
+		if err := jar.load(c.cookieJar); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("invalid --cookie-jar: %w", err)
+		}
+		httpClient.Jar = jar
+		closeLog := cleanup
+		cleanup = func() {
+			if err := jar.save(c.cookieJar); err != nil {
+				logger.Warn("saving the cookie jar failed", "path", c.cookieJar, "err", err)
+			}
+			closeLog()
+		}
+	}
+	if c.debugHTTP != "" {
+		if err := os.MkdirAll(c.debugHTTP, 0o700); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("invalid --debug-http: %w", err)
+		}
+		httpClient.Transport = &debugTransport{base: httpClient.Transport}
+	}
+	return cleanup, nil
+}
+
+func setupVersion(fs *flag.FlagSet) func(context.Context, []string) error {
+	return func(context.Context, []string) error {
+		fmt.Println(version)
+		return nil
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// flagValueCompletions lists the values offered when completing a flag's
+// argument. fields is completed as a comma-separated list.
+var flagValueCompletions = map[string][]string{
+	"fields":        fieldNames(),
+	"f":             fieldNames(),
+	"platform":      {"ios", "android", "all"},
+	"log-level":     {"debug", "info", "warn", "error"},
+	"log-format":    {"text", "json"},
+	"sanitize":      {"strip", "quote", "escape"},
+	"ip-version":    {"any", "4", "6"},
+	"chart":         chartKinds,
+	"output-format": outputFormats,
+	"url-style":     urlStyles,
+	"provenance":    provenanceModes,
+}
+
+// listFlags are completed one comma-separated element at a time.
+var listFlags = map[string]bool{"fields": true, "f": true}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+func fieldNames() []string {
+	names := make([]string, len(allowedFields))
+	for i, f := range allowedFields {
+		names[i] = string(f)
+	}
+	return names
+}
+
+func setupCompletion(fs *flag.FlagSet) func(context.Context, []string) error {
+	return func(_ context.Context, args []string) error {
+		if len(args) != 1 {
+			return errors.New("completion requires a shell: bash, zsh or fish")
+		}
+		switch args[0] {
+		case "bash":
+			return writeBashCompletion(os.Stdout)
+		case "zsh":
+			return writeZshCompletion(os.Stdout)
+		case "fish":
+			return writeFishCompletion(os.Stdout)
+		}
+		return fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", args[0])
+	}
+}
+
+type completionFlag struct {
+	name   string
+	usage  string
+	isBool bool
+	values []string
+}
+
+// commandFlags returns the flags of cmd sorted by name.
+func commandFlags(cmd *command) []completionFlag {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmd.setup(fs)
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			name:   f.Name,
+			usage:  f.Usage,
+			isBool: ok && b.IsBoolFlag(),
+			values: flagValueCompletions[f.Name],
+		})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands)+1)
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return append(names, "help")
+}
+
+func writeBashCompletion(w io.Writer) error {
+	var b strings.Builder
+	prog := progName()
+	fmt.Fprintf(&b, "# bash completion for %s\n", prog)
+	fmt.Fprintf(&b, "_%s() {\n", shellIdent(prog))
+	b.WriteString(`	local cur prev cmd opts
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	cmd=` + defaultCommand + `
+	if [[ ${COMP_CWORD} -gt 1 && ${COMP_WORDS[1]} != -* ]]; then
+		cmd="${COMP_WORDS[1]}"
+	fi
+	if [[ ${COMP_CWORD} -eq 1 && ${cur} != -* ]]; then
+		COMPREPLY=($(compgen -W "` + strings.Join(commandNames(), " ") + `" -- "${cur}"))
+		return
+	fi
+	if [[ ${cmd} == help || ${cmd} == completion ]] && [[ ${COMP_CWORD} -eq 2 ]]; then
+		if [[ ${cmd} == help ]]; then
+			COMPREPLY=($(compgen -W "` + strings.Join(commandNames(), " ") + `" -- "${cur}"))
+		else
+			COMPREPLY=($(compgen -W "` + strings.Join(completionShells, " ") + `" -- "${cur}"))
+		fi
+		return
+	fi
+	case "${prev}" in
+`)
+	listed := map[string]bool{}
+	for _, c := range commands {
+		for _, f := range commandFlags(c) {
+			if f.values == nil || listed[f.name] {
+				continue
+			}
+			listed[f.name] = true
+			values := strings.Join(f.values, " ")
+			if listFlags[f.name] {
+				fmt.Fprintf(&b, "\t-%[1]s|--%[1]s)\n\t\tlocal prefix=\"\"\n\t\t[[ ${cur} == *,* ]] && prefix=\"${cur%%,*},\"\n\t\tCOMPREPLY=($(compgen -P \"${prefix}\" -W \"%[2]s\" -- \"${cur##*,}\"))\n\t\treturn\n\t\t;;\n", f.name, values)
+			} else {
+				fmt.Fprintf(&b, "\t-%[1]s|--%[1]s)\n\t\tCOMPREPLY=($(compgen -W \"%[2]s\" -- \"${cur}\"))\n\t\treturn\n\t\t;;\n", f.name, values)
+			}
+		}
+	}
+	b.WriteString("\tesac\n\tcase \"${cmd}\" in\n")
+	for _, c := range commands {
+		var opts []string
+		for _, f := range commandFlags(c) {
+			opts = append(opts, flagSpelling(f.name))
+		}
+		fmt.Fprintf(&b, "\t%s) opts=\"%s\" ;;\n", c.name, strings.Join(opts, " "))
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("\tCOMPREPLY=($(compgen -W \"${opts}\" -- \"${cur}\"))\n}\n")
+	fmt.Fprintf(&b, "complete -o default -F _%s %s\n", shellIdent(prog), prog)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeZshCompletion(w io.Writer) error {
+	var b strings.Builder
+	prog := progName()
+	fmt.Fprintf(&b, "#compdef %s\n\n", prog)
+	fmt.Fprintf(&b, "_%s() {\n", shellIdent(prog))
+	b.WriteString("\tlocal -a commands\n\tcommands=(\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "\t\t%s\n", zshQuote(c.name+":"+c.summary))
+	}
+	fmt.Fprintf(&b, "\t\t%s\n\t)\n", zshQuote("help:Show help for a command"))
+	b.WriteString(`	local cmd=` + defaultCommand + `
+	if (( CURRENT == 2 )) && [[ ${words[2]} != -* ]]; then
+		_describe 'command' commands
+		return
+	fi
+	if [[ ${words[2]} != -* ]]; then
+		cmd=${words[2]}
+		words=(${words[1]} ${words[3,-1]})
+		(( CURRENT-- ))
+	fi
+	case ${cmd} in
+	help) _describe 'command' commands ;;
+	completion) _values 'shell' ` + strings.Join(completionShells, " ") + ` ;;
+`)
+	for _, c := range commands {
+		if c.name == "completion" {
+			continue // handled above
+		}
+		fmt.Fprintf(&b, "\t%s)\n\t\t_arguments \\\n", c.name)
+		for _, f := range commandFlags(c) {
+			spec := flagSpelling(f.name)
+			desc := zshEscapeDesc(f.usage)
+			switch {
+			case f.isBool:
+				spec += "[" + desc + "]"
+			case listFlags[f.name]:
+				spec += "=[" + desc + "]:" + f.name + ":_sequence compadd - " + strings.Join(f.values, " ")
+			case f.values != nil:
+				spec += "=[" + desc + "]:" + f.name + ":(" + strings.Join(f.values, " ") + ")"
+			default:
+				spec += "=[" + desc + "]:" + f.name + ":_files"
+			}
+			fmt.Fprintf(&b, "\t\t\t%s \\\n", zshQuote(spec))
+		}
+		b.WriteString("\t\t\t'*:file:_files'\n\t\t;;\n")
+	}
+	b.WriteString("\tesac\n}\n\n")
+	fmt.Fprintf(&b, "compdef _%s %s\n", shellIdent(prog), prog)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFishCompletion(w io.Writer) error {
+	var b strings.Builder
+	prog := progName()
+	names := strings.Join(commandNames(), " ")
+	fmt.Fprintf(&b, "# fish completion for %s\n", prog)
+	fmt.Fprintf(&b, "function __%s_fields\n", shellIdent(prog))
+	b.WriteString("\tset -l prefix (commandline -ct | string replace -r '^--?f(ields)?=' '' | string replace -r '[^,]*$' '')\n")
+	fmt.Fprintf(&b, "\tfor f in %s\n\t\techo $prefix$f\n\tend\nend\n\n", strings.Join(fieldNames(), " "))
+	fmt.Fprintf(&b, "complete -c %s -f\n", prog)
+	for _, c := range commands {
+		fmt.Fprintf(&b, "complete -c %s -n '__fish_use_subcommand' -a %s -d %s\n", prog, c.name, fishQuote(c.summary))
+	}
+	fmt.Fprintf(&b, "complete -c %s -n '__fish_use_subcommand' -a help -d 'Show help for a command'\n", prog)
+	fmt.Fprintf(&b, "complete -c %s -n '__fish_seen_subcommand_from help' -a '%s'\n", prog, names)
+	fmt.Fprintf(&b, "complete -c %s -n '__fish_seen_subcommand_from completion' -a '%s'\n", prog, strings.Join(completionShells, " "))
+	for _, c := range commands {
+		cond := "__fish_seen_subcommand_from " + c.name
+		if c.name == defaultCommand {
+			cond = "not __fish_seen_subcommand_from " + names
+		}
+		for _, f := range commandFlags(c) {
+			opt := "-l " + f.name
+			if len(f.name) == 1 {
+				opt = "-s " + f.name
+			}
+			line := fmt.Sprintf("complete -c %s -n '%s' %s", prog, cond, opt)
+			switch {
+			case f.isBool:
+			case listFlags[f.name]:
+				line += fmt.Sprintf(" -x -a '(__%s_fields)'", shellIdent(prog))
+			case f.values != nil:
+				line += fmt.Sprintf(" -x -a '%s'", strings.Join(f.values, " "))
+			default:
+				line += " -r -F"
+			}
+			b.WriteString(line + " -d " + fishQuote(f.usage) + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// flagSpelling returns how completions offer a flag: -f for single-letter
+// aliases, --name otherwise.
+func flagSpelling(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// shellIdent turns a program name into a valid shell function name.
+func shellIdent(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshEscapeDesc escapes characters that are special inside _arguments specs.
+func zshEscapeDesc(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompletionScripts(t *testing.T) {
+	fields := strings.Join(fieldNames(), " ")
+	cases := []struct {
+		shell string
+		write func(*strings.Builder) error
+		want  []string
+	}{
+		{"bash", func(b *strings.Builder) error { return writeBashCompletion(b) }, []string{
+			"compgen -W \"resolve search reviews rank charts serve check selftest adstxt universal-links app-links enrich diff cache monitor version completion help\"",
+			"compgen -P \"${prefix}\" -W \"" + fields + "\"",
+			"search) opts=\"",
+		}},
+		{"zsh", func(b *strings.Builder) error { return writeZshCompletion(b) }, []string{
+			"'search:Search the stores by keyword'",
+			":fields:_sequence compadd - " + fields,
+			"'-f=[",
+		}},
+		{"fish", func(b *strings.Builder) error { return writeFishCompletion(b) }, []string{
+			"-a search -d 'Search the stores by keyword'",
+			"-l platform -x -a 'ios android all'",
+			"-s f -x -a '(__",
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.shell, func(t *testing.T) {
+			var b strings.Builder
+			if err := tc.write(&b); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(b.String(), want) {
+					t.Errorf("%s script missing %q", tc.shell, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix prefixes the environment variables that override config values.
+const envPrefix = "BUNDLERESOLVER_"
+
+// flagAliases maps short aliases to the flag they stand for, so setting
+// either counts as setting the canonical flag.
+var flagAliases = map[string]string{"f": "fields"}
+
+// unconfigurableFlags only make sense on the command line.
+// --profile is consumed before settings are applied (see config.settings).
+var unconfigurableFlags = map[string]bool{"config": true, "version": true, "profile": true, "replay-run": true}
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/bundleresolver/config.yaml,
+// falling back to ~/.config.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "bundleresolver", "config.yaml")
+}
+
+// configPath picks the config file: --config, then $BUNDLERESOLVER_CONFIG,
+// then the default location. required reports whether the user chose the
+// file explicitly, in which case it must exist.
+func configPath(flagValue string) (path string, required bool) {
+	if flagValue != "" {
+		return flagValue, true
+	}
+	if p := os.Getenv(envPrefix + "CONFIG"); p != "" {
+		return p, true
+	}
+	return defaultConfigPath(), false
+}
+
+// config holds the top-level settings of a config file plus its named
+// profiles, each a set of settings layered on top of the top-level ones.
+type config struct {
+	values   map[string]string
+	profiles map[string]map[string]string
+}
+
+// loadConfig reads a YAML config file whose keys are flag names. Lists are
+// joined with commas, so `fields: [name, url]` equals `--fields name,url`.
+// The reserved `profiles` key maps profile names to further settings.
+// A missing file yields an empty config unless required is set.
+func loadConfig(path string, required bool) (*config, error) {
+	cfg := &config{values: map[string]string{}, profiles: map[string]map[string]string{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.values, err = configValues(raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch profiles := raw["profiles"].(type) {
+	case nil:
+	case map[string]any:
+		for name, p := range profiles {
+			m, ok := p.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: profile %q must be a mapping", path, name)
+			}
+			if cfg.profiles[name], err = configValues(m); err != nil {
+				return nil, fmt.Errorf("%s: profile %q: %w", path, name, err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: profiles must be a mapping", path)
+	}
+	return cfg, nil
+}
+
+func configValues(raw map[string]any) (map[string]string, error) {
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if k == "profiles" {
+			continue
+		}
+		s, err := configValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k, err)
+		}
+		values[k] = s
+	}
+	return values, nil
+}
+
+// settings returns the top-level settings overlaid with the named profile.
+// An empty name selects the config's own `profile` key, if any.
+func (c *config) settings(profile string) (map[string]string, error) {
+	if profile == "" {
+		profile = c.values["profile"]
+	}
+	merged := make(map[string]string, len(c.values))
+	for k, v := range c.values {
+		merged[canonicalFlag(k)] = v
+	}
+	delete(merged, "profile")
+	if profile == "" {
+		return merged, nil
+	}
+	p, ok := c.profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", profile)
+	}
+	for k, v := range p {
+		if k == "profile" {
+			return nil, fmt.Errorf("profile %q: profiles cannot select other profiles", profile)
+		}
+		merged[canonicalFlag(k)] = v
+	}
+	return merged, nil
+}
+
+func configValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			s, err := configValue(e)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]any:
+		return "", errors.New("nested mappings are not supported")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// applySettings fills in every flag not given on the command line, first
+// from config and then from BUNDLERESOLVER_<FLAG> environment variables
+// (dashes become underscores), so the precedence is
+// command line > environment > config file > built-in default.
+// Config keys that fset lacks are skipped if known reports them as another
+// command's flag, and rejected otherwise.
+func applySettings(fset *flag.FlagSet, config map[string]string, getenv func(string) string, known func(string) bool) error {
+	explicit := map[string]bool{}
+	fset.Visit(func(f *flag.Flag) {
+		explicit[canonicalFlag(f.Name)] = true
+	})
+
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := canonicalFlag(k)
+		if unconfigurableFlags[name] {
+			return fmt.Errorf("unknown config key %q", k)
+		}
+		if fset.Lookup(name) == nil {
+			if known(name) {
+				continue
+			}
+			return fmt.Errorf("unknown config key %q", k)
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := fset.Set(name, config[k]); err != nil {
+			return fmt.Errorf("config key %q: %w", k, err)
+		}
+	}
+
+	var err error
+	fset.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || unconfigurableFlags[f.Name] || flagAliases[f.Name] != "" {
+			return
+		}
+		env := envName(f.Name)
+		if v := getenv(env); v != "" {
+			if setErr := fset.Set(f.Name, v); setErr != nil {
+				err = fmt.Errorf("%s=%q: %w", env, v, setErr)
+			}
+		}
+	})
+	return err
+}
+
+func canonicalFlag(name string) string {
+	if c, ok := flagAliases[name]; ok {
+		return c
+	}
+	return name
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestFlagSet() (*flag.FlagSet, *string, *bool) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fields := fs.String("fields", "bundle,name,publisher,url", "")
+	fs.StringVar(fields, "f", "bundle,name,publisher,url", "")
+	header := fs.Bool("header", true, "")
+	fs.String("config", "", "")
+	return fs, fields, header
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "fields: [name, url]\nheader: false\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.values["fields"] != "name,url" || cfg.values["header"] != "false" {
+		t.Fatalf("unexpected config: %v", cfg.values)
+	}
+
+	if cfg, err := loadConfig(filepath.Join(t.TempDir(), "missing.yaml"), false); err != nil || len(cfg.values) != 0 {
+		t.Fatalf("missing optional config: %v, %v", cfg, err)
+	}
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing.yaml"), true); err == nil {
+		t.Fatal("missing required config accepted")
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `fields: bundle,name
+header: false
+profile: fast
+profiles:
+  fast:
+    fields: bundle
+  thorough:
+    f: [bundle, name, publisher, url]
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	cases := []struct {
+		profile string
+		fields  string
+	}{
+		{"", "bundle"}, // the config's own default profile
+		{"thorough", "bundle,name,publisher,url"},
+	}
+	for _, tc := range cases {
+		got, err := cfg.settings(tc.profile)
+		if err != nil {
+			t.Fatalf("settings(%q): %v", tc.profile, err)
+		}
+		if got["fields"] != tc.fields || got["header"] != "false" {
+			t.Errorf("settings(%q) = %v", tc.profile, got)
+		}
+		if _, ok := got["profile"]; ok {
+			t.Errorf("settings(%q) leaked the profile key", tc.profile)
+		}
+	}
+	if _, err := cfg.settings("missing"); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
+
+func TestApplySettingsPrecedence(t *testing.T) {
+	fs, fields, header := newTestFlagSet()
+	if err := fs.Parse([]string{"-f", "publisher"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := map[string]string{"fields": "name", "header": "false"}
+	env := map[string]string{"BUNDLERESOLVER_HEADER": "true"}
+	if err := applySettings(fs, cfg, func(k string) string { return env[k] }, func(string) bool { return false }); err != nil {
+		t.Fatalf("applySettings: %v", err)
+	}
+	if *fields != "publisher" {
+		t.Errorf("fields = %q, command line should win", *fields)
+	}
+	if !*header {
+		t.Errorf("header = false, environment should override config")
+	}
+}
+
+func TestApplySettingsUnknownKey(t *testing.T) {
+	fs, _, _ := newTestFlagSet()
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	known := func(name string) bool { return name == "addr" }
+	for _, key := range []string{"colour", "config"} {
+		if err := applySettings(fs, map[string]string{key: "x"}, func(string) string { return "" }, known); err == nil {
+			t.Errorf("key %q accepted", key)
+		}
+	}
+	if err := applySettings(fs, map[string]string{"addr": ":8080"}, func(string) string { return "" }, known); err != nil {
+		t.Errorf("key of another command rejected: %v", err)
+	}
+}
+
+func TestAnnotateEnv(t *testing.T) {
+	fs, _, _ := newTestFlagSet()
+	annotateEnv(fs)
+	if got := fs.Lookup("header").Usage; got != " [$BUNDLERESOLVER_HEADER]" {
+		t.Errorf("header usage = %q", got)
+	}
+	for _, name := range []string{"f", "config"} {
+		if got := fs.Lookup(name).Usage; got != "" {
+			t.Errorf("%s usage = %q, want no environment variable", name, got)
+		}
+	}
+}
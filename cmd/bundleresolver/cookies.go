@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// playCookieJar keeps the cookies Google Play sets, such as NID and the
+// consent cookies, and sends them back on later Play requests: cookie-less
+// requests are increasingly served degraded markup. Other hosts get no
+// cookies. The persistent cookies are saved to and loaded from a file
+// (--cookie-jar), so they carry over to the next run.
+type playCookieJar struct {
+	jar *cookiejar.Jar
+
+	mu    sync.Mutex
+	saved map[string]savedCookie // by domain, path and name
+}
+
+// savedCookie is a persistent cookie of the --cookie-jar file. URL is the
+// page that set it, which the cookie is set again from on loading.
+type savedCookie struct {
+	URL      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires"`
+	Secure   bool      `json:"secure,omitempty"`
+	HTTPOnly bool      `json:"http_only,omitempty"`
+}
+
+func newPlayCookieJar() *playCookieJar {
+	jar, _ := cookiejar.New(nil) // never fails without options
+	return &playCookieJar{jar: jar, saved: map[string]savedCookie{}}
+}
+
+func isPlayURL(u *url.URL) bool {
+	return storeOfHost(u.Hostname()) == platformAndroid
+}
+
+func (j *playCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if !isPlayURL(u) {
+		return
+	}
+	j.jar.SetCookies(u, cookies)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, c := range cookies {
+		domain := strings.TrimPrefix(c.Domain, ".")
+		if domain == "" {
+			domain = u.Hostname()
+		}
+		key := domain + ";" + c.Path + ";" + c.Name
+		expires := c.Expires
+		if c.MaxAge > 0 {
+			expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		if c.MaxAge < 0 || expires.IsZero() || !expires.After(now) {
+			// Deleted, or a session cookie that ends with the run.
+			delete(j.saved, key)
+			continue
+		}
+		j.saved[key] = savedCookie{
+			URL: (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(), Name: c.Name, Value: c.Value,
+			Domain: c.Domain, Path: c.Path, Expires: expires.UTC().Truncate(time.Second),
+			Secure: c.Secure, HTTPOnly: c.HttpOnly,
+		}
+	}
+}
+
+func (j *playCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	if !isPlayURL(u) {
+		return nil
+	}
+	return j.jar.Cookies(u)
+}
+
+// load adds the unexpired cookies of the file at path; a missing file is an
+// empty jar.
+func (j *playCookieJar) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved []savedCookie
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	for _, c := range saved {
+		u, err := url.Parse(c.URL)
+		if err != nil || !c.Expires.After(time.Now()) {
+			continue
+		}
+		j.SetCookies(u, []*http.Cookie{{
+			Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path,
+			Expires: c.Expires, Secure: c.Secure, HttpOnly: c.HTTPOnly,
+		}})
+	}
+	return nil
+}
+
+// save writes the persistent cookies to path, readable only by the user as
+// they identify the session.
+func (j *playCookieJar) save(path string) error {
+	j.mu.Lock()
+	saved := make([]savedCookie, 0, len(j.saved))
+	for _, c := range j.saved {
+		if c.Expires.After(time.Now()) {
+			saved = append(saved, c)
+		}
+	}
+	j.mu.Unlock()
+	sort.Slice(saved, func(a, b int) bool {
+		if saved[a].Domain != saved[b].Domain {
+			return saved[a].Domain < saved[b].Domain
+		}
+		return saved[a].Name < saved[b].Name
+	})
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
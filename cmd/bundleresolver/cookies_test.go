@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cookieServer is a RoundTripper acting as Google Play: it sets NID and a
+// session cookie on every response and records the cookies it is sent.
+type cookieServer struct {
+	sent []string
+}
+
+func (s *cookieServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var names []string
+	for _, c := range req.Cookies() {
+		names = append(names, c.Name+"="+c.Value)
+	}
+	s.sent = append(s.sent, req.URL.Host+": "+strings.Join(names, " "))
+	header := http.Header{}
+	header.Add("Set-Cookie", "NID=511; Domain=.google.com; Path=/; Max-Age=3600; Secure; HttpOnly")
+	header.Add("Set-Cookie", "session=1; Path=/")
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: http.NoBody, Request: req}, nil
+}
+
+func TestPlayCookieJar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	server := &cookieServer{}
+	run := func() {
+		t.Helper()
+		jar := newPlayCookieJar()
+		if err := jar.load(path); err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: server, Jar: jar}
+		for _, u := range []string{"https://play.google.com/store/apps/details?id=a.b", "https://play.google.com/store/apps/details?id=c.d", "https://itunes.apple.com/lookup?id=1"} {
+			resp, err := client.Get(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+		if err := jar.save(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run()
+	run()
+	want := []string{
+		"play.google.com: ",
+		"play.google.com: NID=511 session=1",
+		"itunes.apple.com: ",
+		// The next run starts with the saved NID but not the session cookie.
+		"play.google.com: NID=511",
+		"play.google.com: NID=511 session=1",
+		"itunes.apple.com: ",
+	}
+	if strings.Join(server.sent, "\n") != strings.Join(want, "\n") {
+		t.Errorf("cookies sent:\n%s\nwant:\n%s", strings.Join(server.sent, "\n"), strings.Join(want, "\n"))
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("cookie jar file mode = %v, %v; want 0600", info.Mode(), err)
+	}
+}
+
+func TestPlayCookieJarDropsExpired(t *testing.T) {
+	jar := newPlayCookieJar()
+	u, _ := url.Parse("https://play.google.com/store/apps")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "NID", Value: "1", Expires: time.Now().Add(time.Hour)},
+		{Name: "CONSENT", Value: "YES", Expires: time.Now().Add(time.Hour)},
+	})
+	jar.SetCookies(u, []*http.Cookie{{Name: "CONSENT", MaxAge: -1}})
+	if len(jar.saved) != 1 {
+		t.Errorf("saved %v, want only NID after CONSENT was deleted", jar.saved)
+	}
+	if err := newPlayCookieJar().load(filepath.Join(t.TempDir(), "none.json")); err != nil {
+		t.Errorf("missing jar file: %v", err)
+	}
+}
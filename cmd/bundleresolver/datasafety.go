@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// dataSafety is the Data safety section of a Play listing.
+type dataSafety struct {
+	Shared    []string `json:"shared,omitempty"`    // data types shared with third parties
+	Collected []string `json:"collected,omitempty"` // data types collected
+	// Security lists the declared security practices, such as "Data is
+	// encrypted in transit".
+	Security []string `json:"security,omitempty"`
+}
+
+// buildDataSafetyURL returns the English Data safety page of pkg; the
+// section headings are matched in English.
+func buildDataSafetyURL(pkg string) string {
+	return "https://play.google.com/store/apps/datasafety?id=" + pkg + "&hl=en"
+}
+
+// parseDataSafety extracts the Data safety page of a Play app, or returns
+// nil if it has none of the expected sections. Each section is an h2
+// heading followed by one h3 per data type or practice; the class names
+// are generated, so only the heading structure is relied on.
+func parseDataSafety(doc *goquery.Document) *dataSafety {
+	var ds dataSafety
+	var current *[]string
+	found := false
+	doc.Find("h2, h3").Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if goquery.NodeName(s) == "h2" {
+			switch heading := strings.ToLower(text); {
+			case strings.HasPrefix(heading, "data shared"):
+				current, found = &ds.Shared, true
+			case strings.HasPrefix(heading, "data collected"):
+				current, found = &ds.Collected, true
+			case strings.HasPrefix(heading, "security practices"):
+				current, found = &ds.Security, true
+			default:
+				current = nil
+			}
+			return
+		}
+		if current != nil && text != "" && !containsString(*current, text) {
+			*current = append(*current, text)
+		}
+	})
+	if !found {
+		return nil
+	}
+	return &ds
+}
+
+// value renders the data safety field f like appPrivacy.value.
+func (ds *dataSafety) value(f Field) string {
+	if ds == nil {
+		return ""
+	}
+	var items []string
+	switch f {
+	case FieldDataShared:
+		items = ds.Shared
+	case FieldDataCollected:
+		items = ds.Collected
+	case FieldSecurityPractices:
+		items = ds.Security
+	}
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const sampleDataSafetyHTML = `<html><body>
+<h1>Data safety</h1>
+<div><h2 class="q1rIdc">Data shared</h2><div>No data shared with third parties</div></div>
+<div>
+  <h2 class="q1rIdc">Data collected</h2>
+  <div><h3 class="aFEzEb">Location</h3><span>Approximate location</span></div>
+  <div><h3 class="aFEzEb">App activity</h3><span>App interactions</span></div>
+</div>
+<div>
+  <h2 class="q1rIdc">Security practices</h2>
+  <div><h3 class="aFEzEb">Data is encrypted in transit</h3></div>
+  <div><h3 class="aFEzEb">You can request that data be deleted</h3></div>
+</div>
+<div><h2>Ratings and reviews</h2><h3>Not a data type</h3></div>
+</body></html>`
+
+func TestParseDataSafety(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(sampleDataSafetyHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := parseDataSafety(doc)
+	cases := map[Field]string{
+		FieldDataShared:        "none",
+		FieldDataCollected:     "Location,App activity",
+		FieldSecurityPractices: "Data is encrypted in transit,You can request that data be deleted",
+	}
+	for f, want := range cases {
+		if got := ds.value(f); got != want {
+			t.Errorf("%s = %q, want %q", f, got, want)
+		}
+	}
+
+	empty, _ := goquery.NewDocumentFromReader(strings.NewReader("<html><body><h2>Other</h2></body></html>"))
+	if ds := parseDataSafety(empty); ds != nil || ds.value(FieldDataCollected) != "" {
+		t.Errorf("page without the section parsed as %+v", ds)
+	}
+}
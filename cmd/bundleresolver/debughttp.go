@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpExchange is one recorded request/response pair.
+type httpExchange struct {
+	Method     string
+	URL        string
+	ReqHeader  http.Header
+	Status     string
+	RespHeader http.Header
+	Body       []byte
+	Err        error
+}
+
+// exchangeLog collects the exchanges made on behalf of a single lookup.
+type exchangeLog struct {
+	mu        sync.Mutex
+	exchanges []httpExchange
+}
+
+type exchangeLogKey struct{}
+
+func withExchangeLog(ctx context.Context) (context.Context, *exchangeLog) {
+	l := &exchangeLog{}
+	return context.WithValue(ctx, exchangeLogKey{}, l), l
+}
+
+// debugTransport records every request made with an exchangeLog in its
+// context. Requests without one pass straight through.
+type debugTransport struct {
+	base http.RoundTripper
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l, _ := req.Context().Value(exchangeLogKey{}).(*exchangeLog)
+	if l == nil {
+		return t.base.RoundTrip(req)
+	}
+	ex := httpExchange{Method: req.Method, URL: req.URL.String(), ReqHeader: req.Header.Clone()}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		ex.Err = err
+		l.add(ex)
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	ex.Status, ex.RespHeader, ex.Body, ex.Err = resp.Status, resp.Header.Clone(), body, err
+	l.add(ex)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func (l *exchangeLog) add(ex httpExchange) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.exchanges = append(l.exchanges, ex)
+}
+
+// redactedHeaders carry credentials: the Play Developer API's bearer token
+// and the --cookie-jar cookies.
+var redactedHeaders = map[string]bool{"Authorization": true, "Cookie": true, "Set-Cookie": true}
+
+// redactedBody replaces the bodies of OAuth token responses, which hold an
+// access token.
+const redactedBody = "[token response redacted]"
+
+// dump writes all exchanges for a failed lookup of id into dir, readable by
+// the current user only, with credentials redacted.
+func (l *exchangeLog) dump(dir, id string, lookupErr error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# id: %s\n# error: %v\n", id, lookupErr)
+	for i, ex := range l.exchanges {
+		fmt.Fprintf(&b, "\n# exchange %d\n> %s %s\n", i+1, ex.Method, ex.URL)
+		writeHeaders(&b, "> ", ex.ReqHeader)
+		if ex.Status != "" {
+			fmt.Fprintf(&b, "< %s\n", ex.Status)
+			writeHeaders(&b, "< ", ex.RespHeader)
+		}
+		if ex.Err != nil {
+			fmt.Fprintf(&b, "! %v\n", ex.Err)
+		}
+		if len(ex.Body) > 0 {
+			b.WriteString("\n")
+			if bytes.Contains(ex.Body, []byte(`"access_token"`)) {
+				b.WriteString(redactedBody)
+			} else {
+				b.Write(ex.Body)
+			}
+			b.WriteString("\n")
+		}
+	}
+	name := fmt.Sprintf("%s-%s.txt", safeFilename(id), time.Now().Format("20060102T150405.000"))
+	return os.WriteFile(filepath.Join(dir, name), b.Bytes(), 0o600)
+}
+
+func writeHeaders(w io.Writer, prefix string, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := strings.Join(h[k], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(k)] {
+			v = "[redacted]"
+		}
+		fmt.Fprintf(w, "%s%s: %s\n", prefix, k, v)
+	}
+}
+
+// safeFilename maps an arbitrary input id to a portable file name.
+func safeFilename(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, id)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugTransportDumpsFailedLookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<html>gone</html>"))
+	}))
+	defer srv.Close()
+
+	originalClient := httpClient
+	defer func() {
+		httpClient = originalClient
+	}()
+	httpClient = &http.Client{Transport: &debugTransport{base: http.DefaultTransport}}
+
+	ctx, exchanges := withExchangeLog(context.Background())
+	resp, err := httpGet(ctx, srv.URL+"/details?id=com.example.app")
+	if err != nil {
+		t.Fatalf("httpGet: %v", err)
+	}
+	resp.Body.Close()
+
+	dir := t.TempDir()
+	if err := exchanges.dump(dir, "com.example/app", errors.New("status 404 Not Found")); err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "com.example_app-*.txt"))
+	if len(files) != 1 {
+		t.Fatalf("got dump files %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	for _, want := range []string{"# error: status 404 Not Found", "> GET " + srv.URL + "/details?id=com.example.app", "< 404 Not Found", "< Content-Type: text/html", "<html>gone</html>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("dump missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestDumpRedactsCredentials(t *testing.T) {
+	l := &exchangeLog{}
+	l.add(httpExchange{
+		Method:     "POST",
+		URL:        "https://oauth2.googleapis.com/token",
+		ReqHeader:  http.Header{"Cookie": {"NID=secret-cookie"}},
+		Status:     "200 OK",
+		RespHeader: http.Header{"Set-Cookie": {"NID=secret-cookie; Path=/"}},
+		Body:       []byte(`{"access_token":"secret-token","expires_in":3599}`),
+	})
+	l.add(httpExchange{
+		Method:    "GET",
+		URL:       "https://androidpublisher.googleapis.com/androidpublisher/v3/applications/com.example.app/edits",
+		ReqHeader: http.Header{"Authorization": {"Bearer secret-token"}},
+		Status:    "403 Forbidden",
+		Body:      []byte(`{"error":{"code":403}}`),
+	})
+	dir := t.TempDir()
+	if err := l.dump(dir, "com.example.app", errors.New("403 Forbidden")); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if len(files) != 1 {
+		t.Fatalf("got dump files %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("dump leaks credentials:\n%s", data)
+	}
+	for _, want := range []string{"> Authorization: [redacted]", "> Cookie: [redacted]", "< Set-Cookie: [redacted]", redactedBody, `{"error":{"code":403}}`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("dump missing %q:\n%s", want, data)
+		}
+	}
+	if info, err := os.Stat(files[0]); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("dump mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// sameProductThreshold is the name and publisher similarity above which an
+// iOS and an Android app are taken for the same product.
+const sameProductThreshold = 0.8
+
+// crossPlatform collects the resolved apps of a run for the
+// --dedupe-cross-platform report. It is safe for concurrent use.
+type crossPlatform struct {
+	mu   sync.Mutex
+	apps []crossPlatformApp
+}
+
+type crossPlatformApp struct {
+	input, platform, bundle, name, publisher string
+	// The keys the app is compared by, computed once by add.
+	nameKey, shortKey, publisherKey comparisonKeys
+	// tokens are the words of the name and its keys, which apps share
+	// to be compared at all.
+	tokens []string
+}
+
+func (c *crossPlatform) add(input string, rec record) {
+	if rec.Name == "" {
+		return
+	}
+	app := crossPlatformApp{
+		input:     input,
+		platform:  platformOf(rec.Bundle),
+		bundle:    rec.Bundle,
+		name:      rec.Name,
+		publisher: rec.Publisher,
+		nameKey:   newComparisonKeys(rec.Name),
+		shortKey:  newComparisonKeys(shortName(rec.Name)),
+	}
+	if rec.Publisher != "" {
+		app.publisherKey = newComparisonKeys(normalizePublisher(rec.Publisher))
+	}
+	// A name spaced differently ("CandyCrush") still shares its key.
+	for _, tok := range append(nameTokens(rec.Name), app.nameKey.key, app.shortKey.key) {
+		if tok != "" && !containsString(app.tokens, tok) {
+			app.tokens = append(app.tokens, tok)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apps = append(c.apps, app)
+}
+
+// clusters numbers the apps' clusters from 1, in input order: an iOS and an
+// Android app whose names and publishers are similar enough share one, and
+// so, transitively, do the apps matching either. Apps of one platform are
+// never compared, as a store does not list a product twice, and neither
+// are apps whose names share no token, which cannot be similar enough.
+func (c *crossPlatform) clusters() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	parent := make([]int, len(c.apps))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+	// The earlier apps by token.
+	byToken := map[string][]int{}
+	for j, b := range c.apps {
+		compared := map[int]bool{}
+		for _, tok := range b.tokens {
+			for _, i := range byToken[tok] {
+				if compared[i] || c.apps[i].platform == b.platform {
+					continue
+				}
+				compared[i] = true
+				if !sameProduct(c.apps[i], b) {
+					continue
+				}
+				if ri, rj := root(i), root(j); ri < rj {
+					parent[rj] = ri
+				} else {
+					parent[ri] = rj
+				}
+			}
+		}
+		for _, tok := range b.tokens {
+			byToken[tok] = append(byToken[tok], j)
+		}
+	}
+	ids := make([]int, len(c.apps))
+	byRoot := map[int]int{}
+	for i := range c.apps {
+		r := root(i)
+		if byRoot[r] == 0 {
+			byRoot[r] = len(byRoot) + 1
+		}
+		ids[i] = byRoot[r]
+	}
+	return ids
+}
+
+// writeFile writes the report as TSV: the cluster of every resolved app,
+// with the fields it was clustered by.
+func (c *crossPlatform) writeFile(path string) error {
+	ids := c.clusters()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "cluster\tinput\tplatform\tbundle\tname\tpublisher")
+	for i, a := range c.apps {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", ids[i], a.input, a.platform, a.bundle, sanitize(a.name), sanitize(a.publisher))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sameProduct tells whether two apps' names and publishers are similar
+// enough. Names are also compared without the tagline stores often add
+// after a dash or colon ("Example - Puzzle Game"), and apps without a
+// publisher only match by name.
+func sameProduct(a, b crossPlatformApp) bool {
+	name := max(a.nameKey.similarity(b.nameKey), a.shortKey.similarity(b.shortKey))
+	if name < sameProductThreshold {
+		return false
+	}
+	if a.publisher == "" || b.publisher == "" {
+		return true
+	}
+	return a.publisherKey.similarity(b.publisherKey) >= sameProductThreshold
+}
+
+// taglineSeparators end the short name of an app; a dash within a word
+// ("Tap-Tap") does not.
+var taglineSeparators = []string{":", "：", " | ", " - ", " – ", " — "}
+
+// shortName is an app name up to its tagline.
+func shortName(name string) string {
+	for _, sep := range taglineSeparators {
+		if i := strings.Index(name, sep); i > 0 {
+			name = name[:i]
+		}
+	}
+	return name
+}
+
+// similarity is the Dice coefficient of the letter and digit bigrams of a
+// and b, compared without case, width or punctuation: 1 for the same name,
+// 0 for names without a bigram in common.
+func similarity(a, b string) float64 {
+	return newComparisonKeys(a).similarity(newComparisonKeys(b))
+}
+
+// comparisonKeys are a name's comparisonKey and the key's bigrams.
+type comparisonKeys struct {
+	key   string
+	grams []string
+}
+
+func newComparisonKeys(s string) comparisonKeys {
+	key := comparisonKey(s)
+	return comparisonKeys{key: key, grams: bigrams(key)}
+}
+
+func (a comparisonKeys) similarity(b comparisonKeys) float64 {
+	if a.key == "" || b.key == "" {
+		return 0
+	}
+	if a.key == b.key {
+		return 1
+	}
+	if len(a.grams) == 0 || len(b.grams) == 0 {
+		return 0
+	}
+	counts := make(map[string]int, len(a.grams))
+	for _, g := range a.grams {
+		counts[g]++
+	}
+	common := 0
+	for _, g := range b.grams {
+		if counts[g] > 0 {
+			counts[g]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a.grams)+len(b.grams))
+}
+
+// nameTokens are the words of a name, folded as by comparisonKey.
+func nameTokens(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(norm.NFC.String(width.Fold.String(name))), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func comparisonKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, norm.NFC.String(width.Fold.String(s)))
+}
+
+func bigrams(s string) []string {
+	runes := []rune(s)
+	var grams []string
+	for i := 0; i+1 < len(runes); i++ {
+		grams = append(grams, string(runes[i:i+2]))
+	}
+	return grams
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessDedupeCrossPlatform(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	apps := map[string]record{
+		"123":             {Bundle: "123", Name: "Example Puzzle - Match 3 Game", Publisher: "Example, Inc."},
+		"com.example.app": {Bundle: "com.example.app", Name: "Example Puzzle: Match 3", Publisher: "Example Inc"},
+		"456":             {Bundle: "456", Name: "Other Racer", Publisher: "Other Studio"},
+		"com.copycat.app": {Bundle: "com.copycat.app", Name: "Example Puzzle", Publisher: "Copycat Apps"},
+		"com.other.app":   {Bundle: "com.other.app", Name: "Other Racer", Publisher: "Other Studio GmbH"},
+	}
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return apps[id], nil
+	}
+
+	dedupe := &crossPlatform{}
+	input := strings.NewReader("123\ncom.example.app\n456\n\ncom.copycat.app\ncom.other.app\n")
+	var out strings.Builder
+	if err := process(input, &out, options{Fields: []Field{FieldBundle}, CrossPlatform: dedupe}); err != nil {
+		t.Fatalf("process returned error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "dedupe.tsv")
+	if err := dedupe.writeFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "cluster\tinput\tplatform\tbundle\tname\tpublisher\n" +
+		"1\t123\tios\t123\tExample Puzzle - Match 3 Game\tExample, Inc.\n" +
+		"1\tcom.example.app\tandroid\tcom.example.app\tExample Puzzle: Match 3\tExample Inc\n" +
+		"2\t456\tios\t456\tOther Racer\tOther Studio\n" +
+		"3\tcom.copycat.app\tandroid\tcom.copycat.app\tExample Puzzle\tCopycat Apps\n" +
+		"2\tcom.other.app\tandroid\tcom.other.app\tOther Racer\tOther Studio GmbH\n"
+	if string(data) != want {
+		t.Errorf("report:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		same bool
+	}{
+		{"ＥＸＡＭＰＬＥ", "example", true},
+		{"Example Puzzle", "Example Puzzles", true},
+		{"Example Puzzle", "Sample Racer", false},
+		{"パズル", "パズル", true},
+	} {
+		if got := similarity(tt.a, tt.b) >= sameProductThreshold; got != tt.same {
+			t.Errorf("similarity(%q, %q) = %v", tt.a, tt.b, similarity(tt.a, tt.b))
+		}
+	}
+}
+
+func TestCrossPlatformClusters(t *testing.T) {
+	c := &crossPlatform{}
+	c.add("1", record{Bundle: "1", Name: "CandyCrush Saga", Publisher: "King"})
+	c.add("com.king.candycrushsaga", record{Bundle: "com.king.candycrushsaga", Name: "Candy Crush Saga", Publisher: "King"})
+	c.add("2", record{Bundle: "2", Name: "ＰＡＺＵＲＵ", Publisher: "Example"})
+	c.add("com.example.pazuru", record{Bundle: "com.example.pazuru", Name: "Pazuru", Publisher: "Example"})
+	c.add("com.king.other", record{Bundle: "com.king.other", Name: "Saga Quest", Publisher: "King"})
+	if got, want := c.clusters(), []int{1, 1, 2, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("clusters = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// reEmail matches a text that is a single email address.
+var reEmail = regexp.MustCompile(`^[^\s@<>]+@[^\s@<>]+\.[A-Za-z]{2,}$`)
+
+// playDeveloperEmail returns the email address of the "Developer contact"
+// section of a Play details page, or "" if there is none. The address is
+// a mailto: link on most pages and plain text under an "Email" label on
+// others.
+func playDeveloperEmail(doc *goquery.Document) string {
+	var email string
+	doc.Find("a[href^='mailto:']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		href, _ := s.Attr("href")
+		addr, _, _ := strings.Cut(strings.TrimPrefix(href, "mailto:"), "?")
+		if addr, err := url.PathUnescape(addr); err == nil && reEmail.MatchString(addr) {
+			email = addr
+			return false
+		}
+		return true
+	})
+	if email != "" {
+		return email
+	}
+	doc.Find("div, span").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if s.Children().Length() > 0 {
+			return true // only look at leaf text, not whole sections
+		}
+		if text := strings.TrimSpace(s.Text()); reEmail.MatchString(text) {
+			email = text
+			return false
+		}
+		return true
+	})
+	return email
+}
+
+// playDeveloperID returns the id of the developer page a Play details page
+// links to: numeric (/store/apps/dev?id=…) or the developer name
+// (/store/apps/developer?id=…), as Play uses either.
+func playDeveloperID(doc *goquery.Document) string {
+	var id string
+	doc.Find("a[href*='/store/apps/dev']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		href, _ := s.Attr("href")
+		u, err := url.Parse(href)
+		if err != nil || (!strings.HasSuffix(u.Path, "/store/apps/dev") && !strings.HasSuffix(u.Path, "/store/apps/developer")) {
+			return true
+		}
+		id = u.Query().Get("id")
+		return id == ""
+	})
+	return id
+}
+
+// traderInfo is the trader declaration the EU Digital Services Act requires
+// of Play developers, shown in the "About the developer" section.
+type traderInfo struct {
+	// Trader reports whether the developer declared itself a trader; only
+	// traders have to publish their contact details.
+	Trader  bool     `json:"trader"`
+	Name    string   `json:"name,omitempty"`
+	Address []string `json:"address,omitempty"` // one element per line
+	Phone   string   `json:"phone,omitempty"`
+}
+
+// parsePlayTrader extracts the trader declaration from a Play details
+// page, or returns nil if the page has none (outside the EU, or the markup
+// changed). The details are label/value pairs of the "About the
+// developer" section: a leaf element reading "Address" followed by an
+// element holding the address. Details make the developer a trader unless
+// the page says it is not one.
+func parsePlayTrader(doc *goquery.Document) *traderInfo {
+	var t traderInfo
+	found, notTrader := false, false
+	body := strings.ToLower(doc.Find("body").Text())
+	switch {
+	case strings.Contains(body, "not declared that they are a trader"), strings.Contains(body, "not identified itself as a trader"):
+		found, notTrader = true, true
+	case strings.Contains(body, "declared that they are a trader"), strings.Contains(body, "identified itself as a trader"):
+		t.Trader, found = true, true
+	}
+	traderSection(doc).Find("div, span").Each(func(_ int, s *goquery.Selection) {
+		if s.Children().Length() > 0 {
+			return
+		}
+		label := strings.ToLower(strings.TrimSpace(s.Text()))
+		value := s.Next()
+		if value.Length() == 0 {
+			return
+		}
+		switch label {
+		case "name", "trader name", "legal name":
+			t.Name = strings.Join(textLines(value), " ")
+		case "address":
+			t.Address = textLines(value)
+		case "phone", "phone number":
+			t.Phone = strings.Join(textLines(value), " ")
+		default:
+			return
+		}
+		t.Trader, found = t.Trader || !notTrader, true
+	})
+	if !found {
+		return nil
+	}
+	return &t
+}
+
+// traderSection returns the "About the developer" section of a Play
+// details page, the parent of its heading, or an empty selection. Labels
+// elsewhere on the page ("Name" in a data safety table) are not the
+// trader's.
+func traderSection(doc *goquery.Document) *goquery.Selection {
+	return doc.Find("div, span, h2, h3").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return s.Children().Length() == 0 && strings.EqualFold(strings.TrimSpace(s.Text()), "about the developer")
+	}).First().Parent()
+}
+
+// textLines returns the non-empty lines of s, split at <br> and child
+// elements.
+func textLines(s *goquery.Selection) []string {
+	var lines []string
+	add := func(text string) {
+		for _, l := range strings.Split(text, "\n") {
+			if l = strings.Join(strings.Fields(l), " "); l != "" {
+				lines = append(lines, l)
+			}
+		}
+	}
+	s = s.Clone()
+	s.Find("br").ReplaceWithHtml("\n")
+	if children := s.Children(); children.Length() > 0 {
+		children.Each(func(_ int, c *goquery.Selection) { add(c.Text()) })
+	} else {
+		add(s.Text())
+	}
+	return lines
+}
+
+// value renders the trader field f for TSV/CSV output: trader is true or
+// false, trader_address joins the lines with ", ", and trader_country is
+// its last line. All are empty if the page has no declaration.
+func (t *traderInfo) value(f Field) string {
+	if t == nil {
+		return ""
+	}
+	switch f {
+	case FieldTrader:
+		return strconv.FormatBool(t.Trader)
+	case FieldTraderName:
+		return t.Name
+	case FieldTraderAddress:
+		return strings.Join(t.Address, ", ")
+	case FieldTraderCountry:
+		if len(t.Address) < 2 {
+			return ""
+		}
+		return t.Address[len(t.Address)-1]
+	case FieldTraderPhone:
+		return t.Phone
+	}
+	return ""
+}
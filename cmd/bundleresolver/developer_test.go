@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestPlayDeveloperEmail(t *testing.T) {
+	cases := map[string]string{
+		`<div><a href="mailto:support@example.com?subject=Hi"><span>Email</span><span>support@example.com</span></a></div>`: "support@example.com",
+		`<div><div>Email</div><div>help@example.co.jp</div></div>`:                                                          "help@example.co.jp",
+		`<a href="mailto:">Email</a><p>Write to us at help@example.com</p>`:                                                 "",
+	}
+	for html, want := range cases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := playDeveloperEmail(doc); got != want {
+			t.Errorf("playDeveloperEmail(%s) = %q, want %q", html, got, want)
+		}
+	}
+}
+
+func TestParsePlayTrader(t *testing.T) {
+	fields := []Field{FieldTrader, FieldTraderName, FieldTraderAddress, FieldTraderCountry, FieldTraderPhone}
+	cases := map[string]string{
+		`<div><div>About the developer</div><div><span>Name</span><div>Example Games GmbH</div></div>` +
+			`<div><span>Address</span><div>Hauptstraße 1<br>10115 Berlin<br>Germany</div></div>` +
+			`<div><span>Phone number</span><div>+49 30 1234567</div></div>` +
+			`<p>This developer has declared that they are a trader as defined by EU law.</p></div>`: "true|Example Games GmbH|Hauptstraße 1, 10115 Berlin, Germany|Germany|+49 30 1234567",
+		`<p>This developer has not declared that they are a trader.</p>`: "false||||",
+		`<div><span>Name</span><div>Search history</div></div>` +
+			`<div><div>About the developer</div><div><span>Address</span><div>Example Street 1</div></div>` +
+			`<p>This developer has not declared that they are a trader.</p></div>`: "false||Example Street 1||",
+		`<div><span>Name</span><div>Search history</div></div><div><span>Phone</span><div>Call</div></div>`: "||||",
+		`<div><span>Install</span><span>4.5</span></div>`:                                                   "||||",
+	}
+	for html, want := range cases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := record{Trader: parsePlayTrader(doc)}
+		got := make([]string, len(fields))
+		for i, f := range fields {
+			got[i] = rec.value(f)
+		}
+		if strings.Join(got, "|") != want {
+			t.Errorf("trader of %s = %q, want %q", html, strings.Join(got, "|"), want)
+		}
+	}
+}
+
+func TestPlayDeveloperID(t *testing.T) {
+	cases := map[string]string{
+		`<a href="/store/apps/dev?id=5700313618786177705"><span>Example Inc.</span></a>`:            "5700313618786177705",
+		`<a href="https://play.google.com/store/apps/developer?id=Example+Games">Example Games</a>`: "Example Games",
+		`<a href="/store/apps/details?id=com.example.app">App</a>`:                                  "",
+	}
+	for html, want := range cases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := playDeveloperID(doc); got != want {
+			t.Errorf("playDeveloperID(%s) = %q, want %q", html, got, want)
+		}
+	}
+}
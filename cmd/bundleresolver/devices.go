@@ -0,0 +1,87 @@
+package main
+
+import "strings"
+
+// applePlatforms are the values of the platforms field, in output order.
+var applePlatforms = []string{"iphone", "ipad", "mac", "watch", "tv", "vision"}
+
+// devicePlatforms maps prefixes of the iTunes lookup's supportedDevices
+// entries (e.g. iPhone15Pro-iPhone15Pro, Watch8-Watch8) to the platform
+// they belong to.
+var devicePlatforms = []struct{ prefix, platform string }{
+	{"iphone", "iphone"},
+	{"ipod", "iphone"},
+	{"ipad", "ipad"},
+	{"mac", "mac"},
+	{"watch", "watch"},
+	{"appletv", "tv"},
+	{"applevision", "vision"},
+	{"reality", "vision"},
+	{"vision", "vision"},
+}
+
+// supportedPlatforms tells from an iTunes lookup result which Apple
+// platforms an app runs on: the devices it lists, plus the iPad and Apple
+// TV when it has screenshots for them.
+func supportedPlatforms(devices []string, ipadScreenshots, tvScreenshots int) []string {
+	found := map[string]bool{}
+	for _, d := range devices {
+		d = strings.ToLower(d)
+		for _, dp := range devicePlatforms {
+			if strings.HasPrefix(d, dp.prefix) {
+				found[dp.platform] = true
+				break
+			}
+		}
+	}
+	if ipadScreenshots > 0 {
+		found["ipad"] = true
+	}
+	if tvScreenshots > 0 {
+		found["tv"] = true
+	}
+	var platforms []string
+	for _, p := range applePlatforms {
+		if found[p] {
+			platforms = append(platforms, p)
+		}
+	}
+	return platforms
+}
+
+// iOS device families, the values of the device_family field.
+const (
+	deviceFamilyIPhone    = "iphone"
+	deviceFamilyIPad      = "ipad"
+	deviceFamilyUniversal = "universal"
+)
+
+// deviceFamily tells from an iTunes lookup result whether an app is built
+// for the iPhone, the iPad or both. iPhone apps list iPad models among
+// their supportedDevices, since iPads run them in compatibility mode, so
+// native iPad support is told by the iPad screenshots; an app listing no
+// iPhone or iPod is iPad-only. Apps for neither, such as Watch or Mac only
+// apps, get "".
+func deviceFamily(devices []string, iphoneScreenshots, ipadScreenshots int) string {
+	var phone, pad bool
+	for _, d := range devices {
+		d = strings.ToLower(d)
+		switch {
+		case strings.HasPrefix(d, "iphone"), strings.HasPrefix(d, "ipod"):
+			phone = true
+		case strings.HasPrefix(d, "ipad"):
+			pad = true
+		}
+	}
+	switch {
+	case phone && ipadScreenshots > 0 && iphoneScreenshots == 0:
+		return deviceFamilyIPad
+	case phone && ipadScreenshots > 0:
+		return deviceFamilyUniversal
+	case phone:
+		return deviceFamilyIPhone
+	case pad || ipadScreenshots > 0:
+		return deviceFamilyIPad
+	}
+	return ""
+}
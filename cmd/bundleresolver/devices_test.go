@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSupportedPlatforms(t *testing.T) {
+	cases := []struct {
+		devices  []string
+		ipad, tv int
+		want     []string
+	}{
+		{[]string{"iPhone15Pro-iPhone15Pro", "iPodTouchSeventhGen-iPodTouchSeventhGen", "iPadPro11M4-iPadPro11M4", "Watch8-Watch8", "MacDesktop-MacDesktop", "AppleVisionPro-AppleVisionPro"}, 0, 0, []string{"iphone", "ipad", "mac", "watch", "vision"}},
+		{[]string{"iPhone15-iPhone15"}, 2, 1, []string{"iphone", "ipad", "tv"}},
+		{[]string{"Unknown-Unknown"}, 0, 0, nil},
+	}
+	for _, c := range cases {
+		if got := supportedPlatforms(c.devices, c.ipad, c.tv); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("supportedPlatforms(%v, %d, %d) = %v, want %v", c.devices, c.ipad, c.tv, got, c.want)
+		}
+	}
+}
+
+func TestDeviceFamily(t *testing.T) {
+	phone := []string{"iPhone15-iPhone15", "iPodTouchSeventhGen-iPodTouchSeventhGen", "iPadPro11M4-iPadPro11M4"}
+	cases := []struct {
+		devices      []string
+		iphone, ipad int
+		want         string
+	}{
+		{phone, 3, 0, "iphone"},
+		{phone, 3, 2, "universal"},
+		{phone, 0, 2, "ipad"},
+		{[]string{"iPadPro11M4-iPadPro11M4"}, 0, 0, "ipad"},
+		{[]string{"Watch8-Watch8"}, 0, 0, ""},
+	}
+	for _, c := range cases {
+		if got := deviceFamily(c.devices, c.iphone, c.ipad); got != c.want {
+			t.Errorf("deviceFamily(%v, %d, %d) = %q, want %q", c.devices, c.iphone, c.ipad, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// hostDialer resolves host names itself so connections can be pinned to one
+// IP version, names sent to a chosen DNS server, and answers cached. It
+// connects to the addresses of a host Happy Eyeballs style (RFC 8305):
+// alternating IPv6 and IPv4, starting the next attempt when one fails or
+// takes longer than dialStagger, and keeping the first connection made.
+type hostDialer struct {
+	network string // tcp, tcp4 or tcp6
+	ttl     time.Duration
+	// attemptTimeout bounds the attempt on one address; 0 leaves it to the
+	// dialer's own timeout.
+	attemptTimeout time.Duration
+	lookup         func(ctx context.Context, network, host string) ([]netip.Addr, error)
+	dial           func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mu    sync.Mutex
+	cache map[string]dnsEntry
+	// failed holds when connecting to an address last failed; such
+	// addresses are tried last for failedAddrPenalty.
+	failed map[netip.Addr]time.Time
+}
+
+// dialStagger is the Connection Attempt Delay of RFC 8305.
+const dialStagger = 250 * time.Millisecond
+
+// failedAddrPenalty is how long an address that refused or timed out is
+// tried after the host's other addresses.
+const failedAddrPenalty = time.Minute
+
+type dnsEntry struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// newHostDialer returns a dialer for ipVersion ("any", "4" or "6") that
+// queries dnsServer (host[:port]) or, if empty, the system resolver.
+func newHostDialer(ipVersion, dnsServer string, ttl time.Duration) (*hostDialer, error) {
+	network := "tcp"
+	switch ipVersion {
+	case "any":
+	case "4", "6":
+		network += ipVersion
+	default:
+		return nil, fmt.Errorf("invalid --ip-version %q (want 4, 6 or any)", ipVersion)
+	}
+	resolver := net.DefaultResolver
+	if dnsServer != "" {
+		if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+			dnsServer = net.JoinHostPort(dnsServer, "53")
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, dnsServer)
+			},
+		}
+	}
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &hostDialer{
+		network: network,
+		ttl:     ttl,
+		lookup:  resolver.LookupNetIP,
+		dial:    d.DialContext,
+		cache:   map[string]dnsEntry{},
+		failed:  map[netip.Addr]time.Time{},
+	}, nil
+}
+
+// DialContext connects to the resolved addresses of addr, reporting an
+// error only once every address failed.
+func (d *hostDialer) DialContext(ctx context.Context, _, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return d.dial(ctx, d.network, addr)
+	}
+	addrs, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return d.race(ctx, d.order(addrs), port)
+}
+
+type dialResult struct {
+	ip   netip.Addr
+	conn net.Conn
+	err  error
+}
+
+// race connects to port on addrs, in order and staggered, and returns the
+// first connection made; the attempts still running are abandoned.
+func (d *hostDialer) race(ctx context.Context, addrs []netip.Addr, port string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, len(addrs))
+	next, pending := 0, 0
+	start := func() {
+		ip := addrs[next]
+		next++
+		pending++
+		go func() {
+			actx := ctx
+			if d.attemptTimeout > 0 {
+				var cancel context.CancelFunc
+				actx, cancel = context.WithTimeout(ctx, d.attemptTimeout)
+				defer cancel()
+			}
+			conn, err := d.dial(actx, d.network, net.JoinHostPort(ip.String(), port))
+			results <- dialResult{ip: ip, conn: conn, err: err}
+		}()
+	}
+	start()
+	stagger := time.NewTimer(dialStagger)
+	defer stagger.Stop()
+	var firstErr error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				go closeLateConns(results, pending)
+				return r.conn, nil
+			}
+			if ctx.Err() == nil {
+				d.markFailed(r.ip)
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) {
+				start()
+				stagger.Reset(dialStagger)
+			}
+		case <-stagger.C:
+			if next < len(addrs) {
+				start()
+				stagger.Reset(dialStagger)
+			}
+		}
+	}
+	return nil, firstErr
+}
+
+// closeLateConns closes the connections of the n attempts still running
+// when another one won the race.
+func closeLateConns(results <-chan dialResult, n int) {
+	for ; n > 0; n-- {
+		if r := <-results; r.conn != nil {
+			r.conn.Close()
+		}
+	}
+}
+
+func (d *hostDialer) markFailed(ip netip.Addr) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failed[ip] = time.Now()
+}
+
+// order returns addrs as they are tried: interleaved by family, starting
+// with the family of the first, and those that failed recently last.
+func (d *hostDialer) order(addrs []netip.Addr) []netip.Addr {
+	d.mu.Lock()
+	var healthy, failed []netip.Addr
+	for _, ip := range addrs {
+		if at, ok := d.failed[ip]; ok && time.Since(at) < failedAddrPenalty {
+			failed = append(failed, ip)
+		} else {
+			healthy = append(healthy, ip)
+		}
+	}
+	d.mu.Unlock()
+	return append(interleaveFamilies(healthy), interleaveFamilies(failed)...)
+}
+
+// interleaveFamilies alternates the IPv6 and IPv4 addresses of addrs,
+// keeping their order within each family.
+func interleaveFamilies(addrs []netip.Addr) []netip.Addr {
+	if len(addrs) == 0 {
+		return nil
+	}
+	var first, second []netip.Addr
+	for _, ip := range addrs {
+		if ip.Unmap().Is4() == addrs[0].Unmap().Is4() {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+	out := make([]netip.Addr, 0, len(addrs))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			out = append(out, first[i])
+		}
+		if i < len(second) {
+			out = append(out, second[i])
+		}
+	}
+	return out
+}
+
+func (d *hostDialer) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	d.mu.Lock()
+	e, ok := d.cache[host]
+	d.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.addrs, nil
+	}
+
+	ipNetwork := "ip" + d.network[len("tcp"):]
+	addrs, err := d.lookup(ctx, ipNetwork, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no %s address for %s", ipNetwork, host)
+	}
+	if d.ttl > 0 {
+		d.mu.Lock()
+		d.cache[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(d.ttl)}
+		d.mu.Unlock()
+	}
+	return addrs, nil
+}
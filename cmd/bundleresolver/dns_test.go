@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostDialerPinsVersionAndCaches(t *testing.T) {
+	d, err := newHostDialer("6", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookups := 0
+	d.lookup = func(_ context.Context, network, host string) ([]netip.Addr, error) {
+		lookups++
+		if network != "ip6" || host != "play.google.com" {
+			t.Errorf("lookup(%q, %q)", network, host)
+		}
+		return []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2")}, nil
+	}
+	var dialed []string
+	d.dial = func(_ context.Context, network, addr string) (net.Conn, error) {
+		if network != "tcp6" {
+			t.Errorf("dial network = %q, want tcp6", network)
+		}
+		dialed = append(dialed, addr)
+		if addr == "[2001:db8::1]:443" {
+			return nil, errors.New("unreachable")
+		}
+		c1, c2 := net.Pipe()
+		c2.Close()
+		return c1, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		conn, err := d.DialContext(context.Background(), "tcp", "play.google.com:443")
+		if err != nil {
+			t.Fatalf("DialContext: %v", err)
+		}
+		conn.Close()
+	}
+	if lookups != 1 {
+		t.Errorf("lookups = %d, want 1 (second dial should hit the cache)", lookups)
+	}
+	// The second dial skips ahead to the address that worked.
+	want := []string{"[2001:db8::1]:443", "[2001:db8::2]:443", "[2001:db8::2]:443"}
+	if !reflect.DeepEqual(dialed, want) {
+		t.Errorf("dialed %q, want %q", dialed, want)
+	}
+}
+
+func TestHostDialerRejectsUnknownVersion(t *testing.T) {
+	if _, err := newHostDialer("5", "", 0); err == nil {
+		t.Error("expected an error for --ip-version 5")
+	}
+}
+
+func TestHostDialerStaggersSlowAddresses(t *testing.T) {
+	d, err := newHostDialer("any", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.attemptTimeout = time.Second
+	d.lookup = func(context.Context, string, string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2"), netip.MustParseAddr("192.0.2.1")}, nil
+	}
+	var mu sync.Mutex
+	var dialed []string
+	d.dial = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, addr)
+		mu.Unlock()
+		if addr != "192.0.2.1:443" {
+			<-ctx.Done() // blackholed
+			return nil, ctx.Err()
+		}
+		c1, c2 := net.Pipe()
+		c2.Close()
+		return c1, nil
+	}
+
+	started := time.Now()
+	conn, err := d.DialContext(context.Background(), "tcp", "itunes.apple.com:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if elapsed := time.Since(started); elapsed > 900*time.Millisecond {
+		t.Errorf("connected after %v, want the IPv4 attempt started one stagger after the IPv6 one", elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"[2001:db8::1]:443", "192.0.2.1:443"}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("dialed %q, want the families interleaved %q", dialed, want)
+	}
+}
+
+func TestHostDialerReportsWhenAllFail(t *testing.T) {
+	d, err := newHostDialer("any", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.lookup = func(context.Context, string, string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")}, nil
+	}
+	var attempts atomic.Int64
+	d.dial = func(context.Context, string, string) (net.Conn, error) {
+		attempts.Add(1)
+		return nil, errors.New("connection refused")
+	}
+	if _, err := d.DialContext(context.Background(), "tcp", "play.google.com:443"); err == nil || err.Error() != "connection refused" {
+		t.Errorf("err = %v, want the first attempt's", err)
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("%d attempts, want every address tried", attempts.Load())
+	}
+}
+
+func TestInterleaveFamilies(t *testing.T) {
+	var addrs []netip.Addr
+	for _, s := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "2001:db8::1"} {
+		addrs = append(addrs, netip.MustParseAddr(s))
+	}
+	var got []string
+	for _, ip := range interleaveFamilies(addrs) {
+		got = append(got, ip.String())
+	}
+	if want := []string{"192.0.2.1", "2001:db8::1", "192.0.2.2", "192.0.2.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("interleaved %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// reportIDHeaders are the header names, lower-cased without spaces or
+// punctuation, of the app id columns of Apple Search Ads and Google Ads
+// reports.
+var reportIDHeaders = map[string]bool{
+	"adamid": true, "appadamid": true, "appid": true, "appstoreid": true,
+	"bundleid": true, "packagename": true, "mobileapp": true, "mobileappid": true,
+	"applicationid": true,
+}
+
+// reportSampleRows is how many rows the value-based column detection reads.
+const reportSampleRows = 200
+
+// --format values of enrich besides the adapters' names: detect the kind
+// of report, or read it as an ads report (Apple Search Ads, Google Ads).
+const (
+	reportFormatAuto = "auto"
+	reportFormatAds  = "ads"
+)
+
+// reportAdapter reads the raw-data exports of a mobile measurement
+// partner, whose app id columns are known by name. Header names are
+// normalized (see normalizeHeader).
+type reportAdapter struct {
+	name string
+	// markers are headers only this partner's exports have, telling them
+	// apart when detecting the format.
+	markers []string
+	// idHeaders name the app id columns. Other columns holding ids, such
+	// as the site ids of ad networks, are left alone.
+	idHeaders []string
+}
+
+var reportAdapters = []reportAdapter{
+	{name: "appsflyer", markers: []string{"appsflyerid"}, idHeaders: []string{"appid"}},
+	{name: "adjust", markers: []string{"adid", "trackertoken", "trackername"}, idHeaders: []string{"appid", "storeappid"}},
+}
+
+var reportFormats = []string{reportFormatAuto, reportFormatAds, "appsflyer", "adjust"}
+
+// parseReportFormat validates an enrich --format value.
+func parseReportFormat(s string) (string, error) {
+	if slices.Contains(reportFormats, s) {
+		return s, nil
+	}
+	return "", fmt.Errorf("%q is not one of %s", s, strings.Join(reportFormats, ", "))
+}
+
+// reIDPrefixed matches the id<App Store id> form AppsFlyer writes.
+var reIDPrefixed = regexp.MustCompile(`^id([0-9]+)$`)
+
+// reportAppID returns the store id written as v in a report, or "" if v
+// is not one. Google Ads writes app placements as
+// mobileapp::1-<App Store id> and mobileapp::2-<package>, and AppsFlyer
+// iOS apps as id<App Store id>.
+func reportAppID(v string) string {
+	v = strings.TrimSpace(v)
+	v = strings.TrimPrefix(v, "mobileapp::")
+	if len(v) > 2 && (v[:2] == "1-" || v[:2] == "2-") {
+		v = v[2:]
+	}
+	if m := reIDPrefixed.FindStringSubmatch(v); m != nil {
+		v = m[1]
+	}
+	if platformOf(v) == platformUnknown {
+		return ""
+	}
+	return v
+}
+
+// normalizeHeader lower-cases a column name and drops everything but
+// letters and digits, so "Adam ID", "adamId" and "adam_id" compare equal.
+func normalizeHeader(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, s)
+}
+
+// adsReport is a CSV or TSV report: the lines before its header (such as
+// the report name and date range Google Ads writes), the header and the
+// rows.
+type adsReport struct {
+	comma    rune
+	preamble [][]string
+	header   []string
+	rows     [][]string
+}
+
+// sniffComma picks the delimiter used most in the first lines of data:
+// tab, comma or semicolon.
+func sniffComma(data []byte) rune {
+	lines := bytes.SplitN(data, []byte("\n"), 11)
+	head := bytes.Join(lines[:min(len(lines), 10)], nil)
+	comma, best := ',', 0
+	for _, c := range []byte{'\t', ',', ';'} {
+		if n := bytes.Count(head, []byte{c}); n > best {
+			comma, best = rune(c), n
+		}
+	}
+	return comma
+}
+
+// readAdsReport reads a report, in UTF-8 or, as Excel exports from Google
+// Ads are, UTF-16 with a byte order mark. Its header is the first line
+// with two or more non-empty cells.
+func readAdsReport(r io.Reader) (*adsReport, error) {
+	data, err := io.ReadAll(transform.NewReader(r, unicode.BOMOverride(unicode.UTF8.NewDecoder())))
+	if err != nil {
+		return nil, err
+	}
+	rep := &adsReport{comma: sniffComma(data)}
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.Comma, cr.FieldsPerRecord, cr.LazyQuotes = rep.comma, -1, true
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case rep.header != nil:
+			rep.rows = append(rep.rows, row)
+		case nonEmptyCells(row) >= 2:
+			rep.header = row
+		default:
+			rep.preamble = append(rep.preamble, row)
+		}
+	}
+	if rep.header == nil {
+		return nil, errors.New("the report has no header line")
+	}
+	return rep, nil
+}
+
+func nonEmptyCells(row []string) int {
+	n := 0
+	for _, c := range row {
+		if strings.TrimSpace(c) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// format returns the kind of report rep is: the adapter whose markers its
+// header has, or reportFormatAds.
+func (rep *adsReport) format() string {
+	for _, a := range reportAdapters {
+		for _, h := range rep.header {
+			if slices.Contains(a.markers, normalizeHeader(h)) {
+				return a.name
+			}
+		}
+	}
+	return reportFormatAds
+}
+
+// idColumns returns the indexes of the report's app id columns, in order:
+// those named in names, or else those the format's adapter names, or for
+// ads reports those whose header is a known id column name (see
+// reportIDHeaders) and those whose sampled values are all store ids beyond
+// bare numbers, which other columns such as impressions also hold.
+func (rep *adsReport) idColumns(names []string, format string) ([]int, error) {
+	var cols []int
+	if len(names) > 0 {
+		for _, name := range names {
+			i := -1
+			for j, h := range rep.header {
+				if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(name)) {
+					i = j
+					break
+				}
+			}
+			if i < 0 {
+				return nil, fmt.Errorf("the report has no %q column", name)
+			}
+			cols = append(cols, i)
+		}
+		slices.Sort(cols)
+		return slices.Compact(cols), nil
+	}
+	if format == reportFormatAuto {
+		format = rep.format()
+		logger.Debug("report format detected", "format", format)
+	}
+	for i, h := range rep.header {
+		name := normalizeHeader(h)
+		if format == reportFormatAds {
+			if reportIDHeaders[name] || rep.holdsAppIDs(i) {
+				cols = append(cols, i)
+			}
+			continue
+		}
+		for _, a := range reportAdapters {
+			if a.name == format && slices.Contains(a.idHeaders, name) {
+				cols = append(cols, i)
+			}
+		}
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("no app id column found in the %s report; name it with --columns", format)
+	}
+	return cols, nil
+}
+
+// holdsAppIDs tells whether the sampled values of column i are all store
+// ids, not all of them bare numbers.
+func (rep *adsReport) holdsAppIDs(i int) bool {
+	seen, numeric := 0, 0
+	for _, row := range rep.rows[:min(len(rep.rows), reportSampleRows)] {
+		if i >= len(row) || strings.TrimSpace(row[i]) == "" {
+			continue
+		}
+		id := reportAppID(row[i])
+		if id == "" {
+			return false
+		}
+		seen++
+		if reIOS.MatchString(strings.TrimSpace(row[i])) {
+			numeric++
+		}
+	}
+	return seen > 0 && numeric < seen
+}
+
+// enrichOptions controls enrichReport.
+type enrichOptions struct {
+	// Fields are added after each id column, named "<id column> <field>".
+	Fields []Field
+	// Columns names the id columns; empty detects them.
+	Columns []string
+	// Format is the kind of report (see reportFormats).
+	Format      string
+	Concurrency int
+}
+
+// enrichReport writes the report read from r to w with, for each app id
+// column, the fields of the app each row names. Every distinct id is
+// looked up once; rows whose id did not resolve get empty values.
+func enrichReport(r io.Reader, w io.Writer, opts enrichOptions) error {
+	rep, err := readAdsReport(r)
+	if err != nil {
+		return err
+	}
+	cols, err := rep.idColumns(opts.Columns, opts.Format)
+	if err != nil {
+		return err
+	}
+	var ids strings.Builder
+	seen := map[string]bool{}
+	for _, row := range rep.rows {
+		for _, c := range cols {
+			if c >= len(row) {
+				continue
+			}
+			if id := reportAppID(row[c]); id != "" && !seen[id] {
+				seen[id] = true
+				ids.WriteString(id + "\n")
+			}
+		}
+	}
+	selectFields(opts.Fields)
+	snap := &snapshot{}
+	if err := process(strings.NewReader(ids.String()), io.Discard, options{Fields: opts.Fields, Concurrency: opts.Concurrency, SkipErrors: true, Snapshot: snap}); err != nil {
+		return err
+	}
+	records := map[string]record{}
+	for _, e := range snap.entries {
+		if e.Error == "" {
+			records[e.Input] = e.record
+		}
+	}
+
+	out := csv.NewWriter(w)
+	out.Comma = rep.comma
+	for _, row := range rep.preamble {
+		out.Write(row)
+	}
+	out.Write(enrichRow(rep.header, cols, func(c int) []string {
+		names := make([]string, len(opts.Fields))
+		for i, f := range opts.Fields {
+			names[i] = strings.TrimSpace(rep.header[c]) + " " + string(f)
+		}
+		return names
+	}))
+	for _, row := range rep.rows {
+		out.Write(enrichRow(row, cols, func(c int) []string {
+			var rec record
+			if c < len(row) {
+				rec = records[reportAppID(row[c])]
+			}
+			values := make([]string, len(opts.Fields))
+			if rec.Bundle == "" {
+				return values
+			}
+			for i, f := range opts.Fields {
+				values[i] = sanitize(rec.value(f))
+			}
+			return values
+		}))
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// enrichRow returns row with added(c) inserted after each id column c.
+// Short rows, such as totals lines, are padded first.
+func enrichRow(row []string, cols []int, added func(c int) []string) []string {
+	for len(row) <= cols[len(cols)-1] {
+		row = append(row, "")
+	}
+	var out []string
+	next := 0
+	for i, cell := range row {
+		out = append(out, cell)
+		if next < len(cols) && cols[next] == i {
+			out = append(out, added(i)...)
+			next++
+		}
+	}
+	return out
+}
+
+func setupEnrich(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var inputPath, fieldsCSV, columns, format string
+	var concurrency int
+	fs.StringVar(&inputPath, "input", "", "Read the report from this file instead of STDIN")
+	fs.StringVar(&fieldsCSV, "fields", "name,publisher", "Fields added after each app id column")
+	fs.StringVar(&fieldsCSV, "f", "name,publisher", "Alias of --fields")
+	fs.StringVar(&columns, "columns", "", "Comma-separated names of the app id columns (default: detected)")
+	fs.StringVar(&format, "format", reportFormatAuto, "Kind of report: auto, ads (Apple Search Ads, Google Ads), appsflyer or adjust")
+	fs.IntVar(&concurrency, "concurrency", 4, "Number of lookups run in parallel")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q (the report is read from STDIN or --input)", args)
+		}
+		fields, err := parseFields(fieldsCSV)
+		if err != nil {
+			return fmt.Errorf("invalid --fields: %w", err)
+		}
+		if concurrency < 1 {
+			return fmt.Errorf("invalid --concurrency %d (must be at least 1)", concurrency)
+		}
+		if format, err = parseReportFormat(format); err != nil {
+			return fmt.Errorf("invalid --format: %w", err)
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		in := io.Reader(os.Stdin)
+		if inputPath != "" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		opts := enrichOptions{Fields: fields, Format: format, Concurrency: concurrency}
+		if columns != "" {
+			opts.Columns = strings.Split(columns, ",")
+		}
+		return enrichReport(in, os.Stdout, opts)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestReportAppID(t *testing.T) {
+	cases := map[string]string{
+		"1234567890":                  "1234567890",
+		" com.example.app ":           "com.example.app",
+		"mobileapp::1-1234567890":     "1234567890",
+		"mobileapp::2-com.example.ok": "com.example.ok",
+		"id1234567890":                "1234567890",
+		"idle.game":                   "idle.game",
+		"Brand campaign":              "",
+		"":                            "",
+	}
+	for in, want := range cases {
+		if got := reportAppID(in); got != want {
+			t.Errorf("reportAppID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReadAdsReportDetectsColumns(t *testing.T) {
+	// Google Ads writes a title and date range before the header, and
+	// exports for Excel in UTF-16.
+	text := "Placement report\r\nOctober 1, 2026 - October 7, 2026\r\nPlacement\tCampaign\tImpressions\r\nmobileapp::2-com.example.game\tUA\t1200\r\nmobileapp::1-1234567890\tUA\t800\r\n"
+	var data []byte
+	data = append(data, 0xff, 0xfe)
+	for _, u := range utf16.Encode([]rune(text)) {
+		data = append(data, byte(u), byte(u>>8))
+	}
+	rep, err := readAdsReport(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.comma != '\t' || len(rep.preamble) != 2 || len(rep.rows) != 2 {
+		t.Fatalf("report = comma %q, %d preamble lines, %d rows", rep.comma, len(rep.preamble), len(rep.rows))
+	}
+	cols, err := rep.idColumns(nil, reportFormatAuto)
+	if err != nil || !reflect.DeepEqual(cols, []int{0}) {
+		t.Errorf("idColumns = %v, %v; want the placement column only", cols, err)
+	}
+
+	rep, _ = readAdsReport(strings.NewReader("Campaign Name,Adam ID,Taps\nUS Brand,1234567890,12\n"))
+	if cols, err := rep.idColumns(nil, reportFormatAuto); err != nil || !reflect.DeepEqual(cols, []int{1}) {
+		t.Errorf("Adam ID report: idColumns = %v, %v", cols, err)
+	}
+	if _, err := rep.idColumns([]string{"App"}, reportFormatAuto); err == nil {
+		t.Error("missing --columns name accepted")
+	}
+}
+
+func TestEnrichReport(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() { resolveFunc = originalResolve }()
+	var lookups []string
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		lookups = append(lookups, id)
+		if id == "999" {
+			return record{Bundle: id}, errors.New("not found")
+		}
+		return record{Bundle: id, Name: "App " + id, Publisher: "Dev, Inc."}, nil
+	}
+	in := "Campaign Name,Adam ID,Spend\nBrand,123,10.5\nGeneric,123,3\nOld,999,1\nTotal\n"
+	var out strings.Builder
+	opts := enrichOptions{Fields: []Field{FieldName, FieldPublisher}, Format: reportFormatAuto, Concurrency: 1}
+	if err := enrichReport(strings.NewReader(in), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "Campaign Name,Adam ID,Adam ID name,Adam ID publisher,Spend\n" +
+		"Brand,123,App 123,\"Dev, Inc.\",10.5\n" +
+		"Generic,123,App 123,\"Dev, Inc.\",3\n" +
+		"Old,999,,,1\n" +
+		"Total,,,\n"
+	if got := out.String(); got != want {
+		t.Errorf("output mismatch:\n got: %q\nwant: %q", got, want)
+	}
+	if !reflect.DeepEqual(lookups, []string{"123", "999"}) {
+		t.Errorf("lookups = %v, want each id once", lookups)
+	}
+}
+
+func TestReportAdapters(t *testing.T) {
+	// AppsFlyer raw data: Site ID holds ad network publisher apps, which
+	// are not the app the row is about.
+	appsflyer := "Attributed Touch Time,Media Source,Site ID,AppsFlyer ID,App ID,Platform\n" +
+		"2026-10-01 10:00:00,network_int,com.publisher.app,1700000000000-123,id1234567890,ios\n" +
+		"2026-10-01 11:00:00,network_int,com.publisher.app,1700000000001-456,com.example.app,android\n"
+	adjust := "{adid},{tracker_name},{app_id},{os_name}\nabc123,Organic,com.example.app,android\n"
+	for _, tc := range []struct {
+		name, report, format string
+		want                 []int
+	}{
+		{"appsflyer", appsflyer, reportFormatAuto, []int{4}},
+		{"adjust", adjust, reportFormatAuto, []int{2}},
+		{"appsflyer as ads", appsflyer, reportFormatAds, []int{2, 4}},
+	} {
+		rep, err := readAdsReport(strings.NewReader(tc.report))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cols, err := rep.idColumns(nil, tc.format); err != nil || !reflect.DeepEqual(cols, tc.want) {
+			t.Errorf("%s: idColumns = %v, %v; want %v", tc.name, cols, err, tc.want)
+		}
+	}
+	if _, err := parseReportFormat("branch"); err == nil {
+		t.Error("unknown --format accepted")
+	}
+}
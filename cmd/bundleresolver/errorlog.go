@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// httpStatusError reports a store answer other than 200.
+type httpStatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *httpStatusError) Error() string {
+	return "status " + e.Status
+}
+
+func statusError(resp *http.Response) error {
+	return &httpStatusError{Code: resp.StatusCode, Status: resp.Status}
+}
+
+// invalidIDError reports an input line no store or plugin accepts.
+type invalidIDError struct {
+	msg string
+}
+
+func (e *invalidIDError) Error() string {
+	return e.msg
+}
+
+// Stages of a lookup an error is attributed to (see errorStage).
+const (
+	stageClassify = "classify" // the line is not an id
+	stageBreaker  = "breaker"  // the store was paused after repeated failures
+	stageNetwork  = "network"  // the request failed or timed out
+	stageHTTP     = "http"     // the store answered with an error status
+	stageParse    = "parse"    // the answer could not be understood
+	stagePlugin   = "plugin"   // the --plugin executable failed
+	stageLookup   = "lookup"   // the store has no such app, or anything else
+)
+
+// errorStage tells at which stage of its lookup err occurred.
+func errorStage(err error) string {
+	var statusErr *httpStatusError
+	var invalidErr *invalidIDError
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var guardErr *responseGuardError
+	switch {
+	case errors.As(err, &invalidErr):
+		return stageClassify
+	case errors.Is(err, errCircuitOpen):
+		return stageBreaker
+	case errors.As(err, &statusErr):
+		return stageHTTP
+	case errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return stageNetwork
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.As(err, &guardErr), strings.Contains(err.Error(), "unable to parse"):
+		return stageParse
+	case strings.HasPrefix(err.Error(), "plugin"):
+		return stagePlugin
+	}
+	return stageLookup
+}
+
+// retryable reports whether err may go away on its own: a network
+// failure, a paused store, or a store answering 429 or 5xx.
+func retryable(err error) bool {
+	switch errorStage(err) {
+	case stageNetwork, stageBreaker:
+		return true
+	case stageHTTP:
+		var statusErr *httpStatusError
+		errors.As(err, &statusErr)
+		return statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= 500
+	}
+	return false
+}
+
+// errorReport is one line of --errors.
+type errorReport struct {
+	Input      string `json:"input"`
+	Stage      string `json:"stage"`
+	Store      string `json:"store"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+	NotFound   bool   `json:"not_found,omitempty"`
+	GeoBlocked bool   `json:"geo_blocked,omitempty"`
+	Message    string `json:"message"`
+}
+
+// errorLog writes the failed lookups of a run as JSON lines. It is called
+// from process's output loop only, so it needs no locking.
+type errorLog struct {
+	enc *json.Encoder
+}
+
+func newErrorLog(w io.Writer) *errorLog {
+	return &errorLog{enc: json.NewEncoder(w)}
+}
+
+func (l *errorLog) add(input string, err error) error {
+	r := errorReport{Input: input, Stage: errorStage(err), Store: platformOf(input), NotFound: isNotFoundError(err), Message: err.Error()}
+	if activePlugin != nil && r.Store == platformUnknown && r.Stage != stageClassify {
+		r.Store = "plugin"
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		r.HTTPStatus = statusErr.Code
+	}
+	var geoErr *geoBlockedError
+	r.GeoBlocked = errors.As(err, &geoErr)
+	return l.enc.Encode(r)
+}
+
+// openFD returns the already open file descriptor fd, as passed down by an
+// orchestrator (e.g. `3>results.tsv`). 0 to 2 are refused: they already are
+// STDIN, STDOUT and STDERR.
+func openFD(fd int) (*os.File, error) {
+	if fd <= 2 {
+		return nil, fmt.Errorf("file descriptor %d is reserved (use 3 or higher)", fd)
+	}
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("file descriptor %d is not open", fd)
+	}
+	return f, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestErrorStage(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{&invalidIDError{"cannot detect platform for \"x\""}, stageClassify},
+		{fmt.Errorf("ios: %w", errCircuitOpen), stageBreaker},
+		{statusError(&http.Response{StatusCode: 503, Status: "503 Service Unavailable"}), stageHTTP},
+		{fmt.Errorf("lookup: %w", context.DeadlineExceeded), stageNetwork},
+		{json.Unmarshal([]byte("{"), &struct{}{}), stageParse},
+		{errors.New("unable to parse app name"), stageParse},
+		{errors.New("plugin: exited"), stagePlugin},
+		{errors.New("app not found"), stageLookup},
+	}
+	for _, c := range cases {
+		if got := errorStage(c.err); got != c.want {
+			t.Errorf("errorStage(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	resolveFunc = func(ctx context.Context, id string) (record, error) {
+		if id == "com.gone.app" {
+			return record{Bundle: id}, statusError(&http.Response{StatusCode: 404, Status: "404 Not Found"})
+		}
+		if id == "com.example.app" {
+			return record{Bundle: id, Name: "App"}, nil
+		}
+		return resolve(ctx, id)
+	}
+
+	var out, errs strings.Builder
+	input := strings.NewReader("com.example.app\ncom.gone.app\nhello\n")
+	opts := options{Fields: []Field{FieldBundle}, SkipErrors: true, Errors: newErrorLog(&errs)}
+	if err := process(input, &out, opts); err != nil {
+		t.Fatalf("process returned error: %v", err)
+	}
+	if out.String() != "com.example.app\n" {
+		t.Errorf("stdout = %q", out.String())
+	}
+	want := `{"input":"com.gone.app","stage":"http","store":"android","http_status":404,"not_found":true,"message":"status 404 Not Found"}
+{"input":"hello","stage":"classify","store":"unknown","message":"cannot detect platform for \"hello\""}
+`
+	if errs.String() != want {
+		t.Errorf("errors =\n%s\nwant\n%s", errs.String(), want)
+	}
+}
+
+// TestOpenFDHelper writes to the descriptor its parent passed as fd 3.
+func TestOpenFDHelper(t *testing.T) {
+	if os.Getenv("GO_WANT_FD_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	f, err := openFD(3)
+	if err != nil {
+		os.Exit(2)
+	}
+	fmt.Fprintln(f, "ok")
+	os.Exit(0)
+}
+
+func TestOpenFD(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inherited descriptors need a Unix-like system")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestOpenFDHelper$")
+	cmd.Env = append(os.Environ(), "GO_WANT_FD_HELPER=1")
+	cmd.ExtraFiles = []*os.File{w}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("helper: %v", err)
+	}
+	if string(out) != "ok\n" {
+		t.Errorf("read %q from fd 3", out)
+	}
+
+	if _, err := openFD(1); err == nil {
+		t.Error("openFD(1) succeeded")
+	}
+	if _, err := openFD(1 << 20); err == nil {
+		t.Error("openFD of a closed descriptor succeeded")
+	}
+}
+
+func TestProcessFailedOut(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	resolveFunc = func(ctx context.Context, id string) (record, error) {
+		if id == "com.gone.app" {
+			return record{Bundle: id}, statusError(&http.Response{StatusCode: 404, Status: "404 Not Found"})
+		}
+		if id == "com.example.app" {
+			return record{Bundle: id, Name: "App"}, nil
+		}
+		return resolve(ctx, id)
+	}
+
+	var out, failed strings.Builder
+	input := strings.NewReader("com.example.app\n\ncom.gone.app\nhello\n")
+	opts := options{Fields: []Field{FieldBundle}, FailedOut: &failed}
+	if err := process(input, &out, opts); err != nil {
+		t.Fatalf("process returned error: %v", err)
+	}
+	if failed.String() != "com.gone.app\nhello\n" {
+		t.Errorf("failed-out = %q", failed.String())
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fallbacks a lookup may take when the direct one fails, recorded in
+// record.Fallbacks, and the confidence each leaves in the result.
+const (
+	// fallbackStorefront: the iOS id was only found in the jp storefront.
+	fallbackStorefront = "jp storefront"
+	// fallbackGuessedStorefront: the iOS bundle id was only found in a
+	// storefront it hints at, with --guess-storefront.
+	fallbackGuessedStorefront = "guessed storefront"
+	// fallbackSearch: the Play page was missing and a store search found the
+	// package under a different spelling.
+	fallbackSearch = "search correction"
+	// fallbackPlugin: the id was resolved by the --plugin executable.
+	fallbackPlugin = "plugin"
+	// fallbackArchive: the stores no longer list the app and --archive
+	// found its store page in the Wayback Machine.
+	fallbackArchive = "archive"
+)
+
+var fallbackConfidence = map[string]float64{
+	fallbackStorefront:        0.9,
+	fallbackGuessedStorefront: 0.9,
+	fallbackSearch:            0.6,
+	fallbackArchive:           0.5,
+}
+
+// lenientConfidence is the confidence of ids only --lenient accepts.
+const lenientConfidence = 0.8
+
+// explainFields are the fields --explain adds.
+var explainFields = []Field{FieldDetection, FieldConfidence}
+
+// detection describes how id was classified: the rule it matched and the
+// platform that rule implies.
+func detection(id string) string {
+	switch {
+	case reIOS.MatchString(id):
+		return "ios: numeric App Store id"
+	case reIOSBundle.MatchString(id):
+		return "ios: bundle identifier"
+	case reAndroid.MatchString(id):
+		return "android: package name"
+	case lenientIDs && reAndroidLenient.MatchString(id):
+		return "android: package name (lenient)"
+	case reAndroidLenient.MatchString(id):
+		return "unknown: package name with a segment not starting with a letter"
+	}
+	return "unknown: neither a numeric id nor a package name"
+}
+
+// detectionValue renders the detection field: the classification of the
+// input line followed by the fallbacks the lookup took.
+func (rec record) detectionValue() string {
+	id := rec.Input
+	if id == "" {
+		id = rec.Bundle
+	}
+	parts := []string{detection(id)}
+	for _, f := range rec.Fallbacks {
+		if f == fallbackSearch {
+			f += " to " + rec.Bundle
+		}
+		parts = append(parts, f)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// confidence scores how surely rec is the app its input meant, from 1 for
+// a direct lookup of a well-formed id down by every fallback taken. It is
+// empty for lookups that found nothing.
+func (rec record) confidence() string {
+	if rec.Name == "" {
+		return ""
+	}
+	c := 1.0
+	id := rec.Input
+	if id == "" {
+		id = rec.Bundle
+	}
+	if !reAndroid.MatchString(id) && reAndroidLenient.MatchString(id) {
+		c *= lenientConfidence
+	}
+	for _, f := range rec.Fallbacks {
+		if fc, ok := fallbackConfidence[f]; ok {
+			c *= fc
+		}
+	}
+	return fmt.Sprintf("%.2f", c)
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestExplainFields(t *testing.T) {
+	originalLenient := lenientIDs
+	defer func() { lenientIDs = originalLenient }()
+	lenientIDs = true
+
+	cases := []struct {
+		rec                   record
+		detection, confidence string
+	}{
+		{record{Input: "123456789", Bundle: "123456789", Name: "App"}, "ios: numeric App Store id", "1.00"},
+		{record{Input: "123456789", Bundle: "123456789", Name: "App", Fallbacks: []string{fallbackStorefront}}, "ios: numeric App Store id; jp storefront", "0.90"},
+		{record{Input: "com.Example.App", Bundle: "com.example.app", Name: "App", Fallbacks: []string{fallbackSearch}}, "android: package name; search correction to com.example.app", "0.60"},
+		{record{Input: "com.1example", Bundle: "com.1example", Name: "App"}, "android: package name (lenient)", "0.80"},
+		{record{Input: "not an id"}, "unknown: neither a numeric id nor a package name", ""},
+	}
+	for _, tc := range cases {
+		if got := tc.rec.value(FieldDetection); got != tc.detection {
+			t.Errorf("detection of %q = %q, want %q", tc.rec.Input, got, tc.detection)
+		}
+		if got := tc.rec.value(FieldConfidence); got != tc.confidence {
+			t.Errorf("confidence of %q = %q, want %q", tc.rec.Input, got, tc.confidence)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// rssEncoder renders records as an RSS 2.0 feed with one item per app:
+// its name as title, its store page as link and its publisher as creator.
+// Failed lookups are titled with their id.
+type rssEncoder struct{}
+
+type rssItem struct {
+	XMLName  xml.Name `xml:"item"`
+	Title    string   `xml:"title"`
+	Link     string   `xml:"link,omitempty"`
+	GUID     *rssGUID `xml:"guid,omitempty"`
+	Creator  string   `xml:"dc:creator,omitempty"`
+	Category string   `xml:"category,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+const rssPreamble = xml.Header + `<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+<title>bundleresolver</title>
+<link>https://github.com/arimura/bundleresolver</link>
+<description>Resolved apps</description>
+`
+
+func (rssEncoder) begin(w io.Writer) error {
+	_, err := io.WriteString(w, rssPreamble)
+	return err
+}
+
+func (rssEncoder) encode(w io.Writer, rec record) error {
+	item := rssItem{Title: rec.Name, Link: rec.URL, Creator: rec.Publisher, Category: rec.Category}
+	if item.Title == "" {
+		item.Title = rec.Bundle
+	}
+	if rec.URL != "" {
+		item.GUID = &rssGUID{IsPermaLink: true, Value: rec.URL}
+	}
+	data, err := xml.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (rssEncoder) end(w io.Writer) error {
+	_, err := io.WriteString(w, "</channel>\n</rss>\n")
+	return err
+}
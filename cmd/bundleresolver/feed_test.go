@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProcessRSS(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() { resolveFunc = originalResolve }()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		if id == "2" {
+			return record{Bundle: id, URL: buildAppStoreURL(id)}, errors.New("not found")
+		}
+		return record{Bundle: id, Name: "Tom & Jerry", Publisher: "Example <Labs>", URL: buildAppStoreURL(id), Category: "Games"}, nil
+	}
+	var out strings.Builder
+	opts := options{Fields: []Field{FieldBundle}, Header: true, Format: "rss"}
+	if err := process(strings.NewReader("1\n\n2\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	var feed struct {
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title   string `xml:"title"`
+				Link    string `xml:"link"`
+				GUID    string `xml:"guid"`
+				Creator string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal([]byte(out.String()), &feed); err != nil {
+		t.Fatalf("invalid feed: %v\n%s", err, out.String())
+	}
+	items := feed.Channel.Items
+	if len(items) != 2 {
+		t.Fatalf("items = %+v, want 2", items)
+	}
+	if items[0].Title != "Tom & Jerry" || items[0].Creator != "Example <Labs>" || items[0].Link != "https://apps.apple.com/app/id1" || items[0].GUID != items[0].Link {
+		t.Errorf("item = %+v", items[0])
+	}
+	if items[1].Title != "2" {
+		t.Errorf("failed lookup title = %q, want its id", items[1].Title)
+	}
+
+	// An empty run still yields a valid feed.
+	out.Reset()
+	feed.Channel.Items = nil
+	if err := process(strings.NewReader(""), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal([]byte(out.String()), &feed); err != nil || len(feed.Channel.Items) != 0 {
+		t.Errorf("empty feed = %q (%v)", out.String(), err)
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// filterExpr is a parsed --filter expression, evaluated per record. The
+// language is small:
+//
+//	expr    = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = operand [ op operand ]
+//	operand = field | "string" | number | "(" expr ")"
+//	op      = "==" | "!=" | "<" | "<=" | ">" | ">=" | "contains" |
+//	          "startswith" | "endswith" | "matches"
+//
+// Fields evaluate to their output value. Ordering operators compare
+// numerically when both sides are numbers and as strings otherwise, and
+// are false if a side is empty; matches takes a regular expression. A lone
+// operand is true unless it is empty or "false".
+type filterExpr struct {
+	eval func(rec record) string
+	// fields lists the fields the expression refers to, which lookups must
+	// fill even if they are not output.
+	fields []Field
+}
+
+// Truth values of boolean subexpressions.
+const (
+	filterTrue  = "true"
+	filterFalse = "false"
+)
+
+func filterBool(b bool) string {
+	if b {
+		return filterTrue
+	}
+	return filterFalse
+}
+
+func filterTruthy(v string) bool {
+	return v != "" && v != filterFalse
+}
+
+// match reports whether rec passes the filter.
+func (e *filterExpr) match(rec record) bool {
+	return filterTruthy(e.eval(rec))
+}
+
+// parseFilter parses a --filter expression.
+func parseFilter(src string) (*filterExpr, error) {
+	tokens, err := lexFilter(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	eval, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at offset %d", t, t.pos)
+	}
+	return &filterExpr{eval: eval, fields: p.fields}, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type filterToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t filterToken) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// filterSymbols are the operators spelled with punctuation, longest first.
+var filterSymbols = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!"}
+
+// filterWordOps are the operators spelled as words.
+var filterWordOps = map[string]bool{"contains": true, "startswith": true, "endswith": true, "matches": true}
+
+func lexFilter(src string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, filterToken{tokLParen, "(", i})
+			i++
+			continue
+		case c == ')':
+			tokens = append(tokens, filterToken{tokRParen, ")", i})
+			i++
+			continue
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			s, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at offset %d: %w", i, err)
+			}
+			tokens = append(tokens, filterToken{tokString, s, i})
+			i = end + 1
+			continue
+		case c == '-' || c == '.' || unicode.IsDigit(c):
+			end := i + 1
+			for end < len(src) && (src[end] == '.' || unicode.IsDigit(rune(src[end]))) {
+				end++
+			}
+			if _, err := strconv.ParseFloat(src[i:end], 64); err != nil {
+				return nil, fmt.Errorf("invalid number %q at offset %d", src[i:end], i)
+			}
+			tokens = append(tokens, filterToken{tokNumber, src[i:end], i})
+			i = end
+			continue
+		case c == '_' || unicode.IsLetter(c):
+			end := i + 1
+			for end < len(src) && (src[end] == '_' || unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end]))) {
+				end++
+			}
+			word := src[i:end]
+			kind := tokIdent
+			if filterWordOps[strings.ToLower(word)] {
+				kind, word = tokOp, strings.ToLower(word)
+			}
+			tokens = append(tokens, filterToken{kind, word, i})
+			i = end
+			continue
+		}
+		matched := false
+		for _, sym := range filterSymbols {
+			if strings.HasPrefix(src[i:], sym) {
+				tokens = append(tokens, filterToken{tokOp, sym, i})
+				i += len(sym)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+		}
+	}
+	return append(tokens, filterToken{kind: tokEOF, pos: len(src)}), nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	fields []Field
+}
+
+type filterEval = func(rec record) string
+
+func (p *filterParser) peek() filterToken { return p.tokens[p.pos] }
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == text
+}
+
+func (p *filterParser) expr() (filterEval, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(rec record) string { return filterBool(filterTruthy(l(rec)) || filterTruthy(right(rec))) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (filterEval, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(rec record) string { return filterBool(filterTruthy(l(rec)) && filterTruthy(right(rec))) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) unary() (filterEval, error) {
+	if p.isOp("!") {
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(rec record) string { return filterBool(!filterTruthy(operand(rec))) }, nil
+	}
+	return p.compare()
+}
+
+func (p *filterParser) compare() (filterEval, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOp || t.text == "&&" || t.text == "||" || t.text == "!" {
+		return left, nil
+	}
+	p.next()
+	rt := p.peek()
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if t.text == "matches" {
+		// Constant patterns are compiled once and checked up front.
+		if rt.kind == tokString {
+			re, err := regexp.Compile(rt.text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern at offset %d: %w", rt.pos, err)
+			}
+			return func(rec record) string { return filterBool(re.MatchString(left(rec))) }, nil
+		}
+		return func(rec record) string {
+			re, err := regexp.Compile(right(rec))
+			return filterBool(err == nil && re.MatchString(left(rec)))
+		}, nil
+	}
+	cmp := filterComparisons[t.text]
+	return func(rec record) string { return filterBool(cmp(left(rec), right(rec))) }, nil
+}
+
+// filterComparisons implements the binary operators other than matches.
+var filterComparisons = map[string]func(a, b string) bool{
+	"==":         func(a, b string) bool { return a == b },
+	"!=":         func(a, b string) bool { return a != b },
+	"<":          ordered(func(c int) bool { return c < 0 }),
+	"<=":         ordered(func(c int) bool { return c <= 0 }),
+	">":          ordered(func(c int) bool { return c > 0 }),
+	">=":         ordered(func(c int) bool { return c >= 0 }),
+	"contains":   strings.Contains,
+	"startswith": strings.HasPrefix,
+	"endswith":   strings.HasSuffix,
+}
+
+// ordered returns an ordering operator, false whenever a side is empty so
+// that records lacking a value match neither < nor >.
+func ordered(holds func(c int) bool) func(a, b string) bool {
+	return func(a, b string) bool {
+		return a != "" && b != "" && holds(compareValues(a, b))
+	}
+}
+
+// compareValues orders a and b numerically if both are numbers, as strings
+// otherwise.
+func compareValues(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+func (p *filterParser) operand() (filterEval, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString, tokNumber:
+		v := t.text
+		return func(record) string { return v }, nil
+	case tokIdent:
+		f := Field(t.text)
+		if _, ok := fieldSet[f]; !ok {
+			if _, ok := (record{}).priceValue(f); !ok {
+				return nil, fmt.Errorf("unknown field %q at offset %d", t.text, t.pos)
+			}
+		}
+		if !hasField(p.fields, f) {
+			p.fields = append(p.fields, f)
+		}
+		return func(rec record) string { return rec.value(f) }, nil
+	case tokLParen:
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("expected \")\" at offset %d, found %s", closing.pos, closing)
+		}
+		return inner, nil
+	}
+	return nil, fmt.Errorf("expected a field, string or number at offset %d, found %s", t.pos, t)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	gmail := record{Bundle: "com.google.android.gm", Name: "Gmail", Publisher: "Google LLC", Price: "Free", Category: "Communication"}
+	pages := record{Bundle: "361309726", Name: "Pages", Publisher: "Apple", Price: "Free", Badges: []string{"Editors' Choice"}, History: []appVersion{{Version: "13.2"}}}
+	cases := []struct {
+		expr        string
+		gmail, page bool
+	}{
+		{`publisher contains "Google" && platform == "android"`, true, false},
+		{`platform == "ios" || name == "Gmail"`, true, true},
+		{`!(platform == "ios")`, true, false},
+		{`name matches "^(?i)g"`, true, false},
+		{`badges`, false, true},
+		{`version >= 13`, false, true},
+		{`version < 9`, false, false},
+		{`bundle startswith "com." && category endswith "tion"`, true, false},
+		{`price != "Free"`, false, false},
+		{`name == "say \"hi\""`, false, false},
+	}
+	for _, tc := range cases {
+		f, err := parseFilter(tc.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%s): %v", tc.expr, err)
+			continue
+		}
+		if got := f.match(gmail); got != tc.gmail {
+			t.Errorf("%s on Gmail = %v, want %v", tc.expr, got, tc.gmail)
+		}
+		if got := f.match(pages); got != tc.page {
+			t.Errorf("%s on Pages = %v, want %v", tc.expr, got, tc.page)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	cases := map[string]string{
+		`publisher contains`:         "expected a field",
+		`nmae == "x"`:                `unknown field "nmae"`,
+		`name == "x`:                 "unterminated string",
+		`(name == "x"`:               `expected ")"`,
+		`name == "x" name`:           "unexpected",
+		`name matches "("`:           "invalid pattern",
+		`name = "x"`:                 "unexpected",
+		`price_us == "$1" || kids #`: "unexpected",
+	}
+	for expr, want := range cases {
+		_, err := parseFilter(expr)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseFilter(%s) = %v, want an error containing %q", expr, err, want)
+		}
+	}
+	f, err := parseFilter(`price_jp == "¥120" && kids`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.fields) != 2 || f.fields[0] != "price_jp" || f.fields[1] != FieldKids {
+		t.Errorf("fields = %v", f.fields)
+	}
+}
+
+func TestProcessFilter(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() { resolveFunc = originalResolve }()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return record{Bundle: id, Name: "App " + id}, nil
+	}
+	filter, err := parseFilter(`platform == "android"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	opts := options{Fields: []Field{FieldBundle, FieldName}, Filter: filter}
+	if err := process(strings.NewReader("123\ncom.example.app\n\ncom.example.other\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := "com.example.app\tApp com.example.app\ncom.example.other\tApp com.example.other\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// freshness tracks how old the responses behind one lookup are: the
+// oldest time --cache-dir last knew one of its entries to be current.
+// Responses fetched from the stores count as of the lookup. It is safe for
+// concurrent use.
+type freshness struct {
+	mu     sync.Mutex
+	oldest time.Time
+}
+
+type freshnessKey struct{}
+
+func withFreshness(ctx context.Context) (context.Context, *freshness) {
+	f := &freshness{}
+	return context.WithValue(ctx, freshnessKey{}, f), f
+}
+
+// noteCachedResponse records that a response of ctx's lookup was served
+// from the cache, current as of validated.
+func noteCachedResponse(ctx context.Context, validated time.Time) {
+	f, _ := ctx.Value(freshnessKey{}).(*freshness)
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.oldest.IsZero() || validated.Before(f.oldest) {
+		f.oldest = validated
+	}
+}
+
+// resolvedAt returns the resolved_at value of a lookup started at started:
+// the time of its oldest cached response, if older.
+func (f *freshness) resolvedAt(started time.Time) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	at := started
+	if !f.oldest.IsZero() && f.oldest.Before(at) {
+		at = f.oldest
+	}
+	return at.UTC().Format(time.RFC3339)
+}
+
+// parseAge reads an age such as --max-age: a Go duration (36h), or a
+// number of days (7d).
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid age %q (want e.g. 7d or 36h)", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q (want e.g. 7d or 36h)", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// appStoreGameCenterFeature is the feature the iTunes lookup lists for apps
+// with Game Center support.
+const appStoreGameCenterFeature = "gameCenter"
+
+// controllerTexts are the leaf texts store pages show for apps that support
+// or require a game controller.
+var controllerTexts = []string{"game controllers", "game controller", "supports game controllers", "game controller required", "controller support", "controller supported"}
+
+// playGamesTexts are the leaf texts of a Play details page for games using
+// Play Games services.
+var playGamesTexts = []string{"google play games", "achievements", "uses google play games"}
+
+// hasLeafText reports whether doc has an element without children whose
+// whole text is one of texts, case-insensitively.
+func hasLeafText(doc *goquery.Document, texts []string) bool {
+	found := false
+	doc.Find("dt, dd, li, button, span, div, p").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if s.Children().Length() > 0 {
+			return true // only look at leaf text, not whole sections
+		}
+		found = slices.Contains(texts, strings.ToLower(strings.Join(strings.Fields(s.Text()), " ")))
+		return !found
+	})
+	return found
+}
+
+// boolValue renders an optional flag: true, false, or empty if unknown.
+func boolValue(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
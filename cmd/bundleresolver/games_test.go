@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestGameFeatureTexts(t *testing.T) {
+	cases := []struct {
+		html                  string
+		playGames, controller bool
+	}{
+		{`<div><span>Google Play Games</span></div><div><span>Controller  support</span></div>`, true, true},
+		{`<dl><dt>Supports</dt><dd>Game Controllers</dd></dl>`, false, true},
+		{`<p>Earn achievements with any game controller!</p>`, false, false},
+	}
+	for _, c := range cases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(c.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hasLeafText(doc, playGamesTexts); got != c.playGames {
+			t.Errorf("play games of %s = %v", c.html, got)
+		}
+		if got := hasLeafText(doc, controllerTexts); got != c.controller {
+			t.Errorf("controller of %s = %v", c.html, got)
+		}
+	}
+}
+
+func TestGameFeatureValues(t *testing.T) {
+	yes, no := true, false
+	rec := record{GameCenter: &yes, Controller: &no}
+	got := []string{rec.value(FieldGameCenter), rec.value(FieldPlayGames), rec.value(FieldController)}
+	if strings.Join(got, ",") != "true,,false" {
+		t.Errorf("values = %q", got)
+	}
+}
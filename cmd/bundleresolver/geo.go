@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Values of the status field: how the lookup of a row went.
+const (
+	rowStatusOK         = "ok"
+	rowStatusNotFound   = "not_found"
+	rowStatusGeoBlocked = "geo_blocked"
+	rowStatusError      = "error"
+)
+
+// playGeoTexts are the phrases of a Play page for an app the store does
+// not offer in the country it is browsed from, lower case.
+var playGeoTexts = []string{
+	"isn't available in your country",
+	"is not available in your country",
+	"not available in your country",
+	"isn't available in your region",
+	"not available in your region",
+}
+
+// geoBlockedError reports an app the store exists but does not offer in
+// the country of the request. Reason is the text the page shows.
+type geoBlockedError struct {
+	Reason string
+}
+
+func (e *geoBlockedError) Error() string {
+	return "app is not available in this country: " + e.Reason
+}
+
+// playGeoBlocked returns the "not available in your country" notice of a
+// Play page, or "" if it has none.
+func playGeoBlocked(doc *goquery.Document) string {
+	var reason string
+	doc.Find("div, span, p, h1, h2").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if s.Children().Length() > 0 {
+			return true // only the notice itself, not the sections around it
+		}
+		text := strings.Join(strings.Fields(s.Text()), " ")
+		lower := strings.ToLower(text)
+		for _, phrase := range playGeoTexts {
+			if strings.Contains(lower, phrase) {
+				reason = text
+				return false
+			}
+		}
+		return true
+	})
+	return reason
+}
+
+// lookupStatus returns the status field of a lookup that ended with err,
+// and the reason of a failure.
+func lookupStatus(err error) (status, reason string) {
+	var geoErr *geoBlockedError
+	switch {
+	case err == nil:
+		return rowStatusOK, ""
+	case errors.As(err, &geoErr):
+		return rowStatusGeoBlocked, geoErr.Reason
+	case isNotFoundError(err):
+		return rowStatusNotFound, err.Error()
+	}
+	return rowStatusError, err.Error()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const sampleGeoBlockedHTML = `<html><head><title>Example App - Apps on Google Play</title></head><body>
+<h1><span>Example App</span></h1>
+<div class="notice"><span>This app isn't available in your country.</span></div>
+</body></html>`
+
+func TestFetchAndroidGeoBlocked(t *testing.T) {
+	originalClient := httpClient
+	defer func() {
+		httpClient = originalClient
+	}()
+	httpClient = &http.Client{Transport: fakeTransport{
+		playStorePageURL("com.example.app"): sampleGeoBlockedHTML,
+	}}
+
+	rec, err := fetchAndroid(context.Background(), "com.example.app")
+	var geoErr *geoBlockedError
+	if !errors.As(err, &geoErr) || geoErr.Reason != "This app isn't available in your country." {
+		t.Fatalf("err = %v, want a geo-blocked error with the page's notice", err)
+	}
+	if rec.Name != "Example App" || isNotFoundError(err) {
+		t.Errorf("rec = %+v, not found = %v; want the name kept and no not-found", rec, isNotFoundError(err))
+	}
+}
+
+func TestProcessStatusFields(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	resolveFunc = func(ctx context.Context, id string) (record, error) {
+		switch id {
+		case "com.blocked.app":
+			return record{Bundle: id}, &geoBlockedError{Reason: "This app isn't available in your country."}
+		case "com.gone.app":
+			return record{Bundle: id}, statusError(&http.Response{StatusCode: 404, Status: "404 Not Found"})
+		case "com.flaky.app":
+			return record{Bundle: id}, errors.New("connection reset")
+		}
+		return record{Bundle: id, Name: "App"}, nil
+	}
+
+	var out, errs strings.Builder
+	input := strings.NewReader("com.example.app\ncom.blocked.app\ncom.gone.app\ncom.flaky.app\n\n")
+	opts := options{Fields: []Field{FieldBundle, FieldStatus, FieldStatusReason}, Errors: newErrorLog(&errs)}
+	if err := process(input, &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "com.example.app\tok\t\n" +
+		"com.blocked.app\tgeo_blocked\tThis app isn't available in your country.\n" +
+		"com.gone.app\tnot_found\tstatus 404 Not Found\n" +
+		"com.flaky.app\terror\tconnection reset\n" +
+		"\t\t\n"
+	if out.String() != want {
+		t.Errorf("output =\n%q\nwant\n%q", out.String(), want)
+	}
+	if !strings.Contains(errs.String(), `"input":"com.blocked.app","stage":"lookup","store":"android","geo_blocked":true`) {
+		t.Errorf("errors = %s, want the geo-blocked app flagged", errs.String())
+	}
+}
+
+func TestPlayGeoBlocked(t *testing.T) {
+	for html, want := range map[string]string{
+		sampleGeoBlockedHTML: "This app isn't available in your country.",
+		`<div><p>This item is not available in your region</p></div>`: "This item is not available in your region",
+		sampleDataSafetyHTML: "",
+	} {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := playGeoBlocked(doc); got != want {
+			t.Errorf("playGeoBlocked = %q, want %q", got, want)
+		}
+	}
+}
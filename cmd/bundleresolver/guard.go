@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// defaultMaxBodySize is the default --max-body-size. Store pages are a
+// few megabytes at most.
+const defaultMaxBodySize = "32MB"
+
+// responseGuardError reports a response refused before parsing: too large,
+// or not the kind of content the request asked for. A misbehaving proxy or
+// a captive portal answers like that.
+type responseGuardError struct {
+	msg string
+}
+
+func (e *responseGuardError) Error() string {
+	return e.msg
+}
+
+// guardTransport refuses responses whose body exceeds maxBody bytes, as
+// announced by Content-Length or once that much has been read.
+type guardTransport struct {
+	base    http.RoundTripper
+	maxBody int64
+}
+
+func (t *guardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || t.maxBody <= 0 {
+		return resp, err
+	}
+	if resp.ContentLength > t.maxBody {
+		resp.Body.Close()
+		return nil, t.tooLarge(req)
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, left: t.maxBody, err: t.tooLarge(req)}
+	return resp, nil
+}
+
+func (t *guardTransport) tooLarge(req *http.Request) error {
+	return &responseGuardError{msg: fmt.Sprintf("response of %s is larger than --max-body-size %d bytes", req.URL.Redacted(), t.maxBody)}
+}
+
+// limitedBody fails with err once more than left bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	left int64
+	err  error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left < 0 {
+		return 0, b.err
+	}
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.left {
+		n, b.left = int(b.left), -1
+		return n, b.err
+	}
+	b.left -= int64(n)
+	return n, err
+}
+
+// Media types accepted from the store endpoints; "+json" matches any
+// structured JSON suffix. The iTunes APIs answer JSON as text/javascript,
+// and some as text/plain.
+var (
+	htmlTypes = []string{"text/html", "application/xhtml+xml"}
+	jsonTypes = []string{"application/json", "+json", "text/javascript", "application/javascript", "application/x-javascript", "text/json", "text/plain"}
+)
+
+// checkContentType returns a *responseGuardError unless resp is of one of
+// the media types want. A response without Content-Type is let through.
+func checkContentType(resp *http.Response, want []string) error {
+	header := resp.Header.Get("Content-Type")
+	if header == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err == nil {
+		for _, w := range want {
+			if mediaType == w || strings.HasPrefix(w, "+") && strings.HasSuffix(mediaType, w) {
+				return nil
+			}
+		}
+	}
+	url := "the store"
+	if resp.Request != nil {
+		url = resp.Request.URL.Redacted()
+	}
+	return &responseGuardError{msg: fmt.Sprintf("unexpected content type %q from %s", header, url)}
+}
+
+// parseHTMLResponse parses the store page of resp after checking it is HTML.
+func parseHTMLResponse(resp *http.Response) (*goquery.Document, error) {
+	if err := checkContentType(resp, htmlTypes); err != nil {
+		return nil, err
+	}
+	return goquery.NewDocumentFromReader(resp.Body)
+}
+
+// decodeJSONResponse decodes the store API answer of resp into v after
+// checking it is JSON.
+func decodeJSONResponse(resp *http.Response, v any) error {
+	if err := checkContentType(resp, jsonTypes); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
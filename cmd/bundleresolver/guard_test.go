@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGuardTransportMaxBody(t *testing.T) {
+	client := &http.Client{Transport: &guardTransport{base: fakeTransport{
+		"https://play.google.com/fits":  strings.Repeat("x", 100),
+		"https://play.google.com/large": strings.Repeat("x", 101),
+	}, maxBody: 100}}
+	for path, wantErr := range map[string]bool{"/fits": false, "/large": true} {
+		resp, err := client.Get("https://play.google.com" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		var guardErr *responseGuardError
+		if got := errors.As(err, &guardErr); got != wantErr || len(b) != 100 {
+			t.Errorf("%s: read %d bytes, err %v; want 100 bytes and error %v", path, len(b), err, wantErr)
+		}
+		if wantErr && errorStage(err) != stageParse {
+			t.Errorf("%s: stage %q, want parse", path, errorStage(err))
+		}
+	}
+}
+
+func TestGuardTransportContentLength(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, ContentLength: 1 << 30, Body: http.NoBody, Request: req}, nil
+	})
+	_, err := (&http.Client{Transport: &guardTransport{base: base, maxBody: 1 << 20}}).Get("https://play.google.com/huge")
+	if err == nil || !strings.Contains(err.Error(), "larger than --max-body-size 1048576 bytes") {
+		t.Errorf("err = %v, want the response refused before reading", err)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCheckContentType(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		want        []string
+		ok          bool
+	}{
+		{"text/html; charset=utf-8", htmlTypes, true},
+		{"", htmlTypes, true},
+		{"application/json", htmlTypes, false},
+		{"image/png", htmlTypes, false},
+		{"text/javascript; charset=utf-8", jsonTypes, true},
+		{"application/vnd.api+json", jsonTypes, true},
+		{"text/html", jsonTypes, false}, // a captive portal
+		{"not a media type", jsonTypes, false},
+	} {
+		resp := &http.Response{Header: http.Header{"Content-Type": {tc.contentType}}}
+		if err := checkContentType(resp, tc.want); (err == nil) != tc.ok {
+			t.Errorf("checkContentType(%q, %v) = %v", tc.contentType, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// appVersion is one release of an iOS app.
+type appVersion struct {
+	Version string `json:"version"`
+	Date    string `json:"date,omitempty"` // YYYY-MM-DD
+	Notes   string `json:"notes,omitempty"`
+}
+
+// historyRequested makes iOS lookups fetch the full version history from the
+// App Store page (--history) instead of only the current version.
+var historyRequested bool
+
+// parseVersionHistory extracts the releases listed on an App Store page,
+// newest first. It reads the "Version History" list and falls back to the
+// data embedded for the page's scripts, returning nil if neither is there.
+func parseVersionHistory(doc *goquery.Document) []appVersion {
+	var versions []appVersion
+	doc.Find(".version-history__item").Each(func(_ int, item *goquery.Selection) {
+		v := appVersion{Version: strings.TrimSpace(item.Find(".version-history__item__version-number").First().Text())}
+		if v.Version == "" {
+			return
+		}
+		date, _ := item.Find("time").First().Attr("datetime")
+		v.Date = releaseDate(date)
+		v.Notes = strings.TrimSpace(item.Find(".version-history__item__release-notes, .we-truncate").First().Text())
+		versions = append(versions, v)
+	})
+	if len(versions) > 0 {
+		return versions
+	}
+	doc.Find("script[type='fastboot/shoebox'], script[type='application/json']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		versions = embeddedVersionHistory(s.Text())
+		return versions == nil
+	})
+	return versions
+}
+
+// embeddedVersionHistory looks for a versionHistory list anywhere in a JSON
+// document, including JSON documents held in its string values (as in the
+// page's shoebox cache).
+func embeddedVersionHistory(data string) []appVersion {
+	if !strings.Contains(data, "versionHistory") {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return nil
+	}
+	var found []appVersion
+	var walk func(v any) bool
+	walk = func(v any) bool {
+		switch v := v.(type) {
+		case map[string]any:
+			if list, ok := v["versionHistory"].([]any); ok {
+				for _, e := range list {
+					m, _ := e.(map[string]any)
+					version, _ := m["versionDisplay"].(string)
+					if version == "" {
+						continue
+					}
+					date, _ := m["releaseDate"].(string)
+					notes, _ := m["releaseNotes"].(string)
+					found = append(found, appVersion{Version: version, Date: releaseDate(date), Notes: strings.TrimSpace(notes)})
+				}
+				return len(found) > 0
+			}
+			for _, e := range v {
+				if walk(e) {
+					return true
+				}
+			}
+		case []any:
+			for _, e := range v {
+				if walk(e) {
+					return true
+				}
+			}
+		case string:
+			found = embeddedVersionHistory(v)
+			return found != nil
+		}
+		return false
+	}
+	walk(v)
+	return found
+}
+
+// releaseDate shortens an ISO 8601 timestamp to its date.
+func releaseDate(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > len("2006-01-02") && s[len("2006-01-02")] == 'T' {
+		return s[:len("2006-01-02")]
+	}
+	return s
+}
+
+// versionValue renders the version field f of the newest release in
+// versions.
+func versionValue(versions []appVersion, f Field) string {
+	if len(versions) == 0 {
+		return ""
+	}
+	switch f {
+	case FieldVersion:
+		return versions[0].Version
+	case FieldVersionDate:
+		return versions[0].Date
+	case FieldReleaseNotes:
+		return versions[0].Notes
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const sampleVersionHistoryHTML = `<html><body>
+<div class="version-history"><ul>
+<li class="version-history__item">
+	<h4 class="version-history__item__version-number">2.1.0</h4>
+	<time datetime="2024-04-02T00:00:00.000Z">Apr 2, 2024</time>
+	<div class="we-truncate"><p>Dark mode.</p></div>
+</li>
+<li class="version-history__item">
+	<h4 class="version-history__item__version-number">2.0.3</h4>
+	<time datetime="2024-02-20T00:00:00.000Z">Feb 20, 2024</time>
+	<div class="we-truncate"><p>Bug fixes.</p></div>
+</li>
+</ul></div>
+</body></html>`
+
+const sampleShoeboxHTML = `<html><body>
+<script type="fastboot/shoebox" id="shoebox-media-api-cache-apps">{"apps.1":"{\"d\":[{\"attributes\":{\"platformAttributes\":{\"ios\":{\"versionHistory\":[{\"versionDisplay\":\"3.0\",\"releaseDate\":\"2024-05-01\",\"releaseNotes\":\"New look.\"}]}}}}]}"}</script>
+</body></html>`
+
+func TestParseVersionHistory(t *testing.T) {
+	cases := []struct {
+		name, html string
+		want       []appVersion
+	}{
+		{"list", sampleVersionHistoryHTML, []appVersion{
+			{Version: "2.1.0", Date: "2024-04-02", Notes: "Dark mode."},
+			{Version: "2.0.3", Date: "2024-02-20", Notes: "Bug fixes."},
+		}},
+		{"shoebox", sampleShoeboxHTML, []appVersion{{Version: "3.0", Date: "2024-05-01", Notes: "New look."}}},
+		{"none", "<html><body><p>nothing</p></body></html>", nil},
+	}
+	for _, tc := range cases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(tc.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parseVersionHistory(doc); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: history = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestProcessHistoryRows(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		rec := record{Bundle: id}
+		if id == "1" {
+			rec.History = []appVersion{{Version: "2.0", Date: "2024-04-02"}, {Version: "1.0", Date: "2024-01-10"}}
+		}
+		return rec, nil
+	}
+	var out strings.Builder
+	fields := []Field{FieldBundle, FieldVersion, FieldVersionDate}
+	if err := process(strings.NewReader("1\ncom.example.app\n"), &out, options{Fields: fields, History: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\t2.0\t2024-04-02\n1\t1.0\t2024-01-10\ncom.example.app\t\t\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
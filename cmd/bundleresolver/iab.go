@@ -0,0 +1,109 @@
+package main
+
+import "strings"
+
+// IAB Content Taxonomy v3 tier-1 ids the store categories map to.
+const (
+	iabAutomotive      = "1"
+	iabBooks           = "42"
+	iabBusinessFinance = "52"
+	iabEducation       = "132"
+	iabEvents          = "150"
+	iabFamily          = "186"
+	iabFineArt         = "201"
+	iabFoodDrink       = "210"
+	iabHealthyLiving   = "223"
+	iabHobbies         = "239"
+	iabHomeGarden      = "274"
+	iabMedicalHealth   = "286"
+	iabMusicAudio      = "338"
+	iabNewsPolitics    = "379"
+	iabPersonalFinance = "391"
+	iabPopCulture      = "432"
+	iabShopping        = "473"
+	iabSports          = "483"
+	iabStyleFashion    = "552"
+	iabTechnology      = "596"
+	iabTravel          = "653"
+	iabVideoGaming     = "680"
+)
+
+// iabByAppStoreGenre maps App Store genre ids to IAB ids.
+var iabByAppStoreGenre = map[string]string{
+	"6000": iabBusinessFinance, // Business
+	"6001": iabNewsPolitics,    // Weather
+	"6002": iabTechnology,      // Utilities
+	"6003": iabTravel,          // Travel
+	"6004": iabSports,          // Sports
+	"6005": iabTechnology,      // Social Networking
+	"6006": iabEducation,       // Reference
+	"6007": iabTechnology,      // Productivity
+	"6008": iabHobbies,         // Photo & Video
+	"6009": iabNewsPolitics,    // News
+	"6010": iabTravel,          // Navigation
+	"6011": iabMusicAudio,      // Music
+	"6012": iabHobbies,         // Lifestyle
+	"6013": iabHealthyLiving,   // Health & Fitness
+	"6014": iabVideoGaming,     // Games
+	"6015": iabPersonalFinance, // Finance
+	"6016": iabPopCulture,      // Entertainment
+	"6017": iabEducation,       // Education
+	"6018": iabBooks,           // Books
+	"6020": iabMedicalHealth,   // Medical
+	"6021": iabNewsPolitics,    // Magazines & Newspapers
+	"6023": iabFoodDrink,       // Food & Drink
+	"6024": iabShopping,        // Shopping
+	"6025": iabPopCulture,      // Stickers
+	"6026": iabTechnology,      // Developer Tools
+	"6027": iabFineArt,         // Graphics & Design
+}
+
+// iabByPlayCategory maps Play category ids to IAB ids. Every GAME_* category
+// maps to Video Gaming.
+var iabByPlayCategory = map[string]string{
+	"ART_AND_DESIGN":      iabFineArt,
+	"AUTO_AND_VEHICLES":   iabAutomotive,
+	"BEAUTY":              iabStyleFashion,
+	"BOOKS_AND_REFERENCE": iabBooks,
+	"BUSINESS":            iabBusinessFinance,
+	"COMICS":              iabBooks,
+	"COMMUNICATION":       iabTechnology,
+	"DATING":              iabFamily,
+	"EDUCATION":           iabEducation,
+	"ENTERTAINMENT":       iabPopCulture,
+	"EVENTS":              iabEvents,
+	"FAMILY":              iabFamily,
+	"FINANCE":             iabPersonalFinance,
+	"FOOD_AND_DRINK":      iabFoodDrink,
+	"HEALTH_AND_FITNESS":  iabHealthyLiving,
+	"HOUSE_AND_HOME":      iabHomeGarden,
+	"LIBRARIES_AND_DEMO":  iabTechnology,
+	"LIFESTYLE":           iabHobbies,
+	"MAPS_AND_NAVIGATION": iabTravel,
+	"MEDICAL":             iabMedicalHealth,
+	"MUSIC_AND_AUDIO":     iabMusicAudio,
+	"NEWS_AND_MAGAZINES":  iabNewsPolitics,
+	"PARENTING":           iabFamily,
+	"PERSONALIZATION":     iabTechnology,
+	"PHOTOGRAPHY":         iabHobbies,
+	"PRODUCTIVITY":        iabTechnology,
+	"SHOPPING":            iabShopping,
+	"SOCIAL":              iabTechnology,
+	"SPORTS":              iabSports,
+	"TOOLS":               iabTechnology,
+	"TRAVEL_AND_LOCAL":    iabTravel,
+	"VIDEO_PLAYERS":       iabTechnology,
+	"WEATHER":             iabNewsPolitics,
+}
+
+// iabCategory returns the IAB id of a store category id, or "" if the
+// category is unknown.
+func iabCategory(platform, categoryID string) string {
+	if platform == platformIOS {
+		return iabByAppStoreGenre[categoryID]
+	}
+	if strings.HasPrefix(categoryID, "GAME") {
+		return iabVideoGaming
+	}
+	return iabByPlayCategory[categoryID]
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestIABCategory(t *testing.T) {
+	cases := []struct {
+		platform, id, want string
+	}{
+		{platformIOS, "6014", iabVideoGaming},
+		{platformIOS, "6015", iabPersonalFinance},
+		{platformIOS, "9999", ""},
+		{platformAndroid, "GAME_PUZZLE", iabVideoGaming},
+		{platformAndroid, "TRAVEL_AND_LOCAL", iabTravel},
+		{platformAndroid, "", ""},
+	}
+	for _, tc := range cases {
+		if got := iabCategory(tc.platform, tc.id); got != tc.want {
+			t.Errorf("iabCategory(%s, %q) = %q, want %q", tc.platform, tc.id, got, tc.want)
+		}
+	}
+	rec := record{Bundle: "com.example.game", CategoryID: "GAME_ACTION"}
+	if got := rec.value(FieldIABCategory); got != "680" {
+		t.Errorf("iab_category = %q, want 680", got)
+	}
+}
+
+func TestPlayCategory(t *testing.T) {
+	html := `<div><a href="/store/apps/category/GAME_PUZZLE?hl=en"><span>Puzzle</span></a></div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, id := playCategory(doc); name != "Puzzle" || id != "GAME_PUZZLE" {
+		t.Errorf("category = %q, %q", name, id)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// iapItem is an in-app purchase an App Store page lists.
+type iapItem struct {
+	Name  string `json:"name"`
+	Price string `json:"price"`
+}
+
+// parseInAppPurchases extracts the "In-App Purchases" list of an App Store
+// page, most popular first. The page lists at most ten items, so apps
+// with more show only their top ten.
+func parseInAppPurchases(doc *goquery.Document) []iapItem {
+	var items []iapItem
+	doc.Find(".list-with-numbers__item").Each(func(_ int, s *goquery.Selection) {
+		name := strings.Join(strings.Fields(s.Find(".list-with-numbers__item__title, .truncate-single-line").First().Text()), " ")
+		price := strings.TrimSpace(s.Find(".list-with-numbers__item__price").First().Text())
+		if name != "" {
+			items = append(items, iapItem{Name: name, Price: price})
+		}
+	})
+	return items
+}
+
+// iapValue renders the iap_items field: "name (price)" per item, joined
+// with "; " since names often contain commas.
+func iapValue(items []iapItem) string {
+	parts := make([]string, len(items))
+	for i, it := range items {
+		parts[i] = it.Name
+		if it.Price != "" {
+			parts[i] += " (" + it.Price + ")"
+		}
+	}
+	return strings.Join(parts, "; ")
+}
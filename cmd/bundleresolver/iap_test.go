@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseInAppPurchases(t *testing.T) {
+	html := `<dl><dt>In-App Purchases</dt><dd><ol class="list-with-numbers">` +
+		`<li class="list-with-numbers__item"><span class="truncate-single-line">Gems, small  pack</span><span class="list-with-numbers__item__price">$0.99</span></li>` +
+		`<li class="list-with-numbers__item"><span class="truncate-single-line">Pro</span><span class="list-with-numbers__item__price">$4.99</span></li>` +
+		`</ol></dd></dl>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := record{InAppPurchases: parseInAppPurchases(doc)}
+	if got, want := rec.value(FieldIAPItems), "Gems, small pack ($0.99); Pro ($4.99)"; got != want {
+		t.Errorf("iap_items = %q, want %q", got, want)
+	}
+}
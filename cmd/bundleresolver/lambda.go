@@ -0,0 +1,120 @@
+//go:build lambda
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// lambdaEvent is the payload accepted by the Lambda handler. IDs may be given
+// inline, or fetched from InputURL (e.g. a presigned S3 GET URL) as one id per
+// line. When OutputURL is set (e.g. a presigned S3 PUT URL) the rendered
+// TSV/CSV output is uploaded there instead of being returned inline.
+type lambdaEvent struct {
+	IDs        []string `json:"ids"`
+	InputURL   string   `json:"input_url"`
+	OutputURL  string   `json:"output_url"`
+	Fields     string   `json:"fields"`
+	CSV        bool     `json:"csv"`
+	Header     *bool    `json:"header"`
+	SkipErrors bool     `json:"skip_errors"`
+}
+
+type lambdaResponse struct {
+	resolveResponse
+	Stored int `json:"stored,omitempty"`
+}
+
+func init() {
+	runLambda = func() { lambda.Start(handleLambda) }
+}
+
+func handleLambda(ctx context.Context, ev lambdaEvent) (lambdaResponse, error) {
+	ids := ev.IDs
+	if ev.InputURL != "" {
+		fetched, err := fetchLambdaInput(ctx, ev.InputURL)
+		if err != nil {
+			return lambdaResponse{}, fmt.Errorf("fetch input: %w", err)
+		}
+		ids = append(ids, fetched...)
+	}
+	if len(ids) == 0 {
+		return lambdaResponse{}, errors.New("no ids in event")
+	}
+
+	// Warm invocations share globals: every event selects its fields, which
+	// decide the extra pages fetched for inline records too.
+	fieldsCSV := ev.Fields
+	if fieldsCSV == "" {
+		fieldsCSV = defaultFields
+	}
+	fields, err := parseFields(fieldsCSV)
+	if err != nil {
+		return lambdaResponse{}, fmt.Errorf("invalid fields: %w", err)
+	}
+	selectFields(fields)
+	if ev.OutputURL != "" {
+		header := true
+		if ev.Header != nil {
+			header = *ev.Header
+		}
+		var buf bytes.Buffer
+		in := strings.NewReader(strings.Join(ids, "\n") + "\n")
+		sum := newSummary()
+		opts := options{Fields: fields, Header: header, SkipErrors: ev.SkipErrors, CSV: ev.CSV, Summary: sum}
+		if err := process(in, &buf, opts); err != nil {
+			return lambdaResponse{}, err
+		}
+		if err := storeLambdaOutput(ctx, ev.OutputURL, &buf); err != nil {
+			return lambdaResponse{}, fmt.Errorf("store output: %w", err)
+		}
+		// Blank lines and failed lookups are not stored records.
+		return lambdaResponse{Stored: sum.Resolved}, nil
+	}
+
+	return lambdaResponse{resolveResponse: resolveAll(ctx, ids, ev.SkipErrors, "")}, nil
+}
+
+func fetchLambdaInput(ctx context.Context, u string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(string(body)), "\n"), nil
+}
+
+func storeLambdaOutput(ctx context.Context, u string, body *bytes.Buffer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, body)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+//go:build lambda
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHandleLambda(t *testing.T) {
+	originalResolve, originalClient, originalSelected := resolveFunc, httpClient, selectedFields
+	defer func() {
+		resolveFunc, httpClient, selectedFields = originalResolve, originalClient, originalSelected
+	}()
+	resolveFunc = func(ctx context.Context, id string) (record, error) {
+		if id == "com.gone.app" {
+			return record{Bundle: id}, errors.New("404 Not Found")
+		}
+		rec := record{Bundle: id, Name: "App " + id, Publisher: "Dev"}
+		if selectedFields[FieldTargetSDK] {
+			rec.TargetSDK = 34
+		}
+		return rec, nil
+	}
+	var stored string
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.String() {
+		case "https://bucket.example/in":
+			body := "com.a.app\n\ncom.gone.app\ncom.b.app\n"
+			return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+		case "https://bucket.example/out":
+			data, _ := io.ReadAll(req.Body)
+			stored = string(data)
+			return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: http.NoBody, Request: req}, nil
+		}
+		return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: http.NoBody, Request: req}, nil
+	})}
+
+	// Inline records are looked up for the event's fields.
+	resp, err := handleLambda(context.Background(), lambdaEvent{IDs: []string{"com.a.app", " "}, Fields: "bundle,target_sdk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Records) != 1 || resp.Records[0].TargetSDK != 34 {
+		t.Errorf("inline records = %+v, want one with target_sdk", resp.Records)
+	}
+
+	// Stored counts the resolved rows, not the blank line or the failure.
+	no := false
+	resp, err = handleLambda(context.Background(), lambdaEvent{InputURL: "https://bucket.example/in", OutputURL: "https://bucket.example/out", Fields: "bundle,name", Header: &no, SkipErrors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Stored != 2 {
+		t.Errorf("stored = %d, want 2", resp.Stored)
+	}
+	// As in the CLI, a blank line keeps its empty row.
+	if want := "com.a.app\tApp com.a.app\n\t\ncom.b.app\tApp com.b.app\n"; stored != want {
+		t.Errorf("uploaded %q, want %q", stored, want)
+	}
+
+	if _, err := handleLambda(context.Background(), lambdaEvent{IDs: []string{"com.a.app"}, Fields: "bundle,nope"}); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// scriptLanguages are the languages told apart by their script alone, in
+// the order ties between scripts are broken.
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	lang   string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Thai, "th"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Bengali, "bn"},
+	{unicode.Tamil, "ta"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+	{unicode.Latin, "en"},
+}
+
+// latinLanguages are the languages told apart within the Latin script, in
+// the order ties between them are broken.
+var latinLanguages = []string{"en", "es", "pt", "fr", "it", "de", "vi", "tr", "pl"}
+
+// latinLetters are the accented letters of the Latin-script languages, by
+// how much each tells of the language: distinctive letters hardly any
+// other language uses (ñ, ã), frequent letters it shares with others (é,
+// à), and letters it only has in a few words. A name scores every
+// language whose letters it has, so that a letter several share is
+// decided by the others and by the words.
+var latinLetters = map[string]struct{ distinctive, frequent, possible string }{
+	"vi": {"ơưđạảấầẩẫậắằẳẵặẹẻẽếềểễệỉịọỏốồổỗộớờởỡợụủứừửữựỳỵỷỹ", "", "àáâãèéêìíòóôõùúý"},
+	"tr": {"ğış", "çöü", ""},
+	"pl": {"łąęśźżńć", "", "ó"},
+	"de": {"ß", "äöü", ""},
+	"es": {"ñ¿¡", "áéíóú", "ü"},
+	"pt": {"ãõ", "çêáâô", "éíóúà"},
+	"fr": {"œèëîïûùÿ", "éçàêâ", "ô"},
+	"it": {"ìò", "àèù", "éíóî"},
+}
+
+// Scores of a distinctive, frequent and possible letter, of an accented
+// vowel ending a word and of a common word.
+const (
+	distinctiveLetterScore = 3
+	frequentLetterScore    = 2
+	possibleLetterScore    = 1
+	wordEndingScore        = 2
+	languageWordScore      = 3
+)
+
+// wordEndings are the accented vowels that end words of one language far
+// more than of the others: Italian's stressed vowels (città, più, caffè)
+// and Portuguese's circumflex (você, avô).
+var wordEndings = map[rune]string{
+	'à': "it", 'è': "it", 'ì': "it", 'ò': "it", 'ù': "it",
+	'ê': "pt", 'ô': "pt",
+}
+
+// languageWords are common words of the languages, and of their app names
+// in particular, for the names written in plain ASCII, which only give
+// their language away through their words.
+var languageWords = map[string][]string{
+	"en": {"the", "of", "and", "for", "with", "my", "your"},
+	"es": {"el", "los", "las", "y", "del", "con", "para", "mi", "juegos", "noticias", "tiempo"},
+	"fr": {"le", "les", "des", "et", "du", "pour", "avec", "mon", "jeux", "météo", "actualités"},
+	"de": {"der", "die", "das", "und", "mit", "dein", "mein", "spiele", "nachrichten", "wetter"},
+	"pt": {"o", "os", "do", "da", "dos", "das", "com", "meu", "você", "jogos", "notícias"},
+	"it": {"il", "gli", "della", "di", "per", "mio", "giochi", "notizie", "meteo", "città"},
+}
+
+// nameLanguage guesses the ISO 639-1 language of an app name from its
+// letters: the script most of them are in, kana making Chinese characters
+// Japanese, Cyrillic letters only Ukrainian has making it Ukrainian, and
+// the Persian forms of Arabic letters (پ, ک, ی) Persian. A Latin-script
+// name is told by its accented letters and common words together (see
+// latinLanguage); a brand name with neither is English, as most store
+// names are. Names without letters have none.
+func nameLanguage(name string) string {
+	counts := map[string]int{}
+	kana := false
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if unicode.In(r, unicode.Hiragana, unicode.Katakana) {
+			kana = true
+			continue
+		}
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.script, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+	if kana {
+		return "ja"
+	}
+	lang, most := "", 0
+	for _, s := range scriptLanguages {
+		if counts[s.lang] > most {
+			lang, most = s.lang, counts[s.lang]
+		}
+	}
+	lower := strings.ToLower(name)
+	switch lang {
+	case "ru":
+		if strings.ContainsAny(lower, "іїєґ") {
+			return "uk"
+		}
+	case "ar":
+		if strings.ContainsAny(lower, "پچژگکی") {
+			return "fa"
+		}
+	case "en":
+		return latinLanguage(lower)
+	}
+	return lang
+}
+
+// latinLanguage scores the languages a Latin-script name may be in by its
+// accented letters, the accented vowels ending its words and its common
+// words together, and returns the best. Ties go to the language first in
+// latinLanguages, or to English between names of plain ASCII words.
+func latinLanguage(lower string) string {
+	scores := map[string]int{}
+	accented := false
+	for _, r := range lower {
+		for lang, l := range latinLetters {
+			switch {
+			case strings.ContainsRune(l.distinctive, r):
+				scores[lang] += distinctiveLetterScore
+			case strings.ContainsRune(l.frequent, r):
+				scores[lang] += frequentLetterScore
+			case strings.ContainsRune(l.possible, r):
+				scores[lang] += possibleLetterScore
+			default:
+				continue
+			}
+			accented = true
+		}
+	}
+	words := strings.FieldsFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		last, _ := utf8.DecodeLastRuneInString(w)
+		if lang, ok := wordEndings[last]; ok && len([]rune(w)) > 1 {
+			scores[lang] += wordEndingScore
+		}
+		for lang, common := range languageWords {
+			if containsString(common, w) {
+				scores[lang] += languageWordScore
+			}
+		}
+	}
+	best, most, tie := "en", 0, false
+	for _, lang := range latinLanguages {
+		switch n := scores[lang]; {
+		case n > most:
+			best, most, tie = lang, n, false
+		case n == most && n > 0:
+			tie = true
+		}
+	}
+	if tie && !accented {
+		return "en"
+	}
+	return best
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestNameLanguage(t *testing.T) {
+	for name, want := range map[string]string{
+		"":                         "",
+		"2048":                     "",
+		"Candy Crush Saga":         "en",
+		"パズル&ドラゴンズ":                "ja",
+		"原神":                       "zh",
+		"쿠키런: 킹덤":                  "ko",
+		"ВКонтакте":                "ru",
+		"Дія":                      "uk",
+		"Trò chơi Đố vui":          "vi",
+		"Süper Lig Canlı":          "tr",
+		"Die Sendung mit der Maus": "de",
+		"El Tiempo para Android":   "es",
+		"Météo-France":             "fr",
+		"Le Monde et vous":         "fr",
+		"Teléfono":                 "es",
+		"Città Metropolitana":      "it",
+		"Você Sabia":               "pt",
+		"Das Boot":                 "en",
+		"ดูดวง":                    "th",
+		"دیجی‌کالا":                "fa",
+	} {
+		if got := nameLanguage(name); got != want {
+			t.Errorf("nameLanguage(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
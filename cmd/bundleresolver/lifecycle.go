@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Lifecycle states of a Play listing (FieldLifecycle).
+const (
+	lifecycleReleased        = "released"
+	lifecyclePreRegistration = "pre_registration"
+	lifecycleEarlyAccess     = "early_access"
+)
+
+// playLifecycle tells from a Play details page whether the app is released,
+// open for pre-registration (not installable yet) or in early access (an
+// unreleased build open to testers). Pre-registration pages show a
+// "Pre-register" button instead of "Install", early access ones an "Early
+// access" or "Unreleased" notice.
+func playLifecycle(doc *goquery.Document) string {
+	state := lifecycleReleased
+	doc.Find("button, [role='button'], span, div").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if s.Children().Length() > 0 {
+			return true // only look at leaf text, not whole sections
+		}
+		switch text := strings.ToLower(strings.TrimSpace(s.Text())); text {
+		case "pre-register", "pre-registration", "pre-registered":
+			state = lifecyclePreRegistration
+			return false
+		case "early access", "unreleased":
+			state = lifecycleEarlyAccess
+		}
+		return true
+	})
+	return state
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestPlayLifecycle(t *testing.T) {
+	cases := map[string]string{
+		`<h1><span>Example</span></h1><button><span>Install</span></button>`:                                     lifecycleReleased,
+		`<h1><span>Example</span></h1><button><span>Pre-register</span></button>`:                                lifecyclePreRegistration,
+		`<h1><span>Example</span></h1><div><span>Early access</span></div><button><span>Install</span></button>`: lifecycleEarlyAccess,
+		`<div><span>Unreleased</span></div><button><span>Pre-register</span></button>`:                           lifecyclePreRegistration,
+		`<p>Join early access to read the reviews</p>`:                                                           lifecycleReleased,
+	}
+	for html, want := range cases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := playLifecycle(doc); got != want {
+			t.Errorf("playLifecycle(%s) = %q, want %q", html, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// storeLimiter bounds the lookups of one store: at most concurrency at a
+// time and at most rate started per second. Zero leaves either unlimited,
+// and a nil limiter allows everything. With a jitter, each gap between
+// starts is drawn from interval ± jitter × interval instead, so a run keeps
+// its average rate without a fixed, bot-like cadence.
+type storeLimiter struct {
+	slots    chan struct{} // nil without a concurrency limit
+	interval time.Duration // between lookup starts; 0 without a rate limit
+	jitter   float64       // 0 to 1
+	rand     func() float64
+
+	mu   sync.Mutex
+	next time.Time // earliest start of the next lookup
+}
+
+func newStoreLimiter(concurrency int, rate, jitter float64) *storeLimiter {
+	l := &storeLimiter{jitter: jitter, rand: rand.Float64}
+	if concurrency > 0 {
+		l.slots = make(chan struct{}, concurrency)
+	}
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return l
+}
+
+// storeLimiters holds the limiter of each store, installed by
+// commonFlags.setup. Stores without one are limited only by --concurrency.
+var storeLimiters = map[string]*storeLimiter{}
+
+// acquire waits for a slot and for the rate limit to allow another start.
+// The returned function releases the slot.
+func (l *storeLimiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	release = func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-l.slots }
+	}
+	if wait := l.reserve(time.Now()); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+// reserve books the next start time and returns how long to wait for it.
+func (l *storeLimiter) reserve(now time.Time) time.Duration {
+	if l.interval == 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(jittered(l.interval, l.jitter, l.rand()))
+	return start.Sub(now)
+}
+
+// jittered spreads d over d ± jitter × d as r goes from 0 to 1.
+func jittered(d time.Duration, jitter, r float64) time.Duration {
+	return time.Duration(float64(d) * (1 - jitter + 2*jitter*r))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStoreLimiterConcurrency(t *testing.T) {
+	l := newStoreLimiter(2, 0, 0)
+	var inFlight, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+			n := inFlight.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			inFlight.Add(-1)
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p != 2 {
+		t.Errorf("peak concurrency = %d, want 2", p)
+	}
+}
+
+func TestStoreLimiterRate(t *testing.T) {
+	l := newStoreLimiter(0, 4, 0) // one start every 250ms
+	now := time.Unix(1000, 0)
+	for i, want := range []time.Duration{0, 250 * time.Millisecond, 500 * time.Millisecond} {
+		if got := l.reserve(now); got != want {
+			t.Errorf("wait %d = %v, want %v", i, got, want)
+		}
+	}
+	// After an idle spell the next lookup starts right away.
+	if got := l.reserve(now.Add(time.Second)); got != 0 {
+		t.Errorf("wait after idle = %v, want 0", got)
+	}
+}
+
+func TestStoreLimiterJitter(t *testing.T) {
+	l := newStoreLimiter(0, 4, 0.5) // gaps of 125ms to 375ms
+	draws := []float64{0, 1, 0.5}
+	l.rand = func() float64 {
+		r := draws[0]
+		draws = draws[1:]
+		return r
+	}
+	now := time.Unix(1000, 0)
+	for i, want := range []time.Duration{0, 125 * time.Millisecond, 500 * time.Millisecond} {
+		if got := l.reserve(now); got != want {
+			t.Errorf("wait %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestStoreLimiterCancel(t *testing.T) {
+	l := newStoreLimiter(1, 0, 0)
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.acquire(ctx); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+
+	var none *storeLimiter
+	release, err = none.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestRateJitterFlag(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--rate-jitter", "0.5"}, "--rate-jitter requires --ios-rate or --android-rate"},
+		{[]string{"--rate-jitter", "1.5", "--ios-rate", "2"}, "invalid --rate-jitter"},
+	} {
+		var c commonFlags
+		fs := flag.NewFlagSet("resolve", flag.ContinueOnError)
+		c.register(fs)
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.setup(io.Discard); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: err = %v, want %q", tc.args, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggerJSON(t *testing.T) {
+	var buf strings.Builder
+	l, err := newLogger(&buf, "warn", "json")
+	if err != nil {
+		t.Fatalf("newLogger: %v", err)
+	}
+	l.Info("dropped")
+	l.Warn("resolve failed", "id", "123")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1: %q", len(lines), buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if entry["msg"] != "resolve failed" || entry["id"] != "123" || entry["level"] != "WARN" {
+		t.Fatalf("unexpected entry: %v", entry)
+	}
+}
+
+func TestNewLoggerInvalid(t *testing.T) {
+	if _, err := newLogger(&strings.Builder{}, "loud", "text"); err == nil {
+		t.Error("invalid level accepted")
+	}
+	if _, err := newLogger(&strings.Builder{}, "info", "xml"); err == nil {
+		t.Error("invalid format accepted")
+	}
+}
@@ -2,10 +2,9 @@ package main
 
 import (
 	"bufio"
-	"encoding/csv"
-	"encoding/json"
+	"bytes"
+	"context"
 	"errors"
-	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -13,8 +12,12 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
@@ -23,6 +26,10 @@ import (
 
 var version = "0.1.0"
 
+// runLambda is set by the lambda build (go build -tags lambda) to start the
+// AWS Lambda handler instead of the CLI.
+var runLambda func()
+
 // Field represents the output data fields.
 type Field string
 
@@ -31,9 +38,119 @@ const (
 	FieldName      Field = "name"
 	FieldPublisher Field = "publisher"
 	FieldURL       Field = "url"
+	// FieldInput is the id as read from the input line (the query for search).
+	FieldInput Field = "input"
+	// FieldPlatform is the store an id belongs to: ios, android or unknown.
+	FieldPlatform Field = "platform"
+	// FieldPlatforms lists the Apple platforms an iOS app runs on.
+	FieldPlatforms Field = "platforms"
+	// FieldDeviceFamily tells whether an iOS app is for the iPhone, the iPad
+	// or both.
+	FieldDeviceFamily Field = "device_family"
+	// FieldWebsite is the developer website given in the store listing.
+	FieldWebsite Field = "website"
+	// FieldBadges lists merchandising badges such as Editors' Choice.
+	FieldBadges Field = "badges"
+	// FieldCategory is the store category, and FieldIABCategory its IAB
+	// Content Taxonomy id.
+	FieldCategory    Field = "category"
+	FieldIABCategory Field = "iab_category"
+	// FieldKids reports whether an app is in the App Store Kids category or
+	// the Play Families program.
+	FieldKids Field = "kids"
+	// FieldLifecycle is whether an Android app is released, open for
+	// pre-registration or in early access.
+	FieldLifecycle Field = "lifecycle"
+	// FieldPrice is the listed price, "Free" for free apps.
+	FieldPrice Field = "price"
+	// FieldCountry and FieldAvailable are the storefront of a
+	// --per-country-rows row and whether it carries the app.
+	FieldCountry   Field = "country"
+	FieldAvailable Field = "available"
+	// The current iOS version, or with --history each listed release.
+	FieldVersion      Field = "version"
+	FieldVersionDate  Field = "version_date"
+	FieldReleaseNotes Field = "release_notes"
+	// The privacy label of iOS apps, by kind of use.
+	FieldPrivacyTracking  Field = "privacy_tracking"
+	FieldPrivacyLinked    Field = "privacy_linked"
+	FieldPrivacyNotLinked Field = "privacy_not_linked"
+	// The Data safety section of Android apps.
+	FieldDataShared        Field = "data_shared"
+	FieldDataCollected     Field = "data_collected"
+	FieldSecurityPractices Field = "security_practices"
+	// FieldDetection explains how the input was classified and which
+	// fallbacks the lookup took; FieldConfidence scores the result.
+	FieldDetection  Field = "detection"
+	FieldConfidence Field = "confidence"
+	// FieldResolvedURL is where the store page redirected to, which can reveal
+	// the canonical storefront or a removed app.
+	FieldResolvedURL Field = "resolved_url"
+	// FieldResolvedAt is when the data of a record was current: the time of
+	// the lookup, or of the oldest --cache-dir response it was read from.
+	FieldResolvedAt Field = "resolved_at"
+	// FieldStoreURL is the exact URL the store links to: the iOS
+	// trackViewUrl, with its storefront and app name slug.
+	FieldStoreURL Field = "store_url"
+	// FieldTrackID is the numeric App Store id, which ios:<bundle id>
+	// inputs resolve to.
+	FieldTrackID Field = "track_id"
+	// FieldBundleID is the bundle identifier of an iOS app, and
+	// FieldPackage the package name of an Android app.
+	FieldBundleID Field = "bundle_id"
+	FieldPackage  Field = "package"
+	// FieldSource tells where a record's data came from: store, archive
+	// (--archive) or plugin.
+	FieldSource Field = "source"
+	// FieldDeveloperID is the store's id of the developer: the App Store
+	// artist id, or the Play developer page id.
+	FieldDeveloperID Field = "developer_id"
+	// FieldDeveloperEmail is the contact address of an Android app's
+	// developer.
+	FieldDeveloperEmail Field = "developer_email"
+	// Game features: Game Center support (iOS), Play Games services
+	// (Android) and game controller support (both).
+	FieldGameCenter Field = "game_center"
+	FieldPlayGames  Field = "play_games"
+	FieldController Field = "controller"
+	// FieldPlayTracks lists the releases of an Android app on its Play
+	// tracks (--play-service-account only).
+	FieldPlayTracks Field = "play_tracks"
+	// FieldIAPItems lists the in-app purchases of an iOS app.
+	FieldIAPItems Field = "iap_items"
+	// The EU trader declaration of Android apps (see traderInfo).
+	FieldTrader        Field = "trader"
+	FieldTraderName    Field = "trader_name"
+	FieldTraderAddress Field = "trader_address"
+	FieldTraderCountry Field = "trader_country"
+	FieldTraderPhone   Field = "trader_phone"
+	// FieldPublisherCountry is the country the publisher is inferred to be
+	// in (see publisherCountry).
+	FieldPublisherCountry Field = "publisher_country"
+	// FieldPublisherCountrySource names the signal publisher_country was
+	// read from.
+	FieldPublisherCountrySource Field = "publisher_country_source"
+	// The API levels an Android app requires and targets.
+	FieldMinSDK    Field = "min_sdk"
+	FieldTargetSDK Field = "target_sdk"
+	// How the lookup of the row went (see lookupStatus), and why it failed.
+	FieldStatus       Field = "status"
+	FieldStatusReason Field = "status_reason"
+	// FieldPartial is true for apps found without a publisher (see
+	// markPartial).
+	FieldPartial Field = "partial"
+	// FieldNameLang is the language the name is written in (see
+	// nameLanguage).
+	FieldNameLang Field = "name_lang"
+	// FieldNameLatin is the name transliterated to Latin letters (see
+	// romanize).
+	FieldNameLatin Field = "name_latin"
 )
 
-var allowedFields = []Field{FieldBundle, FieldName, FieldPublisher, FieldURL}
+// defaultFields is the --fields default.
+const defaultFields = "bundle,name,publisher,url"
+
+var allowedFields = []Field{FieldAvailable, FieldBadges, FieldBundle, FieldBundleID, FieldCategory, FieldConfidence, FieldController, FieldCountry, FieldDataCollected, FieldDataShared, FieldDetection, FieldDeveloperEmail, FieldDeveloperID, FieldDeviceFamily, FieldGameCenter, FieldIABCategory, FieldIAPItems, FieldInput, FieldKids, FieldLifecycle, FieldMinSDK, FieldName, FieldNameLang, FieldNameLatin, FieldPackage, FieldPartial, FieldPlatform, FieldPlatforms, FieldPlayGames, FieldPlayTracks, FieldPrice, FieldPrivacyLinked, FieldPrivacyNotLinked, FieldPrivacyTracking, FieldPublisher, FieldPublisherCountry, FieldPublisherCountrySource, FieldReleaseNotes, FieldResolvedAt, FieldResolvedURL, FieldSecurityPractices, FieldSource, FieldStatus, FieldStatusReason, FieldStoreURL, FieldTargetSDK, FieldTrackID, FieldTrader, FieldTraderAddress, FieldTraderCountry, FieldTraderName, FieldTraderPhone, FieldURL, FieldVersion, FieldVersionDate, FieldWebsite}
 var fieldSet map[Field]struct{}
 
 func init() {
@@ -45,52 +162,151 @@ func init() {
 }
 
 func main() {
-	var fieldsCSV string
-	var showVersion bool
-	var showHeader bool
-	var skipErrors bool
-	var outputCSV bool
-
-	flag.StringVar(&fieldsCSV, "fields", "bundle,name,publisher,url", "Comma-separated list of fields to output (allowed: bundle,name,publisher,url)")
-	flag.StringVar(&fieldsCSV, "f", "bundle,name,publisher,url", "Alias of --fields")
-	flag.BoolVar(&showVersion, "version", false, "Print version and exit")
-	flag.BoolVar(&showHeader, "header", true, "Print header row as first line (use --header=false to disable)")
-	flag.BoolVar(&skipErrors, "skip-errors", false, "Skip lines that fail to resolve instead of outputting empty rows")
-	flag.BoolVar(&outputCSV, "csv", false, "Emit output as CSV (RFC4180 with quoting) instead of TSV")
-	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Bundle Resolver\n\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] < <input>\n\n", os.Args[0])
-		fmt.Fprintf(flag.CommandLine.Output(), "Options:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(flag.CommandLine.Output(), "\nInput: lines of either numeric iOS App IDs or Android package names (with dots).\n")
-	}
-	flag.Parse()
-
-	if showVersion {
-		fmt.Println(version)
+	if runLambda != nil {
+		runLambda()
 		return
 	}
 
-	fields, err := parseFields(fieldsCSV)
-	if err != nil {
-		log.Fatalf("invalid --fields: %v", err)
-	}
-
-	if err := process(os.Stdin, os.Stdout, fields, showHeader, skipErrors, outputCSV); err != nil {
+	if err := runCLI(context.Background(), os.Args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
 		log.Fatalf("error: %v", err)
 	}
 }
 
 var (
-	reIOS     = regexp.MustCompile(`^[0-9]+$`)
-	reAndroid = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)+$`)
+	reIOS = regexp.MustCompile(`^[0-9]+$`)
+	// reIOSBundle matches an iOS bundle identifier marked with the ios:
+	// prefix, which tells it apart from an Android package name.
+	reIOSBundle = regexp.MustCompile(`^` + iosBundlePrefix + `[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+$`)
+	// reAndroid follows the Android package name rules: two or more
+	// segments, each starting with a letter.
+	reAndroid = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z][A-Za-z0-9_]*)+$`)
+	// reAndroidLenient also accepts segments starting with a digit or
+	// underscore; used with --lenient.
+	reAndroidLenient = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)+$`)
 )
 
+// iosBundlePrefix marks input lines that are iOS bundle identifiers, such
+// as ios:com.example.app.
+const iosBundlePrefix = "ios:"
+
+// lenientIDs makes Android ids that break the package name rules resolvable
+// anyway (--lenient).
+var lenientIDs bool
+
 type record struct {
-	Bundle    string
-	Name      string
-	Publisher string
-	URL       string
+	Bundle    string `json:"bundle"`
+	Name      string `json:"name"`
+	Publisher string `json:"publisher"`
+	URL       string `json:"url"`
+	// ResolvedURL is left empty for iOS unless resolved_url is selected.
+	ResolvedURL string `json:"resolved_url,omitempty"`
+	// StoreURL is the page URL the store itself links to (see FieldStoreURL).
+	StoreURL string `json:"store_url,omitempty"`
+	Website  string `json:"website,omitempty"`
+	Price    string `json:"price,omitempty"`
+	// DeveloperEmail is the developer contact address (Android only).
+	DeveloperEmail string `json:"developer_email,omitempty"`
+	// Category is the store category's name and CategoryID its id: the App
+	// Store genre id or the Play category (e.g. GAME_PUZZLE).
+	Category   string `json:"category,omitempty"`
+	CategoryID string `json:"category_id,omitempty"`
+	// Kids is set once a lookup has told whether the app is made for
+	// children (see FieldKids).
+	Kids *bool `json:"kids,omitempty"`
+	// GameCenter, PlayGames and Controller are the game features, set
+	// once a lookup has told (see FieldGameCenter).
+	GameCenter *bool `json:"game_center,omitempty"`
+	PlayGames  *bool `json:"play_games,omitempty"`
+	Controller *bool `json:"controller,omitempty"`
+	// Platforms lists the Apple platforms an iOS app runs on (see
+	// applePlatforms).
+	Platforms []string `json:"platforms,omitempty"`
+	// DeviceFamily is iphone, ipad or universal (see deviceFamily).
+	DeviceFamily string `json:"device_family,omitempty"`
+	// MinSDK and TargetSDK are the API levels of an Android app (see
+	// sdkSource).
+	MinSDK    int `json:"min_sdk,omitempty"`
+	TargetSDK int `json:"target_sdk,omitempty"`
+	// Lifecycle is the state of an Android listing (see FieldLifecycle).
+	Lifecycle string `json:"lifecycle,omitempty"`
+	// Badges lists the page's merchandising badges; for iOS fetched only if
+	// badges is selected.
+	Badges []string `json:"badges,omitempty"`
+	// Prices holds the price per storefront with --price-countries.
+	Prices map[string]storePrice `json:"prices,omitempty"`
+	// Country is the storefront of a --per-country-rows row.
+	Country string `json:"country,omitempty"`
+	// Partial is set on records found without a publisher (see
+	// markPartial).
+	Partial bool `json:"partial,omitempty"`
+	// ResolvedAt is when the record's data was current, in RFC 3339 UTC
+	// (see freshness), set only if resolved_at is selected.
+	ResolvedAt string `json:"resolved_at,omitempty"`
+	// BundleID is the iOS bundle identifier (e.g. com.example.app) and
+	// TrackID the numeric App Store id.
+	BundleID string `json:"bundle_id,omitempty"`
+	TrackID  string `json:"track_id,omitempty"`
+	// DeveloperID is the store's id of the developer (see FieldDeveloperID).
+	DeveloperID string `json:"developer_id,omitempty"`
+	// Source names the --sources entry a record came from, and is empty
+	// for store lookups (see sourceValue).
+	Source string `json:"source,omitempty"`
+	// Privacy is the iOS privacy label, fetched only if a privacy field is selected.
+	Privacy *appPrivacy `json:"privacy,omitempty"`
+	// History lists iOS releases, newest first: the current version, or every
+	// release on the App Store page with --history.
+	History []appVersion `json:"history,omitempty"`
+	// DataSafety is the Android Data safety section, fetched only if one of
+	// its fields is selected.
+	DataSafety *dataSafety `json:"data_safety,omitempty"`
+	// InAppPurchases lists the in-app purchases of an iOS app's page,
+	// fetched only if iap_items is selected.
+	InAppPurchases []iapItem `json:"in_app_purchases,omitempty"`
+	// PlayReleases lists the releases on an Android app's Play tracks, told
+	// only by the Play Developer API (see addPlayAPIFields).
+	PlayReleases []playRelease `json:"play_releases,omitempty"`
+	// Trader is the EU trader declaration of an Android app.
+	Trader *traderInfo `json:"trader,omitempty"`
+	// Fallbacks lists the fallbacks the lookup took (see explain.go).
+	Fallbacks []string `json:"fallbacks,omitempty"`
+	// IconURL and Screenshots locate the store artwork for --download-assets.
+	IconURL     string   `json:"-"`
+	Screenshots []string `json:"-"`
+	// Status and StatusReason tell why the lookup failed (see
+	// lookupStatus); set by process, and empty for a successful one.
+	Status       string `json:"status,omitempty"`
+	StatusReason string `json:"status_reason,omitempty"`
+	// Input is the input line the record answers; set by process.
+	Input string `json:"-"`
+}
+
+// fieldPresets are names --fields expands to a list of fields.
+var fieldPresets = map[string][]Field{
+	// ids is a mapping table of every identifier of an app, for
+	// identity-resolution tables.
+	"ids": {FieldBundle, FieldPlatform, FieldTrackID, FieldBundleID, FieldPackage, FieldDeveloperID},
+}
+
+// fieldsUsage is the help text of --fields.
+func fieldsUsage() string {
+	presets := make([]string, 0, len(fieldPresets))
+	for name := range fieldPresets {
+		presets = append(presets, name)
+	}
+	sort.Strings(presets)
+	return "Comma-separated list of fields to output (allowed: " + strings.Join(fieldNames(), ",") + "; presets: " + strings.Join(presets, ",") + ")"
+}
+
+func hasField(fields []Field, f Field) bool {
+	for _, g := range fields {
+		if g == f {
+			return true
+		}
+	}
+	return false
 }
 
 func parseFields(csv string) ([]Field, error) {
@@ -102,16 +318,21 @@ func parseFields(csv string) ([]Field, error) {
 		if p == "" {
 			continue
 		}
-		f := Field(p)
-		if _, ok := fieldSet[f]; !ok {
-			return nil, fmt.Errorf("unknown field %q", p)
+		fields, ok := fieldPresets[p]
+		if !ok {
+			fields = []Field{Field(p)}
 		}
-		// allow duplicates? Probably not useful; keep order but de-dup
-		if seen[f] {
-			continue
+		for _, f := range fields {
+			if _, ok := fieldSet[f]; !ok {
+				return nil, fmt.Errorf("unknown field %q", p)
+			}
+			// allow duplicates? Probably not useful; keep order but de-dup
+			if seen[f] {
+				continue
+			}
+			seen[f] = true
+			res = append(res, f)
 		}
-		seen[f] = true
-		res = append(res, f)
 	}
 	if len(res) == 0 {
 		return nil, errors.New("no valid fields specified")
@@ -119,87 +340,447 @@ func parseFields(csv string) ([]Field, error) {
 	return res, nil
 }
 
-func process(r io.Reader, w io.Writer, fields []Field, header bool, skipErrors bool, csvOutput bool) error {
-	s := bufio.NewScanner(r)
+// options controls how process renders its output.
+type options struct {
+	Fields     []Field
+	Header     bool
+	SkipErrors bool
+	CSV        bool
+	Sanitize   sanitizeMode
+	// Format, when a document format such as rss or html, replaces the
+	// rows (see newEncoder).
+	Format string
+	// Normalize NFC-normalizes values and strips invisible fillers.
+	Normalize bool
+	// Progress, when non-nil, is updated as lines are processed. The input is
+	// read up front so the total is known.
+	Progress *progress
+	// Summary, when non-nil, accumulates end-of-run statistics.
+	Summary *summary
+	// DebugDir, when set, receives a dump of the HTTP exchanges behind each
+	// failed lookup. httpClient must use a debugTransport for it to be filled.
+	DebugDir string
+	// FlushInterval is how often buffered rows are written out; zero writes
+	// every row as soon as it is ready.
+	FlushInterval time.Duration
+	// Concurrency is the number of lookups run in parallel (at least 1).
+	Concurrency int
+	// Unordered writes rows as lookups finish instead of in input order,
+	// prefixed with the input field, and drops blank lines.
+	Unordered bool
+	// Assets, when its Dir is set, receives each resolved app's artwork. The
+	// downloads run in the lookup workers.
+	Assets assetOptions
+	// Prices, when it lists countries, looks up each app's price there,
+	// and with Rows writes a row per country.
+	Prices priceOptions
+	// QRDir, when set, receives a QR code PNG of each resolved store URL.
+	QRDir string
+	// History writes one row per release in each record's History, so a
+	// record without releases still gets a single row.
+	History bool
+	// Filter, when non-nil, leaves out the rows it does not match, and the
+	// rows of blank lines.
+	Filter *filterExpr
+	// Publishers, when non-nil, normalizes every publisher name (see
+	// normalizePublisher) and maps its aliases to one spelling.
+	Publishers *publisherAliases
+	// URLStyle chooses the form of the url field (see urlStyle).
+	URLStyle urlStyle
+	// Transforms rewrite field values before they are filtered and written.
+	Transforms transforms
+	// Errors, when non-nil, receives a structured report of every failed
+	// lookup.
+	Errors *errorLog
+	// FailedOut, when non-nil, receives the input line of every failed
+	// lookup, one per line, so it can be the input of another run.
+	FailedOut io.Writer
+	// Script, when set, is the path of a --script Starlark file or
+	// executable started for the run to rewrite or drop every output row.
+	Script string
+	// Context, when set, cancels the run: lookups stop, and failed ones
+	// are written without their retry.
+	Context context.Context
+	// RetryFailed looks up the ids whose lookup failed transiently (see
+	// retryable) once more after all other rows are written, one at a time
+	// and each after waiting RetryDelay; their rows come last.
+	RetryFailed bool
+	RetryDelay  time.Duration
+	// MaxRequests and MaxErrors, when positive, stop the run from starting
+	// lookups once it has sent that many requests or seen that many failed
+	// lookups (see runBudget). The lines not looked up go to FailedOut and
+	// process returns a *budgetError once the rows are written.
+	MaxRequests int
+	MaxErrors   int
+	// Snapshot, when non-nil, collects every lookup (including failed ones
+	// that SkipErrors leaves out of the output).
+	Snapshot *snapshot
+	// CrossPlatform, when non-nil, collects the resolved apps for the
+	// --dedupe-cross-platform report.
+	CrossPlatform *crossPlatform
+}
+
+// reorderWindowPerWorker bounds, per worker, how many lookups may be
+// started ahead of the oldest unfinished one when output keeps input order.
+const reorderWindowPerWorker = 16
+
+// lookupJob is one input line, numbered from 0.
+type lookupJob struct {
+	seq  int
+	line string
+}
 
-	var writeRow func([]string) error
-	flush := func() error { return nil }
+type lookupResult struct {
+	lookupJob
+	rec  record
+	err  error
+	took time.Duration
+	// stopped is the limit that kept the line from being looked up.
+	stopped string
+}
 
-	if csvOutput {
-		csvWriter := csv.NewWriter(w)
-		writeRow = func(cols []string) error {
-			return csvWriter.Write(cols)
+func process(r io.Reader, w io.Writer, opts options) error {
+	if opts.Progress != nil {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
 		}
-		flush = func() error {
-			csvWriter.Flush()
-			return csvWriter.Error()
+		opts.Progress.begin(bytes.Count(data, []byte("\n")) + countUnterminated(data))
+		defer opts.Progress.finish()
+		r = bytes.NewReader(data)
+	}
+
+	fields := opts.Fields
+	if (opts.Unordered || opts.RetryFailed) && !hasField(fields, FieldInput) {
+		// Rows arrive in completion order, or retried ones after the rest,
+		// so say which line each one answers.
+		fields = append([]Field{FieldInput}, fields...)
+	}
+	out := newRowWriter(w, fields, opts.CSV, opts.Sanitize)
+	out.normalize = opts.Normalize
+	encoder, err := newEncoder(opts.Format, fields)
+	if err != nil {
+		return err
+	}
+	if encoder != nil {
+		out.useEncoder(encoder)
+	}
+	if opts.Script != "" {
+		script, err := startScript(fields, opts.Script)
+		if err != nil {
+			return fmt.Errorf("starting --script: %w", err)
 		}
+		defer func() {
+			if err := script.close(); err != nil {
+				logger.Warn("script exited with an error", "script", opts.Script, "err", err)
+			}
+		}()
+		out.script = script
+	}
+	if opts.FlushInterval > 0 {
+		defer out.autoFlush(opts.FlushInterval)()
 	} else {
-		writeRow = func(cols []string) error {
-			_, err := fmt.Fprintln(w, strings.Join(cols, "\t"))
+		out.flushEachRow = true
+	}
+
+	// Print header immediately if requested so it's always the first line in output.
+	if opts.Header {
+		if err := out.writeHeader(); err != nil {
 			return err
 		}
 	}
 
-	writeRecord := func(rec record) error {
-		cols := make([]string, len(fields))
-		for i, f := range fields {
-			var val string
-			switch f {
-			case FieldBundle:
-				val = rec.Bundle
-			case FieldName:
-				val = rec.Name
-			case FieldPublisher:
-				val = rec.Publisher
-			case FieldURL:
-				val = rec.URL
+	workers := max(opts.Concurrency, 1)
+	// Every started lookup holds a slot until its row is written, which
+	// bounds the results waiting behind a slow lookup in ordered mode.
+	window := workers
+	if !opts.Unordered {
+		window *= reorderWindowPerWorker
+	}
+	slots := make(chan struct{}, window)
+
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	budget := newRunBudget(opts.MaxRequests, opts.MaxErrors)
+	jobs := make(chan lookupJob)
+	var scanErr error
+	go func() {
+		defer close(jobs)
+		s := bufio.NewScanner(r)
+		for seq := 0; s.Scan(); seq++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- lookupJob{seq: seq, line: strings.TrimSpace(s.Text())}:
+			case <-ctx.Done():
+				return
 			}
-			cols[i] = sanitize(val)
 		}
-		return writeRow(cols)
+		scanErr = s.Err()
+	}()
+
+	results := make(chan lookupResult, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				res := lookupResult{lookupJob: job}
+				// Once the budget is spent, the remaining lines pass through
+				// unresolved.
+				if limit := budget.exceeded(); limit != "" {
+					res.stopped = limit
+				} else if job.line != "" {
+					res = lookup(ctx, job, opts)
+					if res.err != nil {
+						budget.failed()
+					}
+				}
+				select {
+				case results <- res:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
 	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-	// Print header immediately if requested so it's always the first line in output.
-	if header {
-		names := make([]string, len(fields))
-		for i, f := range fields {
-			names[i] = string(f)
+	emit := func(res lookupResult) error {
+		if res.line == "" {
+			if opts.Progress != nil {
+				opts.Progress.skip()
+			}
+			if opts.Summary != nil {
+				opts.Summary.blank()
+			}
+			if opts.Unordered || opts.Filter != nil {
+				return nil // there is no alignment to preserve
+			}
+			// Preserve alignment: output an empty row corresponding to the blank input line.
+			return out.writeRecord(record{})
 		}
-		if err := writeRow(names); err != nil {
-			return err
+		if opts.Progress != nil {
+			opts.Progress.record(res.err == nil)
 		}
+		if opts.Summary != nil {
+			opts.Summary.lookup(res.line, res.took, res.err)
+		}
+		if opts.Snapshot != nil {
+			opts.Snapshot.add(res.line, res.rec, res.err)
+		}
+		if opts.CrossPlatform != nil && res.err == nil {
+			opts.CrossPlatform.add(res.line, res.rec)
+		}
+		if opts.Errors != nil && res.err != nil {
+			if err := opts.Errors.add(res.line, res.err); err != nil {
+				return fmt.Errorf("writing --errors: %w", err)
+			}
+		}
+		if opts.FailedOut != nil && res.err != nil {
+			if _, err := fmt.Fprintln(opts.FailedOut, res.line); err != nil {
+				return fmt.Errorf("writing --failed-out: %w", err)
+			}
+		}
+		// If skipErrors is true, skip this line entirely. Otherwise, still
+		// emit placeholder row; rec may have URL (canonical) or be empty.
+		if res.err != nil && opts.SkipErrors {
+			return nil
+		}
+		res.rec.Input = res.line
+		if res.err != nil {
+			res.rec.Status, res.rec.StatusReason = lookupStatus(res.err)
+		}
+		opts.URLStyle.apply(&res.rec)
+		if opts.Publishers != nil {
+			res.rec.Publisher = opts.Publishers.canonical(res.rec.Publisher)
+		}
+		opts.Transforms.apply(&res.rec)
+		write := func(rec record) error {
+			if opts.Filter != nil && !opts.Filter.match(rec) {
+				return nil
+			}
+			return out.writeRecord(rec)
+		}
+		writeReleases := func(rec record) error {
+			if !opts.History || len(rec.History) < 2 {
+				return write(rec)
+			}
+			for i := range rec.History {
+				release := rec
+				release.History = rec.History[i:]
+				if err := write(release); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if !opts.Prices.Rows || res.err != nil || res.rec.Bundle == "" {
+			return writeReleases(res.rec)
+		}
+		for _, c := range opts.Prices.Countries {
+			if err := writeReleases(res.rec.inCountry(c)); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	for s.Scan() {
-		raw := s.Text()
-		line := strings.TrimSpace(raw)
-		if line == "" {
-			// Preserve alignment: output an empty row corresponding to the blank input line.
-			if err := writeRecord(record{}); err != nil {
+	// With RetryFailed, transient failures wait for the retry pass. stopped
+	// is the limit that stopped the run, and skipped the lines not looked
+	// up because of it.
+	var failed []lookupResult
+	var stopped string
+	var skipped []string
+	release := func(res lookupResult) error {
+		<-slots
+		if res.stopped != "" {
+			stopped = res.stopped
+			if res.line != "" {
+				skipped = append(skipped, res.line)
+			}
+			if opts.Progress != nil {
+				opts.Progress.skip()
+			}
+			return nil
+		}
+		if opts.RetryFailed && res.err != nil && retryable(res.err) {
+			failed = append(failed, res)
+			return nil
+		}
+		return emit(res)
+	}
+
+	pending := map[int]lookupResult{}
+	next := 0
+	for res := range results {
+		if opts.Unordered {
+			if err := release(res); err != nil {
 				return err
 			}
 			continue
 		}
-		rec, err := resolveFunc(line)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "resolve %q: %v\n", line, err)
-			// If skipErrors is true, skip this line entirely
-			if skipErrors {
-				continue
+		pending[res.seq] = res
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err := release(ready); err != nil {
+				return err
 			}
-			// Otherwise, still emit placeholder row; rec may have URL (canonical) or be empty.
 		}
-		if err := writeRecord(rec); err != nil {
+	}
+
+	if scanErr != nil {
+		return scanErr
+	}
+
+	for _, res := range failed {
+		if stopped == "" {
+			stopped = budget.exceeded()
+		}
+		if stopped == "" {
+			select {
+			case <-ctx.Done():
+			case <-time.After(opts.RetryDelay):
+			}
+		}
+		if stopped == "" && ctx.Err() == nil {
+			logger.Info("retrying failed lookup", "id", res.line, "err", res.err)
+			lookupRetries.Add(1)
+			if res = lookup(ctx, res.lookupJob, opts); res.err != nil {
+				budget.failed()
+			}
+		}
+		if err := emit(res); err != nil {
 			return err
 		}
 	}
 
-	if err := s.Err(); err != nil {
+	if stopped == "" {
+		return out.close()
+	}
+	logger.Warn("run stopped", "limit", stopped, "not_looked_up", len(skipped))
+	if opts.FailedOut != nil {
+		for _, line := range skipped {
+			if _, err := fmt.Fprintln(opts.FailedOut, line); err != nil {
+				return fmt.Errorf("writing --failed-out: %w", err)
+			}
+		}
+	}
+	if err := out.close(); err != nil {
 		return err
 	}
+	return &budgetError{limit: stopped, skipped: len(skipped)}
+}
+
+// lookup resolves job's id and runs the per-record downloads and lookups
+// that opts asks for.
+func lookup(ctx context.Context, job lookupJob, opts options) lookupResult {
+	res := lookupResult{lookupJob: job}
+	started := time.Now()
+	freshCtx, fresh := withFreshness(ctx)
+	res.rec, res.err = resolveOne(freshCtx, job.line, opts.DebugDir)
+	res.took = time.Since(started)
+	if res.err == nil && selectedFields[FieldResolvedAt] {
+		res.rec.ResolvedAt = fresh.resolvedAt(started)
+	}
+	if res.err == nil && opts.Assets.Dir != "" {
+		if err := downloadAssets(ctx, opts.Assets, res.rec); err != nil {
+			logger.Warn("asset download failed", "id", job.line, "err", err)
+		}
+	}
+	if res.err == nil && len(opts.Prices.Countries) > 0 {
+		addPrices(ctx, opts.Prices, &res.rec)
+	}
+	if res.err == nil && opts.QRDir != "" {
+		if err := writeQR(opts.QRDir, res.rec); err != nil {
+			logger.Warn("QR code failed", "id", job.line, "err", err)
+		}
+	}
+	return res
+}
+
+// resolveOne resolves a single id, logging failures and, when debugDir is
+// set, dumping the HTTP exchanges behind them.
+func resolveOne(ctx context.Context, id, debugDir string) (record, error) {
+	var exchanges *exchangeLog
+	if debugDir != "" {
+		ctx, exchanges = withExchangeLog(ctx)
+	}
+	rec, err := resolveFunc(ctx, id)
+	if err != nil {
+		logger.Warn("resolve failed", "id", id, "err", err)
+		if exchanges != nil {
+			if dumpErr := exchanges.dump(debugDir, id, err); dumpErr != nil {
+				logger.Error("writing HTTP debug dump failed", "id", id, "err", dumpErr)
+			}
+		}
+	}
+	return rec, err
+}
 
-	return flush()
+// countUnterminated returns 1 if data ends with a line lacking a trailing newline.
+func countUnterminated(data []byte) int {
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return 1
+	}
+	return 0
 }
 
 // sanitize removes tabs and newlines to preserve TSV integrity.
@@ -225,45 +806,98 @@ func sanitize(s string) string {
 	return strings.TrimSpace(b.String())
 }
 
+const (
+	platformIOS     = "ios"
+	platformAndroid = "android"
+	platformUnknown = "unknown"
+)
+
+// platformOf classifies an input id by its shape.
+func platformOf(id string) string {
+	switch {
+	case reIOS.MatchString(id), reIOSBundle.MatchString(id):
+		return platformIOS
+	case reAndroid.MatchString(id), lenientIDs && reAndroidLenient.MatchString(id):
+		return platformAndroid
+	}
+	return platformUnknown
+}
+
 // resolve decides platform and fetches metadata.
-func resolve(id string) (record, error) {
-	if reIOS.MatchString(id) {
-		return fetchIOS(id)
+func resolve(ctx context.Context, id string) (record, error) {
+	switch p := platformOf(id); p {
+	case platformIOS, platformAndroid:
+		return resolveSources(ctx, p, id)
+	}
+	if activePlugin != nil {
+		return activePlugin.resolve(ctx, id)
 	}
-	if reAndroid.MatchString(id) {
-		return fetchAndroid(id)
+	if reAndroidLenient.MatchString(id) {
+		return record{}, &invalidIDError{fmt.Sprintf("invalid Android package name %q: every segment must start with a letter (use --lenient to look it up anyway)", id)}
 	}
-	return record{}, fmt.Errorf("cannot detect platform for %q", id)
+	return record{}, &invalidIDError{fmt.Sprintf("cannot detect platform for %q", id)}
 }
 
 var resolveFunc = resolve
 
+// lookupRetries counts extra store requests made after a first attempt
+// failed (storefront fallbacks, search corrections).
+var lookupRetries atomic.Int64
+
 var httpClient = &http.Client{Timeout: 10 * time.Second}
 
-func fetchIOS(appID string) (record, error) {
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return httpClient.Do(req)
+}
+
+func fetchIOS(ctx context.Context, appID string) (record, error) {
 	lookup := func(country string) (record, error) {
 		url := fmt.Sprintf("https://itunes.apple.com/lookup?id=%s", appID)
+		if bundleID, ok := strings.CutPrefix(appID, iosBundlePrefix); ok {
+			url = fmt.Sprintf("https://itunes.apple.com/lookup?bundleId=%s", bundleID)
+		}
 		if country != "" {
 			url += "&country=" + country
 		}
-		resp, err := httpClient.Get(url)
+		resp, err := httpGet(ctx, url)
 		if err != nil {
 			return record{}, err
 		}
-		defer resp.Body.Close()
+		defer drainAndClose(resp.Body)
 		if resp.StatusCode != 200 {
-			return record{}, fmt.Errorf("status %s", resp.Status)
+			return record{}, statusError(resp)
 		}
 		var payload struct {
 			ResultCount int `json:"resultCount"`
 			Results     []struct {
-				TrackName    string `json:"trackName"`
-				SellerName   string `json:"sellerName"`
-				TrackViewURL string `json:"trackViewUrl"`
-				BundleID     string `json:"bundleId"`
+				TrackID         int64    `json:"trackId"`
+				GameCenter      *bool    `json:"isGameCenterEnabled"`
+				Features        []string `json:"features"`
+				TrackName       string   `json:"trackName"`
+				SellerName      string   `json:"sellerName"`
+				ArtistID        int64    `json:"artistId"`
+				TrackViewURL    string   `json:"trackViewUrl"`
+				BundleID        string   `json:"bundleId"`
+				SellerURL       string   `json:"sellerUrl"`
+				FormattedPrice  string   `json:"formattedPrice"`
+				Version         string   `json:"version"`
+				ReleaseDate     string   `json:"currentVersionReleaseDate"`
+				ReleaseNotes    string   `json:"releaseNotes"`
+				ArtworkURL      string   `json:"artworkUrl512"`
+				Screenshots     []string `json:"screenshotUrls"`
+				IPadScreenshots []string `json:"ipadScreenshotUrls"`
+				TVScreenshots   []string `json:"appletvScreenshotUrls"`
+				Devices         []string `json:"supportedDevices"`
+				Genre           string   `json:"primaryGenreName"`
+				GenreID         int      `json:"primaryGenreId"`
+				Genres          []string `json:"genres"`
 			} `json:"results"`
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		if err := decodeJSONResponse(resp, &payload); err != nil {
 			return record{}, err
 		}
 		if payload.ResultCount == 0 || len(payload.Results) == 0 {
@@ -274,86 +908,305 @@ func fetchIOS(appID string) (record, error) {
 		if urlOut == "" {
 			// if TrackViewURL missing we'll still build canonical later
 		}
+		// Bundle-id inputs are keyed on the numeric id they resolve to, as
+		// every other iOS record.
+		bundle, trackID := appID, ""
+		if res.TrackID != 0 {
+			trackID = strconv.FormatInt(res.TrackID, 10)
+			if !reIOS.MatchString(bundle) {
+				bundle = trackID
+			}
+		}
 		// Normalize to canonical short form per README
-		canonical := fmt.Sprintf("https://apps.apple.com/app/id%s", appID)
-		return record{Bundle: appID, Name: res.TrackName, Publisher: res.SellerName, URL: canonical}, nil
+		canonical := buildAppStoreURL(bundle)
+		rec := record{Bundle: bundle, Name: res.TrackName, Publisher: res.SellerName, URL: canonical, Website: res.SellerURL, Price: res.FormattedPrice, BundleID: res.BundleID, TrackID: trackID}
+		rec.IconURL, rec.Screenshots = res.ArtworkURL, res.Screenshots
+		if res.ArtistID != 0 {
+			rec.DeveloperID = strconv.FormatInt(res.ArtistID, 10)
+		}
+		rec.StoreURL = res.TrackViewURL
+		if res.GenreID != 0 {
+			rec.Category, rec.CategoryID = res.Genre, strconv.Itoa(res.GenreID)
+		}
+		kids := slices.Contains(res.Genres, appStoreKidsGenre)
+		rec.Kids = &kids
+		gameCenter := slices.Contains(res.Features, appStoreGameCenterFeature) || (res.GameCenter != nil && *res.GameCenter)
+		rec.GameCenter = &gameCenter
+		rec.Platforms = supportedPlatforms(res.Devices, len(res.IPadScreenshots), len(res.TVScreenshots))
+		rec.DeviceFamily = deviceFamily(res.Devices, len(res.Screenshots), len(res.IPadScreenshots))
+		if len(rec.Screenshots) == 0 {
+			rec.Screenshots = res.IPadScreenshots
+		}
+		if res.Version != "" {
+			rec.History = []appVersion{{Version: res.Version, Date: releaseDate(res.ReleaseDate), Notes: strings.TrimSpace(res.ReleaseNotes)}}
+		}
+		return rec, nil
 	}
 
 	// 1st try: no country (Apple often defaults to US)
 	rec, err := lookup("")
-	if err == nil {
-		return rec, nil
-	}
-	// Fallback to jp (common case for JP-only apps)
-	jpRec, errJP := lookup("jp")
-	if errJP == nil {
-		return jpRec, nil
+	if err != nil {
+		// Fallback to jp (common case for JP-only apps), after the
+		// storefronts the bundle id hints at with --guess-storefront
+		found := false
+		for _, country := range storefrontChain(appID) {
+			logger.Debug("iOS lookup failed, retrying storefront", "id", appID, "country", country, "err", err)
+			lookupRetries.Add(1)
+			countryRec, errCountry := lookup(country)
+			if errCountry != nil {
+				continue
+			}
+			rec, err, found = countryRec, nil, true
+			if country == "jp" {
+				rec.Fallbacks = append(rec.Fallbacks, fallbackStorefront)
+			} else {
+				rec.Fallbacks = append(rec.Fallbacks, fallbackGuessedStorefront)
+			}
+			break
+		}
+		if !found {
+			// Return the original error but still provide constructed URL
+			rec = record{Bundle: appID, URL: storeURL(platformIOS, appID)}
+		}
 	}
-	// Return the original error but still provide constructed URL
-	return record{Bundle: appID, URL: fmt.Sprintf("https://apps.apple.com/app/id%s", appID)}, err
+	// Also for failed lookups: a removed app redirects away from its page.
+	addAppStorePageFields(ctx, &rec)
+	return rec, err
+}
+
+func fetchAndroid(ctx context.Context, pkg string) (record, error) {
+	rec, err := fetchAndroidPage(ctx, pkg)
+	return completeFromPlayAPI(ctx, pkg, rec, err)
 }
 
-func fetchAndroid(pkg string) (record, error) {
+// fetchAndroidPage looks pkg up on its public Play page.
+func fetchAndroidPage(ctx context.Context, pkg string) (record, error) {
 	// Step 1: Try direct access first
-	rec, err := fetchAndroidDirect(pkg)
+	rec, err := fetchAndroidDirect(ctx, pkg)
 	if err == nil {
 		return rec, nil
 	}
 
 	// Step 2: If not found, try case-insensitive search fallback
 	if isNotFoundError(err) {
-		correctPkg, searchErr := searchAndroidPackage(pkg)
+		logger.Debug("Play page not found, searching for package", "id", pkg, "err", err)
+		lookupRetries.Add(1)
+		correctPkg, searchErr := searchAndroidPackage(ctx, pkg)
 		if searchErr != nil {
 			// Search also failed, return original error
 			return record{Bundle: pkg, URL: buildPlayStoreURL(pkg)}, err
 		}
 		// Retry with the correct package name
-		return fetchAndroidDirect(correctPkg)
+		rec, err := fetchAndroidDirect(ctx, correctPkg)
+		if err == nil {
+			rec.Fallbacks = append(rec.Fallbacks, fallbackSearch)
+		}
+		return rec, err
+	}
+
+	// A geo-blocked app exists: keep what its page told.
+	var geoErr *geoBlockedError
+	if errors.As(err, &geoErr) {
+		return rec, err
 	}
 
 	// Other errors (network, etc.) - return as-is
 	return record{Bundle: pkg, URL: buildPlayStoreURL(pkg)}, err
 }
 
-func fetchAndroidDirect(pkg string) (record, error) {
+func fetchAndroidDirect(ctx context.Context, pkg string) (record, error) {
 	storeURL := buildPlayStoreURL(pkg)
-	resp, err := httpClient.Get(storeURL)
+	resp, err := httpGet(ctx, playStorePageURL(pkg))
 	if err != nil {
 		return record{Bundle: pkg, URL: storeURL}, err
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
+	resolvedURL := finalURL(resp)
 	if resp.StatusCode != 200 {
-		return record{Bundle: pkg, URL: storeURL}, fmt.Errorf("status %s", resp.Status)
+		return record{Bundle: pkg, URL: storeURL, ResolvedURL: resolvedURL}, statusError(resp)
 	}
 	// Parse HTML with goquery
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parseHTMLResponse(resp)
 	if err != nil {
-		return record{Bundle: pkg, URL: storeURL}, err
+		return record{Bundle: pkg, URL: storeURL, ResolvedURL: resolvedURL}, err
+	}
+	name, publisher := playNameAndPublisher(doc)
+
+	if reason := playGeoBlocked(doc); reason != "" {
+		return record{Bundle: pkg, Name: name, URL: storeURL, ResolvedURL: resolvedURL}, &geoBlockedError{Reason: reason}
 	}
-	name := strings.TrimSpace(doc.Find("h1 span").First().Text())
+	// If we couldn't extract name, it's likely a 404 with some HTML response
+	if name == "" {
+		return record{Bundle: pkg, URL: storeURL, ResolvedURL: resolvedURL}, fmt.Errorf("app not found or unable to parse")
+	}
+	rec := record{Bundle: pkg, Name: name, Publisher: publisher, URL: storeURL, ResolvedURL: resolvedURL, Website: playWebsite(doc), Price: playPrice(doc), IconURL: playIcon(doc), Screenshots: playScreenshots(doc)}
+	rec.Category, rec.CategoryID = playCategory(doc)
+	rec.Lifecycle = playLifecycle(doc)
+	rec.Badges = parseBadges(doc)
+	rec.DeveloperEmail = playDeveloperEmail(doc)
+	rec.DeveloperID = playDeveloperID(doc)
+	rec.Trader = parsePlayTrader(doc)
+	kids := playFamilies(doc)
+	rec.Kids = &kids
+	playGames, controller := hasLeafText(doc, playGamesTexts), hasLeafText(doc, controllerTexts)
+	rec.PlayGames, rec.Controller = &playGames, &controller
+	rec.MinSDK = playMinSDK(doc)
+	addDataSafetyFields(ctx, &rec)
+	addSDKFields(ctx, &rec)
+	return rec, nil
+}
+
+// playNameAndPublisher returns the app and developer names of a Play
+// details page.
+func playNameAndPublisher(doc *goquery.Document) (name, publisher string) {
+	name = strings.TrimSpace(doc.Find("h1 span").First().Text())
 	if name == "" { // fallback to title tag
 		title := strings.TrimSpace(doc.Find("title").Text())
 		if strings.Contains(title, " - Apps on Google Play") {
 			name = strings.TrimSuffix(title, " - Apps on Google Play")
 		}
 	}
-	publisher := strings.TrimSpace(doc.Find("div[itemprop='author'] a span").First().Text())
+	publisher = strings.TrimSpace(doc.Find("div[itemprop='author'] a span").First().Text())
 	if publisher == "" {
 		// New Play Store layout fallback (may change frequently)
 		publisher = strings.TrimSpace(doc.Find("a[href^='/store/apps/dev'] span").First().Text())
 	}
+	return name, publisher
+}
 
-	// If we couldn't extract name, it's likely a 404 with some HTML response
-	if name == "" {
-		return record{Bundle: pkg, URL: storeURL}, fmt.Errorf("app not found or unable to parse")
+// playWebsite returns the developer website linked from the "App support"
+// section of a Play details page, or "" if there is none.
+func playWebsite(doc *goquery.Document) string {
+	var website string
+	doc.Find("a[href^='http']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		href, _ := s.Attr("href")
+		// Matches both "Website" and the older "Visit website" link text.
+		if !strings.Contains(strings.ToLower(s.Text()), "website") || strings.Contains(href, "play.google.com") {
+			return true
+		}
+		website = href
+		return false
+	})
+	return website
+}
+
+// playCategory returns the name and id of the category a Play details page
+// links to, such as "Puzzle" and GAME_PUZZLE.
+func playCategory(doc *goquery.Document) (name, id string) {
+	link := doc.Find("a[itemprop='genre']").First()
+	if link.Length() == 0 {
+		link = doc.Find("a[href*='/store/apps/category/']").First()
+	}
+	href, _ := link.Attr("href")
+	_, id, found := strings.Cut(href, "/store/apps/category/")
+	if !found {
+		return "", ""
 	}
-	return record{Bundle: pkg, Name: name, Publisher: publisher, URL: storeURL}, nil
+	id, _, _ = strings.Cut(id, "?")
+	return strings.TrimSpace(link.Text()), id
 }
 
-func buildPlayStoreURL(pkg string) string {
+// playFamilies reports whether a Play details page shows the age range or
+// Teacher Approved badge of apps in the Families program.
+func playFamilies(doc *goquery.Document) bool {
+	if doc.Find("a[href*='/store/apps/category/FAMILY'], a[href*='age=AGE_RANGE']").Length() > 0 {
+		return true
+	}
+	// The badge itself: a review or description mentioning it is no badge.
+	return containsString(parseBadges(doc), badgeTeacherApproved)
+}
+
+// playPrice returns the price of a Play details page from its offer
+// metadata, "Free" when it is zero, or "" if the page has none.
+func playPrice(doc *goquery.Document) string {
+	price, ok := doc.Find("[itemprop='offers'] meta[itemprop='price']").First().Attr("content")
+	price = strings.TrimSpace(price)
+	switch {
+	case !ok || price == "":
+		return ""
+	case price == "0":
+		return "Free"
+	}
+	return price
+}
+
+// appStoreKidsGenre is the genre the iTunes lookup lists for apps in the
+// App Store Kids category, next to their primary genre.
+const appStoreKidsGenre = "Kids"
+
+// urlCountry and urlLang localize the store URLs written for records
+// (--country, --lang). Pages are still fetched from the bare URLs, whose
+// English text the parsers expect.
+var urlCountry, urlLang string
+
+// reLang matches the language tags the stores accept, such as ja or pt-BR.
+var reLang = regexp.MustCompile(`^[a-z]{2,3}([-_][A-Za-z0-9]{2,4})?$`)
+
+// parseCountry validates a --country value, returning it lowercased.
+func parseCountry(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s != "" && (len(s) != 2 || strings.Trim(s, "abcdefghijklmnopqrstuvwxyz") != "") {
+		return "", fmt.Errorf("%q is not a two-letter country code such as jp", s)
+	}
+	return s, nil
+}
+
+// parseLang validates a --lang value.
+func parseLang(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s != "" && !reLang.MatchString(s) {
+		return "", fmt.Errorf("%q is not a language code such as ja or pt-BR", s)
+	}
+	return s, nil
+}
+
+// appStorePageURL and playStorePageURL return the bare store page URLs.
+func appStorePageURL(appID string) string {
+	return fmt.Sprintf("https://apps.apple.com/app/id%s", appID)
+}
+
+func playStorePageURL(pkg string) string {
 	return fmt.Sprintf("https://play.google.com/store/apps/details?id=%s", pkg)
 }
 
+// buildAppStoreURL returns the canonical App Store URL of appID, in the
+// --country storefront and --lang language when set.
+func buildAppStoreURL(appID string) string {
+	u := appStorePageURL(appID)
+	if urlCountry != "" {
+		u = fmt.Sprintf("https://apps.apple.com/%s/app/id%s", urlCountry, appID)
+	}
+	if urlLang != "" {
+		u += "?l=" + urlLang
+	}
+	return u
+}
+
+// buildPlayStoreURL returns the canonical Google Play URL of pkg, with the
+// --lang and --country parameters when set.
+func buildPlayStoreURL(pkg string) string {
+	u := playStorePageURL(pkg)
+	if urlLang != "" {
+		u += "&hl=" + urlLang
+	}
+	if urlCountry != "" {
+		u += "&gl=" + strings.ToUpper(urlCountry)
+	}
+	return u
+}
+
+// storeURL returns the store page URL of id on platform, or "" for an iOS
+// bundle identifier, whose numeric id only its lookup tells.
+func storeURL(platform, id string) string {
+	if platform == platformIOS {
+		if !reIOS.MatchString(id) {
+			return ""
+		}
+		return buildAppStoreURL(id)
+	}
+	return buildPlayStoreURL(id)
+}
+
 func isNotFoundError(err error) bool {
 	if err == nil {
 		return false
@@ -365,52 +1218,63 @@ func isNotFoundError(err error) bool {
 		strings.Contains(errStr, "unable to parse")
 }
 
-func searchAndroidPackage(pkg string) (string, error) {
+func searchAndroidPackage(ctx context.Context, pkg string) (string, error) {
+	pkgs, err := searchAndroid(ctx, pkg)
+	if err != nil {
+		return "", err
+	}
+	for _, found := range pkgs {
+		// Case-insensitive comparison
+		if strings.EqualFold(found, pkg) {
+			return found, nil
+		}
+	}
+	return "", fmt.Errorf("package not found in search results")
+}
+
+// searchAndroid returns the distinct package names linked from the Play
+// search results for query, in page order.
+func searchAndroid(ctx context.Context, query string) ([]string, error) {
 	searchURL := fmt.Sprintf("https://play.google.com/store/search?c=apps&q=%s",
-		url.QueryEscape(pkg))
+		url.QueryEscape(query))
 
-	resp, err := httpClient.Get(searchURL)
+	resp, err := httpGet(ctx, searchURL)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("search failed: %s", resp.Status)
+		return nil, fmt.Errorf("search failed: %s", resp.Status)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parseHTMLResponse(resp)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return detailsPackages(doc), nil
+}
 
-	// Extract package names from search results
-	var foundPkg string
-	doc.Find("a[href*='/store/apps/details?id=']").EachWithBreak(func(i int, s *goquery.Selection) bool {
+// detailsPackages returns the packages of the details pages doc links to,
+// in page order without duplicates.
+func detailsPackages(doc *goquery.Document) []string {
+	var pkgs []string
+	seen := map[string]bool{}
+	doc.Find("a[href*='/store/apps/details?id=']").Each(func(i int, s *goquery.Selection) {
 		href, exists := s.Attr("href")
 		if !exists {
-			return true // continue
+			return
 		}
 
 		// Extract package name from URL
 		extractedPkg := extractPackageFromURL(href)
-		if extractedPkg == "" {
-			return true // continue
+		if extractedPkg == "" || seen[extractedPkg] {
+			return
 		}
-
-		// Case-insensitive comparison
-		if strings.EqualFold(extractedPkg, pkg) {
-			foundPkg = extractedPkg
-			return false // break
-		}
-		return true // continue
+		seen[extractedPkg] = true
+		pkgs = append(pkgs, extractedPkg)
 	})
-
-	if foundPkg == "" {
-		return "", fmt.Errorf("package not found in search results")
-	}
-
-	return foundPkg, nil
+	return pkgs
 }
 
 func extractPackageFromURL(href string) string {
@@ -1,8 +1,17 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
 func TestSanitize(t *testing.T) {
@@ -42,13 +51,42 @@ func TestSanitize(t *testing.T) {
 	}
 }
 
+func TestPlatformOf(t *testing.T) {
+	cases := []struct {
+		id      string
+		strict  string
+		lenient string
+	}{
+		{"123456789", platformIOS, platformIOS},
+		{"com.example.app", platformAndroid, platformAndroid},
+		{"com.example.app_2", platformAndroid, platformAndroid},
+		{"com.1example.app", platformUnknown, platformAndroid},
+		{"_com.example", platformUnknown, platformAndroid},
+		{"example", platformUnknown, platformUnknown},
+		{"com..example", platformUnknown, platformUnknown},
+		{"ios:com.example.my-app", platformIOS, platformIOS},
+		{"ios:example", platformUnknown, platformUnknown},
+	}
+	defer func() { lenientIDs = false }()
+	for _, tc := range cases {
+		lenientIDs = false
+		if got := platformOf(tc.id); got != tc.strict {
+			t.Errorf("platformOf(%q) = %s, want %s", tc.id, got, tc.strict)
+		}
+		lenientIDs = true
+		if got := platformOf(tc.id); got != tc.lenient {
+			t.Errorf("lenient platformOf(%q) = %s, want %s", tc.id, got, tc.lenient)
+		}
+	}
+}
+
 func TestProcessCSVOutput(t *testing.T) {
 	originalResolve := resolveFunc
 	defer func() {
 		resolveFunc = originalResolve
 	}()
 
-	resolveFunc = func(id string) (record, error) {
+	resolveFunc = func(_ context.Context, id string) (record, error) {
 		if id != "123" {
 			t.Fatalf("unexpected id: %s", id)
 		}
@@ -64,7 +102,7 @@ func TestProcessCSVOutput(t *testing.T) {
 	var out strings.Builder
 	fields := []Field{FieldBundle, FieldName, FieldPublisher, FieldURL}
 
-	if err := process(input, &out, fields, true, false, true); err != nil {
+	if err := process(input, &out, options{Fields: fields, Header: true, CSV: true}); err != nil {
 		t.Fatalf("process returned error: %v", err)
 	}
 
@@ -81,7 +119,7 @@ func TestProcessTSVOutput(t *testing.T) {
 		resolveFunc = originalResolve
 	}()
 
-	resolveFunc = func(id string) (record, error) {
+	resolveFunc = func(_ context.Context, id string) (record, error) {
 		return record{
 			Bundle:    id,
 			Name:      "My\nApp",
@@ -94,7 +132,7 @@ func TestProcessTSVOutput(t *testing.T) {
 	var out strings.Builder
 	fields := []Field{FieldBundle, FieldName, FieldPublisher, FieldURL}
 
-	if err := process(input, &out, fields, true, false, false); err != nil {
+	if err := process(input, &out, options{Fields: fields, Header: true}); err != nil {
 		t.Fatalf("process returned error: %v", err)
 	}
 
@@ -104,3 +142,249 @@ func TestProcessTSVOutput(t *testing.T) {
 		t.Fatalf("tsv output mismatch:\n got: %q\nwant: %q", got, want)
 	}
 }
+
+func TestProcessConcurrentKeepsInputOrder(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+
+	// Later ids finish first.
+	delays := map[string]time.Duration{"1": 30 * time.Millisecond, "2": 20 * time.Millisecond, "3": 10 * time.Millisecond}
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		time.Sleep(delays[id])
+		return record{Bundle: id}, nil
+	}
+
+	var out strings.Builder
+	opts := options{Fields: []Field{FieldBundle}, Concurrency: 3}
+	if err := process(strings.NewReader("1\n\n2\n3\n"), &out, opts); err != nil {
+		t.Fatalf("process returned error: %v", err)
+	}
+	if got, want := out.String(), "1\n\n2\n3\n"; got != want {
+		t.Fatalf("output mismatch:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+// rowSignal records output and closes release once want rows were written.
+type rowSignal struct {
+	strings.Builder
+	want    int
+	release chan struct{}
+}
+
+func (w *rowSignal) Write(p []byte) (int, error) {
+	n, err := w.Builder.Write(p)
+	if strings.Count(w.String(), "\n") == w.want {
+		close(w.release)
+	}
+	return n, err
+}
+
+func TestProcessUnordered(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+
+	// The first id only finishes once the header and the other two rows have
+	// been written.
+	out := &rowSignal{want: 3, release: make(chan struct{})}
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		if id == "com.slow.app" {
+			<-out.release
+		}
+		return record{Bundle: strings.ToLower(id)}, nil
+	}
+
+	opts := options{Fields: []Field{FieldBundle}, Header: true, Concurrency: 3, Unordered: true}
+	if err := process(strings.NewReader("com.slow.app\n\n  111\nCom.Example.App\n"), out, opts); err != nil {
+		t.Fatalf("process returned error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 4 || lines[0] != "input\tbundle" || lines[3] != "com.slow.app\tcom.slow.app" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	rest := []string{lines[1], lines[2]}
+	sort.Strings(rest)
+	if want := []string{"111\t111", "Com.Example.App\tcom.example.app"}; !reflect.DeepEqual(rest, want) {
+		t.Fatalf("rows %q, want %q in any order", rest, want)
+	}
+}
+
+func TestPlayPrice(t *testing.T) {
+	cases := map[string]string{
+		`<span itemprop="offers"><meta itemprop="price" content="0"></span>`:     "Free",
+		`<span itemprop="offers"><meta itemprop="price" content="$4.99"></span>`: "$4.99",
+		`<span itemprop="offers"><meta itemprop="url" content="x"></span>`:       "",
+	}
+	for html, want := range cases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := playPrice(doc); got != want {
+			t.Errorf("playPrice(%s) = %q, want %q", html, got, want)
+		}
+	}
+}
+
+func TestPlayFamilies(t *testing.T) {
+	cases := map[string]bool{
+		`<a href="/store/apps/category/FAMILY?age=AGE_RANGE1">Ages 5 &amp; under</a>`:    true,
+		`<div><span>Teacher Approved</span></div>`:                                       true,
+		`<div><p>Not Teacher Approved, but my kids love it.</p></div>`:                   false,
+		`<a href="/store/apps/category/GAME_PUZZLE">Puzzle</a><span>Contains ads</span>`: false,
+	}
+	for html, want := range cases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := playFamilies(doc); got != want {
+			t.Errorf("playFamilies(%s) = %v, want %v", html, got, want)
+		}
+	}
+}
+
+func TestKidsValue(t *testing.T) {
+	yes := true
+	if got := (record{Kids: &yes}).value(FieldKids); got != "true" {
+		t.Errorf("kids = %q, want true", got)
+	}
+	if got := (record{}).value(FieldKids); got != "" {
+		t.Errorf("kids of an unresolved record = %q, want empty", got)
+	}
+}
+
+func TestLocalizedStoreURLs(t *testing.T) {
+	defer func() { urlCountry, urlLang = "", "" }()
+
+	if got, want := buildAppStoreURL("123"), "https://apps.apple.com/app/id123"; got != want {
+		t.Errorf("bare App Store URL = %q, want %q", got, want)
+	}
+	urlCountry, urlLang = "jp", "ja"
+	if got, want := buildAppStoreURL("123"), "https://apps.apple.com/jp/app/id123?l=ja"; got != want {
+		t.Errorf("App Store URL = %q, want %q", got, want)
+	}
+	if got, want := buildPlayStoreURL("com.example.app"), "https://play.google.com/store/apps/details?id=com.example.app&hl=ja&gl=JP"; got != want {
+		t.Errorf("Play URL = %q, want %q", got, want)
+	}
+	if got, want := playStorePageURL("com.example.app"), "https://play.google.com/store/apps/details?id=com.example.app"; got != want {
+		t.Errorf("Play page URL = %q, want %q", got, want)
+	}
+}
+
+func TestParseCountryAndLang(t *testing.T) {
+	if c, err := parseCountry(" JP "); err != nil || c != "jp" {
+		t.Errorf("parseCountry = %q, %v", c, err)
+	}
+	for _, bad := range []string{"jpn", "j1"} {
+		if _, err := parseCountry(bad); err == nil {
+			t.Errorf("parseCountry(%q) succeeded", bad)
+		}
+	}
+	for _, good := range []string{"", "ja", "pt-BR", "zh_Hant"} {
+		if _, err := parseLang(good); err != nil {
+			t.Errorf("parseLang(%q): %v", good, err)
+		}
+	}
+	if _, err := parseLang("ja&gl=US"); err == nil {
+		t.Error("parseLang accepted a query string")
+	}
+}
+
+func TestFetchIOSStoreURL(t *testing.T) {
+	originalClient, originalSelected := httpClient, selectedFields
+	defer func() {
+		httpClient, selectedFields = originalClient, originalSelected
+	}()
+	selectFields([]Field{FieldStoreURL})
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://itunes.apple.com/lookup?id=123": `{"resultCount":1,"results":[{"trackName":"App","trackViewUrl":"https://apps.apple.com/us/app/app/id123?uo=4"}]}`,
+	}}
+
+	rec, err := fetchIOS(context.Background(), "123")
+	if err != nil {
+		t.Fatalf("fetchIOS returned error: %v", err)
+	}
+	if got, want := rec.value(FieldStoreURL), "https://apps.apple.com/us/app/app/id123?uo=4"; got != want {
+		t.Errorf("store_url = %q, want %q", got, want)
+	}
+	if got, want := rec.URL, "https://apps.apple.com/app/id123"; got != want {
+		t.Errorf("url = %q, want %q", got, want)
+	}
+}
+
+func TestFetchIOSBundleID(t *testing.T) {
+	originalClient, originalSelected := httpClient, selectedFields
+	defer func() {
+		httpClient, selectedFields = originalClient, originalSelected
+	}()
+	selectFields([]Field{FieldTrackID})
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://itunes.apple.com/lookup?bundleId=com.example.app": `{"resultCount":1,"results":[{"trackId":123,"trackName":"App","bundleId":"com.example.app","artistId":456}]}`,
+	}}
+
+	rec, err := fetchIOS(context.Background(), "ios:com.example.app")
+	if err != nil {
+		t.Fatalf("fetchIOS returned error: %v", err)
+	}
+	if rec.Bundle != "123" || rec.value(FieldTrackID) != "123" || rec.URL != "https://apps.apple.com/app/id123" {
+		t.Errorf("got bundle %q, track_id %q, url %q", rec.Bundle, rec.TrackID, rec.URL)
+	}
+	if got := rec.value(FieldBundleID) + "," + rec.value(FieldPackage) + "," + rec.value(FieldDeveloperID); got != "com.example.app,,456" {
+		t.Errorf("bundle_id,package,developer_id = %q", got)
+	}
+
+	rec, err = fetchIOS(context.Background(), "ios:com.missing.app")
+	if err == nil || rec.URL != "" {
+		t.Errorf("missing bundle id: url %q, err %v", rec.URL, err)
+	}
+}
+
+func TestParseFieldsPreset(t *testing.T) {
+	fields, err := parseFields("name,ids,bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Field{FieldName, FieldBundle, FieldPlatform, FieldTrackID, FieldBundleID, FieldPackage, FieldDeveloperID}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("parseFields = %v, want %v", fields, want)
+	}
+	if got := (record{Bundle: "com.example.app"}).value(FieldPackage); got != "com.example.app" {
+		t.Errorf("package of an Android record = %q", got)
+	}
+}
+
+func TestReplacement(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.txt")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	discarded, err := createReplacement(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	discarded.WriteString("discarded\n")
+	discarded.discard()
+
+	r, err := createReplacement(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.discard()
+	r.WriteString("new\n")
+	if data, _ := os.ReadFile(path); string(data) != "old\n" {
+		t.Errorf("before commit, file = %q", data)
+	}
+	if err := r.commit(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new\n" {
+		t.Errorf("after commit, file = %q", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only %s", len(entries), path)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// sourceMirror is the source field of records read from --mirror.
+const sourceMirror = "mirror"
+
+// mirrorSource, set by --mirror, is the URL template of a mirror of store
+// listings: {id} is replaced with the input id, and the reply is a JSON
+// object with the keys of an --android-source dump line (see
+// playDumpLine), such as a line of a --snapshot. A 404 means the mirror
+// does not have the app.
+var mirrorSource string
+
+// fetchMirror looks id up in the --mirror.
+func fetchMirror(ctx context.Context, p, id string) (record, error) {
+	rec := record{Bundle: id, URL: storeURL(p, id)}
+	if mirrorSource == "" {
+		return rec, errors.New("no --mirror")
+	}
+	u := strings.ReplaceAll(mirrorSource, "{id}", url.PathEscape(id))
+	resp, err := httpGet(ctx, u)
+	if err != nil {
+		return rec, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return rec, statusError(resp)
+	}
+	var l playDumpLine
+	if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+		return rec, fmt.Errorf("%s: invalid JSON: %w", u, err)
+	}
+	if l.Error != "" {
+		return rec, fmt.Errorf("mirror: %s", l.Error)
+	}
+	listing := l.record()
+	if listing.Name == "" {
+		return rec, errors.New("not found in the mirror")
+	}
+	listing.Bundle, listing.URL, listing.Source = id, rec.URL, sourceMirror
+	return listing, nil
+}
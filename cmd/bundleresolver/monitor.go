@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// monitorWatches are the kinds of change monitor can alert on.
+var monitorWatches = []string{"name", "publisher", "price", "availability"}
+
+// monitor re-resolves a watch-list and alerts on changes since the previous
+// check, keeping every check as a snapshot in its state directory.
+type monitor struct {
+	inputPath   string
+	stateDir    string
+	webhooks    []string
+	watch       map[string]bool
+	concurrency int
+}
+
+func setupMonitor(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var m monitor
+	var watchCSV string
+	var webhooks webhookList
+	var interval time.Duration
+	var once bool
+	fs.StringVar(&m.inputPath, "input", "", "Watch the ids in this file (re-read before every check)")
+	fs.StringVar(&m.stateDir, "state-dir", "", "Keep the snapshot of every check and its changes in this directory")
+	fs.DurationVar(&interval, "interval", time.Hour, "Time between checks")
+	fs.Var(&webhooks, "webhook", "POST a JSON description of the changes to this URL (repeatable)")
+	fs.StringVar(&watchCSV, "watch", strings.Join(monitorWatches, ","), "Comma-separated changes to alert on ("+strings.Join(monitorWatches, ", ")+")")
+	fs.IntVar(&m.concurrency, "concurrency", 4, "Number of lookups run in parallel")
+	fs.BoolVar(&once, "once", false, "Run a single check and exit, e.g. from cron")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q (the watch-list is read from --input)", args)
+		}
+		if m.inputPath == "" || m.stateDir == "" {
+			return errors.New("monitor requires --input and --state-dir")
+		}
+		if interval <= 0 {
+			return fmt.Errorf("invalid --interval %s", interval)
+		}
+		if m.concurrency < 1 {
+			return fmt.Errorf("invalid --concurrency %d (must be at least 1)", m.concurrency)
+		}
+		var err error
+		if m.watch, err = parseWatch(watchCSV); err != nil {
+			return fmt.Errorf("invalid --watch: %w", err)
+		}
+		m.webhooks = webhooks
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		if once {
+			return m.check(ctx, time.Now())
+		}
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		for {
+			if err := m.check(ctx, time.Now()); err != nil {
+				// A failed check must not stop monitoring; report and try again later.
+				logger.Error("monitor check failed", "err", err)
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(interval):
+			}
+		}
+	}
+}
+
+func parseWatch(csv string) (map[string]bool, error) {
+	watch := map[string]bool{}
+	for _, w := range strings.Split(csv, ",") {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		if !containsString(monitorWatches, w) {
+			return nil, fmt.Errorf("unknown change %q (want %s)", w, strings.Join(monitorWatches, ", "))
+		}
+		watch[w] = true
+	}
+	if len(watch) == 0 {
+		return nil, errors.New("nothing to watch")
+	}
+	return watch, nil
+}
+
+// webhookList collects repeated --webhook flags.
+type webhookList []string
+
+func (l *webhookList) String() string { return strings.Join(*l, ",") }
+
+func (l *webhookList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// check resolves the watch-list once, saves the snapshot and posts the
+// watched changes to the webhooks.
+func (m *monitor) check(ctx context.Context, at time.Time) error {
+	newTorCircuit() // each check is a --tor batch
+	f, err := os.Open(m.inputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fields := []Field{FieldBundle, FieldName, FieldPublisher, FieldURL, FieldPrice}
+	selectFields(fields)
+	snap := &snapshot{}
+	if err := process(f, io.Discard, options{Fields: fields, Concurrency: m.concurrency, Snapshot: snap}); err != nil {
+		return err
+	}
+
+	_, prev, err := latestSnapshot(m.stateDir)
+	if err != nil {
+		return err
+	}
+	changes, err := saveSnapshot(m.stateDir, at, carryOver(prev, snap.entries))
+	if err != nil {
+		return err
+	}
+	alerts := m.alerts(changes)
+	if len(alerts) == 0 {
+		return nil
+	}
+	logger.Warn("watched apps changed", "changes", len(alerts))
+	payload := newWebhookPayload(at, alerts)
+	for _, u := range m.webhooks {
+		if err := postWebhook(ctx, u, payload); err != nil {
+			logger.Error("webhook failed", "url", u, "err", err)
+		}
+	}
+	return nil
+}
+
+// carryOver replaces lookups that failed for reasons other than the app
+// being gone (timeouts, store outages) with their previous state, so a
+// flaky network is not reported as a delisting.
+func carryOver(prev, cur []snapshotEntry) []snapshotEntry {
+	byInput := make(map[string]snapshotEntry, len(prev))
+	for _, e := range prev {
+		byInput[e.Input] = e
+	}
+	out := make([]snapshotEntry, len(cur))
+	for i, e := range cur {
+		if p, ok := byInput[e.Input]; ok && e.Error != "" && !e.NotFound {
+			logger.Warn("lookup failed, keeping previous state", "id", e.Input, "err", e.Error)
+			e = p
+		}
+		out[i] = e
+	}
+	return out
+}
+
+// alerts keeps the changes m watches. Ids added to or removed from the
+// watch-list are not changes of the apps themselves.
+func (m *monitor) alerts(changes []snapshotChange) []snapshotChange {
+	var alerts []snapshotChange
+	for _, c := range changes {
+		switch c.Kind {
+		case "changed":
+			if !m.watch[c.Field] {
+				continue
+			}
+		case "failed", "recovered":
+			if !m.watch["availability"] {
+				continue
+			}
+		default:
+			continue
+		}
+		alerts = append(alerts, c)
+	}
+	return alerts
+}
+
+// webhookPayload is the body posted to --webhook. text summarizes the
+// changes for chat services that display it (Slack, Mattermost).
+type webhookPayload struct {
+	Text    string           `json:"text"`
+	At      time.Time        `json:"at"`
+	Changes []snapshotChange `json:"changes"`
+}
+
+func newWebhookPayload(at time.Time, changes []snapshotChange) webhookPayload {
+	lines := make([]string, len(changes))
+	for i, c := range changes {
+		switch c.Kind {
+		case "changed":
+			lines[i] = fmt.Sprintf("%s: %s changed from %q to %q", c.Bundle, c.Field, c.Old, c.New)
+		case "failed":
+			lines[i] = fmt.Sprintf("%s: no longer available (%s)", c.Bundle, c.New)
+		case "recovered":
+			lines[i] = fmt.Sprintf("%s: available again", c.Bundle)
+		}
+	}
+	return webhookPayload{Text: strings.Join(lines, "\n"), At: at, Changes: changes}
+}
+
+func postWebhook(ctx context.Context, u string, payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
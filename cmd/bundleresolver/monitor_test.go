@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMonitorCheck(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	apps := map[string]record{
+		"1": {Bundle: "1", Name: "One", Publisher: "Acme", Price: "Free"},
+		"2": {Bundle: "2", Name: "Two", Publisher: "Acme", Price: "$0.99"},
+		"3": {Bundle: "3", Name: "Three", Publisher: "Acme"},
+	}
+	var lookupErr = map[string]error{}
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		if err := lookupErr[id]; err != nil {
+			return record{Bundle: id}, err
+		}
+		return apps[id], nil
+	}
+
+	var got []webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("decode webhook: %v", err)
+		}
+		got = append(got, p)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "watch.txt")
+	if err := os.WriteFile(input, []byte("1\n2\n3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := &monitor{
+		inputPath:   input,
+		stateDir:    filepath.Join(dir, "state"),
+		webhooks:    []string{srv.URL},
+		watch:       map[string]bool{"name": true, "price": true, "availability": true},
+		concurrency: 2,
+	}
+	at := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	if err := m.check(context.Background(), at); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("first check posted %+v", got)
+	}
+
+	apps["1"] = record{Bundle: "1", Name: "One Pro", Publisher: "Globex", Price: "Free"}
+	lookupErr["2"] = errors.New("context deadline exceeded")
+	lookupErr["3"] = errors.New("status 404 Not Found")
+	if err := m.check(context.Background(), at.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	want := []snapshotChange{
+		{Input: "1", Bundle: "1", Kind: "changed", Field: "name", Old: "One", New: "One Pro"},
+		{Input: "3", Bundle: "3", Kind: "failed", New: "status 404 Not Found"},
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Changes, want) {
+		t.Fatalf("webhooks = %+v, want one with %+v", got, want)
+	}
+	if got[0].Text == "" {
+		t.Error("webhook text is empty")
+	}
+}
+
+func TestParseWatch(t *testing.T) {
+	if _, err := parseWatch("name,rating"); err == nil {
+		t.Error("unknown change accepted")
+	}
+	w, err := parseWatch("name, price")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w, map[string]bool{"name": true, "price": true}) {
+		t.Errorf("watch = %v", w)
+	}
+}
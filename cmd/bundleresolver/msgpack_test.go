@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMsgpackEncoder(t *testing.T) {
+	var out bytes.Buffer
+	if err := (msgpackEncoder{}).encode(&out, record{Bundle: "1", Name: "A", Input: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "85" + "a6" + hex.EncodeToString([]byte("bundle")) + "a131" +
+		"a5" + hex.EncodeToString([]byte("input")) + "a131" +
+		"a4" + hex.EncodeToString([]byte("name")) + "a141" +
+		"a9" + hex.EncodeToString([]byte("publisher")) + "a0" +
+		"a3" + hex.EncodeToString([]byte("url")) + "a0"
+	if got := hex.EncodeToString(out.Bytes()); got != want {
+		t.Errorf("encoding = %s, want %s", got, want)
+	}
+}
+
+func TestAppendMsgpack(t *testing.T) {
+	cases := []struct {
+		v    any
+		want string
+	}{
+		{nil, "c0"},
+		{true, "c3"},
+		{json.Number("5"), "05"},
+		{json.Number("-3"), "fd"},
+		{json.Number("300"), "d20000012c"},
+		{json.Number("1.5"), "cb3ff8000000000000"},
+		{strings.Repeat("x", 40), "d928" + strings.Repeat("78", 40)},
+		{[]any{false, "a"}, "92c2a161"},
+		{map[string]any{"b": nil, "a": json.Number("1")}, "82a16101a162c0"},
+	}
+	for _, tc := range cases {
+		b, err := appendMsgpack(nil, tc.v)
+		if err != nil {
+			t.Errorf("appendMsgpack(%v): %v", tc.v, err)
+			continue
+		}
+		if got := hex.EncodeToString(b); got != tc.want {
+			t.Errorf("appendMsgpack(%v) = %s, want %s", tc.v, got, tc.want)
+		}
+	}
+}
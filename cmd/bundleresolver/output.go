@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// sanitizeMode selects how tabs and newlines inside values are written.
+type sanitizeMode string
+
+const (
+	sanitizeStrip  sanitizeMode = "strip"  // replace them with a space (default)
+	sanitizeQuote  sanitizeMode = "quote"  // keep them and quote the value as in RFC4180
+	sanitizeEscape sanitizeMode = "escape" // write them as \t, \n, \r (and \ as \\)
+)
+
+func parseSanitizeMode(s string) (sanitizeMode, error) {
+	switch m := sanitizeMode(s); m {
+	case sanitizeStrip, sanitizeQuote, sanitizeEscape:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown sanitize mode %q (want strip, quote or escape)", s)
+}
+
+var valueEscapes = map[rune]string{'\t': `\t`, '\n': `\n`, '\r': `\r`, '\\': `\\`}
+
+// sanitizeValue cleans s for output according to mode. Control and format
+// characters other than tabs and newlines are dropped in every mode.
+func sanitizeValue(s string, mode sanitizeMode) string {
+	if mode == sanitizeStrip || mode == "" {
+		return sanitize(s)
+	}
+	s = strings.TrimSpace(s)
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch r {
+		case '\t', '\n', '\r', '\\':
+			if mode == sanitizeEscape {
+				b.WriteString(valueEscapes[r])
+			} else {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		if unicode.Is(unicode.Cc, r) || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// invisibleFillers are blank-rendering characters outside the format
+// category (which sanitize already drops) that are abused to make names
+// look empty or to spoof other apps.
+var invisibleFillers = map[rune]bool{
+	'\u034F': true, // combining grapheme joiner
+	'\u115F': true, // Hangul choseong filler
+	'\u1160': true, // Hangul jungseong filler
+	'\u2800': true, // braille pattern blank
+	'\u3164': true, // Hangul filler
+	'\uFFA0': true, // halfwidth Hangul filler
+}
+
+// normalizeUnicode NFC-normalizes s and removes invisible filler characters,
+// so visually identical names compare equal downstream.
+func normalizeUnicode(s string) string {
+	s = norm.NFC.String(s)
+	if !strings.ContainsFunc(s, func(r rune) bool { return invisibleFillers[r] }) {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if invisibleFillers[r] {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// rowWriter renders records as TSV or CSV rows restricted to the selected
+// fields. Rows are buffered until flush is called, by autoFlush or, with
+// flushEachRow, after every row. Methods are safe for concurrent use.
+type rowWriter struct {
+	fields       []Field
+	mode         sanitizeMode
+	normalize    bool
+	flushEachRow bool
+	// script, when set, rewrites every record row and names the columns.
+	script *rowScript
+	// encoder, when set, renders records in a document format instead of
+	// rows (see useEncoder).
+	encoder recordEncoder
+
+	mu          sync.Mutex
+	buf         *bufio.Writer
+	write       func([]string) error
+	flushBuffer func() error
+	started     bool // the encoder's preamble is written
+}
+
+// recordEncoder renders records as a document: a preamble, one entry per
+// record and a closing part.
+type recordEncoder interface {
+	begin(w io.Writer) error
+	encode(w io.Writer, rec record) error
+	end(w io.Writer) error
+}
+
+// outputFormats are the values of --output-format.
+var outputFormats = []string{"tsv", "csv", "rss", "html", "proto", "msgpack"}
+
+// formatExtensions maps each output format to the extension of its files,
+// for --output and --schedule --output-dir.
+var formatExtensions = map[string]string{"tsv": ".tsv", "csv": ".csv", "rss": ".xml", "html": ".html", "proto": ".pb", "msgpack": ".msgpack"}
+
+// formatOfPath infers the output format from a file name's extension,
+// defaulting to tsv.
+func formatOfPath(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".htm":
+		return "html"
+	case ".rss":
+		return "rss"
+	}
+	for format, e := range formatExtensions {
+		if e == ext {
+			return format
+		}
+	}
+	return "tsv"
+}
+
+// newEncoder returns the encoder of a document format rendering fields, or
+// nil for the row formats (tsv and csv).
+func newEncoder(format string, fields []Field) (recordEncoder, error) {
+	switch format {
+	case "", "tsv", "csv":
+		return nil, nil
+	case "rss":
+		return rssEncoder{}, nil
+	case "html":
+		return reportEncoder{fields: fields}, nil
+	case "proto":
+		return protoEncoder{}, nil
+	case "msgpack":
+		return msgpackEncoder{}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (want %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// outputBufferSize is large enough that million-row runs make few write
+// syscalls.
+const outputBufferSize = 64 << 10
+
+func newRowWriter(w io.Writer, fields []Field, csvOutput bool, mode sanitizeMode) *rowWriter {
+	buf := bufio.NewWriterSize(w, outputBufferSize)
+	rw := &rowWriter{fields: fields, mode: mode, buf: buf}
+	// Values keeping raw tabs/newlines need RFC4180 quoting even in TSV.
+	if csvOutput || mode == sanitizeQuote {
+		csvWriter := csv.NewWriter(buf)
+		if !csvOutput {
+			csvWriter.Comma = '\t'
+		}
+		rw.write = csvWriter.Write
+		rw.flushBuffer = func() error {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+			return buf.Flush()
+		}
+	} else {
+		rw.write = func(cols []string) error {
+			buf.WriteString(strings.Join(cols, "\t"))
+			return buf.WriteByte('\n')
+		}
+		rw.flushBuffer = buf.Flush
+	}
+	return rw
+}
+
+func (rw *rowWriter) writeRow(cols []string) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if err := rw.write(cols); err != nil {
+		return err
+	}
+	if rw.flushEachRow {
+		return rw.flushBuffer()
+	}
+	return nil
+}
+
+// flush writes out buffered rows.
+func (rw *rowWriter) flush() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.flushBuffer()
+}
+
+// autoFlush flushes buffered rows every interval until stop is called, so
+// tail -f consumers see partial results of long runs.
+func (rw *rowWriter) autoFlush(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := rw.flush(); err != nil {
+					// The final flush reports the error to the caller.
+					logger.Debug("periodic output flush failed", "err", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		wg.Wait()
+	}
+}
+
+// useEncoder makes rw render records with e. Headers are then left out,
+// and close must be called to complete the document.
+func (rw *rowWriter) useEncoder(e recordEncoder) {
+	rw.encoder = e
+}
+
+// encodeRecord renders rec with the encoder, after its preamble.
+func (rw *rowWriter) encodeRecord(rec record) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if err := rw.begin(); err != nil {
+		return err
+	}
+	if err := rw.encoder.encode(rw.buf, rec); err != nil {
+		return err
+	}
+	if rw.flushEachRow {
+		return rw.flushBuffer()
+	}
+	return nil
+}
+
+func (rw *rowWriter) begin() error {
+	if rw.started {
+		return nil
+	}
+	rw.started = true
+	return rw.encoder.begin(rw.buf)
+}
+
+// close completes the document of an encoder, if any, and flushes.
+func (rw *rowWriter) close() error {
+	if rw.encoder != nil {
+		rw.mu.Lock()
+		err := rw.begin()
+		if err == nil {
+			err = rw.encoder.end(rw.buf)
+		}
+		rw.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return rw.flush()
+}
+
+func (rw *rowWriter) writeHeader() error {
+	if rw.encoder != nil {
+		return nil
+	}
+	if rw.script != nil {
+		return rw.writeRow(append([]string(nil), rw.script.columns...))
+	}
+	names := make([]string, len(rw.fields))
+	for i, f := range rw.fields {
+		names[i] = string(f)
+	}
+	return rw.writeRow(names)
+}
+
+func (rw *rowWriter) writeRecord(rec record) error {
+	if rw.encoder != nil {
+		if rec.Bundle == "" {
+			return nil // blank lines have no entry
+		}
+		return rw.encodeRecord(rec)
+	}
+	cols := make([]string, len(rw.fields))
+	for i, f := range rw.fields {
+		cols[i] = rec.value(f)
+	}
+	if rw.script != nil {
+		if rec.Input == "" {
+			// A blank input line keeps its empty row.
+			return rw.writeValues(make([]string, len(rw.script.columns))...)
+		}
+		var keep bool
+		var err error
+		if cols, keep, err = rw.script.apply(rw.fields, rec, cols); err != nil || !keep {
+			return err
+		}
+	}
+	return rw.writeValues(cols...)
+}
+
+// writeValues writes a row of raw values, sanitized like record fields. It
+// serves commands whose columns are not record fields.
+func (rw *rowWriter) writeValues(cols ...string) error {
+	for i, v := range cols {
+		if rw.normalize {
+			v = normalizeUnicode(v)
+		}
+		cols[i] = sanitizeValue(v, rw.mode)
+	}
+	return rw.writeRow(cols)
+}
+
+// value returns the raw (unsanitized) value of field f.
+func (rec record) value(f Field) string {
+	switch f {
+	case FieldBundle:
+		return rec.Bundle
+	case FieldName:
+		return rec.Name
+	case FieldNameLang:
+		return nameLanguage(rec.Name)
+	case FieldNameLatin:
+		return romanize(rec.Name)
+	case FieldPublisher:
+		return rec.Publisher
+	case FieldURL:
+		return rec.URL
+	case FieldResolvedAt:
+		return rec.ResolvedAt
+	case FieldResolvedURL:
+		return rec.ResolvedURL
+	case FieldStoreURL:
+		return rec.StoreURL
+	case FieldTrackID:
+		return rec.TrackID
+	case FieldBundleID:
+		return rec.BundleID
+	case FieldPackage:
+		if platformOf(rec.Bundle) == platformAndroid {
+			return rec.Bundle
+		}
+		return ""
+	case FieldDeveloperID:
+		return rec.DeveloperID
+	case FieldSource:
+		return rec.sourceValue()
+	case FieldDeveloperEmail:
+		return rec.DeveloperEmail
+	case FieldInput:
+		return rec.Input
+	case FieldStatus:
+		if rec.Status == "" && rec.Bundle != "" {
+			return rowStatusOK
+		}
+		return rec.Status
+	case FieldPartial:
+		return rec.partialValue()
+	case FieldPublisherCountry, FieldPublisherCountrySource:
+		if rec.Status != "" {
+			return ""
+		}
+		country, source := rec.publisherCountry()
+		if f == FieldPublisherCountrySource {
+			return source
+		}
+		return country
+	case FieldStatusReason:
+		return rec.StatusReason
+	case FieldWebsite:
+		return rec.Website
+	case FieldPlatform:
+		if rec.Bundle == "" {
+			return ""
+		}
+		return platformOf(rec.Bundle)
+	case FieldPrice:
+		return rec.Price
+	case FieldCountry:
+		return rec.Country
+	case FieldAvailable:
+		return rec.availableValue()
+	case FieldCategory:
+		return rec.Category
+	case FieldDetection:
+		return rec.detectionValue()
+	case FieldConfidence:
+		return rec.confidence()
+	case FieldBadges:
+		return strings.Join(rec.Badges, ",")
+	case FieldLifecycle:
+		return rec.Lifecycle
+	case FieldKids:
+		return boolValue(rec.Kids)
+	case FieldGameCenter:
+		return boolValue(rec.GameCenter)
+	case FieldPlayGames:
+		return boolValue(rec.PlayGames)
+	case FieldController:
+		return boolValue(rec.Controller)
+	case FieldIABCategory:
+		return iabCategory(platformOf(rec.Bundle), rec.CategoryID)
+	case FieldVersion, FieldVersionDate, FieldReleaseNotes:
+		return versionValue(rec.History, f)
+	case FieldPrivacyTracking, FieldPrivacyLinked, FieldPrivacyNotLinked:
+		return rec.Privacy.value(f)
+	case FieldDataShared, FieldDataCollected, FieldSecurityPractices:
+		return rec.DataSafety.value(f)
+	case FieldPlatforms:
+		return strings.Join(rec.Platforms, ",")
+	case FieldDeviceFamily:
+		return rec.DeviceFamily
+	case FieldMinSDK:
+		return sdkValue(rec.MinSDK)
+	case FieldTargetSDK:
+		return sdkValue(rec.TargetSDK)
+	case FieldPlayTracks:
+		return playTracksValue(rec.PlayReleases)
+	case FieldIAPItems:
+		return iapValue(rec.InAppPurchases)
+	case FieldTrader, FieldTraderName, FieldTraderAddress, FieldTraderCountry, FieldTraderPhone:
+		return rec.Trader.value(f)
+	}
+	v, _ := rec.priceValue(f)
+	return v
+}
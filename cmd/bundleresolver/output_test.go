@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSanitizeValueModes(t *testing.T) {
+	in := " My\tApp\nName \\ \u202a"
+	cases := []struct {
+		mode sanitizeMode
+		want string
+	}{
+		{sanitizeStrip, "My App Name \\"},
+		{sanitizeQuote, "My\tApp\nName \\"},
+		{sanitizeEscape, `My\tApp\nName \\`},
+	}
+	for _, tc := range cases {
+		if got := sanitizeValue(in, tc.mode); got != tc.want {
+			t.Errorf("sanitizeValue(%q, %s) = %q, want %q", in, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestProcessTSVQuoteMode(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return record{Bundle: id, Name: "My\tApp"}, nil
+	}
+
+	var out strings.Builder
+	opts := options{Fields: []Field{FieldBundle, FieldName}, Sanitize: sanitizeQuote}
+	if err := process(strings.NewReader("123\n"), &out, opts); err != nil {
+		t.Fatalf("process returned error: %v", err)
+	}
+	if got, want := out.String(), "123\t\"My\tApp\"\n"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeUnicode(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"Cafe\u0301", "Caf\u00e9"},    // decomposed e + acute -> precomposed
+		{"\u3164\u3164Game", "Game"},   // Hangul fillers used as fake blanks
+		{"Super\u2800App", "SuperApp"}, // braille blank
+		{"Plain name", "Plain name"},
+	}
+	for _, tc := range cases {
+		if got := normalizeUnicode(tc.in); got != tc.want {
+			t.Errorf("normalizeUnicode(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	// Bidi and zero-width controls are format characters, stripped by every sanitize mode.
+	if got := sanitizeValue("\u202EppA\u202C\u200B", sanitizeStrip); got != "ppA" {
+		t.Errorf("bidi controls survived: %q", got)
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe for the autoFlush goroutine.
+type lockedBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.String()
+}
+
+func TestRowWriterAutoFlush(t *testing.T) {
+	var out lockedBuffer
+	rw := newRowWriter(&out, []Field{FieldBundle}, false, sanitizeStrip)
+	stop := rw.autoFlush(100 * time.Millisecond)
+	defer stop()
+
+	if err := rw.writeRecord(record{Bundle: "123"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "" {
+		t.Fatalf("row written before a flush: %q", got)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for out.String() == "" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := out.String(); got != "123\n" {
+		t.Errorf("after the flush interval got %q, want %q", got, "123\n")
+	}
+}
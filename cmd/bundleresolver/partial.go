@@ -0,0 +1,28 @@
+package main
+
+import "strconv"
+
+// markPartial flags rec, just found, as partial if its name was read but
+// not its publisher: on Play, a layout change usually breaks the publisher
+// first. Only the store's are worth a warning; other sources often lack
+// publishers altogether.
+func markPartial(rec *record, source string) {
+	if rec.Name == "" || rec.Publisher != "" {
+		return
+	}
+	rec.Partial = true
+	if source == sourceStore {
+		logger.Warn("publisher not found, writing a partial record", "id", rec.Bundle)
+	} else {
+		logger.Debug("publisher not found, writing a partial record", "id", rec.Bundle, "source", source)
+	}
+}
+
+// partialValue renders the partial field, empty for failed lookups and
+// blank lines.
+func (rec record) partialValue() string {
+	if rec.Status != "" || rec.Bundle == "" {
+		return ""
+	}
+	return strconv.FormatBool(rec.Partial)
+}
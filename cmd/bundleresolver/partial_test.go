@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProcessPartial(t *testing.T) {
+	originalClient := httpClient
+	defer func() {
+		httpClient = originalClient
+	}()
+	httpClient = &http.Client{Transport: fakeTransport{
+		// A layout whose publisher link bundleresolver no longer finds.
+		playStorePageURL("com.example.app"):   `<html><h1><span>AppName</span></h1><div class="developer">Example Inc.</div></html>`,
+		playStorePageURL("com.example.whole"): `<html><h1><span>Whole</span></h1><a href="/store/apps/dev?id=1"><span>Whole Inc.</span></a></html>`,
+	}}
+	fields := []Field{FieldBundle, FieldName, FieldPublisher, FieldStatus, FieldPartial}
+	selectFields(fields)
+	var out strings.Builder
+	if err := process(strings.NewReader("com.example.app\ncom.example.whole\n"), &out, options{Fields: fields}); err != nil {
+		t.Fatal(err)
+	}
+	want := "com.example.app\tAppName\t\tok\ttrue\n" +
+		"com.example.whole\tWhole\tWhole Inc.\tok\tfalse\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// playAPIBase is the root of the Google Play Developer API.
+var playAPIBase = "https://androidpublisher.googleapis.com/androidpublisher/v3/applications/"
+
+// playAPIScope is the OAuth scope of the Play Developer API.
+const playAPIScope = "https://www.googleapis.com/auth/androidpublisher"
+
+// playAPI, set by --play-service-account, looks Android ids up through the
+// Play Developer API as well as their public page.
+var playAPI *playDeveloperAPI
+
+// playRelease is a release of an Android app on one of its Play tracks, as
+// only its developer can see through the Play Developer API.
+type playRelease struct {
+	Track string `json:"track"`
+	// Name is the release name, usually its version name.
+	Name         string   `json:"name,omitempty"`
+	Status       string   `json:"status"`
+	VersionCodes []string `json:"version_codes,omitempty"`
+	// UserFraction is the share of users a staged rollout has reached.
+	UserFraction float64 `json:"user_fraction,omitempty"`
+}
+
+// playDeveloperAPI signs in to the Play Developer API as a service account.
+// It is safe for concurrent use.
+type playDeveloperAPI struct {
+	email    string
+	key      *rsa.PrivateKey
+	tokenURI string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+
+	// developers maps the developers (see developerKey) the account was
+	// refused an app of to false, and those it could read one of to true.
+	devMu      sync.Mutex
+	developers map[string]bool
+}
+
+// loadPlayServiceAccount reads a service account's JSON key file, as the
+// Google Cloud console downloads it.
+func loadPlayServiceAccount(path string) (*playDeveloperAPI, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var account struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("%s: invalid JSON: %w", path, err)
+	}
+	if account.Type != "service_account" || account.ClientEmail == "" {
+		return nil, fmt.Errorf("%s is not a service account key", path)
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("%s: private_key is not PEM", path)
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		key, _ = parsed.(*rsa.PrivateKey)
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("%s: invalid private_key: %w", path, err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%s: private_key is not an RSA key", path)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &playDeveloperAPI{email: account.ClientEmail, key: key, tokenURI: account.TokenURI, developers: map[string]bool{}}, nil
+}
+
+// jwt returns the signed assertion exchanged for an access token.
+func (a *playDeveloperAPI) jwt(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	claims, err := json.Marshal(map[string]any{
+		"iss":   a.email,
+		"scope": playAPIScope,
+		"aud":   a.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// accessToken returns a valid access token, signing in again a minute
+// before the last one expires.
+func (a *playDeveloperAPI) accessToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Until(a.expiry) > time.Minute {
+		return a.token, nil
+	}
+	assertion, err := a.jwt(time.Now())
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("service account sign-in: %w", statusError(resp))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := decodeJSONResponse(resp, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("service account sign-in returned no access token")
+	}
+	a.token, a.expiry = token.AccessToken, time.Now().Add(time.Duration(token.ExpiresIn)*time.Second)
+	return a.token, nil
+}
+
+// call sends an authenticated request to path under the package's API root
+// and decodes the JSON reply into v, if not nil.
+func (a *playDeveloperAPI) call(ctx context.Context, method, pkg, path string, v any) error {
+	token, err := a.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, playAPIBase+url.PathEscape(pkg)+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 && resp.StatusCode != 204 {
+		return statusError(resp)
+	}
+	if v == nil {
+		return nil
+	}
+	return decodeJSONResponse(resp, v)
+}
+
+// playAPIApp is what the Play Developer API tells of an app.
+type playAPIApp struct {
+	Title    string
+	Website  string
+	Email    string
+	Releases []playRelease
+}
+
+// app reads pkg's listing, contact details and tracks in a read-only edit,
+// which it deletes afterwards. The listing is the one in --lang, or the
+// app's default language.
+func (a *playDeveloperAPI) app(ctx context.Context, pkg string) (playAPIApp, error) {
+	var edit struct {
+		ID string `json:"id"`
+	}
+	if err := a.call(ctx, http.MethodPost, pkg, "/edits", &edit); err != nil {
+		return playAPIApp{}, err
+	}
+	editPath := "/edits/" + url.PathEscape(edit.ID)
+	defer func() {
+		if err := a.call(context.WithoutCancel(ctx), http.MethodDelete, pkg, editPath, nil); err != nil {
+			logger.Debug("Play Developer API edit not deleted", "id", pkg, "err", err)
+		}
+	}()
+	var details struct {
+		DefaultLanguage string `json:"defaultLanguage"`
+		ContactWebsite  string `json:"contactWebsite"`
+		ContactEmail    string `json:"contactEmail"`
+	}
+	if err := a.call(ctx, http.MethodGet, pkg, editPath+"/details", &details); err != nil {
+		return playAPIApp{}, err
+	}
+	var listings struct {
+		Listings []struct {
+			Language string `json:"language"`
+			Title    string `json:"title"`
+		} `json:"listings"`
+	}
+	if err := a.call(ctx, http.MethodGet, pkg, editPath+"/listings", &listings); err != nil {
+		return playAPIApp{}, err
+	}
+	var tracks struct {
+		Tracks []struct {
+			Track    string `json:"track"`
+			Releases []struct {
+				Name         string   `json:"name"`
+				Status       string   `json:"status"`
+				VersionCodes []string `json:"versionCodes"`
+				UserFraction float64  `json:"userFraction"`
+			} `json:"releases"`
+		} `json:"tracks"`
+	}
+	if err := a.call(ctx, http.MethodGet, pkg, editPath+"/tracks", &tracks); err != nil {
+		return playAPIApp{}, err
+	}
+	app := playAPIApp{Website: details.ContactWebsite, Email: details.ContactEmail}
+	for _, l := range listings.Listings {
+		lang, _, _ := strings.Cut(l.Language, "-")
+		if strings.EqualFold(lang, urlLang) || (app.Title == "" && l.Language == details.DefaultLanguage) {
+			app.Title = l.Title
+		}
+	}
+	for _, t := range tracks.Tracks {
+		for _, r := range t.Releases {
+			app.Releases = append(app.Releases, playRelease{Track: t.Track, Name: r.Name, Status: r.Status, VersionCodes: r.VersionCodes, UserFraction: r.UserFraction})
+		}
+	}
+	return app, nil
+}
+
+// developerKey identifies the developer of a public lookup, or is "" when
+// the page gave none.
+func developerKey(rec record) string {
+	return cmp.Or(rec.DeveloperID, rec.Publisher)
+}
+
+// refused tells whether the account was refused an app of developer and
+// never could read one of theirs, so their other apps need not be asked
+// for: on a list of other developers' apps, each developer costs a single
+// request.
+func (a *playDeveloperAPI) refused(developer string) bool {
+	if developer == "" {
+		return false
+	}
+	a.devMu.Lock()
+	defer a.devMu.Unlock()
+	readable, seen := a.developers[developer]
+	return seen && !readable
+}
+
+// noteAccess records whether the account could read an app of developer.
+func (a *playDeveloperAPI) noteAccess(developer string, readable bool) {
+	if developer == "" {
+		return
+	}
+	a.devMu.Lock()
+	defer a.devMu.Unlock()
+	a.developers[developer] = a.developers[developer] || readable
+}
+
+// addPlayAPIFields completes the lookup of pkg, which gave rec and err,
+// with what the Play Developer API tells. Apps the service account cannot
+// access keep their public lookup; those it can resolve even when their
+// page is missing, as for drafts and apps not published in --country.
+// Once refused an app, the API is not asked for the others of its
+// developer.
+func addPlayAPIFields(ctx context.Context, pkg string, rec record, err error) (record, error) {
+	if playAPI == nil {
+		return rec, err
+	}
+	developer := ""
+	if err == nil {
+		developer = developerKey(rec)
+	}
+	if playAPI.refused(developer) {
+		logger.Debug("Play Developer API refused an app of the developer before, not asked", "id", pkg, "developer", developer)
+		return rec, err
+	}
+	app, apiErr := playAPI.app(ctx, pkg)
+	if apiErr != nil {
+		var se *httpStatusError
+		if errors.As(apiErr, &se) && (se.Code == http.StatusForbidden || se.Code == http.StatusNotFound) {
+			if se.Code == http.StatusForbidden {
+				playAPI.noteAccess(developer, false)
+			}
+			logger.Debug("Play Developer API has no access to the app", "id", pkg, "err", apiErr)
+		} else {
+			logger.Warn("Play Developer API request failed", "id", pkg, "err", apiErr)
+		}
+		return rec, err
+	}
+	playAPI.noteAccess(developer, true)
+	if err != nil {
+		rec = record{Bundle: pkg, URL: buildPlayStoreURL(pkg), ResolvedURL: rec.ResolvedURL}
+	}
+	if app.Title != "" {
+		rec.Name = app.Title
+	}
+	if app.Website != "" {
+		rec.Website = app.Website
+	}
+	if app.Email != "" {
+		rec.DeveloperEmail = app.Email
+	}
+	rec.PlayReleases = app.Releases
+	return rec, nil
+}
+
+// playTracksValue renders the play_tracks field: "track:status:name" per
+// release, with the rollout percentage of staged releases, joined with
+// "; ".
+func playTracksValue(releases []playRelease) string {
+	parts := make([]string, len(releases))
+	for i, r := range releases {
+		name := r.Name
+		if name == "" {
+			name = strings.Join(r.VersionCodes, ",")
+		}
+		parts[i] = r.Track + ":" + r.Status + ":" + name
+		if r.UserFraction > 0 {
+			parts[i] += " (" + strconv.FormatFloat(r.UserFraction*100, 'f', -1, 64) + "%)"
+		}
+	}
+	return strings.Join(parts, "; ")
+}
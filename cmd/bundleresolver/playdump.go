@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// androidDump, set by --android-source file:<path>, answers Android
+// lookups from a pre-downloaded dump instead of the Play pages.
+var androidDump *playDump
+
+// sourceDump is the source field of records read from --android-source.
+const sourceDump = "dump"
+
+// playDump is a pre-downloaded set of Play listings, keyed by package.
+type playDump struct {
+	path string
+	apps map[string]record
+}
+
+// playDumpLine is a line of a JSONL dump: a bundleresolver snapshot
+// (--snapshot) or the output of the common Play scrapers, whose keys
+// differ.
+type playDumpLine struct {
+	Bundle      string `json:"bundle"`
+	Package     string `json:"package"`
+	PackageName string `json:"packageName"`
+	AppID       string `json:"appId"`
+
+	Name  string `json:"name"`
+	Title string `json:"title"`
+
+	Publisher     string `json:"publisher"`
+	Developer     string `json:"developer"`
+	DeveloperName string `json:"developerName"`
+
+	Website          string `json:"website"`
+	DeveloperWebsite string `json:"developerWebsite"`
+
+	Category string `json:"category"`
+	Genre    string `json:"genre"`
+
+	DeveloperID      string `json:"developer_id"`
+	DeveloperIDCamel string `json:"developerId"`
+
+	// Error is set on the failed lookups of a snapshot.
+	Error string `json:"error"`
+}
+
+// parseAndroidSource reads --android-source: play for the live Play pages,
+// or file:<path> for a dump.
+func parseAndroidSource(s string) (*playDump, error) {
+	if s == "" || s == "play" {
+		return nil, nil
+	}
+	path, ok := strings.CutPrefix(s, "file:")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("%q is neither play nor file:<path>", s)
+	}
+	return loadPlayDump(path)
+}
+
+// loadPlayDump reads a JSONL dump, or a sitemap of Play details URLs
+// (which only tells which packages exist). Either may be gzipped.
+func loadPlayDump(path string) (*playDump, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	br := bufio.NewReader(r)
+	d := &playDump{path: path, apps: map[string]record{}}
+	if start, _ := br.Peek(64); bytes.HasPrefix(bytes.TrimSpace(start), []byte("<")) {
+		err = d.readSitemap(br)
+	} else {
+		err = d.readJSONL(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("loaded Android dump", "path", path, "apps", len(d.apps))
+	return d, nil
+}
+
+func (d *playDump) readJSONL(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for n := 1; s.Scan(); n++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		var l playDumpLine
+		if err := json.Unmarshal(s.Bytes(), &l); err != nil {
+			return fmt.Errorf("%s:%d: %w", d.path, n, err)
+		}
+		rec := l.record()
+		if rec.Bundle == "" {
+			return fmt.Errorf("%s:%d: no package name", d.path, n)
+		}
+		if l.Error != "" || platformOf(rec.Bundle) != platformAndroid {
+			continue
+		}
+		d.apps[rec.Bundle] = rec
+	}
+	return s.Err()
+}
+
+// record returns the listing of the line, whichever keys it uses.
+func (l playDumpLine) record() record {
+	return record{
+		Bundle:      cmp.Or(l.Bundle, l.Package, l.PackageName, l.AppID),
+		Name:        cmp.Or(l.Name, l.Title),
+		Publisher:   cmp.Or(l.Publisher, l.Developer, l.DeveloperName),
+		Website:     cmp.Or(l.Website, l.DeveloperWebsite),
+		Category:    cmp.Or(l.Category, l.Genre),
+		DeveloperID: cmp.Or(l.DeveloperID, l.DeveloperIDCamel),
+	}
+}
+
+func (d *playDump) readSitemap(r io.Reader) error {
+	var sitemap struct {
+		URLs []struct {
+			Loc string `xml:"loc"`
+		} `xml:"url"`
+	}
+	if err := xml.NewDecoder(r).Decode(&sitemap); err != nil {
+		return fmt.Errorf("%s: invalid sitemap: %w", d.path, err)
+	}
+	for _, u := range sitemap.URLs {
+		parsed, err := url.Parse(strings.TrimSpace(u.Loc))
+		if err != nil || !strings.HasSuffix(parsed.Path, "/store/apps/details") {
+			continue
+		}
+		if pkg := parsed.Query().Get("id"); pkg != "" {
+			d.apps[pkg] = record{Bundle: pkg}
+		}
+	}
+	return nil
+}
+
+// lookup answers the lookup of pkg; packages missing from the dump are not
+// found.
+func (d *playDump) lookup(pkg string) (record, error) {
+	rec, ok := d.apps[pkg]
+	if !ok {
+		return record{Bundle: pkg, URL: buildPlayStoreURL(pkg)}, fmt.Errorf("not found in %s", d.path)
+	}
+	rec.URL = buildPlayStoreURL(pkg)
+	rec.Source = sourceDump
+	return rec, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveFromDump(t *testing.T) {
+	originalClient, originalDump := httpClient, androidDump
+	defer func() {
+		httpClient, androidDump = originalClient, originalDump
+	}()
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request to %s", req.URL)
+		return nil, errors.New("offline")
+	})}
+
+	path := filepath.Join(t.TempDir(), "dump.jsonl")
+	dump := `{"input":"com.example.app","bundle":"com.example.app","name":"AppName","publisher":"Example Inc.","website":"https://example.com"}
+{"input":"com.example.gone","bundle":"com.example.gone","error":"404 Not Found","not_found":true}
+
+{"appId":"com.other.game","title":"Other Game","developer":"Other Studio","developerId":"Other+Studio","genre":"Puzzle","price":0}
+{"bundle":"123456789","name":"iOS App"}
+`
+	if err := os.WriteFile(path, []byte(dump), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if androidDump, err = parseAndroidSource("file:" + path); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := resolve(context.Background(), "com.example.app")
+	if err != nil || rec.Name != "AppName" || rec.Publisher != "Example Inc." || rec.Website != "https://example.com" || rec.sourceValue() != sourceDump {
+		t.Errorf("com.example.app = %+v, %v", rec, err)
+	}
+	rec, err = resolve(context.Background(), "com.other.game")
+	if err != nil || rec.Name != "Other Game" || rec.Publisher != "Other Studio" || rec.Category != "Puzzle" || rec.DeveloperID != "Other+Studio" {
+		t.Errorf("com.other.game = %+v, %v", rec, err)
+	}
+	if rec.URL != buildPlayStoreURL("com.other.game") {
+		t.Errorf("url = %q", rec.URL)
+	}
+	for _, pkg := range []string{"com.example.gone", "com.missing.app"} {
+		if _, err := resolve(context.Background(), pkg); !isNotFoundError(err) {
+			t.Errorf("%s: err = %v, want not found", pkg, err)
+		}
+	}
+}
+
+func TestLoadPlayDumpSitemap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sitemap.xml.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://play.google.com/store/apps/details?id=com.example.app&amp;hl=en</loc></url>
+  <url><loc>https://play.google.com/store/apps/developer?id=Example</loc></url>
+</urlset>`))
+	gz.Close()
+	f.Close()
+
+	d, err := loadPlayDump(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.apps) != 1 {
+		t.Errorf("apps = %v, want com.example.app only", d.apps)
+	}
+	if rec, err := d.lookup("com.example.app"); err != nil || rec.Bundle != "com.example.app" {
+		t.Errorf("lookup = %+v, %v", rec, err)
+	}
+}
+
+func TestParseAndroidSource(t *testing.T) {
+	for _, s := range []string{"", "play"} {
+		if d, err := parseAndroidSource(s); d != nil || err != nil {
+			t.Errorf("parseAndroidSource(%q) = %v, %v; want the live pages", s, d, err)
+		}
+	}
+	for _, s := range []string{"dump.jsonl", "file:", "file:" + filepath.Join(t.TempDir(), "missing.jsonl")} {
+		if _, err := parseAndroidSource(s); err == nil {
+			t.Errorf("parseAndroidSource(%q) accepted", s)
+		}
+	}
+}
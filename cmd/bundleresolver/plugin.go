@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// jsonProcess is an external executable answering each JSON line written
+// to its STDIN with one JSON line on STDOUT. Its STDERR is passed through.
+// Calls are made one at a time.
+type jsonProcess struct {
+	path string
+	cmd  *exec.Cmd
+
+	mu     sync.Mutex
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	err    error // set once the process stopped answering
+}
+
+// startJSONProcess starts the executable at path with args.
+func startJSONProcess(path string, args ...string) (*jsonProcess, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &jsonProcess{path: path, cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+// call sends req and decodes the answer into resp. Once the process fails
+// to answer, every later call returns the same error.
+func (p *jsonProcess) call(req, resp any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	if err := p.exchange(req, resp); err != nil {
+		p.err = fmt.Errorf("%s: %w", p.path, err)
+	}
+	return p.err
+}
+
+func (p *jsonProcess) exchange(req, resp any) error {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	line, err = p.stdout.ReadBytes('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("exited without answering")
+		}
+		return err
+	}
+	if err := json.Unmarshal(line, resp); err != nil {
+		return fmt.Errorf("invalid answer: %w", err)
+	}
+	return nil
+}
+
+// close ends the process's input and waits for it to exit.
+func (p *jsonProcess) close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// activePlugin, when set by --plugin, resolves the ids neither store
+// accepts.
+var activePlugin *plugin
+
+// plugin is an external resolver: for each id it reads a line {"id": "..."}
+// and answers with a record ({"bundle", "name", "publisher", "url",
+// "website", ...}), or {"error": "..."} and optionally "not_found": true if
+// it cannot resolve the id.
+type plugin struct {
+	*jsonProcess
+}
+
+type pluginRequest struct {
+	ID string `json:"id"`
+}
+
+type pluginResponse struct {
+	record
+	Error    string `json:"error,omitempty"`
+	NotFound bool   `json:"not_found,omitempty"`
+}
+
+// startPlugin starts the plugin executable at path with args.
+func startPlugin(path string, args ...string) (*plugin, error) {
+	p, err := startJSONProcess(path, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &plugin{p}, nil
+}
+
+// resolve asks the plugin for the record of id.
+func (p *plugin) resolve(ctx context.Context, id string) (record, error) {
+	if err := ctx.Err(); err != nil {
+		return record{Bundle: id}, err
+	}
+	var resp pluginResponse
+	if err := p.call(pluginRequest{ID: id}, &resp); err != nil {
+		return record{Bundle: id}, fmt.Errorf("plugin %w", err)
+	}
+	rec := resp.record
+	if rec.Bundle == "" {
+		rec.Bundle = id
+	}
+	switch {
+	case resp.NotFound:
+		return rec, fmt.Errorf("plugin: not found: %s", resp.Error)
+	case resp.Error != "":
+		return rec, fmt.Errorf("plugin: %s", resp.Error)
+	}
+	rec.Fallbacks = append(rec.Fallbacks, fallbackPlugin)
+	return rec, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestPluginHelper is the plugin executable of the tests below: run as a
+// subprocess, it answers "acme:<n>" ids and reports the others not found.
+func TestPluginHelper(t *testing.T) {
+	if os.Getenv("GO_WANT_PLUGIN_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	s := bufio.NewScanner(os.Stdin)
+	for s.Scan() {
+		var req pluginRequest
+		if err := json.Unmarshal(s.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+		if n, ok := strings.CutPrefix(req.ID, "acme:"); ok {
+			fmt.Printf(`{"bundle":%q,"name":"Acme %s","publisher":"Acme Corp","url":"https://apps.acme.example/%s"}`+"\n", req.ID, n, n)
+		} else {
+			fmt.Println(`{"error":"unknown id","not_found":true}`)
+		}
+	}
+	os.Exit(0)
+}
+
+func startTestPlugin(t *testing.T) *plugin {
+	t.Helper()
+	t.Setenv("GO_WANT_PLUGIN_HELPER", "1")
+	p, err := startPlugin(os.Args[0], "-test.run=^TestPluginHelper$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.close() })
+	return p
+}
+
+func TestPluginResolve(t *testing.T) {
+	originalPlugin := activePlugin
+	defer func() { activePlugin = originalPlugin }()
+	activePlugin = startTestPlugin(t)
+
+	rec, err := resolve(context.Background(), "acme:42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Bundle != "acme:42" || rec.Name != "Acme 42" || rec.Publisher != "Acme Corp" || rec.URL != "https://apps.acme.example/42" {
+		t.Errorf("record = %+v", rec)
+	}
+	if got := rec.value(FieldConfidence); got != "1.00" {
+		t.Errorf("confidence = %q, want 1.00", got)
+	}
+
+	rec, err = resolve(context.Background(), "other:1")
+	if !isNotFoundError(err) {
+		t.Errorf("err = %v, want a not-found error", err)
+	}
+	if rec.Bundle != "other:1" {
+		t.Errorf("bundle = %q, want the id", rec.Bundle)
+	}
+}
+
+func TestPluginExited(t *testing.T) {
+	p := startTestPlugin(t)
+	p.stdin.Close()
+	if _, err := p.resolve(context.Background(), "acme:1"); err == nil || !strings.Contains(err.Error(), "plugin") {
+		t.Errorf("err = %v, want a plugin error", err)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/text/currency"
+)
+
+// storePrice is an app's price in one storefront.
+type storePrice struct {
+	Formatted string  `json:"formatted"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency,omitempty"`
+	// Converted is Amount in the --base-currency, if it was asked for and
+	// the rate is known.
+	Converted string `json:"converted,omitempty"`
+	// Name is the app's name in the storefront, for iOS only: Play listings
+	// are named per language.
+	Name string `json:"name,omitempty"`
+}
+
+// priceOptions controls --price-countries.
+type priceOptions struct {
+	Countries []string // lower-case two-letter codes
+	// Rows writes one row per country instead of price_<cc> columns (see
+	// inCountry).
+	Rows bool
+	// Base, when set, adds the price converted to this currency at Rates.
+	Base  string
+	Rates map[string]float64 // units per euro
+}
+
+// parseCountries reads a comma-separated list of two-letter country codes.
+func parseCountries(csv string) ([]string, error) {
+	var countries []string
+	for _, c := range strings.Split(csv, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if len(c) != 2 {
+			return nil, fmt.Errorf("invalid country %q (want two-letter codes such as us)", c)
+		}
+		if !containsString(countries, c) {
+			countries = append(countries, c)
+		}
+	}
+	return countries, nil
+}
+
+// fields returns the columns --price-countries adds: price_<cc> and,
+// with a base currency, price_<cc>_<base>. Per-country rows need none.
+func (o priceOptions) fields() []Field {
+	var fields []Field
+	if o.Rows {
+		return nil
+	}
+	for _, c := range o.Countries {
+		fields = append(fields, Field("price_"+c))
+		if o.Base != "" {
+			fields = append(fields, Field("price_"+c+"_"+strings.ToLower(o.Base)))
+		}
+	}
+	return fields
+}
+
+// addPrices fills rec.Prices for every country in o. A storefront that
+// doesn't carry the app is left out.
+func addPrices(ctx context.Context, o priceOptions, rec *record) {
+	rec.Prices = map[string]storePrice{}
+	for _, c := range o.Countries {
+		var p storePrice
+		var err error
+		if platformOf(rec.Bundle) == platformIOS {
+			p, err = fetchIOSPrice(ctx, rec.Bundle, c)
+		} else {
+			p, err = fetchPlayPrice(ctx, rec.Bundle, c)
+		}
+		if err != nil {
+			logger.Debug("price lookup failed", "id", rec.Bundle, "country", c, "err", err)
+			continue
+		}
+		if o.Base != "" {
+			if v, ok := convertPrice(p.Amount, p.Currency, o.Base, o.Rates); ok {
+				p.Converted = strconv.FormatFloat(v, 'f', 2, 64)
+			}
+		}
+		rec.Prices[c] = p
+	}
+}
+
+func fetchIOSPrice(ctx context.Context, appID, country string) (storePrice, error) {
+	resp, err := httpGet(ctx, fmt.Sprintf("https://itunes.apple.com/lookup?id=%s&country=%s", appID, country))
+	if err != nil {
+		return storePrice{}, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return storePrice{}, fmt.Errorf("status %s", resp.Status)
+	}
+	var payload struct {
+		Results []struct {
+			TrackName      string  `json:"trackName"`
+			Price          float64 `json:"price"`
+			Currency       string  `json:"currency"`
+			FormattedPrice string  `json:"formattedPrice"`
+		} `json:"results"`
+	}
+	if err := decodeJSONResponse(resp, &payload); err != nil {
+		return storePrice{}, err
+	}
+	if len(payload.Results) == 0 {
+		return storePrice{}, fmt.Errorf("not found")
+	}
+	r := payload.Results[0]
+	return storePrice{Formatted: r.FormattedPrice, Amount: r.Price, Currency: r.Currency, Name: r.TrackName}, nil
+}
+
+func fetchPlayPrice(ctx context.Context, pkg, country string) (storePrice, error) {
+	_, doc, err := fetchStorePage(ctx, playStorePageURL(pkg)+"&hl=en&gl="+strings.ToUpper(country))
+	if err != nil {
+		return storePrice{}, err
+	}
+	if doc == nil {
+		return storePrice{}, fmt.Errorf("not found")
+	}
+	return parsePlayOffer(doc)
+}
+
+// parsePlayOffer reads the offer metadata of a Play details page.
+func parsePlayOffer(doc *goquery.Document) (storePrice, error) {
+	formatted := playPrice(doc)
+	if formatted == "" {
+		return storePrice{}, fmt.Errorf("no price on page")
+	}
+	p := storePrice{Formatted: formatted}
+	p.Currency, _ = doc.Find("[itemprop='offers'] meta[itemprop='priceCurrency']").First().Attr("content")
+	if formatted != "Free" {
+		p.Amount = parseAmount(formatted, p.Currency)
+	}
+	return p, nil
+}
+
+// parseAmount extracts the number from a formatted price such as "$4.99",
+// "4,99 €" or "¥480" in the ISO 4217 currency code. The currency's minor
+// units tell a decimal separator from a thousands one: "1.250" is 1250
+// rupees but 1.25 Kuwaiti dinars, and yen have no decimals at all. An
+// unknown currency is taken to have two.
+func parseAmount(s, code string) float64 {
+	decimals := 2
+	if unit, err := currency.ParseISO(code); err == nil {
+		decimals, _ = currency.Standard.Rounding(unit)
+	}
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return r
+		case r == '.' || r == ',':
+			return '.'
+		}
+		return -1
+	}, s)
+	// Only the last separator can be the decimal one ("1,299.00").
+	if i := strings.LastIndexByte(digits, '.'); i >= 0 {
+		whole := strings.ReplaceAll(digits[:i], ".", "")
+		switch n := len(digits) - i - 1; {
+		case decimals == 0, n == 3 && decimals != 3: // a thousands separator ("1.299")
+			digits = whole + digits[i+1:]
+		default:
+			digits = whole + digits[i:]
+		}
+	}
+	v, _ := strconv.ParseFloat(digits, 64)
+	return v
+}
+
+// priceValue renders the price_<cc> and price_<cc>_<base> fields; ok is
+// false for other fields.
+func (rec record) priceValue(f Field) (v string, ok bool) {
+	rest, ok := strings.CutPrefix(string(f), "price_")
+	if !ok || len(rest) < 2 {
+		return "", false
+	}
+	p := rec.Prices[rest[:2]]
+	if len(rest) == 2 {
+		return p.Formatted, true
+	}
+	return p.Converted, true
+}
+
+// inCountry returns the row of rec for storefront country with
+// --per-country-rows: the name and price there, or no price if the
+// storefront does not carry the app.
+func (rec record) inCountry(country string) record {
+	rec.Country = country
+	p, ok := rec.Prices[country]
+	if !ok {
+		rec.Price = ""
+		return rec
+	}
+	rec.Price = p.Formatted
+	if p.Name != "" {
+		rec.Name = p.Name
+	}
+	return rec
+}
+
+// availableValue renders the available field: whether the storefront of a
+// per-country row carries the app.
+func (rec record) availableValue() string {
+	if rec.Country == "" {
+		return ""
+	}
+	_, ok := rec.Prices[rec.Country]
+	return strconv.FormatBool(ok)
+}
+
+func convertPrice(amount float64, from, to string, rates map[string]float64) (float64, bool) {
+	if amount == 0 {
+		return 0, true
+	}
+	if from == to {
+		return amount, true
+	}
+	fromRate, ok1 := rates[from]
+	toRate, ok2 := rates[to]
+	if !ok1 || !ok2 || fromRate == 0 {
+		return 0, false
+	}
+	return amount / fromRate * toRate, true
+}
+
+// ecbRatesURL serves the European Central Bank's daily reference rates.
+const ecbRatesURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+
+// fetchRates returns the ECB reference rates as units of each currency per
+// euro, including EUR itself.
+func fetchRates(ctx context.Context) (map[string]float64, error) {
+	resp, err := httpGet(ctx, ecbRatesURL)
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	var doc struct {
+		Cubes []struct {
+			Currency string  `xml:"currency,attr"`
+			Rate     float64 `xml:"rate,attr"`
+		} `xml:"Cube>Cube>Cube"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	rates := map[string]float64{"EUR": 1}
+	for _, c := range doc.Cubes {
+		rates[c.Currency] = c.Rate
+	}
+	return rates, nil
+}
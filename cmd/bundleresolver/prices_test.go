@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleECBRates = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube><Cube time="2024-05-31">
+		<Cube currency="USD" rate="1.0848"/>
+		<Cube currency="JPY" rate="170.88"/>
+	</Cube></Cube>
+</gesmes:Envelope>`
+
+func TestAddPrices(t *testing.T) {
+	originalClient := httpClient
+	defer func() {
+		httpClient = originalClient
+	}()
+	httpClient = &http.Client{Transport: fakeTransport{
+		ecbRatesURL: sampleECBRates,
+		"https://itunes.apple.com/lookup?id=123&country=us": `{"results":[{"price":4.99,"currency":"USD","formattedPrice":"$4.99"}]}`,
+		"https://itunes.apple.com/lookup?id=123&country=jp": `{"results":[{"price":800,"currency":"JPY","formattedPrice":"¥800"}]}`,
+		"https://itunes.apple.com/lookup?id=123&country=cn": `{"results":[]}`,
+	}}
+
+	rates, err := fetchRates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]float64{"EUR": 1, "USD": 1.0848, "JPY": 170.88}; !reflect.DeepEqual(rates, want) {
+		t.Fatalf("rates = %v, want %v", rates, want)
+	}
+
+	opts := priceOptions{Countries: []string{"us", "jp", "cn"}, Base: "USD", Rates: rates}
+	rec := record{Bundle: "123"}
+	addPrices(context.Background(), opts, &rec)
+	want := map[Field]string{
+		"price_us": "$4.99", "price_us_usd": "4.99",
+		"price_jp": "¥800", "price_jp_usd": "5.08",
+		"price_cn": "", "price_cn_usd": "",
+	}
+	for _, f := range opts.fields() {
+		if got := rec.value(f); got != want[f] {
+			t.Errorf("%s = %q, want %q", f, got, want[f])
+		}
+	}
+	if len(opts.fields()) != len(want) {
+		t.Errorf("fields = %v", opts.fields())
+	}
+}
+
+func TestProcessPerCountryRows(t *testing.T) {
+	originalClient, originalResolve := httpClient, resolveFunc
+	defer func() {
+		httpClient, resolveFunc = originalClient, originalResolve
+	}()
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://itunes.apple.com/lookup?id=123&country=us": `{"results":[{"trackName":"Puzzle","price":0,"currency":"USD","formattedPrice":"Free"}]}`,
+		"https://itunes.apple.com/lookup?id=123&country=jp": `{"results":[{"trackName":"パズル","price":160,"currency":"JPY","formattedPrice":"¥160"}]}`,
+		"https://itunes.apple.com/lookup?id=123&country=cn": `{"results":[]}`,
+	}}
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return record{Bundle: id, Name: "Puzzle", Price: "Free"}, nil
+	}
+	var out strings.Builder
+	opts := options{
+		Fields: []Field{FieldBundle, FieldName, FieldCountry, FieldAvailable, FieldPrice},
+		Prices: priceOptions{Countries: []string{"us", "jp", "cn"}, Rows: true},
+	}
+	if err := process(strings.NewReader("123\n\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "123\tPuzzle\tus\ttrue\tFree\n" +
+		"123\tパズル\tjp\ttrue\t¥160\n" +
+		"123\tPuzzle\tcn\tfalse\t\n" +
+		"\t\t\t\t\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	cases := []struct {
+		in, currency string
+		want         float64
+	}{
+		{"$4.99", "USD", 4.99},
+		{"4,99 €", "EUR", 4.99},
+		{"¥480", "JPY", 480},
+		{"¥1,200", "JPY", 1200},
+		{"1,299.00", "USD", 1299},
+		{"₹1.299", "INR", 1299},
+		{"CHF 12.50", "CHF", 12.5},
+		{"KWD 1.250", "KWD", 1.25},
+		{"BHD 12,500.750", "BHD", 12500.75},
+		{"1.299", "", 1299},
+	}
+	for _, c := range cases {
+		if got := parseAmount(c.in, c.currency); got != c.want {
+			t.Errorf("parseAmount(%q, %q) = %v, want %v", c.in, c.currency, got, c.want)
+		}
+	}
+}
+
+func TestParseCountries(t *testing.T) {
+	got, err := parseCountries("US, jp,,us")
+	if err != nil || !reflect.DeepEqual(got, []string{"us", "jp"}) {
+		t.Errorf("countries = %v, %v", got, err)
+	}
+	if _, err := parseCountries("usa"); err == nil {
+		t.Error("three-letter code accepted")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// appPrivacy is the privacy label ("App Privacy" section) of an App Store
+// page: the data categories per kind of use.
+type appPrivacy struct {
+	Tracking  []string `json:"tracking,omitempty"`   // Data Used to Track You
+	Linked    []string `json:"linked,omitempty"`     // Data Linked to You
+	NotLinked []string `json:"not_linked,omitempty"` // Data Not Linked to You
+	// NotCollected is set when the developer declares no data collection.
+	NotCollected bool `json:"not_collected,omitempty"`
+}
+
+// parseAppPrivacy extracts the privacy label from an App Store page, or
+// returns nil if the page has none (the developer hasn't provided one yet,
+// or the markup changed).
+func parseAppPrivacy(doc *goquery.Document) *appPrivacy {
+	var p appPrivacy
+	found := false
+	doc.Find(".app-privacy__card, .privacy-type").Each(func(_ int, card *goquery.Selection) {
+		heading := strings.ToLower(strings.TrimSpace(card.Find(".privacy-type__heading, h3").First().Text()))
+		var categories []string
+		card.Find(".privacy-type__data-category-heading, .privacy-type__item span").Each(func(_ int, s *goquery.Selection) {
+			if c := strings.TrimSpace(s.Text()); c != "" && !containsString(categories, c) {
+				categories = append(categories, c)
+			}
+		})
+		switch {
+		case strings.Contains(heading, "track"):
+			p.Tracking, found = categories, true
+		case strings.Contains(heading, "not linked"):
+			p.NotLinked, found = categories, true
+		case strings.Contains(heading, "linked"):
+			p.Linked, found = categories, true
+		case strings.Contains(heading, "not collected"):
+			p.NotCollected, found = true, true
+		}
+	})
+	if !found {
+		return nil
+	}
+	return &p
+}
+
+// value renders the privacy field f for TSV/CSV output: the categories
+// comma-separated, "none" if the label declares nothing for that kind of
+// use, and empty if there is no label.
+func (p *appPrivacy) value(f Field) string {
+	if p == nil {
+		return ""
+	}
+	var categories []string
+	switch f {
+	case FieldPrivacyTracking:
+		categories = p.Tracking
+	case FieldPrivacyLinked:
+		categories = p.Linked
+	case FieldPrivacyNotLinked:
+		categories = p.NotLinked
+	}
+	if len(categories) == 0 {
+		return "none"
+	}
+	return strings.Join(categories, ",")
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const samplePrivacyHTML = `<html><body>
+<section class="app-privacy">
+  <div class="app-privacy__card">
+    <h3 class="privacy-type__heading">Data Used to Track You</h3>
+    <ul class="privacy-type__items">
+      <li class="privacy-type__item"><span class="privacy-type__data-category-heading">Identifiers</span></li>
+    </ul>
+  </div>
+  <div class="app-privacy__card">
+    <h3 class="privacy-type__heading">Data Linked to You</h3>
+    <ul class="privacy-type__items">
+      <li class="privacy-type__item"><span class="privacy-type__data-category-heading">Purchases</span></li>
+      <li class="privacy-type__item"><span class="privacy-type__data-category-heading">Location</span></li>
+    </ul>
+  </div>
+  <div class="app-privacy__card">
+    <h3 class="privacy-type__heading">Data Not Linked to You</h3>
+  </div>
+</section>
+</body></html>`
+
+func TestParseAppPrivacy(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(samplePrivacyHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parseAppPrivacy(doc)
+	cases := map[Field]string{
+		FieldPrivacyTracking:  "Identifiers",
+		FieldPrivacyLinked:    "Purchases,Location",
+		FieldPrivacyNotLinked: "none",
+	}
+	for f, want := range cases {
+		if got := p.value(f); got != want {
+			t.Errorf("%s = %q, want %q", f, got, want)
+		}
+	}
+
+	empty, _ := goquery.NewDocumentFromReader(strings.NewReader("<html><body>no label</body></html>"))
+	if p := parseAppPrivacy(empty); p != nil || p.value(FieldPrivacyLinked) != "" {
+		t.Errorf("page without a label parsed as %+v", p)
+	}
+}
+
+func TestAddAppStorePageFieldsOnlyWhenSelected(t *testing.T) {
+	originalClient, originalSelected := httpClient, selectedFields
+	defer func() {
+		httpClient, selectedFields = originalClient, originalSelected
+	}()
+	fake := fakeTransport{"https://apps.apple.com/app/id1": samplePrivacyHTML}
+	httpClient = &http.Client{Transport: fake}
+
+	selectFields([]Field{FieldBundle, FieldName})
+	rec := record{Bundle: "1", URL: "https://apps.apple.com/app/id1"}
+	addAppStorePageFields(context.Background(), &rec)
+	if rec.Privacy != nil || rec.ResolvedURL != "" {
+		t.Fatalf("page fetched although no page field was selected: %+v", rec)
+	}
+
+	selectFields([]Field{FieldPrivacyLinked})
+	addAppStorePageFields(context.Background(), &rec)
+	if got := rec.value(FieldPrivacyLinked); got != "Purchases,Location" {
+		t.Errorf("privacy_linked = %q", got)
+	}
+	if rec.ResolvedURL != rec.URL {
+		t.Errorf("resolved_url = %q, want %q", rec.ResolvedURL, rec.URL)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	_ "net/http/pprof" // registers /debug/pprof handlers on http.DefaultServeMux
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiling enables the requested profilers. The returned stop function
+// finishes the CPU profile and writes the heap profile; call it on exit.
+func startProfiling(pprofAddr, cpuProfile, memProfile string) (stop func(), err error) {
+	if pprofAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(pprofAddr, nil); err != nil {
+				logger.Error("pprof server stopped", "addr", pprofAddr, "err", err)
+			}
+		}()
+		logger.Info("pprof listening", "addr", pprofAddr)
+	}
+
+	var cpuFile *os.File
+	if cpuProfile != "" {
+		cpuFile, err = os.Create(cpuProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, err
+		}
+	}
+
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+		if memProfile != "" {
+			f, err := os.Create(memProfile)
+			if err != nil {
+				logger.Error("writing memory profile failed", "err", err)
+				return
+			}
+			defer f.Close()
+			runtime.GC() // report up-to-date live allocations
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				logger.Error("writing memory profile failed", "err", err)
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progress renders a single-line progress bar with live counters on a
+// terminal. All methods are safe for concurrent use.
+type progress struct {
+	mu      sync.Mutex
+	out     io.Writer
+	total   int
+	done    int
+	ok      int
+	failed  int
+	start   time.Time
+	now     func() time.Time
+	stopCh  chan struct{}
+	stopped sync.WaitGroup
+	// recent holds when the lines of the last progressRateWindow were
+	// processed, oldest first.
+	recent []time.Time
+}
+
+const progressBarWidth = 30
+
+// progressRateWindow is the span the rate and ETA are measured over, so
+// that they follow a run slowing down or speeding up rather than
+// averaging it from the start.
+const progressRateWindow = 10 * time.Second
+
+func newProgress(out io.Writer) *progress {
+	return &progress{out: out, now: time.Now}
+}
+
+// isTerminal reports whether f is attached to a character device (a TTY).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// begin resets the counters for a run of total lines and starts periodic
+// redrawing, so the bar keeps ticking while a slow lookup is in flight.
+func (p *progress) begin(total int) {
+	p.mu.Lock()
+	p.total, p.done, p.ok, p.failed = total, 0, 0, 0
+	p.start = p.now()
+	p.recent = p.recent[:0]
+	p.stopCh = make(chan struct{})
+	p.mu.Unlock()
+
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		t := time.NewTicker(200 * time.Millisecond)
+		defer t.Stop()
+		for {
+			select {
+			case <-p.stopCh:
+				return
+			case <-t.C:
+				p.mu.Lock()
+				p.render()
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// record counts one processed line. ok is false for lines that failed to resolve.
+func (p *progress) record(ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.processed()
+	if ok {
+		p.ok++
+	} else {
+		p.failed++
+	}
+}
+
+// skip counts a processed line that was neither resolved nor failed (blank input).
+func (p *progress) skip() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.processed()
+}
+
+// processed notes a line processed now, and forgets those out of the
+// window. It must be called with p.mu held.
+func (p *progress) processed() {
+	now := p.now()
+	p.recent = append(p.recent, now)
+	p.trimRecent(now)
+}
+
+func (p *progress) trimRecent(now time.Time) {
+	i := 0
+	for i < len(p.recent) && now.Sub(p.recent[i]) > progressRateWindow {
+		i++
+	}
+	p.recent = p.recent[i:]
+}
+
+// finish draws the final state and moves the cursor past the bar.
+func (p *progress) finish() {
+	if p.stopCh != nil {
+		close(p.stopCh)
+		p.stopped.Wait()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopCh = nil
+	p.render()
+	fmt.Fprintln(p.out)
+}
+
+// render must be called with p.mu held.
+func (p *progress) render() {
+	fmt.Fprint(p.out, "\r\033[K"+p.line())
+}
+
+func (p *progress) line() string {
+	now := p.now()
+	p.trimRecent(now)
+	window := min(now.Sub(p.start), progressRateWindow)
+	var rps float64
+	if window > 0 {
+		rps = float64(len(p.recent)) / window.Seconds()
+	}
+
+	var b strings.Builder
+	if p.total > 0 {
+		filled := progressBarWidth * p.done / p.total
+		b.WriteString("[")
+		b.WriteString(strings.Repeat("#", filled))
+		b.WriteString(strings.Repeat(".", progressBarWidth-filled))
+		b.WriteString("] ")
+		fmt.Fprintf(&b, "%d/%d", p.done, p.total)
+	} else {
+		fmt.Fprintf(&b, "%d", p.done)
+	}
+	fmt.Fprintf(&b, " ok=%d fail=%d %.1f/s", p.ok, p.failed, rps)
+	if p.total > 0 && rps > 0 && p.done < p.total {
+		eta := time.Duration(float64(p.total-p.done) / rps * float64(time.Second))
+		fmt.Fprintf(&b, " ETA %s", eta.Round(time.Second))
+	}
+	return b.String()
+}
+
+// logWriter returns a writer for diagnostics that clears the bar before each
+// write and redraws it afterwards, so log lines don't interleave with it.
+func (p *progress) logWriter() io.Writer {
+	return progressLogWriter{p}
+}
+
+type progressLogWriter struct{ p *progress }
+
+func (w progressLogWriter) Write(b []byte) (int, error) {
+	w.p.mu.Lock()
+	defer w.p.mu.Unlock()
+	fmt.Fprint(w.p.out, "\r\033[K")
+	n, err := w.p.out.Write(b)
+	if w.p.stopCh != nil {
+		fmt.Fprint(w.p.out, w.p.line())
+	}
+	return n, err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressLine(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := newProgress(nil)
+	p.start = start
+	p.total = 10
+	p.now = func() time.Time { return start.Add(2 * time.Second) }
+	for i := 0; i < 4; i++ {
+		p.record(i != 3)
+	}
+
+	want := "[############..................] 4/10 ok=3 fail=1 2.0/s ETA 3s"
+	if got := p.line(); got != want {
+		t.Fatalf("line() = %q, want %q", got, want)
+	}
+}
+
+func TestProgressLineUnknownTotal(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := newProgress(nil)
+	p.start = start
+	p.now = func() time.Time { return start.Add(time.Second) }
+	p.record(true)
+	p.skip()
+
+	want := "2 ok=1 fail=0 2.0/s"
+	if got := p.line(); got != want {
+		t.Fatalf("line() = %q, want %q", got, want)
+	}
+}
+
+func TestProgressRateWindow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	p := newProgress(nil)
+	p.now = func() time.Time { return now }
+	p.start, p.total = start, 100
+	// 50 lines in the first 5s, then 5 in the last 10s.
+	for i := 0; i < 50; i++ {
+		now = start.Add(time.Duration(i) * 100 * time.Millisecond)
+		p.record(true)
+	}
+	for i := 1; i <= 5; i++ {
+		now = start.Add(5*time.Second + time.Duration(i)*2*time.Second)
+		p.record(true)
+	}
+
+	want := "[################..............] 55/100 ok=55 fail=0 0.5/s ETA 1m30s"
+	if got := p.line(); got != want {
+		t.Fatalf("line() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+)
+
+// protoEncoder renders records as length-delimited protocol buffers: each
+// record is an App message of proto/bundleresolver.proto preceded by its
+// size as a varint. The wire format is written by hand; it needs no more
+// than varints, strings, doubles and nested messages.
+type protoEncoder struct{}
+
+func (protoEncoder) begin(io.Writer) error { return nil }
+func (protoEncoder) end(io.Writer) error   { return nil }
+
+func (protoEncoder) encode(w io.Writer, rec record) error {
+	msg := appMessage(rec)
+	frame := binary.AppendUvarint(nil, uint64(len(msg)))
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
+
+// Protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// protoMessage builds a message; zero values are left out as in proto3.
+type protoMessage []byte
+
+func (m *protoMessage) tag(num, wireType int) {
+	*m = binary.AppendUvarint(*m, uint64(num<<3|wireType))
+}
+
+func (m *protoMessage) bytes(num int, b []byte) {
+	m.tag(num, wireBytes)
+	*m = binary.AppendUvarint(*m, uint64(len(b)))
+	*m = append(*m, b...)
+}
+
+func (m *protoMessage) string(num int, s string) {
+	if s != "" {
+		m.bytes(num, []byte(s))
+	}
+}
+
+func (m *protoMessage) strings(num int, list []string) {
+	for _, s := range list {
+		m.bytes(num, []byte(s)) // repeated elements are kept even if empty
+	}
+}
+
+func (m *protoMessage) bool(num int, b bool) {
+	if b {
+		m.tag(num, wireVarint)
+		*m = append(*m, 1)
+	}
+}
+
+// optionalBool writes an optional bool field: present even when false,
+// and left out when b is nil.
+func (m *protoMessage) optionalBool(num int, b *bool) {
+	if b == nil {
+		return
+	}
+	m.tag(num, wireVarint)
+	if *b {
+		*m = append(*m, 1)
+	} else {
+		*m = append(*m, 0)
+	}
+}
+
+func (m *protoMessage) int(num, v int) {
+	if v != 0 {
+		m.tag(num, wireVarint)
+		*m = binary.AppendUvarint(*m, uint64(v))
+	}
+}
+
+func (m *protoMessage) double(num int, f float64) {
+	if f != 0 {
+		m.tag(num, wireFixed64)
+		*m = binary.LittleEndian.AppendUint64(*m, math.Float64bits(f))
+	}
+}
+
+// message writes a nested message; it is present even if empty.
+func (m *protoMessage) message(num int, sub protoMessage) {
+	m.bytes(num, sub)
+}
+
+// appMessage encodes rec as an App message.
+func appMessage(rec record) protoMessage {
+	var m protoMessage
+	m.string(1, rec.Bundle)
+	m.string(2, rec.Name)
+	m.string(3, rec.Publisher)
+	m.string(4, rec.URL)
+	m.string(5, rec.ResolvedURL)
+	m.string(6, rec.Website)
+	m.string(7, rec.Price)
+	m.string(8, rec.Category)
+	m.string(9, rec.CategoryID)
+	m.optionalBool(10, rec.Kids)
+	m.string(11, rec.Lifecycle)
+	m.strings(12, rec.Badges)
+	countries := make([]string, 0, len(rec.Prices))
+	for cc := range rec.Prices {
+		countries = append(countries, cc)
+	}
+	sort.Strings(countries)
+	for _, cc := range countries {
+		p := rec.Prices[cc]
+		var price protoMessage
+		price.string(1, p.Formatted)
+		price.double(2, p.Amount)
+		price.string(3, p.Currency)
+		price.string(4, p.Converted)
+		var entry protoMessage
+		entry.string(1, cc)
+		entry.message(2, price)
+		m.message(13, entry)
+	}
+	m.string(14, rec.BundleID)
+	if p := rec.Privacy; p != nil {
+		var privacy protoMessage
+		privacy.strings(1, p.Tracking)
+		privacy.strings(2, p.Linked)
+		privacy.strings(3, p.NotLinked)
+		privacy.bool(4, p.NotCollected)
+		m.message(15, privacy)
+	}
+	for _, v := range rec.History {
+		var version protoMessage
+		version.string(1, v.Version)
+		version.string(2, v.Date)
+		version.string(3, v.Notes)
+		m.message(16, version)
+	}
+	if d := rec.DataSafety; d != nil {
+		var safety protoMessage
+		safety.strings(1, d.Shared)
+		safety.strings(2, d.Collected)
+		safety.strings(3, d.Security)
+		m.message(17, safety)
+	}
+	m.strings(18, rec.Fallbacks)
+	m.string(19, rec.Input)
+	m.string(20, rec.StoreURL)
+	m.string(21, rec.TrackID)
+	m.string(22, rec.DeveloperEmail)
+	if t := rec.Trader; t != nil {
+		var trader protoMessage
+		trader.bool(1, t.Trader)
+		trader.string(2, t.Name)
+		trader.strings(3, t.Address)
+		trader.string(4, t.Phone)
+		m.message(23, trader)
+	}
+	for _, it := range rec.InAppPurchases {
+		var item protoMessage
+		item.string(1, it.Name)
+		item.string(2, it.Price)
+		m.message(24, item)
+	}
+	m.optionalBool(25, rec.GameCenter)
+	m.optionalBool(26, rec.PlayGames)
+	m.optionalBool(27, rec.Controller)
+	m.strings(28, rec.Platforms)
+	m.int(29, rec.MinSDK)
+	m.int(30, rec.TargetSDK)
+	m.string(31, rec.Status)
+	m.string(32, rec.StatusReason)
+	m.string(33, rec.DeviceFamily)
+	for _, r := range rec.PlayReleases {
+		var release protoMessage
+		release.string(1, r.Track)
+		release.string(2, r.Name)
+		release.string(3, r.Status)
+		release.strings(4, r.VersionCodes)
+		release.double(5, r.UserFraction)
+		m.message(34, release)
+	}
+	m.string(35, rec.DeveloperID)
+	m.string(36, rec.Source)
+	m.string(37, rec.Country)
+	m.string(38, rec.ResolvedAt)
+	m.bool(39, rec.Partial)
+	return m
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestAppMessage(t *testing.T) {
+	no := false
+	cases := []struct {
+		rec  record
+		want string
+	}{
+		// bundle "a", name "b", kids false (optional, so present)
+		{record{Bundle: "a", Name: "b", Kids: &no}, "0a0161" + "120162" + "5000"},
+		// prices {"us": {formatted "$1", amount 1}}: a map entry message
+		{record{Prices: map[string]storePrice{"us": {Formatted: "$1", Amount: 1}}}, "6a13" + "0a027573" + "120d" + "0a022431" + "11000000000000f03f"},
+		// privacy with only not_collected, history with one version
+		{record{Privacy: &appPrivacy{NotCollected: true}, History: []appVersion{{Version: "2"}}}, "7a022001" + "8201030a0132"},
+		{record{}, ""},
+	}
+	for _, tc := range cases {
+		if got := hex.EncodeToString(appMessage(tc.rec)); got != tc.want {
+			t.Errorf("appMessage(%+v) = %s, want %s", tc.rec, got, tc.want)
+		}
+	}
+}
+
+func TestProcessProto(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() { resolveFunc = originalResolve }()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return record{Bundle: id}, nil
+	}
+	var out bytes.Buffer
+	opts := options{Fields: []Field{FieldBundle}, Header: true, Format: "proto"}
+	if err := process(strings.NewReader("1\n\n22\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	// Two frames, each App{bundle, input}; the blank line has none.
+	want := "07" + "0a0131" + "9a010131" + "09" + "0a023232" + "9a01023232"
+	if got := hex.EncodeToString(out.Bytes()); got != want {
+		t.Errorf("output = %s, want %s", got, want)
+	}
+}
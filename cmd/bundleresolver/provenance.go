@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// --provenance modes: a comment line before the output, or a JSON file
+// next to it.
+const (
+	provenanceHeader  = "header"
+	provenanceSidecar = "sidecar"
+)
+
+var provenanceModes = []string{provenanceHeader, provenanceSidecar}
+
+// provenanceHeaderPrefix starts the --provenance header line.
+const provenanceHeaderPrefix = "# provenance: "
+
+// secretFlags are left out of the provenance flags, which are written next
+// to the results; the proxy URLs may hold credentials.
+var secretFlags = map[string]bool{"cache-encryption-key": true, "ios-proxy": true, "android-proxy": true}
+
+// provenanceSuffix is appended to the --output path to name the sidecar.
+const provenanceSuffix = ".provenance.json"
+
+// provenance is the metadata of one run, enough to tell how a result file
+// was made and to reproduce it.
+type provenance struct {
+	Tool       string     `json:"tool"`
+	Version    string     `json:"version"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Country and Lang are the --country and --lang of the URLs;
+	// PriceCountries the storefronts of --price-countries.
+	Country        string   `json:"country,omitempty"`
+	Lang           string   `json:"lang,omitempty"`
+	PriceCountries []string `json:"price_countries,omitempty"`
+	// Flags holds every flag set on the command line or by the config file,
+	// except secretFlags.
+	Flags map[string]string `json:"flags"`
+	// Input is the --input path, or "-" for STDIN.
+	Input       string `json:"input"`
+	InputSHA256 string `json:"input_sha256"`
+}
+
+// parseProvenanceMode validates a --provenance value.
+func parseProvenanceMode(s string) (string, error) {
+	switch s {
+	case "", provenanceHeader, provenanceSidecar:
+		return s, nil
+	}
+	return "", fmt.Errorf("%q is not header or sidecar", s)
+}
+
+// newProvenance describes a run of fs's command over input, read from
+// inputPath ("" for STDIN), starting now.
+func newProvenance(fs *flag.FlagSet, inputPath string, input []byte, priceCountries []string) *provenance {
+	p := &provenance{
+		Tool:           progName(),
+		Version:        version,
+		StartedAt:      time.Now().UTC().Truncate(time.Second),
+		Country:        urlCountry,
+		Lang:           urlLang,
+		PriceCountries: priceCountries,
+		Flags:          map[string]string{},
+		Input:          inputPath,
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if !secretFlags[f.Name] {
+			p.Flags[f.Name] = f.Value.String()
+		}
+	})
+	if p.Input == "" {
+		p.Input = "-"
+	}
+	sum := sha256.Sum256(input)
+	p.InputSHA256 = hex.EncodeToString(sum[:])
+	return p
+}
+
+// writeHeader writes p as a comment line, the first line of a TSV or CSV
+// output; readers skip it as they would any line starting with #.
+func (p *provenance) writeHeader(w io.Writer) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", provenanceHeaderPrefix, b)
+	return err
+}
+
+// writeSidecar writes p, finished now, next to the output file at path.
+func (p *provenance) writeSidecar(path string) error {
+	finished := time.Now().UTC().Truncate(time.Second)
+	p.FinishedAt = &finished
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+provenanceSuffix, append(b, '\n'), 0o644)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProvenanceHeader(t *testing.T) {
+	fs := flag.NewFlagSet("resolve", flag.ContinueOnError)
+	fs.String("fields", defaultFields, "")
+	fs.Int("concurrency", 1, "")
+	fs.String("cache-encryption-key", "", "")
+	if err := fs.Parse([]string{"--fields", "bundle,name", "--cache-encryption-key", strings.Repeat("ab", 32)}); err != nil {
+		t.Fatal(err)
+	}
+	p := newProvenance(fs, "", []byte("com.example.app\n"), []string{"us", "jp"})
+
+	var b strings.Builder
+	if err := p.writeHeader(&b); err != nil {
+		t.Fatal(err)
+	}
+	line, ok := strings.CutPrefix(b.String(), provenanceHeaderPrefix)
+	if !ok || strings.Count(line, "\n") != 1 {
+		t.Fatalf("header = %q, want one %q line", b.String(), provenanceHeaderPrefix)
+	}
+	var got provenance
+	if err := json.Unmarshal([]byte(line), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != version || got.Input != "-" || got.FinishedAt != nil {
+		t.Errorf("version, input, finished = %q, %q, %v", got.Version, got.Input, got.FinishedAt)
+	}
+	if got.InputSHA256 != "d0aff4bb0980f6adfc11f02f09126d1a32fd9af8211f1d156ce96ebe7708d748" {
+		t.Errorf("input_sha256 = %q", got.InputSHA256)
+	}
+	if len(got.Flags) != 1 || got.Flags["fields"] != "bundle,name" {
+		t.Errorf("flags = %v, want only the --fields that was set, not the secret key", got.Flags)
+	}
+	if strings.Join(got.PriceCountries, ",") != "us,jp" {
+		t.Errorf("price_countries = %v", got.PriceCountries)
+	}
+}
+
+func TestProvenanceInputHash(t *testing.T) {
+	fs := flag.NewFlagSet("resolve", flag.ContinueOnError)
+	a := newProvenance(fs, "ids.txt", []byte("a\nb\n"), nil)
+	b := newProvenance(fs, "ids.txt", []byte("a\nb\n"), nil)
+	c := newProvenance(fs, "ids.txt", []byte("b\na\n"), nil)
+	if a.InputSHA256 != b.InputSHA256 || a.InputSHA256 == c.InputSHA256 {
+		t.Errorf("hashes %s, %s, %s: want the same input to hash alike and a reordered one not", a.InputSHA256, b.InputSHA256, c.InputSHA256)
+	}
+}
+
+func TestProvenanceSidecar(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "apps.tsv")
+	p := newProvenance(flag.NewFlagSet("resolve", flag.ContinueOnError), "ids.txt", nil, nil)
+	if err := p.writeSidecar(out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out + provenanceSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got provenance
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Input != "ids.txt" || got.FinishedAt == nil || got.FinishedAt.Before(got.StartedAt) {
+		t.Errorf("sidecar = %s", data)
+	}
+}
+
+func TestParseProvenanceMode(t *testing.T) {
+	for _, ok := range []string{"", "header", "sidecar"} {
+		if _, err := parseProvenanceMode(ok); err != nil {
+			t.Errorf("parseProvenanceMode(%q): %v", ok, err)
+		}
+	}
+	if _, err := parseProvenanceMode("footer"); err == nil {
+		t.Error("parseProvenanceMode(footer) accepted")
+	}
+}
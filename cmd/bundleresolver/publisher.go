@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// legalForms are the company-form words --normalize-publisher strips from
+// the end of a name, lowercased and without dots ("S.A." is "sa").
+var legalForms = map[string]bool{
+	"inc": true, "incorporated": true, "llc": true, "ltd": true, "limited": true,
+	"co": true, "corp": true, "corporation": true, "plc": true, "pty": true,
+	"pte": true, "gmbh": true, "ag": true, "kg": true, "ug": true, "sa": true,
+	"sas": true, "sarl": true, "srl": true, "spa": true, "bv": true, "nv": true,
+	"oy": true, "ab": true, "kk": true,
+}
+
+// japaneseLegalForms precede or follow a Japanese company name.
+var japaneseLegalForms = []string{"株式会社", "有限会社", "合同会社", "(株)", "(有)"}
+
+// normalizePublisher folds full- and half-width forms (ＡＢＣ is ABC,
+// ｶﾞ is ガ) and strips legal suffixes, so "Example, Inc." and "Example
+// LLC" both become "Example". A name that is nothing but a legal form is
+// kept.
+func normalizePublisher(s string) string {
+	s = strings.TrimSpace(norm.NFC.String(width.Fold.String(s)))
+	for _, form := range japaneseLegalForms {
+		if t := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(s, form), form)); t != "" {
+			s = t
+		}
+	}
+	for {
+		trimmed := strings.TrimRight(s, " ,&-")
+		i := strings.LastIndexAny(trimmed, " ,")
+		if i < 0 {
+			return trimmed
+		}
+		word := strings.ToLower(strings.ReplaceAll(trimmed[i+1:], ".", ""))
+		rest := strings.TrimRight(trimmed[:i], " ,&-")
+		if !legalForms[word] || rest == "" {
+			return trimmed
+		}
+		s = rest
+	}
+}
+
+// publisherAliases maps every spelling of a publisher met during a run to
+// the first one, so "Example" and "EXAMPLE" group as one in rollups. It is
+// used from process's output loop only, so it needs no locking.
+type publisherAliases struct {
+	seen map[string]string
+}
+
+func newPublisherAliases() *publisherAliases {
+	return &publisherAliases{seen: map[string]string{}}
+}
+
+// canonical returns the normalized spelling of publisher used for the run.
+func (a *publisherAliases) canonical(publisher string) string {
+	if publisher == "" {
+		return ""
+	}
+	n := normalizePublisher(publisher)
+	key := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, n)
+	if key == "" {
+		return n
+	}
+	if first, ok := a.seen[key]; ok {
+		return first
+	}
+	a.seen[key] = n
+	return n
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestNormalizePublisher(t *testing.T) {
+	cases := map[string]string{
+		"Google LLC":        "Google",
+		"Example, Inc.":     "Example",
+		"Acme Co., Ltd.":    "Acme",
+		"Foo GmbH & Co. KG": "Foo",
+		"Bar Pty Ltd":       "Bar",
+		"Baz S.A.":          "Baz",
+		"株式会社ミクシィ":          "ミクシィ",
+		"任天堂株式会社":           "任天堂",
+		"（株）ｺﾛﾌﾟﾗ":          "コロプラ",
+		"ＡＢＣ Games":         "ABC Games",
+		"Limited":           "Limited",
+		"Electronic Arts":   "Electronic Arts",
+		"  Spotify AB  ":    "Spotify",
+	}
+	for in, want := range cases {
+		if got := normalizePublisher(in); got != want {
+			t.Errorf("normalizePublisher(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPublisherAliases(t *testing.T) {
+	a := newPublisherAliases()
+	for _, c := range []struct{ in, want string }{
+		{"Example, Inc.", "Example"},
+		{"EXAMPLE LLC", "Example"},
+		{"ｅｘａｍｐｌｅ", "Example"},
+		{"Other Ltd", "Other"},
+		{"", ""},
+	} {
+		if got := a.canonical(c.in); got != c.want {
+			t.Errorf("canonical(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// vanityTLDs are country-code domains mostly registered as generic ones,
+// which say nothing of where their owner is.
+var vanityTLDs = map[string]bool{
+	"ac": true, "ai": true, "am": true, "cc": true, "co": true, "fm": true,
+	"gg": true, "io": true, "la": true, "ly": true, "me": true, "nu": true,
+	"sh": true, "so": true, "tk": true, "to": true, "tv": true, "vc": true,
+	"ws": true,
+}
+
+// countryAliases are the country names addresses use besides the English
+// names of CLDR, normalized as by countryKey.
+var countryAliases = map[string]string{
+	"usa": "us", "united states of america": "us",
+	"great britain": "gb", "england": "gb", "scotland": "gb", "wales": "gb", "northern ireland": "gb",
+	"korea": "kr", "republic of korea": "kr", "korea republic of": "kr",
+	"hong kong": "hk", "macao": "mo", "macau": "mo",
+	"czech republic": "cz", "türkiye": "tr", "turkiye": "tr", "russian federation": "ru",
+	"viet nam": "vn", "holland": "nl", "uae": "ae", "prc": "cn", "people's republic of china": "cn",
+	"iran islamic republic of": "ir", "syrian arab republic": "sy",
+	"democratic people's republic of korea": "kp", "dprk": "kp",
+}
+
+var (
+	countryNamesOnce sync.Once
+	countryNames     map[string]string
+)
+
+// countryCode returns the ISO 3166-1 code, lowercase, of a country's name
+// (Japan, United Kingdom) or code (JP), or "" if s is neither.
+func countryCode(s string) string {
+	if code := countryOfName(s); code != "" {
+		return code
+	}
+	key := countryKey(s)
+	if key == "uk" {
+		return "gb"
+	}
+	if len(key) == 2 {
+		if r, err := language.ParseRegion(key); err == nil && r.IsCountry() && r.Canonicalize().String() == strings.ToUpper(key) {
+			return key
+		}
+	}
+	return ""
+}
+
+// countryOfName returns the ISO 3166-1 code, lowercase, of a country's
+// name of three letters or more, or "" if s is none. Two letters are too
+// often a state or province (Wilmington, DE; San Francisco, CA) to be read
+// as a country next to a city.
+func countryOfName(s string) string {
+	countryNamesOnce.Do(func() {
+		countryNames = map[string]string{}
+		for a := 'a'; a <= 'z'; a++ {
+			for b := 'a'; b <= 'z'; b++ {
+				code := string([]rune{a, b})
+				r, err := language.ParseRegion(code)
+				// Deprecated codes (dd, uk) canonicalize to their successor.
+				if err != nil || !r.IsCountry() || r.Canonicalize().String() != strings.ToUpper(code) {
+					continue
+				}
+				name := countryKey(display.English.Regions().Name(r))
+				if _, dup := countryNames[name]; name != "" && !dup {
+					countryNames[name] = code
+				}
+			}
+		}
+		for name, code := range countryAliases {
+			countryNames[name] = code
+		}
+	})
+	key := countryKey(s)
+	if len([]rune(key)) < 3 {
+		return ""
+	}
+	return countryNames[key]
+}
+
+func countryKey(s string) string {
+	s = strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(strings.ReplaceAll(s, ".", ""), ",", " ")), " "))
+	return strings.TrimPrefix(s, "the ")
+}
+
+// domainCountry returns the country of a host's country-code domain
+// (example.co.jp is jp, example.co.uk gb), or "" for generic and vanity
+// domains.
+func domainCountry(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	tld := host[strings.LastIndex(host, ".")+1:]
+	if len(tld) != 2 || vanityTLDs[tld] {
+		return ""
+	}
+	return countryCode(tld)
+}
+
+// The signals publisherCountry reads a country from, as the
+// publisher_country_source field names them.
+const (
+	countrySourceAddress  = "address"
+	countrySourceWebsite  = "website"
+	countrySourceEmail    = "email"
+	countrySourceBundleID = "bundle_id"
+)
+
+// publisherCountry infers the country of rec's publisher, and names the
+// signal it came from. From the most to the least telling: the country
+// line of its EU trader address (a country's name, or its code alone on
+// the line), the domain of its website, then of its developer email, and a
+// bundle id or package under a country's domain (jp.co.example.app). Only
+// the address is declared by the publisher.
+func (rec record) publisherCountry() (country, source string) {
+	if addr := rec.Trader.addressLines(); len(addr) > 0 {
+		last := addr[len(addr)-1]
+		if code := countryCode(last); code != "" {
+			return code, countrySourceAddress
+		}
+		if i := strings.LastIndex(last, ","); i >= 0 {
+			if code := countryOfName(last[i+1:]); code != "" {
+				return code, countrySourceAddress
+			}
+		}
+	}
+	if u, err := url.Parse(rec.Website); err == nil && u.Hostname() != "" {
+		if code := domainCountry(u.Hostname()); code != "" {
+			return code, countrySourceWebsite
+		}
+	}
+	if _, domain, ok := strings.Cut(rec.DeveloperEmail, "@"); ok {
+		if code := domainCountry(domain); code != "" {
+			return code, countrySourceEmail
+		}
+	}
+	id := rec.BundleID
+	if platformOf(rec.Bundle) == platformAndroid {
+		id = rec.Bundle
+	}
+	if first, _, ok := strings.Cut(id, "."); ok {
+		if code := domainCountry(first); code != "" {
+			return code, countrySourceBundleID
+		}
+	}
+	return "", ""
+}
+
+// addressLines returns the lines of the trader's address, if any.
+func (t *traderInfo) addressLines() []string {
+	if t == nil {
+		return nil
+	}
+	return t.Address
+}
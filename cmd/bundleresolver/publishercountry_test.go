@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestPublisherCountry(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		rec          record
+		want, source string
+	}{
+		{"trader address", record{Bundle: "com.example.app", Website: "https://example.de", Trader: &traderInfo{Trader: true, Address: []string{"1-2-3 Shibuya", "Tokyo 150-0002", "Japan"}}}, "jp", "address"},
+		{"country after city", record{Bundle: "com.example.app", Trader: &traderInfo{Trader: true, Address: []string{"1 Main St", "Berlin, Germany"}}}, "de", "address"},
+		{"code line", record{Bundle: "com.example.app", Trader: &traderInfo{Trader: true, Address: []string{"1 Main St", "London", "UK"}}}, "gb", "address"},
+		{"us state", record{Bundle: "com.example.app", Website: "https://example.com", Trader: &traderInfo{Trader: true, Address: []string{"1209 Orange St", "Wilmington, DE"}}}, "", ""},
+		{"us state then website", record{Bundle: "com.example.app", Website: "https://example.co.uk", Trader: &traderInfo{Trader: true, Address: []string{"1 Market St", "San Francisco, CA"}}}, "gb", "website"},
+		{"website", record{Bundle: "com.example.app", Website: "https://www.example.co.uk/apps", DeveloperEmail: "dev@example.fr"}, "gb", "website"},
+		{"vanity website", record{Bundle: "com.example.app", Website: "https://example.io", DeveloperEmail: "dev@example.fr"}, "fr", "email"},
+		{"android package", record{Bundle: "ir.example.app", Website: "https://example.com"}, "ir", "bundle_id"},
+		{"language suffix", record{Bundle: "123", BundleID: "com.example.game.ko"}, "", ""},
+		{"nothing", record{Bundle: "123", BundleID: "com.example.app", Website: "https://example.com"}, "", ""},
+	} {
+		if got, source := tt.rec.publisherCountry(); got != tt.want || source != tt.source {
+			t.Errorf("%s: publisherCountry = %q, %q; want %q, %q", tt.name, got, source, tt.want, tt.source)
+		}
+	}
+}
+
+func TestCountryCode(t *testing.T) {
+	for name, want := range map[string]string{
+		"Japan": "jp", "United Kingdom": "gb", "the Netherlands": "nl", "South Korea": "kr",
+		"Republic of Korea": "kr", "U.S.A.": "us", "JP": "jp", "UK": "gb", "Atlantis": "", "": "",
+	} {
+		if got := countryCode(name); got != want {
+			t.Errorf("countryCode(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCountryOfName(t *testing.T) {
+	for _, s := range []string{"DE", "CA", "GA", "PA", "MA", "IN", "IL", "UK"} {
+		if got := countryOfName(s); got != "" {
+			t.Errorf("countryOfName(%q) = %q, want none", s, got)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" at 1-M, from the worked example of the standard.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomonRemainder(data, reedSolomonDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("ec codewords = %v, want %v", got, want)
+	}
+}
+
+func TestQRFormatAndVersionBits(t *testing.T) {
+	if got, want := qrFormatBits(5), 0b100000011001110; got != want {
+		t.Errorf("format bits M/5 = %015b, want %015b", got, want)
+	}
+	if got, want := qrVersionBits(7), 0b000111110010010100; got != want {
+		t.Errorf("version 7 bits = %018b, want %018b", got, want)
+	}
+}
+
+func TestEncodeQR(t *testing.T) {
+	q, err := encodeQR([]byte("https://apps.apple.com/app/id1234567890"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.size != 29 { // version 3
+		t.Errorf("size = %d, want 29", q.size)
+	}
+	// Finder pattern rings: dark, light, dark core.
+	for _, c := range [][2]int{{3, 3}, {q.size - 4, 3}, {3, q.size - 4}} {
+		x, y := c[0], c[1]
+		if !q.modules[y][x] || !q.modules[y-3][x] || q.modules[y-2][x] {
+			t.Errorf("no finder pattern at (%d,%d)", x, y)
+		}
+	}
+	if !q.modules[q.size-8][8] {
+		t.Error("dark module is light")
+	}
+
+	long := bytes.Repeat([]byte("a"), 214)
+	if _, err := encodeQR(long); err == nil {
+		t.Error("214 bytes encoded")
+	}
+	if q, err := encodeQR(long[:213]); err != nil || q.size != 57 {
+		t.Errorf("213 bytes: size %v, err %v; want version 10", q, err)
+	}
+}
+
+func TestWriteQR(t *testing.T) {
+	dir := t.TempDir()
+	rec := record{Bundle: "com.example.app", URL: buildPlayStoreURL("com.example.app")}
+	if err := writeQR(dir, rec); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(dir, "com.example.app.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != b.Dy() || b.Dx()%qrScale != 0 {
+		t.Errorf("image bounds %v", b)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxIOSSearchLimit is the most results the iTunes Search API returns.
+const maxIOSSearchLimit = 200
+
+func setupRank(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var inputPath string
+	var header bool
+	var depth int
+	fs.StringVar(&inputPath, "input", "", "Read ids from this file instead of STDIN")
+	fs.BoolVar(&header, "header", true, "Print header row as first line (use --header=false to disable)")
+	fs.IntVar(&depth, "depth", maxIOSSearchLimit, "Look this many search results deep (the App Store returns at most 200)")
+
+	return func(ctx context.Context, args []string) error {
+		keyword := strings.TrimSpace(strings.Join(args, " "))
+		if keyword == "" {
+			return errors.New("rank requires a keyword")
+		}
+		if depth < 1 {
+			return fmt.Errorf("invalid --depth %d (must be at least 1)", depth)
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		// Only the ids of the results are needed.
+		selectFields(nil)
+
+		in := io.Reader(os.Stdin)
+		if inputPath != "" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		return rankKeyword(ctx, keyword, in, os.Stdout, depth, header)
+	}
+}
+
+// rankKeyword writes one TSV row per input id giving its position (from 1)
+// in the store's search results for keyword. status is ranked, not-ranked
+// (not within the first depth results), unknown (not an app id) or error.
+// iOS apps may be given by track id or as ios:<bundle id>. Each store is
+// searched once.
+func rankKeyword(ctx context.Context, keyword string, r io.Reader, w io.Writer, depth int, header bool) error {
+	out := newRowWriter(w, nil, false, sanitizeStrip)
+	if header {
+		if err := out.writeValues("keyword", "bundle", "platform", "status", "rank", "results"); err != nil {
+			return err
+		}
+	}
+
+	type storeResult struct {
+		hits []searchHit
+		err  error
+	}
+	byStore := map[string]*storeResult{}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		id := strings.TrimSpace(s.Text())
+		if id == "" {
+			continue
+		}
+		platform := platformOf(id)
+		if platform == platformUnknown {
+			if err := out.writeValues(keyword, id, platform, "unknown", "", ""); err != nil {
+				return err
+			}
+			continue
+		}
+		res, ok := byStore[platform]
+		if !ok {
+			res = &storeResult{}
+			res.hits, res.err = searchIDs(ctx, platform, keyword, depth)
+			if res.err != nil {
+				logger.Warn("search failed", "platform", platform, "query", keyword, "err", res.err)
+			}
+			byStore[platform] = res
+		}
+		status, rank, results := "error", "", ""
+		if res.err == nil {
+			status, results = "not-ranked", strconv.Itoa(len(res.hits))
+			for i, found := range res.hits {
+				if found.is(id) {
+					status, rank = "ranked", strconv.Itoa(i+1)
+					break
+				}
+			}
+		}
+		if err := out.writeValues(keyword, id, platform, status, rank, results); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return out.flush()
+}
+
+// searchHit is a search result: its store id, and on iOS its bundle id.
+type searchHit struct {
+	id, bundleID string
+}
+
+// is reports whether the hit is the app id, as its store id or, on iOS,
+// as ios:<bundle id>.
+func (h searchHit) is(id string) bool {
+	if strings.EqualFold(h.id, id) {
+		return true
+	}
+	bundleID, ok := strings.CutPrefix(id, iosBundlePrefix)
+	return ok && h.bundleID != "" && strings.EqualFold(h.bundleID, bundleID)
+}
+
+// searchIDs returns the first depth search results for query, in result
+// order.
+func searchIDs(ctx context.Context, platform, query string, depth int) ([]searchHit, error) {
+	b := storeBreakers[platform]
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	var hits []searchHit
+	var err error
+	if platform == platformIOS {
+		var recs []record
+		recs, err = searchIOS(ctx, query, min(depth, maxIOSSearchLimit))
+		for _, rec := range recs {
+			hits = append(hits, searchHit{id: rec.Bundle, bundleID: rec.BundleID})
+		}
+	} else {
+		var ids []string
+		ids, err = searchAndroid(ctx, query)
+		for _, id := range ids {
+			hits = append(hits, searchHit{id: id})
+		}
+	}
+	b.done(err)
+	if len(hits) > depth {
+		hits = hits[:depth]
+	}
+	return hits, err
+}
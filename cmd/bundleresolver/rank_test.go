@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRankKeyword(t *testing.T) {
+	originalClient, originalFields := httpClient, selectedFields
+	defer func() {
+		httpClient, selectedFields = originalClient, originalFields
+	}()
+	selectFields(nil)
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://itunes.apple.com/search?entity=software&term=photo+editor&limit=200": `{"results":[{"trackId":111,"bundleId":"com.one.ios"},{"trackId":222,"bundleId":"com.two.ios"},{"trackId":333,"bundleId":"com.three.ios"}]}`,
+		"https://play.google.com/store/search?c=apps&q=photo+editor": `<a href="/store/apps/details?id=com.a.one">1</a>` +
+			`<a href="/store/apps/details?id=com.b.two">2</a><a href="/store/apps/details?id=com.a.one">again</a>`,
+	}}
+
+	var out strings.Builder
+	input := strings.NewReader("222\nios:com.three.ios\n999\ncom.b.two\nnot an id\n")
+	if err := rankKeyword(context.Background(), "photo editor", input, &out, 200, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "keyword\tbundle\tplatform\tstatus\trank\tresults\n" +
+		"photo editor\t222\tios\tranked\t2\t3\n" +
+		"photo editor\tios:com.three.ios\tios\tranked\t3\t3\n" +
+		"photo editor\t999\tios\tnot-ranked\t\t3\n" +
+		"photo editor\tcom.b.two\tandroid\tranked\t2\t2\n" +
+		"photo editor\tnot an id\tunknown\tunknown\t\t\n"
+	if got := out.String(); got != want {
+		t.Errorf("output mismatch:\n got: %q\nwant: %q", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// defaultMaxRedirects matches net/http's own limit.
+const defaultMaxRedirects = 10
+
+// redirectPolicy returns an http.Client CheckRedirect that follows at most
+// max redirects. With max 0 the redirect response itself is returned, so
+// lookups see its 3xx status.
+func redirectPolicy(max int) func(*http.Request, []*http.Request) error {
+	return func(_ *http.Request, via []*http.Request) error {
+		if len(via) <= max {
+			return nil
+		}
+		if max == 0 {
+			return http.ErrUseLastResponse
+		}
+		return fmt.Errorf("stopped after %d redirects", max)
+	}
+}
+
+// finalURL returns the URL a response was served from, or the target of a
+// redirect that was not followed.
+func finalURL(resp *http.Response) string {
+	if resp.StatusCode/100 == 3 {
+		loc, err := resp.Location()
+		if err == nil {
+			return loc.String()
+		}
+		if !errors.Is(err, http.ErrNoLocation) {
+			logger.Debug("invalid Location header", "url", resp.Request.URL.String(), "err", err)
+		}
+	}
+	return resp.Request.URL.String()
+}
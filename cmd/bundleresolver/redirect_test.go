@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app/id1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/us/app/id1", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/us/app/id1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/us/app/my-app/id1", http.StatusFound)
+	})
+	mux.HandleFunc("/us/app/my-app/id1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	originalClient := httpClient
+	defer func() {
+		httpClient = originalClient
+	}()
+
+	cases := []struct {
+		max     int
+		want    string
+		wantErr bool
+	}{
+		{defaultMaxRedirects, srv.URL + "/us/app/my-app/id1", false},
+		{0, srv.URL + "/us/app/id1", false},
+		{1, "", true},
+	}
+	for _, tc := range cases {
+		httpClient = &http.Client{CheckRedirect: redirectPolicy(tc.max)}
+		resp, err := httpGet(context.Background(), srv.URL+"/app/id1")
+		if tc.wantErr {
+			if err == nil {
+				resp.Body.Close()
+				t.Errorf("max %d: expected an error", tc.max)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("max %d: %v", tc.max, err)
+		}
+		resp.Body.Close()
+		if got := finalURL(resp); got != tc.want {
+			t.Errorf("max %d: finalURL = %q, want %q", tc.max, got, tc.want)
+		}
+	}
+}
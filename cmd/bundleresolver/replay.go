@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// replayOmittedFlags are left out of a replayed run's flags: they name
+// where the run wrote its results and metadata, which a replay must not
+// overwrite, or file descriptors of the original process.
+var replayOmittedFlags = map[string]bool{
+	"output": true, "output-dir": true, "provenance": true, "errors": true,
+	"errors-fd": true, "results-fd": true, "summary-json": true,
+	"snapshot": true, "qr": true, "download-assets": true, "debug-http": true,
+	"log-file": true, "pprof": true, "cpuprofile": true, "memprofile": true,
+	"failed-out": true, "request-stats": true, "replay-run": true,
+	"dedupe-cross-platform": true,
+}
+
+// loadProvenance reads the metadata of a previous run from a --provenance
+// sidecar, or from an output file starting with a provenance header.
+func loadProvenance(path string) (*provenance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if header, ok := bytes.CutPrefix(data, []byte(provenanceHeaderPrefix)); ok {
+		line, _, _ := bytes.Cut(header, []byte("\n"))
+		data = line
+	}
+	var p provenance
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: invalid provenance: %w", path, err)
+	}
+	if p.Flags == nil {
+		return nil, fmt.Errorf("%s: provenance has no flags", path)
+	}
+	return &p, nil
+}
+
+// applyReplay sets the flags of the run p describes, except those given on
+// the command line and replayOmittedFlags. The config file and environment
+// are not read: p already holds what they set.
+func applyReplay(fset *flag.FlagSet, p *provenance) error {
+	explicit := map[string]bool{}
+	fset.Visit(func(f *flag.Flag) {
+		explicit[canonicalFlag(f.Name)] = true
+	})
+	names := make([]string, 0, len(p.Flags))
+	for name := range p.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if replayOmittedFlags[name] || explicit[canonicalFlag(name)] {
+			continue
+		}
+		if fset.Lookup(name) == nil {
+			return fmt.Errorf("the replayed run's --%s is not a %s flag", name, fset.Name())
+		}
+		if err := fset.Set(name, p.Flags[name]); err != nil {
+			return fmt.Errorf("the replayed run's --%s: %w", name, err)
+		}
+	}
+	return nil
+}
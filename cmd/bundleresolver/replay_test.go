@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProvenance(t *testing.T) {
+	dir := t.TempDir()
+	p := &provenance{Version: version, Flags: map[string]string{"fields": "bundle,name"}, Input: "ids.txt", InputSHA256: "abc"}
+
+	out := filepath.Join(dir, "apps.tsv")
+	if err := p.writeSidecar(out); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, "header.tsv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.writeHeader(f); err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("bundle\tname\ncom.example.app\tApp\n")
+	f.Close()
+
+	for _, path := range []string{out + provenanceSuffix, f.Name()} {
+		got, err := loadProvenance(path)
+		if err != nil {
+			t.Fatalf("loadProvenance(%s): %v", path, err)
+		}
+		if got.Flags["fields"] != "bundle,name" || got.InputSHA256 != "abc" {
+			t.Errorf("loadProvenance(%s) = %+v", path, got)
+		}
+	}
+
+	plain := filepath.Join(dir, "plain.tsv")
+	os.WriteFile(plain, []byte("bundle\tname\n"), 0o644)
+	if _, err := loadProvenance(plain); err == nil {
+		t.Error("a file without provenance was accepted")
+	}
+}
+
+func TestApplyReplay(t *testing.T) {
+	fs := flag.NewFlagSet("resolve", flag.ContinueOnError)
+	fields := fs.String("fields", defaultFields, "")
+	fs.StringVar(fields, "f", defaultFields, "")
+	concurrency := fs.Int("concurrency", 1, "")
+	output := fs.String("output", "", "")
+	input := fs.String("input", "", "")
+	if err := fs.Parse([]string{"--concurrency", "8"}); err != nil {
+		t.Fatal(err)
+	}
+	p := &provenance{Flags: map[string]string{"f": "bundle,name", "concurrency": "2", "output": "apps.tsv", "input": "ids.txt"}}
+	if err := applyReplay(fs, p); err != nil {
+		t.Fatal(err)
+	}
+	if *fields != "bundle,name" || *input != "ids.txt" {
+		t.Errorf("fields, input = %q, %q, want the replayed run's", *fields, *input)
+	}
+	if *concurrency != 8 {
+		t.Errorf("concurrency = %d, want the command line's 8", *concurrency)
+	}
+	if *output != "" {
+		t.Errorf("output = %q, want it left out of the replay", *output)
+	}
+
+	p.Flags["no-such-flag"] = "1"
+	if err := applyReplay(fs, p); err == nil {
+		t.Error("an unknown replayed flag was accepted")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// reportEncoder renders records as a standalone HTML page: a table of the
+// selected fields with each app's icon, sortable by clicking a column
+// heading and filtered by a search box, for sharing results with people
+// who don't use spreadsheets or the command line.
+type reportEncoder struct {
+	fields []Field
+}
+
+// reportLinkFields are rendered as links.
+var reportLinkFields = map[Field]bool{FieldURL: true, FieldResolvedURL: true, FieldWebsite: true}
+
+const reportHead = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>bundleresolver report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+input { font-size: 1em; padding: .4em; width: 24em; margin-bottom: 1em; }
+table { border-collapse: collapse; }
+th, td { border-bottom: 1px solid #ddd; padding: .4em .6em; text-align: left; vertical-align: middle; }
+th { cursor: pointer; user-select: none; background: #f6f6f6; position: sticky; top: 0; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+td img { width: 32px; height: 32px; border-radius: 7px; }
+#count { color: #666; margin-left: 1em; }
+</style>
+</head>
+<body>
+<h1>bundleresolver report</h1>
+<input id="filter" type="search" placeholder="Filter rows" autofocus><span id="count"></span>
+<table id="report">
+<thead><tr><th data-nosort></th>`
+
+const reportTail = `</tbody>
+</table>
+<script>
+(function () {
+  var table = document.getElementById("report");
+  var body = table.tBodies[0];
+  var rows = Array.prototype.slice.call(body.rows);
+  var count = document.getElementById("count");
+  function update() {
+    var q = document.getElementById("filter").value.toLowerCase();
+    var shown = 0;
+    rows.forEach(function (r) {
+      var hit = r.textContent.toLowerCase().indexOf(q) >= 0;
+      r.style.display = hit ? "" : "none";
+      if (hit) shown++;
+    });
+    count.textContent = shown + " of " + rows.length + " apps";
+  }
+  document.getElementById("filter").addEventListener("input", update);
+  Array.prototype.forEach.call(table.tHead.rows[0].cells, function (th, i) {
+    if (th.hasAttribute("data-nosort")) return;
+    th.addEventListener("click", function () {
+      var asc = !th.classList.contains("asc");
+      Array.prototype.forEach.call(th.parentNode.cells, function (c) { c.classList.remove("asc", "desc"); });
+      th.classList.add(asc ? "asc" : "desc");
+      rows.sort(function (a, b) {
+        var x = a.cells[i].textContent, y = b.cells[i].textContent;
+        var d = x.localeCompare(y, undefined, {numeric: true});
+        return asc ? d : -d;
+      });
+      rows.forEach(function (r) { body.appendChild(r); });
+    });
+  });
+  update();
+})();
+</script>
+</body>
+</html>
+`
+
+func (e reportEncoder) begin(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(reportHead)
+	for _, f := range e.fields {
+		fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(string(f)))
+	}
+	b.WriteString("</tr></thead>\n<tbody>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (e reportEncoder) encode(w io.Writer, rec record) error {
+	var b strings.Builder
+	b.WriteString("<tr><td>")
+	if rec.IconURL != "" {
+		fmt.Fprintf(&b, `<img src="%s" alt="" loading="lazy">`, html.EscapeString(rec.IconURL))
+	}
+	b.WriteString("</td>")
+	for _, f := range e.fields {
+		v := html.EscapeString(rec.value(f))
+		if reportLinkFields[f] && strings.HasPrefix(v, "http") {
+			v = fmt.Sprintf(`<a href="%s">%s</a>`, v, v)
+		}
+		fmt.Fprintf(&b, "<td>%s</td>", v)
+	}
+	b.WriteString("</tr>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (reportEncoder) end(w io.Writer) error {
+	_, err := io.WriteString(w, reportTail)
+	return err
+}
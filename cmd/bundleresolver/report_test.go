@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProcessHTMLReport(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() { resolveFunc = originalResolve }()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return record{Bundle: id, Name: "<b>Bold</b> & Co", URL: buildPlayStoreURL(id), IconURL: "https://play-lh.example/icon=s512"}, nil
+	}
+	var out strings.Builder
+	opts := options{Fields: []Field{FieldName, FieldURL}, Header: true, Format: "html"}
+	if err := process(strings.NewReader("com.example.app\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	page := out.String()
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<th data-nosort></th><th>name</th><th>url</th></tr></thead>",
+		`<tr><td><img src="https://play-lh.example/icon=s512" alt="" loading="lazy"></td><td>&lt;b&gt;Bold&lt;/b&gt; &amp; Co</td><td><a href="https://play.google.com/store/apps/details?id=com.example.app">https://play.google.com/store/apps/details?id=com.example.app</a></td></tr>`,
+		"</html>\n",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report lacks %q:\n%s", want, page)
+		}
+	}
+}
+
+func TestFormatOfPath(t *testing.T) {
+	cases := map[string]string{"report.html": "html", "r.HTM": "html", "apps.csv": "csv", "feed.xml": "rss", "feed.rss": "rss", "out.tsv": "tsv", "out": "tsv"}
+	for path, want := range cases {
+		if got := formatOfPath(path); got != want {
+			t.Errorf("formatOfPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
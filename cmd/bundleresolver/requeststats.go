@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// activeRequestStats, when set, receives every request countingTransport
+// sees (--request-stats).
+var activeRequestStats atomic.Pointer[requestStats]
+
+// requestStats accumulates the HTTP requests of a run per store. All
+// methods are safe for concurrent use.
+type requestStats struct {
+	mu     sync.Mutex
+	stores map[string]*storeRequestStats
+}
+
+// storeRequestStats is one store's entry of --request-stats. Latencies are
+// measured until the response headers arrive; Bytes counts the response
+// bodies read.
+type storeRequestStats struct {
+	Requests int            `json:"requests"`
+	Failed   int            `json:"failed"`
+	Bytes    int64          `json:"bytes"`
+	P50MS    int64          `json:"p50_ms"`
+	P95MS    int64          `json:"p95_ms"`
+	Status   map[string]int `json:"status"`
+
+	latencies []time.Duration
+}
+
+func newRequestStats() *requestStats {
+	return &requestStats{stores: map[string]*storeRequestStats{}}
+}
+
+// storeOfHost names the store a host belongs to; hosts of neither store,
+// such as developer sites, are "other".
+func storeOfHost(host string) string {
+	switch {
+	case host == "apple.com" || strings.HasSuffix(host, ".apple.com"):
+		return platformIOS
+	case host == "play.google.com":
+		return platformAndroid
+	}
+	return "other"
+}
+
+func (s *requestStats) store(name string) *storeRequestStats {
+	st := s.stores[name]
+	if st == nil {
+		st = &storeRequestStats{Status: map[string]int{}}
+		s.stores[name] = st
+	}
+	return st
+}
+
+// record adds a request that took d to answer with resp, or failed with
+// err. It wraps resp's body to count the bytes read.
+func (s *requestStats) record(req *http.Request, resp *http.Response, err error, d time.Duration) {
+	name := storeOfHost(req.URL.Hostname())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.store(name)
+	st.Requests++
+	st.latencies = append(st.latencies, d)
+	if err != nil {
+		st.Failed++
+		return
+	}
+	st.Status[strconv.Itoa(resp.StatusCode)]++
+	resp.Body = &countingBody{ReadCloser: resp.Body, stats: s, store: name}
+}
+
+func (s *requestStats) addBytes(store string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(store).Bytes += int64(n)
+}
+
+// countingBody counts the bytes read from a response body.
+type countingBody struct {
+	io.ReadCloser
+	stats *requestStats
+	store string
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.stats.addBytes(b.store, n)
+	}
+	return n, err
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (p*len(sorted) + 99) / 100
+	return sorted[max(i, 1)-1]
+}
+
+// writeJSONFile writes the stats per store to path.
+func (s *requestStats) writeJSONFile(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, st := range s.stores {
+		sorted := append([]time.Duration(nil), st.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		st.P50MS = percentile(sorted, 50).Milliseconds()
+		st.P95MS = percentile(sorted, 95).Milliseconds()
+	}
+	data, err := json.MarshalIndent(s.stores, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRequestStats(t *testing.T) {
+	stats := newRequestStats()
+	activeRequestStats.Store(stats)
+	defer activeRequestStats.Store(nil)
+	client := &http.Client{Transport: &countingTransport{base: fakeTransport{
+		"https://itunes.apple.com/lookup?id=1": `{"resultCount":0}`,
+		"https://dev.example/app-ads.txt":      "x",
+	}}}
+	for _, u := range []string{
+		"https://itunes.apple.com/lookup?id=1",
+		"https://itunes.apple.com/lookup?id=1",
+		"https://play.google.com/store/apps/details?id=com.gone.app",
+		"https://dev.example/app-ads.txt",
+	} {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	path := filepath.Join(t.TempDir(), "stats.json")
+	if err := stats.writeJSONFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]storeRequestStats
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if ios := got["ios"]; ios.Requests != 2 || ios.Bytes != 34 || ios.Status["200"] != 2 {
+		t.Errorf("ios = %+v", ios)
+	}
+	if android := got["android"]; android.Requests != 1 || android.Status["404"] != 1 {
+		t.Errorf("android = %+v", android)
+	}
+	if other := got["other"]; other.Requests != 1 || other.Bytes != 1 {
+		t.Errorf("other = %+v", other)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 20; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	if p50, p95 := percentile(sorted, 50), percentile(sorted, 95); p50 != 10*time.Millisecond || p95 != 19*time.Millisecond {
+		t.Errorf("p50, p95 = %v, %v", p50, p95)
+	}
+	if p := percentile(sorted[:1], 95); p != time.Millisecond {
+		t.Errorf("p95 of one = %v", p)
+	}
+	if p := percentile(nil, 50); p != 0 {
+		t.Errorf("p50 of none = %v", p)
+	}
+}
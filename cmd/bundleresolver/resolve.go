@@ -0,0 +1,589 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func setupResolve(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var fieldsCSV string
+	var showVersion bool
+	var showHeader bool
+	var skipErrors bool
+	var outputCSV bool
+	var outputFormat string
+	var outputPath string
+	var errorsPath string
+	var failedOutPath string
+	var resultsFD int
+	var errorsFD int
+	var inputPath string
+	var schedule string
+	var outputDir string
+	var showProgress bool
+	var showSummary bool
+	var summaryJSON string
+	var dedupePath string
+	var requestStatsPath string
+	var provenanceFlag string
+	var replayPath string
+	var pprofAddr string
+	var cpuProfile string
+	var memProfile string
+	var sanitizeFlag string
+	var normalize bool
+	var normalizePublishers bool
+	var flushInterval time.Duration
+	var concurrency int
+	var unordered bool
+	var retryFailed bool
+	var retryDelay time.Duration
+	var maxRequests int
+	var maxErrors int
+	var snapshotDir string
+	var history bool
+	var explain bool
+	var romanizeNames bool
+	var pluginPath string
+	var sdkSourceFlag string
+	var mirrorFlag string
+	var androidSource string
+	var sourcesFlag string
+	var playServiceAccount string
+	var archive bool
+	var guessStorefront bool
+	var scriptPath string
+	var filterSrc string
+	var transformSrc string
+	var assets assetOptions
+	var qrDir string
+	var priceCountries string
+	var urlStyleFlag string
+	var shortBase string
+	var country string
+	var lang string
+	var baseCurrency string
+	var perCountryRows bool
+
+	fs.StringVar(&fieldsCSV, "fields", defaultFields, fieldsUsage())
+	fs.StringVar(&fieldsCSV, "f", defaultFields, "Alias of --fields")
+	fs.BoolVar(&showVersion, "version", false, "Print version and exit")
+	fs.BoolVar(&showHeader, "header", true, "Print header row as first line (use --header=false to disable)")
+	fs.BoolVar(&skipErrors, "skip-errors", false, "Skip lines that fail to resolve instead of outputting empty rows")
+	fs.BoolVar(&outputCSV, "csv", false, "Emit output as CSV (RFC4180 with quoting) instead of TSV")
+	fs.StringVar(&outputFormat, "output-format", "", "Output format: tsv, csv (same as --csv), rss (a feed of the resolved apps), html (a sortable report page), proto (length-delimited protocol buffers) or msgpack (MessagePack maps mirroring the JSON records); default tsv, or after the --output extension")
+	fs.StringVar(&errorsPath, "errors", "", "Write a JSON line per failed lookup (input, stage, store, http_status, message) to this file")
+	fs.StringVar(&failedOutPath, "failed-out", "", "Write the input line of every failed lookup to this file, one per line, ready to be the --input of another run")
+	fs.IntVar(&errorsFD, "errors-fd", 0, "Like --errors, but write to this already open file descriptor (e.g. 4)")
+	fs.IntVar(&resultsFD, "results-fd", 0, "Write the output to this already open file descriptor instead of STDOUT (e.g. 3)")
+	fs.StringVar(&outputPath, "output", "", "Write the output to this file instead of STDOUT (e.g. report.html)")
+	fs.StringVar(&sanitizeFlag, "sanitize", "strip", "How tabs and newlines inside values are written: strip (replace with a space), quote (keep, RFC4180-quoted) or escape (as \\t, \\n)")
+	fs.BoolVar(&normalize, "normalize-unicode", false, "NFC-normalize values and strip invisible filler characters (bidi and zero-width controls are always stripped)")
+	fs.BoolVar(&normalizePublishers, "normalize-publisher", false, "Strip legal suffixes (Inc., LLC, GmbH, 株式会社) from publisher names, fold full-width characters and use one spelling per publisher")
+	fs.DurationVar(&flushInterval, "flush-interval", time.Second, "Write buffered output at least this often (0 writes every row immediately)")
+	fs.IntVar(&concurrency, "concurrency", 4, "Number of lookups run in parallel")
+	fs.BoolVar(&retryFailed, "retry-failed", false, "After all other rows, retry once the lookups that failed transiently (network errors, 429 or 5xx), one at a time; their rows come last, with the input id as first column")
+	fs.DurationVar(&retryDelay, "retry-delay", 2*time.Second, "With --retry-failed, wait this long before each retry")
+	fs.IntVar(&maxRequests, "max-requests", 0, "Stop starting lookups once the run has sent this many requests (0 means unlimited); the ids not looked up go to --failed-out")
+	fs.IntVar(&maxErrors, "max-errors", 0, "Stop starting lookups once this many have failed (0 means unlimited); the ids not looked up go to --failed-out")
+	fs.BoolVar(&unordered, "unordered", false, "Write rows as lookups finish instead of in input order, with the input id as first column")
+	fs.BoolVar(&history, "history", false, "Write one row per release of each iOS app, with the version, version_date and release_notes fields, from its App Store page")
+	fs.BoolVar(&explain, "explain", false, "Add the detection and confidence fields, telling how each line was classified and which fallbacks its lookup took")
+	fs.BoolVar(&romanizeNames, "romanize", false, "Add the name_latin field, the name with its kana, Hangul, Cyrillic and Greek transliterated to Latin letters")
+	fs.StringVar(&assets.Dir, "download-assets", "", "Download each app's icon into <dir>/<bundle>/")
+	fs.BoolVar(&assets.Screenshots, "screenshots", false, "With --download-assets, also download the screenshots")
+	fs.StringVar(&urlStyleFlag, "url-style", urlStyleCanonical, "Form of the url field: canonical (built from the id), store (the URL the store links to, with the app's slug) or short (a --short-base redirect link)")
+	fs.StringVar(&shortBase, "short-base", "", "With --url-style short, the address of a serve instance (e.g. https://go.example.com), whose /go/<id> redirects to the store")
+	fs.StringVar(&country, "country", "", "Write store URLs for this storefront (e.g. jp: apps.apple.com/jp/..., Play &gl=JP)")
+	fs.StringVar(&lang, "lang", "", "Write store URLs in this language (e.g. ja: App Store ?l=ja, Play &hl=ja)")
+	fs.StringVar(&priceCountries, "price-countries", "", "Add a price_<cc> column per storefront in this comma-separated list (e.g. us,jp,gb)")
+	fs.BoolVar(&perCountryRows, "per-country-rows", false, "With --price-countries, write one row per app and country, with that storefront's name and price and the country and available fields, instead of price_<cc> columns")
+	fs.StringVar(&baseCurrency, "base-currency", "", "With --price-countries, add price_<cc>_<currency> columns converted to this currency (e.g. USD) at ECB reference rates")
+	fs.StringVar(&qrDir, "qr", "", "Write a QR code PNG of each resolved store URL to <dir>/<bundle>.png")
+	fs.StringVar(&snapshotDir, "snapshot", "", "Save each run as a JSONL snapshot in this directory and report the changes since the previous one")
+	fs.StringVar(&sourcesFlag, "sources", "", "Comma-separated chain of data sources each lookup tries in order until one has the app: api, store, mirror, dump, archive, cache (default store, or dump with --android-source file:)")
+	fs.StringVar(&androidSource, "android-source", "play", "Where Android ids are looked up: play (the live Play pages) or file:<path>, a JSONL dump of Play listings or a sitemap of Play details URLs, optionally gzipped, with no Play requests")
+	fs.StringVar(&mirrorFlag, "mirror", "", "URL template of a listing mirror for --sources mirror; {id} is replaced with the input id and the reply is a JSON listing in the keys of an --android-source dump line")
+	fs.StringVar(&sdkSourceFlag, "sdk-source", "", "Fill target_sdk (and min_sdk where Play does not say) from this APK metadata URL; {id} is replaced with the package name and the reply is JSON with min_sdk and target_sdk")
+	fs.BoolVar(&guessStorefront, "guess-storefront", false, "When an ios:<bundle id> is not in the default storefront, try the storefronts its domain or language suffix hints at (e.g. jp.co.*, kr.co.*, *.ko) before jp")
+	fs.BoolVar(&archive, "archive", false, "Recover the name and publisher of apps the stores no longer list from their last Wayback Machine snapshot, with source=archive")
+	fs.StringVar(&playServiceAccount, "play-service-account", "", "Also look Android ids up through the Play Developer API with this service account JSON key, for your own apps' listing and play_tracks")
+	fs.StringVar(&pluginPath, "plugin", "", "Resolve ids neither store accepts with this executable, speaking JSON lines over STDIN/STDOUT")
+	fs.StringVar(&filterSrc, "filter", "", "Only output records matching this expression, e.g. 'publisher contains \"Google\" && platform == \"android\"'")
+	fs.StringVar(&transformSrc, "transform", "", "Rewrite field values, e.g. 'name=lower,publisher=trim-suffix:\", Inc.\"' (lower, upper, trim, trim-prefix:<s>, trim-suffix:<s>, truncate:<n>)")
+	fs.StringVar(&scriptPath, "script", "", "Pass every output row through this Starlark file (.star), run by the embedded interpreter, or executable (JSON lines over STDIN/STDOUT), which may rewrite, drop, add or rename columns")
+	fs.StringVar(&inputPath, "input", "", "Read ids from this file instead of STDIN")
+	fs.StringVar(&schedule, "schedule", "", "Run as a daemon, re-resolving --input on this cron schedule (e.g. \"0 3 * * *\")")
+	fs.StringVar(&outputDir, "output-dir", "", "With --schedule, write each run to a timestamped file in this directory instead of STDOUT")
+	fs.BoolVar(&showProgress, "progress", true, "Show a progress bar on STDERR when it is a terminal (use --progress=false to disable)")
+	fs.BoolVar(&showSummary, "summary", false, "Print an end-of-run summary (counts per platform and outcome, retries, elapsed time, slowest lookups) to STDERR")
+	fs.StringVar(&summaryJSON, "summary-json", "", "Write the end-of-run summary as JSON to this file")
+	fs.StringVar(&dedupePath, "dedupe-cross-platform", "", "Write a TSV report to this file giving every resolved app a cluster id, shared by the iOS and Android apps whose names and publishers are alike, for deduplicating catalogs")
+	fs.StringVar(&requestStatsPath, "request-stats", "", "Write per-store HTTP statistics (requests, bytes, p50/p95 latency, status codes) of the run as JSON to this file")
+	fs.StringVar(&provenanceFlag, "provenance", "", "Record the run's metadata (version, time, countries, flags, input hash): header (a # line before TSV or CSV output) or sidecar (<output>.provenance.json)")
+	fs.StringVar(&replayPath, "replay-run", "", "Rerun with the flags recorded in this --provenance sidecar or header file (flags given on the command line win), checking the input is unchanged")
+	fs.StringVar(&pprofAddr, "pprof", "", "Serve net/http/pprof on this address (e.g. :6060)")
+	fs.StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile to this file")
+	fs.StringVar(&memProfile, "memprofile", "", "Write a heap profile to this file on exit")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q (ids are read from STDIN or --input)", args)
+		}
+		if showVersion {
+			fmt.Println(version)
+			return nil
+		}
+
+		var prog *progress
+		logOut := io.Writer(os.Stderr)
+		if showProgress && !common.quiet && isTerminal(os.Stderr) {
+			prog = newProgress(os.Stderr)
+			logOut = prog.logWriter()
+		}
+		cleanup, err := common.setup(logOut)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		fields, err := parseFields(fieldsCSV)
+		if err != nil {
+			return fmt.Errorf("invalid --fields: %w", err)
+		}
+		if history {
+			for _, f := range []Field{FieldVersion, FieldVersionDate, FieldReleaseNotes} {
+				if !hasField(fields, f) {
+					fields = append(fields, f)
+				}
+			}
+		}
+		if explain {
+			for _, f := range explainFields {
+				if !hasField(fields, f) {
+					fields = append(fields, f)
+				}
+			}
+		}
+		if romanizeNames && !hasField(fields, FieldNameLatin) {
+			fields = append(fields, FieldNameLatin)
+		}
+		var prices priceOptions
+		if prices.Countries, err = parseCountries(priceCountries); err != nil {
+			return fmt.Errorf("invalid --price-countries: %w", err)
+		}
+		if perCountryRows {
+			if len(prices.Countries) == 0 {
+				return errors.New("--per-country-rows requires --price-countries")
+			}
+			if baseCurrency != "" {
+				return errors.New("--base-currency does not apply to --per-country-rows")
+			}
+			prices.Rows = true
+			for _, f := range []Field{FieldCountry, FieldAvailable, FieldPrice} {
+				if !hasField(fields, f) {
+					fields = append(fields, f)
+				}
+			}
+		}
+		if baseCurrency != "" {
+			if len(prices.Countries) == 0 {
+				return errors.New("--base-currency requires --price-countries")
+			}
+			prices.Base = strings.ToUpper(baseCurrency)
+			if prices.Rates, err = fetchRates(ctx); err != nil {
+				return fmt.Errorf("fetching exchange rates: %w", err)
+			}
+			if _, ok := prices.Rates[prices.Base]; !ok {
+				return fmt.Errorf("invalid --base-currency %q (no ECB reference rate)", baseCurrency)
+			}
+		}
+		fields = append(fields, prices.fields()...)
+		var filter *filterExpr
+		if filterSrc != "" {
+			if filter, err = parseFilter(filterSrc); err != nil {
+				return fmt.Errorf("invalid --filter: %w", err)
+			}
+		}
+		style, err := parseURLStyle(urlStyleFlag, shortBase)
+		if err != nil {
+			return err
+		}
+		xforms, err := parseTransforms(transformSrc)
+		if err != nil {
+			return fmt.Errorf("invalid --transform: %w", err)
+		}
+		if filter != nil {
+			selectFields(append(append([]Field(nil), fields...), filter.fields...))
+		} else {
+			selectFields(fields)
+		}
+		historyRequested = history
+		archiveLookups = archive
+		guessStorefronts = guessStorefront
+		if urlCountry, err = parseCountry(country); err != nil {
+			return fmt.Errorf("invalid --country: %w", err)
+		}
+		if urlLang, err = parseLang(lang); err != nil {
+			return fmt.Errorf("invalid --lang: %w", err)
+		}
+		if sdkSource, err = parseSDKSource(sdkSourceFlag); err != nil {
+			return fmt.Errorf("invalid --sdk-source: %w", err)
+		}
+		if mirrorSource, err = parseIDTemplate(mirrorFlag); err != nil {
+			return fmt.Errorf("invalid --mirror: %w", err)
+		}
+		if androidDump, err = parseAndroidSource(androidSource); err != nil {
+			return fmt.Errorf("invalid --android-source: %w", err)
+		}
+		playAPI = nil
+		if playServiceAccount != "" {
+			if playAPI, err = loadPlayServiceAccount(playServiceAccount); err != nil {
+				return fmt.Errorf("invalid --play-service-account: %w", err)
+			}
+		}
+		if sourceOrder, err = parseSources(sourcesFlag); err != nil {
+			return fmt.Errorf("invalid --sources: %w", err)
+		}
+		for _, r := range []struct {
+			source, flag string
+			missing      bool
+		}{
+			{sourceAPI, "--play-service-account", playAPI == nil},
+			{sourceMirror, "--mirror", mirrorSource == ""},
+			{sourceDump, "--android-source file:<path>", androidDump == nil},
+			{sourceCache, "--cache-dir", common.cacheDir == ""},
+		} {
+			if r.missing && containsString(sourceOrder, r.source) {
+				return fmt.Errorf("--sources %s requires %s", r.source, r.flag)
+			}
+		}
+		if archive && len(sourceOrder) > 0 && !containsString(sourceOrder, sourceArchive) {
+			sourceOrder = append(sourceOrder, sourceArchive)
+		}
+		if outputFormat == "" && outputPath != "" && !outputCSV {
+			outputFormat = formatOfPath(outputPath)
+		}
+		switch outputFormat {
+		case "csv":
+			outputCSV = true
+		case "tsv", "":
+		default:
+			if _, err := newEncoder(outputFormat, nil); err != nil {
+				return fmt.Errorf("invalid --output-format: %w", err)
+			}
+			if outputCSV {
+				return fmt.Errorf("--csv conflicts with --output-format %s", outputFormat)
+			}
+			if scriptPath != "" {
+				return errors.New("--script requires tsv or csv output")
+			}
+		}
+		mode, err := parseSanitizeMode(sanitizeFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --sanitize: %w", err)
+		}
+		provenanceMode, err := parseProvenanceMode(provenanceFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --provenance: %w", err)
+		}
+		switch {
+		case provenanceMode == provenanceHeader && outputFormat != "" && outputFormat != "tsv" && outputFormat != "csv":
+			return fmt.Errorf("--provenance header requires tsv or csv output (use sidecar with %s)", outputFormat)
+		case provenanceMode == provenanceSidecar && schedule != "":
+			return errors.New("--provenance sidecar conflicts with --schedule (use header)")
+		case provenanceMode == provenanceSidecar && outputPath == "":
+			return errors.New("--provenance sidecar requires --output")
+		}
+		var replayed *provenance
+		if replayPath != "" {
+			if replayed, err = loadProvenance(replayPath); err != nil {
+				return fmt.Errorf("invalid --replay-run: %w", err)
+			}
+			if replayed.Version != version {
+				logger.Warn("replaying a run of another version", "run", replayed.Version, "version", version)
+			}
+		}
+		if maxRequests < 0 || maxErrors < 0 {
+			return errors.New("--max-requests and --max-errors must not be negative")
+		}
+		if concurrency < 1 {
+			return fmt.Errorf("invalid --concurrency %d (must be at least 1)", concurrency)
+		}
+		stdout := io.Writer(os.Stdout)
+		if resultsFD != 0 {
+			if outputPath != "" {
+				return errors.New("--results-fd conflicts with --output")
+			}
+			f, err := openFD(resultsFD)
+			if err != nil {
+				return fmt.Errorf("invalid --results-fd: %w", err)
+			}
+			defer f.Close()
+			stdout = f
+		}
+		var errorsFDLog *errorLog
+		if errorsFD != 0 {
+			if errorsPath != "" {
+				return errors.New("--errors-fd conflicts with --errors")
+			}
+			f, err := openFD(errorsFD)
+			if err != nil {
+				return fmt.Errorf("invalid --errors-fd: %w", err)
+			}
+			defer f.Close()
+			errorsFDLog = newErrorLog(f)
+		}
+
+		if pluginPath != "" {
+			p, err := startPlugin(pluginPath)
+			if err != nil {
+				return fmt.Errorf("invalid --plugin: %w", err)
+			}
+			activePlugin = p
+			defer func() {
+				activePlugin = nil
+				if err := p.close(); err != nil {
+					logger.Warn("plugin exited with an error", "plugin", pluginPath, "err", err)
+				}
+			}()
+		}
+
+		stopProfiling, err := startProfiling(pprofAddr, cpuProfile, memProfile)
+		if err != nil {
+			return fmt.Errorf("profiling: %w", err)
+		}
+		defer stopProfiling()
+
+		run := func(w io.Writer) error {
+			newTorCircuit() // each run, scheduled or not, is a --tor batch
+			in := io.Reader(os.Stdin)
+			if inputPath != "" {
+				f, err := os.Open(inputPath)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			var prov *provenance
+			if provenanceMode != "" || replayed != nil {
+				// The hash must be known before the header is written.
+				data, err := io.ReadAll(in)
+				if err != nil {
+					return err
+				}
+				prov = newProvenance(fs, inputPath, data, prices.Countries)
+				in = bytes.NewReader(data)
+				if replayed != nil && prov.InputSHA256 != replayed.InputSHA256 {
+					logger.Warn("input differs from the replayed run's", "input", prov.Input, "sha256", prov.InputSHA256, "run", replayed.InputSHA256)
+				}
+				if provenanceMode == provenanceHeader {
+					if err := prov.writeHeader(w); err != nil {
+						return err
+					}
+				}
+			}
+			opts := options{
+				Fields:        fields,
+				Header:        showHeader,
+				SkipErrors:    skipErrors,
+				CSV:           outputCSV,
+				Format:        outputFormat,
+				Sanitize:      mode,
+				Normalize:     normalize,
+				Progress:      prog,
+				DebugDir:      common.debugHTTP,
+				FlushInterval: flushInterval,
+				Concurrency:   concurrency,
+				Unordered:     unordered,
+				RetryFailed:   retryFailed,
+				RetryDelay:    retryDelay,
+				MaxRequests:   maxRequests,
+				MaxErrors:     maxErrors,
+				History:       history,
+				Assets:        assets,
+				QRDir:         qrDir,
+				Prices:        prices,
+				Filter:        filter,
+				Transforms:    xforms,
+				URLStyle:      style,
+				Script:        scriptPath,
+				Errors:        errorsFDLog,
+				Context:       ctx,
+			}
+			if normalizePublishers {
+				opts.Publishers = newPublisherAliases()
+			}
+			if showSummary || summaryJSON != "" {
+				opts.Summary = newSummary()
+			}
+			if errorsPath != "" {
+				f, err := os.Create(errorsPath)
+				if err != nil {
+					return fmt.Errorf("invalid --errors: %w", err)
+				}
+				defer f.Close()
+				opts.Errors = newErrorLog(f)
+			}
+			var failedOut *replacement
+			if failedOutPath != "" {
+				// Replaced at the end, so it can be this run's --input too.
+				f, err := createReplacement(failedOutPath)
+				if err != nil {
+					return fmt.Errorf("invalid --failed-out: %w", err)
+				}
+				defer f.discard()
+				failedOut, opts.FailedOut = f, f
+			}
+			var reqStats *requestStats
+			if requestStatsPath != "" {
+				reqStats = newRequestStats()
+				activeRequestStats.Store(reqStats)
+				defer activeRequestStats.Store(nil)
+			}
+			if snapshotDir != "" {
+				opts.Snapshot = &snapshot{}
+			}
+			if dedupePath != "" {
+				opts.CrossPlatform = &crossPlatform{}
+			}
+			// A run stopped by its budget keeps what it wrote, but not its
+			// snapshot: the apps it did not look up are not gone.
+			processErr := process(in, w, opts)
+			var stopped *budgetError
+			if processErr != nil && !errors.As(processErr, &stopped) {
+				return processErr
+			}
+			if failedOut != nil {
+				if err := failedOut.commit(); err != nil {
+					return fmt.Errorf("writing --failed-out: %w", err)
+				}
+			}
+			if opts.Snapshot != nil && stopped == nil {
+				if _, err := saveSnapshot(snapshotDir, time.Now(), opts.Snapshot.entries); err != nil {
+					return fmt.Errorf("snapshot: %w", err)
+				}
+			}
+			if opts.CrossPlatform != nil {
+				if err := opts.CrossPlatform.writeFile(dedupePath); err != nil {
+					return fmt.Errorf("dedupe report: %w", err)
+				}
+			}
+			if reqStats != nil {
+				if err := reqStats.writeJSONFile(requestStatsPath); err != nil {
+					return fmt.Errorf("request stats: %w", err)
+				}
+			}
+			if provenanceMode == provenanceSidecar {
+				if err := prov.writeSidecar(outputPath); err != nil {
+					return fmt.Errorf("provenance: %w", err)
+				}
+			}
+			if opts.Summary == nil {
+				return processErr
+			}
+			opts.Summary.finish()
+			if showSummary {
+				opts.Summary.writeText(os.Stderr)
+			}
+			if summaryJSON != "" {
+				if err := opts.Summary.writeJSONFile(summaryJSON); err != nil {
+					return err
+				}
+			}
+			return processErr
+		}
+
+		if schedule == "" {
+			if outputPath != "" {
+				return writeOutputFile(outputPath, run)
+			}
+			return run(stdout)
+		}
+		if outputPath != "" {
+			return errors.New("--output conflicts with --schedule (use --output-dir)")
+		}
+
+		sched, err := parseCron(schedule)
+		if err != nil {
+			return fmt.Errorf("invalid --schedule: %w", err)
+		}
+		if inputPath == "" {
+			return errors.New("--schedule requires --input (STDIN cannot be re-read between runs)")
+		}
+		ext := formatExtensions["tsv"]
+		if e, ok := formatExtensions[outputFormat]; ok {
+			ext = e
+		}
+		if outputCSV {
+			ext = formatExtensions["csv"]
+		}
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runScheduled(ctx, sched, stdout, outputDir, ext, run)
+	}
+}
+
+// writeOutputFile runs fn with path as its output, replacing the file only
+// once fn succeeds or is stopped by its budget.
+func writeOutputFile(path string, fn func(w io.Writer) error) error {
+	f, err := createReplacement(path)
+	if err != nil {
+		return fmt.Errorf("invalid --output: %w", err)
+	}
+	defer f.discard()
+	err = fn(f)
+	var stopped *budgetError
+	if err != nil && !errors.As(err, &stopped) {
+		return err
+	}
+	if commitErr := f.commit(); commitErr != nil {
+		return commitErr
+	}
+	return err
+}
+
+// replacement is a temporary file that commit renames over path.
+type replacement struct {
+	*os.File
+	path string
+}
+
+func createReplacement(path string) (*replacement, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	return &replacement{File: f, path: path}, nil
+}
+
+func (r *replacement) commit() error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+	// CreateTemp makes the file private; give it the usual permissions.
+	if err := os.Chmod(r.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(r.Name(), r.path)
+}
+
+// discard removes the file unless it was committed.
+func (r *replacement) discard() {
+	r.Close()
+	os.Remove(r.Name())
+}
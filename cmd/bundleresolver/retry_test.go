@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	cases := map[error]bool{
+		statusError(&http.Response{StatusCode: 503, Status: "503 Service Unavailable"}): true,
+		statusError(&http.Response{StatusCode: 429, Status: "429 Too Many Requests"}):   true,
+		statusError(&http.Response{StatusCode: 404, Status: "404 Not Found"}):           false,
+		context.DeadlineExceeded: true,
+		errCircuitOpen:           true,
+		&invalidIDError{msg: "cannot detect platform"}: false,
+		errors.New("app not found or unable to parse"): false,
+	}
+	for err, want := range cases {
+		if got := retryable(err); got != want {
+			t.Errorf("retryable(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
+
+func TestProcessRetryFailed(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	attempts := map[string]int{}
+	resolveFunc = func(ctx context.Context, id string) (record, error) {
+		attempts[id]++ // the test runs one worker
+		switch {
+		case id == "com.flaky.app" && attempts[id] == 1:
+			return record{Bundle: id}, statusError(&http.Response{StatusCode: 503, Status: "503 Service Unavailable"})
+		case id == "com.gone.app":
+			return record{Bundle: id}, statusError(&http.Response{StatusCode: 404, Status: "404 Not Found"})
+		}
+		return record{Bundle: id, Name: "App"}, nil
+	}
+
+	var out strings.Builder
+	input := strings.NewReader("com.flaky.app\ncom.gone.app\ncom.example.app\n")
+	opts := options{Fields: []Field{FieldBundle, FieldName}, SkipErrors: true, Concurrency: 1, RetryFailed: true}
+	if err := process(input, &out, opts); err != nil {
+		t.Fatalf("process returned error: %v", err)
+	}
+	want := "com.example.app\tcom.example.app\tApp\ncom.flaky.app\tcom.flaky.app\tApp\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if attempts["com.flaky.app"] != 2 || attempts["com.gone.app"] != 1 {
+		t.Errorf("attempts = %v, want a retry of the 503 only", attempts)
+	}
+}
+
+func TestProcessRetryCancelled(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	attempts := 0
+	resolveFunc = func(context.Context, string) (record, error) {
+		attempts++
+		cancel()
+		return record{Bundle: "com.flaky.app"}, statusError(&http.Response{StatusCode: 503, Status: "503 Service Unavailable"})
+	}
+
+	var out strings.Builder
+	opts := options{Fields: []Field{FieldBundle}, Concurrency: 1, RetryFailed: true, RetryDelay: time.Hour, Context: ctx}
+	done := make(chan error, 1)
+	go func() { done <- process(strings.NewReader("com.flaky.app\n"), &out, opts) }()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("process waited out the retry delay after the run was cancelled")
+	}
+	if attempts != 1 {
+		t.Errorf("%d attempts, want no retry once cancelled", attempts)
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// review is one customer review, as written by the reviews command.
+type review struct {
+	Bundle   string    `json:"bundle"`
+	Platform string    `json:"platform"`
+	ID       string    `json:"id"`
+	Author   string    `json:"author,omitempty"`
+	Rating   int       `json:"rating"`
+	Title    string    `json:"title,omitempty"`
+	Text     string    `json:"text"`
+	Version  string    `json:"version,omitempty"`
+	Date     time.Time `json:"date"`
+}
+
+// iosReviewPages is how many pages the customer reviews feed serves, 50
+// reviews each.
+const iosReviewPages = 10
+
+// playReviewPageSize is how many reviews are asked of Play per request.
+const playReviewPageSize = 100
+
+// reviewOptions controls which reviews are fetched.
+type reviewOptions struct {
+	Max     int
+	Since   time.Time // zero for no limit
+	Country string
+	Lang    string
+}
+
+func setupReviews(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var inputPath string
+	var since string
+	var opts reviewOptions
+	fs.StringVar(&inputPath, "input", "", "Read ids from this file instead of STDIN")
+	fs.IntVar(&opts.Max, "max-reviews", 100, "Export at most this many of the most recent reviews per app")
+	fs.StringVar(&since, "since", "", "Only export reviews from this date (YYYY-MM-DD) or this long ago (e.g. 720h)")
+	fs.StringVar(&opts.Country, "country", "us", "Two-letter country code of the storefront whose reviews are read")
+	fs.StringVar(&opts.Lang, "lang", "en", "Language of Google Play reviews")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q (ids are read from STDIN or --input)", args)
+		}
+		if opts.Max < 1 {
+			return fmt.Errorf("invalid --max-reviews %d (must be at least 1)", opts.Max)
+		}
+		if len(opts.Country) != 2 {
+			return fmt.Errorf("invalid --country %q (want a two-letter code such as us)", opts.Country)
+		}
+		var err error
+		if opts.Since, err = parseSince(since, time.Now()); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		in := io.Reader(os.Stdin)
+		if inputPath != "" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		return exportReviews(ctx, in, os.Stdout, opts)
+	}
+}
+
+// parseSince reads --since: a date, or a duration back from now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("want a date (YYYY-MM-DD) or a positive duration, got %q", s)
+	}
+	return now.Add(-d), nil
+}
+
+// exportReviews writes the reviews of every input id as JSON lines, newest
+// first per app. An id whose reviews cannot be read is logged and skipped.
+func exportReviews(ctx context.Context, r io.Reader, w io.Writer, opts reviewOptions) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		id := strings.TrimSpace(s.Text())
+		if id == "" {
+			continue
+		}
+		reviews, err := fetchReviews(ctx, id, opts)
+		if err != nil {
+			logger.Warn("reviews failed", "id", id, "err", err)
+		}
+		for _, rv := range reviews {
+			if err := enc.Encode(rv); err != nil {
+				return err
+			}
+		}
+		// Let a pipeline start on an app's reviews while the next one loads.
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+func fetchReviews(ctx context.Context, id string, opts reviewOptions) ([]review, error) {
+	p := platformOf(id)
+	if p == platformUnknown {
+		return nil, errors.New("unknown platform")
+	}
+	if p == platformIOS && !reIOS.MatchString(id) {
+		return nil, errors.New("iOS reviews need the numeric App Store id")
+	}
+	b := storeBreakers[p]
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	fetch := fetchPlayReviews
+	if p == platformIOS {
+		fetch = fetchIOSReviews
+	}
+	reviews, err := fetch(ctx, id, opts)
+	b.done(err)
+	return reviews, err
+}
+
+// keep appends rv to reviews unless it is older than opts.Since, and
+// reports whether more (older) reviews are wanted.
+func (opts reviewOptions) keep(reviews *[]review, rv review) bool {
+	if !opts.Since.IsZero() && rv.Date.Before(opts.Since) {
+		return false
+	}
+	*reviews = append(*reviews, rv)
+	return len(*reviews) < opts.Max
+}
+
+func iosReviewsURL(country, appID string, page int) string {
+	return fmt.Sprintf("https://itunes.apple.com/%s/rss/customerreviews/page=%d/id=%s/sortby=mostrecent/json", strings.ToLower(country), page, appID)
+}
+
+func fetchIOSReviews(ctx context.Context, appID string, opts reviewOptions) ([]review, error) {
+	type label struct {
+		Label string `json:"label"`
+	}
+	type entry struct {
+		ID     label `json:"id"`
+		Author struct {
+			Name label `json:"name"`
+		} `json:"author"`
+		Rating  label `json:"im:rating"`
+		Title   label `json:"title"`
+		Content label `json:"content"`
+		Version label `json:"im:version"`
+		Updated label `json:"updated"`
+	}
+	var reviews []review
+	for page := 1; page <= iosReviewPages; page++ {
+		resp, err := httpGet(ctx, iosReviewsURL(opts.Country, appID, page))
+		if err != nil {
+			return reviews, err
+		}
+		var payload struct {
+			Feed struct {
+				// A page with a single entry holds an object instead of a list.
+				Entry json.RawMessage `json:"entry"`
+			} `json:"feed"`
+		}
+		if resp.StatusCode != 200 {
+			err = fmt.Errorf("status %s", resp.Status)
+		} else {
+			err = decodeJSONResponse(resp, &payload)
+		}
+		drainAndClose(resp.Body)
+		if err != nil {
+			return reviews, err
+		}
+		raw := payload.Feed.Entry
+		if len(raw) == 0 {
+			return reviews, nil // past the last page
+		}
+		if raw[0] == '{' {
+			raw = append(append([]byte("["), raw...), ']')
+		}
+		var entries []entry
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return reviews, err
+		}
+		for _, e := range entries {
+			date, _ := time.Parse(time.RFC3339, e.Updated.Label)
+			rating, _ := strconv.Atoi(e.Rating.Label)
+			rv := review{
+				Bundle: appID, Platform: platformIOS, ID: e.ID.Label,
+				Author: e.Author.Name.Label, Rating: rating,
+				Title: e.Title.Label, Text: e.Content.Label,
+				Version: e.Version.Label, Date: date,
+			}
+			if !opts.keep(&reviews, rv) {
+				return reviews, nil
+			}
+		}
+	}
+	return reviews, nil
+}
+
+// playReviewsURL is the Play web client's RPC endpoint; the UsvDTd call
+// returns a page of reviews.
+const playReviewsURL = "https://play.google.com/_/PlayStoreUi/data/batchexecute"
+
+func fetchPlayReviews(ctx context.Context, pkg string, opts reviewOptions) ([]review, error) {
+	var reviews []review
+	token := ""
+	for {
+		page, next, err := fetchPlayReviewPage(ctx, pkg, opts, token)
+		if err != nil {
+			return reviews, err
+		}
+		for _, rv := range page {
+			if !opts.keep(&reviews, rv) {
+				return reviews, nil
+			}
+		}
+		if next == "" || len(page) == 0 {
+			return reviews, nil
+		}
+		token = next
+	}
+}
+
+// fetchPlayReviewPage requests the page of newest reviews after token
+// ("" for the first page) and returns it with the next page's token.
+func fetchPlayReviewPage(ctx context.Context, pkg string, opts reviewOptions, token string) ([]review, string, error) {
+	// Sort order 2 is "newest"; the pagination token goes after the page size.
+	paging := fmt.Sprintf("[%d]", playReviewPageSize)
+	if token != "" {
+		t, _ := json.Marshal(token)
+		paging = fmt.Sprintf("[%d,null,%s]", playReviewPageSize, t)
+	}
+	p, _ := json.Marshal(pkg)
+	args := fmt.Sprintf("[null,null,[2,2,%s,null,[]],[%s,7]]", paging, p)
+	freq, _ := json.Marshal([]any{[]any{[]any{"UsvDTd", args, nil, "generic"}}})
+
+	q := url.Values{"rpcids": {"UsvDTd"}, "hl": {opts.Lang}, "gl": {strings.ToUpper(opts.Country)}}
+	body := url.Values{"f.req": {string(freq)}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, playReviewsURL+"?"+q.Encode(), strings.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("status %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return parsePlayReviews(pkg, data)
+}
+
+// parsePlayReviews decodes a batchexecute response: an anti-XSSI prefix,
+// then a JSON envelope whose UsvDTd entry holds the reviews as a JSON
+// string. Fields are found by position, as the web client does.
+func parsePlayReviews(pkg string, data []byte) ([]review, string, error) {
+	start := bytes.IndexByte(data, '[')
+	if start < 0 {
+		return nil, "", errors.New("unexpected reviews response")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data[start:]))
+	var envelope [][]any
+	if err := dec.Decode(&envelope); err != nil {
+		return nil, "", fmt.Errorf("unexpected reviews response: %w", err)
+	}
+	var inner string
+	for _, e := range envelope {
+		if len(e) > 2 && e[0] == "wrb.fr" && e[1] == "UsvDTd" {
+			inner, _ = e[2].(string)
+		}
+	}
+	if inner == "" {
+		return nil, "", nil // no reviews
+	}
+	var payload []any
+	if err := json.Unmarshal([]byte(inner), &payload); err != nil {
+		return nil, "", fmt.Errorf("unexpected reviews payload: %w", err)
+	}
+	var reviews []review
+	list, _ := at(payload, 0).([]any)
+	for _, item := range list {
+		seconds, _ := at(item, 5, 0).(float64)
+		rating, _ := at(item, 2).(float64)
+		rv := review{Bundle: pkg, Platform: platformAndroid, Rating: int(rating), Date: time.Unix(int64(seconds), 0).UTC()}
+		rv.ID, _ = at(item, 0).(string)
+		rv.Author, _ = at(item, 1, 0).(string)
+		rv.Text, _ = at(item, 4).(string)
+		rv.Version, _ = at(item, 10).(string)
+		reviews = append(reviews, rv)
+	}
+	next, _ := at(payload, 1, 1).(string)
+	return reviews, next, nil
+}
+
+// at walks nested JSON arrays by index, returning nil when a step is
+// missing or not an array.
+func at(v any, path ...int) any {
+	for _, i := range path {
+		list, ok := v.([]any)
+		if !ok || i >= len(list) {
+			return nil
+		}
+		v = list[i]
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleIOSReviews = `{"feed":{"entry":[
+{"id":{"label":"9001"},"author":{"name":{"label":"kate"}},"im:rating":{"label":"5"},"title":{"label":"Great"},"content":{"label":"Love it."},"im:version":{"label":"2.1"},"updated":{"label":"2024-05-02T10:00:00-07:00"}},
+{"id":{"label":"9000"},"author":{"name":{"label":"sam"}},"im:rating":{"label":"2"},"title":{"label":"Meh"},"content":{"label":"Crashes."},"im:version":{"label":"2.0"},"updated":{"label":"2024-04-01T10:00:00-07:00"}}
+]}}`
+
+func TestFetchIOSReviews(t *testing.T) {
+	originalClient := httpClient
+	defer func() {
+		httpClient = originalClient
+	}()
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://itunes.apple.com/us/rss/customerreviews/page=1/id=123/sortby=mostrecent/json": sampleIOSReviews,
+		"https://itunes.apple.com/us/rss/customerreviews/page=2/id=123/sortby=mostrecent/json": `{"feed":{}}`,
+	}}
+
+	opts := reviewOptions{Max: 10, Country: "US"}
+	got, err := fetchIOSReviews(context.Background(), "123", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "9001" || got[0].Rating != 5 || got[0].Version != "2.1" || got[1].Text != "Crashes." {
+		t.Errorf("reviews = %+v", got)
+	}
+
+	opts.Since = time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC)
+	if got, _ := fetchIOSReviews(context.Background(), "123", opts); len(got) != 1 {
+		t.Errorf("--since kept %d reviews, want 1", len(got))
+	}
+	opts.Since, opts.Max = time.Time{}, 1
+	if got, _ := fetchIOSReviews(context.Background(), "123", opts); len(got) != 1 {
+		t.Errorf("--max-reviews 1 kept %d reviews", len(got))
+	}
+}
+
+func TestParsePlayReviews(t *testing.T) {
+	inner, _ := json.Marshal([]any{
+		[]any{
+			[]any{"gp:AOqp", []any{"Alex", []any{nil}}, 4, nil, "Works well.", []any{1714644000, 0}, nil, nil, nil, nil, "3.2.1"},
+		},
+		[]any{nil, "NEXT"},
+	})
+	envelope, _ := json.Marshal([]any{[]any{"wrb.fr", "UsvDTd", string(inner), nil, nil, nil, "generic"}})
+	data := append([]byte(")]}'\n\n"), envelope...)
+
+	got, next, err := parsePlayReviews("com.example.app", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []review{{
+		Bundle: "com.example.app", Platform: platformAndroid, ID: "gp:AOqp", Author: "Alex", Rating: 4,
+		Text: "Works well.", Version: "3.2.1", Date: time.Unix(1714644000, 0).UTC(),
+	}}
+	if !reflect.DeepEqual(got, want) || next != "NEXT" {
+		t.Errorf("reviews = %+v, next %q; want %+v, NEXT", got, next, want)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+	if got, _ := parseSince("720h", now); !got.Equal(now.Add(-720 * time.Hour)) {
+		t.Errorf("720h = %v", got)
+	}
+	if got, _ := parseSince("2024-05-01", now); got.Format("2006-01-02") != "2024-05-01" {
+		t.Errorf("date = %v", got)
+	}
+	if _, err := parseSince("yesterday", now); err == nil || !strings.Contains(err.Error(), "yesterday") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// kanaRomaji is the Hepburn romanization of the hiragana; katakana are
+// looked up as their hiragana.
+var kanaRomaji = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'ゔ': "vu",
+}
+
+// Hangul syllables romanized letter by letter (Revised Romanization,
+// without the sound changes between syllables).
+var (
+	hangulInitials = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	hangulMedials  = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	hangulFinals   = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+)
+
+// letterLatin romanizes the Cyrillic and Greek letters, lowercase; Greek
+// letters are looked up without their accents.
+var letterLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g",
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+}
+
+// romanize transliterates the kana, Hangul, Cyrillic and Greek of name to
+// Latin letters, capitalizing each Japanese or Korean word; Latin text is
+// unchanged but for its full-width forms. Chinese characters, Japanese
+// kanji included, would take a dictionary: a name with them, or with
+// letters of another script, has no romanization rather than a half one.
+func romanize(name string) string {
+	var b strings.Builder
+	runes := []rune(norm.NFC.String(width.Fold.String(name)))
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case isKana(r):
+			j := i
+			for j < len(runes) && isKana(runes[j]) {
+				j++
+			}
+			b.WriteString(capitalize(kanaLatin(runes[i:j])))
+			i = j
+			continue
+		case r >= 0xAC00 && r <= 0xD7A3:
+			j := i
+			var word strings.Builder
+			for ; j < len(runes) && runes[j] >= 0xAC00 && runes[j] <= 0xD7A3; j++ {
+				s := int(runes[j] - 0xAC00)
+				word.WriteString(hangulInitials[s/588] + hangulMedials[s%588/28] + hangulFinals[s%28])
+			}
+			b.WriteString(capitalize(word.String()))
+			i = j
+			continue
+		}
+		lower := unicode.ToLower(r)
+		if unicode.Is(unicode.Greek, lower) {
+			lower = []rune(norm.NFD.String(string(lower)))[0]
+		}
+		latin, ok := letterLatin[lower]
+		switch {
+		case !ok:
+			b.WriteRune(r)
+		case unicode.IsUpper(r):
+			b.WriteString(capitalize(latin))
+		default:
+			b.WriteString(latin)
+		}
+		i++
+	}
+	for _, r := range b.String() {
+		if unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r) {
+			return ""
+		}
+	}
+	return b.String()
+}
+
+func isKana(r rune) bool {
+	return unicode.In(r, unicode.Hiragana, unicode.Katakana) || r == 'ー'
+}
+
+// kanaLatin romanizes a run of kana: small ya, yu and yo contract the
+// syllable before (きゃ is kya, しゃ sha), other small vowels replace its
+// vowel (ファ is fa, ウォ wo), a small tsu doubles the next consonant and a long
+// vowel mark repeats the last vowel.
+func kanaLatin(run []rune) string {
+	var b strings.Builder
+	double := false
+	var prev rune
+	for _, r := range run {
+		if r >= 'ァ' && r <= 'ヶ' {
+			r -= 'ァ' - 'ぁ'
+		}
+		s := b.String()
+		last := prev
+		prev = r
+		switch r {
+		case 'っ':
+			double = true
+			continue
+		case 'ー':
+			if i := strings.LastIndexAny(s, "aiueo"); i >= 0 && i == len(s)-1 {
+				b.WriteByte(s[i])
+			}
+			continue
+		case 'ゃ', 'ゅ', 'ょ':
+			vowel := map[rune]string{'ゃ': "a", 'ゅ': "u", 'ょ': "o"}[r]
+			if base, ok := strings.CutSuffix(s, "i"); ok && base != "" {
+				if !strings.HasSuffix(base, "sh") && !strings.HasSuffix(base, "ch") && !strings.HasSuffix(base, "j") {
+					base += "y"
+				}
+				b.Reset()
+				b.WriteString(base + vowel)
+			} else {
+				b.WriteString("y" + vowel)
+			}
+			continue
+		case 'ぁ', 'ぃ', 'ぅ', 'ぇ', 'ぉ', 'ゎ':
+			vowel := kanaRomaji[r+1]
+			if last == 'う' && r != 'ぅ' {
+				vowel = "w" + vowel // ウォ is wo
+			}
+			if s != "" && strings.ContainsRune("aiueo", rune(s[len(s)-1])) {
+				b.Reset()
+				b.WriteString(s[:len(s)-1] + vowel)
+			} else {
+				b.WriteString(vowel)
+			}
+			continue
+		}
+		latin, ok := kanaRomaji[r]
+		if !ok {
+			b.WriteRune(r)
+			continue
+		}
+		if double {
+			if strings.HasPrefix(latin, "ch") {
+				b.WriteByte('t')
+			} else if latin[0] != 'n' && !strings.ContainsRune("aiueo", rune(latin[0])) {
+				b.WriteByte(latin[0])
+			}
+			double = false
+		}
+		b.WriteString(latin)
+	}
+	return b.String()
+}
+
+func capitalize(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	if n == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[n:]
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestRomanize(t *testing.T) {
+	for name, want := range map[string]string{
+		"Candy Crush Saga": "Candy Crush Saga",
+		"パズル&ドラゴンズ":        "Pazuru&Doragonzu",
+		"モンスターストライク":       "Monsutaasutoraiku",
+		"ポケモンGO":           "PokemonGO",
+		"きゃりーぱみゅぱみゅ":       "Kyariipamyupamyu",
+		"しょうぎ ウォーズ":        "Shougi Woozu",
+		"ファイナルファンタジー":      "Fainarufantajii",
+		"マッチ3 ハッピー":        "Matchi3 Happii",
+		"ｶﾞﾁｬ":             "Gacha",
+		"쿠키런: 킹덤":          "Kukireon: Kingdeom",
+		"ВКонтакте":        "VKontakte",
+		"Дія":              "Diya",
+		"Καιρός":           "Kairos",
+		"原神":               "",
+		"王者荣耀":             "",
+		"信長の野望":            "",
+		"ملك":              "",
+		"2048":             "2048",
+	} {
+		if got := romanize(name); got != want {
+			t.Errorf("romanize(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2024, 5, 10, 12, 30, 0, 0, time.UTC) // Friday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 3 * * *", time.Date(2024, 5, 11, 3, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 5, 10, 12, 45, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2024, 5, 13, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 5, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			s, err := parseCron(tc.expr)
+			if err != nil {
+				t.Fatalf("parseCron(%q): %v", tc.expr, err)
+			}
+			if got := s.next(base); !got.Equal(tc.want) {
+				t.Fatalf("next = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCronNextHalfHourZone(t *testing.T) {
+	india := time.FixedZone("IST", 5*3600+30*60)
+	s, err := parseCron("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 5, 10, 1, 10, 0, 0, india)
+	if got, want := s.next(base), time.Date(2024, 5, 10, 3, 0, 0, 0, india); !got.Equal(want) {
+		t.Errorf("next = %s, want %s", got, want)
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * 13 *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want error", expr)
+		}
+	}
+}
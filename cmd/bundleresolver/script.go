@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// rowScript is a --script post-processing hook that rewrites each output
+// row: a Starlark file (see startStarlarkScript), or an external
+// executable, written in any language, that speaks JSON lines like a
+// plugin (see jsonProcess):
+//
+//  1. It first receives {"columns": [...]}, the selected fields, and
+//     answers with the output columns, which may rename, drop, reorder or
+//     add columns.
+//  2. For every row it receives {"row": {field: value}, "record": {...}}
+//     and answers {"row": {column: value}}, missing columns being empty,
+//     or {"drop": true} to leave the row out.
+type rowScript struct {
+	columns []string
+	// rewrite answers the request of a row.
+	rewrite func(scriptRequest) (scriptResponse, error)
+	close   func() error
+}
+
+type scriptColumns struct {
+	Columns []string `json:"columns"`
+}
+
+type scriptRequest struct {
+	Row    map[string]string `json:"row"`
+	Record record            `json:"record"`
+}
+
+type scriptResponse struct {
+	Row  map[string]string `json:"row"`
+	Drop bool              `json:"drop,omitempty"`
+}
+
+// startScript starts the script at path, a Starlark file if it ends in
+// .star and an executable run with args otherwise, and agrees on the
+// output columns for rows of fields.
+func startScript(fields []Field, path string, args ...string) (*rowScript, error) {
+	if strings.HasSuffix(path, ".star") {
+		return startStarlarkScript(fields, path)
+	}
+	p, err := startJSONProcess(path, args...)
+	if err != nil {
+		return nil, err
+	}
+	req := scriptColumns{Columns: make([]string, len(fields))}
+	for i, f := range fields {
+		req.Columns[i] = string(f)
+	}
+	var resp scriptColumns
+	if err := p.call(req, &resp); err != nil {
+		p.close()
+		return nil, err
+	}
+	if len(resp.Columns) == 0 {
+		p.close()
+		return nil, errors.New("the script answered with no columns")
+	}
+	rewrite := func(req scriptRequest) (scriptResponse, error) {
+		var resp scriptResponse
+		err := p.call(req, &resp)
+		return resp, err
+	}
+	return &rowScript{columns: resp.Columns, rewrite: rewrite, close: p.close}, nil
+}
+
+// apply rewrites the row cols of rec, whose values are those of fields,
+// into the script's columns. keep is false if the script drops the row.
+func (s *rowScript) apply(fields []Field, rec record, cols []string) (out []string, keep bool, err error) {
+	req := scriptRequest{Row: make(map[string]string, len(fields)), Record: rec}
+	for i, f := range fields {
+		req.Row[string(f)] = cols[i]
+	}
+	resp, err := s.rewrite(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("script %w", err)
+	}
+	if resp.Drop {
+		return nil, false, nil
+	}
+	out = make([]string, len(s.columns))
+	for i, c := range s.columns {
+		out[i] = resp.Row[c]
+	}
+	return out, true, nil
+}
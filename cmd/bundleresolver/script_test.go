@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestScriptHelper is the --script executable of the tests below: run as a
+// subprocess, it renames name to title, adds an upper-cased publisher and
+// drops apps by "Spam Inc".
+func TestScriptHelper(t *testing.T) {
+	if os.Getenv("GO_WANT_SCRIPT_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	s := bufio.NewScanner(os.Stdin)
+	enc := json.NewEncoder(os.Stdout)
+	s.Scan()
+	enc.Encode(scriptColumns{Columns: []string{"bundle", "title", "publisher_upper"}})
+	for s.Scan() {
+		var req scriptRequest
+		if err := json.Unmarshal(s.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+		if req.Record.Publisher == "Spam Inc" {
+			enc.Encode(scriptResponse{Drop: true})
+			continue
+		}
+		enc.Encode(scriptResponse{Row: map[string]string{
+			"bundle":          req.Row["bundle"],
+			"title":           req.Row["name"],
+			"publisher_upper": strings.ToUpper(req.Record.Publisher),
+		}})
+	}
+	os.Exit(0)
+}
+
+func TestProcessScript(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() { resolveFunc = originalResolve }()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		publisher := "Example Ltd"
+		if id == "2" {
+			publisher = "Spam Inc"
+		}
+		return record{Bundle: id, Name: "App " + id, Publisher: publisher}, nil
+	}
+	t.Setenv("GO_WANT_SCRIPT_HELPER", "1")
+	// process starts the script without arguments, so wrap the test binary.
+	script := writeHelperWrapper(t, "^TestScriptHelper$")
+
+	var out strings.Builder
+	opts := options{Fields: []Field{FieldBundle, FieldName}, Header: true, Script: script}
+	if err := process(strings.NewReader("1\n2\n\n3\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "bundle\ttitle\tpublisher_upper\n1\tApp 1\tEXAMPLE LTD\n\t\t\n3\tApp 3\tEXAMPLE LTD\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestProcessStarlarkScript(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() { resolveFunc = originalResolve }()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		publisher := "Example Ltd"
+		if id == "2" {
+			publisher = "Spam Inc"
+		}
+		return record{Bundle: id, Name: "App " + id, Publisher: publisher}, nil
+	}
+	script := filepath.Join(t.TempDir(), "transform.star")
+	src := `
+def columns(fields):
+    return ["bundle", "title", "publisher_upper", "spam"]
+
+def transform(row, record):
+    if record["publisher"] == "Spam Inc":
+        return None
+    return {
+        "bundle": row["bundle"],
+        "title": row["name"],
+        "publisher_upper": record["publisher"].upper(),
+        "spam": False,
+    }
+`
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	opts := options{Fields: []Field{FieldBundle, FieldName}, Header: true, Script: script}
+	if err := process(strings.NewReader("1\n2\n\n3\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "bundle\ttitle\tpublisher_upper\tspam\n1\tApp 1\tEXAMPLE LTD\tfalse\n\t\t\t\n3\tApp 3\tEXAMPLE LTD\tfalse\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestStarlarkScriptErrors(t *testing.T) {
+	fields := []Field{FieldBundle, FieldName}
+	for name, src := range map[string]string{
+		"no transform": "x = 1\n",
+		"syntax":       "def transform(row, record)\n",
+		"no network":   "load('net.star', 'get')\ndef transform(row, record):\n    return row\n",
+	} {
+		path := filepath.Join(t.TempDir(), "transform.star")
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := startScript(fields, path); err == nil {
+			t.Errorf("%s: started", name)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "transform.star")
+	src := "def transform(row, record):\n    n = 0\n    for i in range(1000000000):\n        n += i\n    return row\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := startScript(fields, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.apply(fields, record{Bundle: "1"}, []string{"1", ""}); err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Errorf("runaway transform err = %v, want too many steps", err)
+	}
+}
+
+// writeHelperWrapper writes a shell script running the test binary's
+// helper test run, for tests that start an executable without arguments.
+func writeHelperWrapper(t *testing.T, run string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	path := t.TempDir() + "/helper.sh"
+	body := "#!/bin/sh\nexec '" + os.Args[0] + "' -test.run='" + run + "'\n"
+	if err := os.WriteFile(path, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// androidAPILevels maps the Android versions Play shows under "Requires
+// Android" to their API levels.
+var androidAPILevels = map[string]int{
+	"1.0": 1, "1.1": 2, "1.5": 3, "1.6": 4, "2.0": 5, "2.0.1": 6, "2.1": 7,
+	"2.2": 8, "2.3": 9, "2.3.3": 10, "3.0": 11, "3.1": 12, "3.2": 13,
+	"4.0": 14, "4.0.3": 15, "4.1": 16, "4.2": 17, "4.3": 18, "4.4": 19,
+	"4.4W": 20, "5.0": 21, "5.1": 22, "6.0": 23, "7.0": 24, "7.1": 25,
+	"8.0": 26, "8.1": 27, "9": 28, "10": 29, "11": 30, "12": 31, "12L": 32,
+	"13": 33, "14": 34, "15": 35, "16": 36,
+}
+
+// reRequiresAndroid matches the minimum version in a Play page's
+// "Requires Android 8.0 and up"; "Varies with device" does not match.
+var reRequiresAndroid = regexp.MustCompile(`Requires Android\s*(\d+(?:\.\d+)*[LW]?) and up`)
+
+// playMinSDK returns the API level of the Android version a Play details
+// page requires, or 0 if it does not say.
+func playMinSDK(doc *goquery.Document) int {
+	m := reRequiresAndroid.FindStringSubmatch(strings.Join(strings.Fields(doc.Find("body").Text()), " "))
+	if m == nil {
+		return 0
+	}
+	if level, ok := androidAPILevels[m[1]]; ok {
+		return level
+	}
+	// Other point releases ("9.0", "2.3.4") share the level of the latest
+	// listed version before them.
+	v := versionParts(m[1])
+	if v == nil {
+		return 0
+	}
+	best, bestLevel := []int(nil), 0
+	for name, level := range androidAPILevels {
+		p := versionParts(name)
+		if p != nil && p[0] == v[0] && compareVersions(p, v) <= 0 && compareVersions(p, best) > 0 {
+			best, bestLevel = p, level
+		}
+	}
+	return bestLevel
+}
+
+// versionParts splits a dotted version into its numbers, or returns nil if
+// it has a suffix such as 12L.
+func versionParts(s string) []int {
+	var parts []int
+	for _, f := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// compareVersions orders versions by their numbers; missing ones count as 0.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// sdkFields are the fields filled from the SDK levels.
+var sdkFields = []Field{FieldMinSDK, FieldTargetSDK}
+
+// sdkSource is the --sdk-source URL template: {id} is replaced with the
+// package name, and the response is a JSON object with min_sdk and
+// target_sdk, as APK metadata services report them from the manifest.
+var sdkSource string
+
+// apkSDK is the part of an --sdk-source response bundleresolver reads.
+type apkSDK struct {
+	MinSDK    int `json:"min_sdk"`
+	TargetSDK int `json:"target_sdk"`
+}
+
+// parseSDKSource validates a --sdk-source template.
+func parseSDKSource(s string) (string, error) {
+	return parseIDTemplate(s)
+}
+
+// parseIDTemplate validates the template of an http(s) URL with an {id}
+// placeholder.
+func parseIDTemplate(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	if !strings.Contains(s, "{id}") {
+		return "", fmt.Errorf("%q has no {id} placeholder", s)
+	}
+	if u, err := url.Parse(strings.ReplaceAll(s, "{id}", "x")); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("%q is not an http(s) URL", s)
+	}
+	return s, nil
+}
+
+// fetchAPKSDK asks the --sdk-source for the SDK levels of pkg.
+func fetchAPKSDK(ctx context.Context, pkg string) (apkSDK, error) {
+	u := strings.ReplaceAll(sdkSource, "{id}", url.PathEscape(pkg))
+	resp, err := httpGet(ctx, u)
+	if err != nil {
+		return apkSDK{}, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return apkSDK{}, statusError(resp)
+	}
+	var sdk apkSDK
+	if err := json.NewDecoder(resp.Body).Decode(&sdk); err != nil {
+		return apkSDK{}, fmt.Errorf("%s: invalid JSON: %w", u, err)
+	}
+	return sdk, nil
+}
+
+// addSDKFields fills target_sdk, and min_sdk when the Play page did not
+// tell, from the --sdk-source if one is set and an SDK field is selected.
+func addSDKFields(ctx context.Context, rec *record) {
+	if sdkSource == "" || !anySelected(sdkFields) {
+		return
+	}
+	sdk, err := fetchAPKSDK(ctx, rec.Bundle)
+	if err != nil {
+		logger.Debug("SDK source request failed", "id", rec.Bundle, "err", err)
+		return
+	}
+	if rec.MinSDK == 0 {
+		rec.MinSDK = sdk.MinSDK
+	}
+	rec.TargetSDK = sdk.TargetSDK
+}
+
+// sdkValue renders an API level, "" when unknown.
+func sdkValue(level int) string {
+	if level == 0 {
+		return ""
+	}
+	return strconv.Itoa(level)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestPlayMinSDK(t *testing.T) {
+	cases := map[string]int{
+		`<div><div>Requires Android</div><div>8.0 and up</div></div>`: 26,
+		`<div>Requires Android</div><div>9.0 and up</div>`:            28,
+		`<div>Requires Android 2.3.4 and up</div>`:                    10,
+		`<div>Requires Android 12L and up</div>`:                      32,
+		`<div>Requires Android</div><div>Varies with device</div>`:    0,
+		`<div>Updated on</div><div>Mar 1, 2024</div>`:                 0,
+	}
+	for html, want := range cases {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := playMinSDK(doc); got != want {
+			t.Errorf("playMinSDK(%s) = %d, want %d", html, got, want)
+		}
+	}
+}
+
+func TestParseSDKSource(t *testing.T) {
+	if _, err := parseSDKSource("https://apk.example/{id}.json"); err != nil {
+		t.Errorf("valid template rejected: %v", err)
+	}
+	for _, bad := range []string{"https://apk.example/meta", "ftp://apk.example/{id}"} {
+		if _, err := parseSDKSource(bad); err == nil {
+			t.Errorf("parseSDKSource(%q) accepted", bad)
+		}
+	}
+}
+
+func TestAddSDKFields(t *testing.T) {
+	originalClient, originalSelected, originalSource := httpClient, selectedFields, sdkSource
+	defer func() {
+		httpClient, selectedFields, sdkSource = originalClient, originalSelected, originalSource
+	}()
+	selectFields([]Field{FieldMinSDK, FieldTargetSDK})
+	sdkSource = "https://apk.example/{id}.json"
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://apk.example/com.example.app.json": `{"min_sdk":21,"target_sdk":34,"compile_sdk":34}`,
+	}}
+
+	rec := record{Bundle: "com.example.app", MinSDK: 24}
+	addSDKFields(context.Background(), &rec)
+	if got := rec.value(FieldMinSDK) + "," + rec.value(FieldTargetSDK); got != "24,34" {
+		t.Errorf("min_sdk,target_sdk = %q, want the Play page's 24 and the source's 34", got)
+	}
+
+	missing := record{Bundle: "com.example.missing"}
+	addSDKFields(context.Background(), &missing)
+	if missing.MinSDK != 0 || missing.TargetSDK != 0 {
+		t.Errorf("unknown package got SDK levels %d/%d", missing.MinSDK, missing.TargetSDK)
+	}
+}
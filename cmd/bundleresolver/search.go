@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func setupSearch(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var fieldsCSV string
+	var showHeader bool
+	var outputCSV bool
+	var platform string
+	var limit int
+	var sanitizeFlag string
+	var normalize bool
+
+	fs.StringVar(&fieldsCSV, "fields", defaultFields, fieldsUsage())
+	fs.StringVar(&fieldsCSV, "f", defaultFields, "Alias of --fields")
+	fs.BoolVar(&showHeader, "header", true, "Print header row as first line (use --header=false to disable)")
+	fs.BoolVar(&outputCSV, "csv", false, "Emit output as CSV (RFC4180 with quoting) instead of TSV")
+	fs.StringVar(&sanitizeFlag, "sanitize", "strip", "How tabs and newlines inside values are written: strip (replace with a space), quote (keep, RFC4180-quoted) or escape (as \\t, \\n)")
+	fs.BoolVar(&normalize, "normalize-unicode", false, "NFC-normalize values and strip invisible filler characters (bidi and zero-width controls are always stripped)")
+	fs.StringVar(&platform, "platform", "all", "Store to search: ios, android or all")
+	fs.IntVar(&limit, "limit", 10, "Maximum number of results per store")
+
+	return func(ctx context.Context, args []string) error {
+		query := strings.TrimSpace(strings.Join(args, " "))
+		if query == "" {
+			return errors.New("search requires a query")
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		fields, err := parseFields(fieldsCSV)
+		if err != nil {
+			return fmt.Errorf("invalid --fields: %w", err)
+		}
+		selectFields(fields)
+		mode, err := parseSanitizeMode(sanitizeFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --sanitize: %w", err)
+		}
+		var stores []string
+		switch platform {
+		case "all":
+			stores = []string{platformIOS, platformAndroid}
+		case platformIOS, platformAndroid:
+			stores = []string{platform}
+		default:
+			return fmt.Errorf("invalid --platform %q (want ios, android or all)", platform)
+		}
+
+		out := newRowWriter(os.Stdout, fields, outputCSV, mode)
+		out.normalize = normalize
+		if showHeader {
+			if err := out.writeHeader(); err != nil {
+				return err
+			}
+		}
+		for _, store := range stores {
+			recs, err := searchStore(ctx, store, query, limit)
+			if err != nil {
+				// One store failing shouldn't hide the other's results.
+				logger.Warn("search failed", "platform", store, "query", query, "err", err)
+			}
+			for _, rec := range recs {
+				rec.Input = query
+				if err := out.writeRecord(rec); err != nil {
+					return err
+				}
+			}
+		}
+		return out.flush()
+	}
+}
+
+func searchStore(ctx context.Context, store, query string, limit int) ([]record, error) {
+	b := storeBreakers[store]
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	if store == platformIOS {
+		recs, err := searchIOS(ctx, query, limit)
+		b.done(err)
+		return recs, err
+	}
+	pkgs, err := searchAndroid(ctx, query)
+	b.done(err)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) > limit {
+		pkgs = pkgs[:limit]
+	}
+	recs := make([]record, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		// Search result markup carries no reliable publisher; fetch each details page.
+		rec, err := fetchAndroidDirect(ctx, pkg)
+		if err != nil {
+			logger.Warn("resolve failed", "id", pkg, "err", err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// searchIOS queries the iTunes Search API for software matching query.
+func searchIOS(ctx context.Context, query string, limit int) ([]record, error) {
+	searchURL := "https://itunes.apple.com/search?entity=software&term=" + url.QueryEscape(query) +
+		"&limit=" + strconv.Itoa(limit)
+	resp, err := httpGet(ctx, searchURL)
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	var payload struct {
+		Results []struct {
+			TrackID    int64  `json:"trackId"`
+			BundleID   string `json:"bundleId"`
+			TrackName  string `json:"trackName"`
+			SellerName string `json:"sellerName"`
+		} `json:"results"`
+	}
+	if err := decodeJSONResponse(resp, &payload); err != nil {
+		return nil, err
+	}
+	recs := make([]record, 0, len(payload.Results))
+	for _, res := range payload.Results {
+		id := strconv.FormatInt(res.TrackID, 10)
+		rec := record{
+			Bundle:    id,
+			BundleID:  res.BundleID,
+			Name:      res.TrackName,
+			Publisher: res.SellerName,
+			URL:       buildAppStoreURL(id),
+		}
+		addAppStorePageFields(ctx, &rec)
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
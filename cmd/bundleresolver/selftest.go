@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// canary is a well-known app whose fields selftest checks. want maps each
+// field to a substring its value must contain; "" only requires a value.
+type canary struct {
+	id   string
+	want map[Field]string
+}
+
+// canaries exercise every extraction path of both stores with apps that
+// are unlikely to disappear or change their listing much.
+var canaries = []canary{
+	{"544007664", map[Field]string{FieldName: "YouTube", FieldPublisher: "Google", FieldCategory: "", FieldPrice: "Free", FieldVersion: "", FieldWebsite: "", FieldPrivacyLinked: "", FieldStoreURL: "id544007664"}},
+	{"310633997", map[Field]string{FieldName: "WhatsApp", FieldPublisher: "WhatsApp", FieldCategory: "", FieldPrice: "Free", FieldVersion: ""}},
+	{"com.google.android.youtube", map[Field]string{FieldName: "YouTube", FieldPublisher: "Google", FieldCategory: "", FieldPrice: "Free", FieldDataCollected: ""}},
+	{"com.whatsapp", map[Field]string{FieldName: "WhatsApp", FieldPublisher: "WhatsApp", FieldCategory: "Communication", FieldWebsite: "whatsapp", FieldDeveloperEmail: "@"}},
+}
+
+func setupSelftest(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+	var header bool
+	fs.BoolVar(&header, "header", true, "Print header row as first line (use --header=false to disable)")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q", args)
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		broken, err := selftest(ctx, canaries, os.Stdout, header)
+		if err != nil {
+			return err
+		}
+		if broken > 0 {
+			return fmt.Errorf("%d extraction paths broken", broken)
+		}
+		return nil
+	}
+}
+
+// selftest resolves the canaries and writes one TSV row per checked field:
+// status is ok, broken (the value is missing or unexpected, as when store
+// markup changed) or failed (the lookup itself failed). It returns how
+// many rows were not ok.
+func selftest(ctx context.Context, canaries []canary, w io.Writer, header bool) (broken int, err error) {
+	var fields []Field
+	for _, c := range canaries {
+		for f := range c.want {
+			if !hasField(fields, f) {
+				fields = append(fields, f)
+			}
+		}
+	}
+	selectFields(fields)
+
+	out := newRowWriter(w, nil, false, sanitizeStrip)
+	if header {
+		if err := out.writeValues("platform", "id", "field", "status", "want", "got"); err != nil {
+			return 0, err
+		}
+	}
+	for _, c := range canaries {
+		rec, lookupErr := resolveOne(ctx, c.id, "")
+		for _, f := range allowedFields { // a stable order
+			want, ok := c.want[f]
+			if !ok {
+				continue
+			}
+			got := rec.value(f)
+			status := "ok"
+			switch {
+			case lookupErr != nil:
+				status, got = "failed", lookupErr.Error()
+			case got == "" || !strings.Contains(got, want):
+				status = "broken"
+			}
+			if status != "ok" {
+				broken++
+			}
+			if want == "" {
+				want = "(any)"
+			}
+			if err := out.writeValues(platformOf(c.id), c.id, string(f), status, want, got); err != nil {
+				return broken, err
+			}
+		}
+	}
+	return broken, out.flush()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSelftest(t *testing.T) {
+	originalResolve, originalSelected := resolveFunc, selectedFields
+	defer func() { resolveFunc, selectedFields = originalResolve, originalSelected }()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		switch id {
+		case "1":
+			return record{Bundle: id, Name: "Example: Maps", Publisher: "Example Inc.", Price: "Free"}, nil
+		case "com.example.maps":
+			return record{Bundle: id, Name: "Example Maps"}, nil // the publisher went missing
+		}
+		return record{Bundle: id}, errors.New("status 503")
+	}
+	list := []canary{
+		{"1", map[Field]string{FieldName: "Maps", FieldPublisher: "Example", FieldPrice: ""}},
+		{"com.example.maps", map[Field]string{FieldName: "Maps", FieldPublisher: "Example"}},
+		{"2", map[Field]string{FieldName: "Maps"}},
+	}
+	var out strings.Builder
+	broken, err := selftest(context.Background(), list, &out, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broken != 2 {
+		t.Errorf("broken = %d, want 2", broken)
+	}
+	want := "platform\tid\tfield\tstatus\twant\tgot\n" +
+		"ios\t1\tname\tok\tMaps\tExample: Maps\n" +
+		"ios\t1\tprice\tok\t(any)\tFree\n" +
+		"ios\t1\tpublisher\tok\tExample\tExample Inc.\n" +
+		"android\tcom.example.maps\tname\tok\tMaps\tExample Maps\n" +
+		"android\tcom.example.maps\tpublisher\tbroken\tExample\t\n" +
+		"ios\t2\tname\tfailed\tMaps\tstatus 503\n"
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+	if !selectedFields[FieldPrice] || selectedFields[FieldWebsite] {
+		t.Errorf("selected fields = %v", selectedFields)
+	}
+}
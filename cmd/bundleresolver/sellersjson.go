@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Values of the chain_status column of adstxt --sellers-json, best first:
+// the status of an app is the best one among its app-ads.txt entries for
+// the ad system.
+const (
+	chainAuthorized     = "authorized"      // the entry is confirmed by sellers.json
+	chainConfidential   = "confidential"    // the seller exists but hides its domain
+	chainDomainMismatch = "domain-mismatch" // a DIRECT seller of another domain
+	chainTypeMismatch   = "type-mismatch"   // e.g. DIRECT to an INTERMEDIARY
+	chainUnknownSeller  = "unknown-seller"  // the publisher id is not in sellers.json
+	chainNotListed      = "not-listed"      // app-ads.txt lists no entry for the ad system
+)
+
+var chainStatusRank = []string{chainAuthorized, chainConfidential, chainDomainMismatch, chainTypeMismatch, chainUnknownSeller}
+
+// sellerID is a seller_id, which some ad systems write as a number.
+type sellerID string
+
+func (id *sellerID) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(b, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*id = sellerID(s)
+		return nil
+	}
+	*id = sellerID(b)
+	return nil
+}
+
+// sellersJSONEntry is a seller of an IAB sellers.json file.
+type sellersJSONEntry struct {
+	SellerID     sellerID `json:"seller_id"`
+	Name         string   `json:"name"`
+	Domain       string   `json:"domain"`
+	SellerType   string   `json:"seller_type"`
+	Confidential int      `json:"is_confidential"`
+}
+
+// sellersJSON is an ad system's sellers.json, indexed by seller id.
+type sellersJSON struct {
+	domain  string
+	sellers map[string]sellersJSONEntry
+}
+
+// fetchSellersJSON fetches the sellers.json of the ad system at domain.
+func fetchSellersJSON(ctx context.Context, domain string) (*sellersJSON, error) {
+	u := "https://" + domain + "/sellers.json"
+	resp, err := httpGet(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s: status %s", u, resp.Status)
+	}
+	var file struct {
+		Sellers []sellersJSONEntry `json:"sellers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: invalid JSON: %w", u, err)
+	}
+	s := &sellersJSON{domain: domain, sellers: make(map[string]sellersJSONEntry, len(file.Sellers))}
+	for _, e := range file.Sellers {
+		s.sellers[strings.TrimSpace(string(e.SellerID))] = e
+	}
+	return s, nil
+}
+
+// check verifies the app-ads.txt entry e of the app whose developer
+// domain is appDomain: DIRECT entries need a PUBLISHER (or BOTH) seller of
+// that domain, RESELLER entries an INTERMEDIARY (or BOTH) seller.
+func (s *sellersJSON) check(e adsTxtEntry, appDomain string) string {
+	seller, ok := s.sellers[e.PublisherID]
+	if !ok {
+		return chainUnknownSeller
+	}
+	want := "INTERMEDIARY"
+	if e.Relationship == "DIRECT" {
+		want = "PUBLISHER"
+	}
+	if t := strings.ToUpper(seller.SellerType); t != want && t != "BOTH" {
+		return chainTypeMismatch
+	}
+	if e.Relationship != "DIRECT" {
+		return chainAuthorized
+	}
+	if seller.Confidential == 1 || seller.Domain == "" {
+		return chainConfidential
+	}
+	if !sameSite(seller.Domain, appDomain) {
+		return chainDomainMismatch
+	}
+	return chainAuthorized
+}
+
+// sameSite reports whether two domains are equal, or one is a subdomain
+// of the other, ignoring case and a leading "www.".
+func sameSite(a, b string) bool {
+	a = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(a)), "www.")
+	b = strings.TrimPrefix(strings.ToLower(b), "www.")
+	return a == b || strings.HasSuffix(a, "."+b) || strings.HasSuffix(b, "."+a)
+}
+
+// chain returns the chain_status of the app whose app-ads.txt is f and
+// developer domain appDomain, and the publisher ids that have it.
+func (s *sellersJSON) chain(f *adsTxt, appDomain string) (status string, ids []string) {
+	best := len(chainStatusRank)
+	for _, e := range f.Entries {
+		if e.Domain != s.domain {
+			continue
+		}
+		st := s.check(e, appDomain)
+		rank := 0
+		for rank < len(chainStatusRank) && chainStatusRank[rank] != st {
+			rank++
+		}
+		switch {
+		case rank < best:
+			best, ids = rank, []string{e.PublisherID}
+		case rank == best:
+			ids = append(ids, e.PublisherID)
+		}
+	}
+	if best == len(chainStatusRank) {
+		return chainNotListed, nil
+	}
+	return chainStatusRank[best], ids
+}
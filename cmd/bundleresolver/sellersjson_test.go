@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const sampleSellersJSON = `{"version":"1.0","sellers":[
+{"seller_id":"pub-1234567890","name":"Dev","domain":"dev.example","seller_type":"PUBLISHER"},
+{"seller_id":"pub-555","name":"Other","domain":"elsewhere.example","seller_type":"PUBLISHER"},
+{"seller_id":"pub-777","seller_type":"INTERMEDIARY","is_confidential":1},
+{"seller_id":42,"name":"Numeric","domain":"num.example","seller_type":"BOTH"}]}`
+
+func TestCheckAdsTxtSellersJSON(t *testing.T) {
+	originalResolve, originalClient := resolveFunc, httpClient
+	defer func() {
+		resolveFunc, httpClient = originalResolve, originalClient
+	}()
+	websites := map[string]string{"1": "https://www.dev.example/", "2": "https://spoof.example", "3": "https://resold.example", "4": "https://plain.example", "5": "https://nofile.example"}
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return record{Bundle: id, Website: websites[id]}, nil
+	}
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://google.com/sellers.json":    sampleSellersJSON,
+		"https://dev.example/app-ads.txt":    sampleAdsTxt,
+		"https://spoof.example/app-ads.txt":  "google.com, pub-555, DIRECT\ngoogle.com, pub-404, DIRECT\n",
+		"https://resold.example/app-ads.txt": "google.com, pub-777, RESELLER\ngoogle.com, pub-1234567890, RESELLER\n",
+		"https://plain.example/app-ads.txt":  "adnetwork.example, 42, DIRECT\n",
+	}}
+
+	var out strings.Builder
+	opts := adsTxtOptions{SellersJSON: "google.com"}
+	if err := checkAdsTxt(context.Background(), strings.NewReader("1\n2\n3\n4\n5\n"), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\t1\tdev.example\tok\t2\t1\tauthorized\tpub-1234567890\n" +
+		"2\t2\tspoof.example\tok\t2\t0\tdomain-mismatch\tpub-555\n" +
+		"3\t3\tresold.example\tok\t2\t0\tauthorized\tpub-777\n" +
+		"4\t4\tplain.example\tok\t1\t0\tnot-listed\t\n" +
+		"5\t5\tnofile.example\tmissing\t\t\t\t\n"
+	if got := out.String(); got != want {
+		t.Errorf("output mismatch:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestSellersJSONCheck(t *testing.T) {
+	s := &sellersJSON{domain: "adnetwork.example", sellers: map[string]sellersJSONEntry{
+		"42":  {SellerID: "42", Domain: "num.example", SellerType: "BOTH"},
+		"pub": {SellerID: "pub", Domain: "dev.example", SellerType: "INTERMEDIARY"},
+	}}
+	cases := []struct {
+		entry adsTxtEntry
+		want  string
+	}{
+		{adsTxtEntry{PublisherID: "42", Relationship: "DIRECT"}, chainAuthorized},
+		{adsTxtEntry{PublisherID: "42", Relationship: "RESELLER"}, chainAuthorized},
+		{adsTxtEntry{PublisherID: "pub", Relationship: "DIRECT"}, chainTypeMismatch},
+		{adsTxtEntry{PublisherID: "43", Relationship: "DIRECT"}, chainUnknownSeller},
+	}
+	for _, c := range cases {
+		if got := s.check(c.entry, "apps.num.example"); got != c.want {
+			t.Errorf("check(%+v) = %q, want %q", c.entry, got, c.want)
+		}
+	}
+}
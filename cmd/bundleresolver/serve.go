@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// resolveResponse is the JSON body returned by the serve command and the
+// Lambda handler.
+type resolveResponse struct {
+	Records []record       `json:"records"`
+	Errors  []resolveError `json:"errors,omitempty"`
+}
+
+type resolveError struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// resolveAll resolves ids in order. Failed lookups are reported in Errors and,
+// unless skipErrors is set, still contribute their partial record.
+func resolveAll(ctx context.Context, ids []string, skipErrors bool, debugDir string) resolveResponse {
+	resp := resolveResponse{Records: []record{}}
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		rec, err := resolveOne(ctx, id, debugDir)
+		if err != nil {
+			resp.Errors = append(resp.Errors, resolveError{ID: id, Error: err.Error()})
+			if skipErrors {
+				continue
+			}
+		}
+		resp.Records = append(resp.Records, rec)
+	}
+	return resp
+}
+
+func setupServe(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var addr string
+	var maxIDs int
+	fs.StringVar(&addr, "addr", ":8080", "Address to listen on")
+	fs.IntVar(&maxIDs, "max-ids", 100, "Maximum number of ids accepted per request")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q", args)
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           newServeMux(maxIDs, common.debugHTTP),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+
+		logger.Info("serving", "addr", addr)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// newServeMux routes:
+//
+//	GET  /resolve?id=123&id=com.example.app  resolve the given ids
+//	POST /resolve                            resolve ids from the body, one per line
+//	GET  /go/123                             redirect to the store page (--url-style short)
+//	GET  /healthz                            liveness probe
+func newServeMux(maxIDs int, debugDir string) *http.ServeMux {
+	mux := http.NewServeMux()
+	handle := func(w http.ResponseWriter, r *http.Request, ids []string) {
+		if len(ids) == 0 {
+			http.Error(w, "no ids given", http.StatusBadRequest)
+			return
+		}
+		if len(ids) > maxIDs {
+			http.Error(w, fmt.Sprintf("too many ids (max %d)", maxIDs), http.StatusRequestEntityTooLarge)
+			return
+		}
+		resp := resolveAll(r.Context(), ids, r.URL.Query().Get("skip_errors") == "true", debugDir)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+	mux.HandleFunc("GET /resolve", func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, r.URL.Query()["id"])
+	})
+	mux.HandleFunc("POST /resolve", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var ids []string
+		for _, line := range strings.Split(string(body), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				ids = append(ids, line)
+			}
+		}
+		handle(w, r, ids)
+	})
+	mux.HandleFunc("GET /go/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		p := platformOf(id)
+		u := storeURL(p, id)
+		if p == platformUnknown || u == "" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, u, http.StatusFound)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
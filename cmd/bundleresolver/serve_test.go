@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeResolve(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		if id == "com.missing.app" {
+			return record{Bundle: id}, errors.New("not found")
+		}
+		return record{Bundle: id, Name: "App " + id}, nil
+	}
+
+	srv := httptest.NewServer(newServeMux(2, ""))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/resolve", "text/plain", strings.NewReader("123\ncom.missing.app\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got resolveResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Records) != 2 || got.Records[0].Name != "App 123" {
+		t.Errorf("records = %+v", got.Records)
+	}
+	if len(got.Errors) != 1 || got.Errors[0].ID != "com.missing.app" {
+		t.Errorf("errors = %+v", got.Errors)
+	}
+
+	resp, err = http.Get(srv.URL + "/resolve?id=1&id=2&id=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status for too many ids = %d", resp.StatusCode)
+	}
+}
+
+func TestServeShortLink(t *testing.T) {
+	srv := httptest.NewServer(newServeMux(2, ""))
+	defer srv.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := client.Get(srv.URL + "/go/com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != buildPlayStoreURL("com.example.app") {
+		t.Errorf("got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp, err = client.Get(srv.URL + "/go/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status for an invalid id = %d", resp.StatusCode)
+	}
+}
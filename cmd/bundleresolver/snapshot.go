@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snapshotEntry is one line of a JSONL snapshot: a record keyed by the input
+// id it answers, plus the lookup error if there was one.
+type snapshotEntry struct {
+	Input string `json:"input"`
+	record
+	Error string `json:"error,omitempty"`
+	// NotFound is set when the store reports that the app does not exist,
+	// as opposed to a failure to ask it.
+	NotFound bool `json:"not_found,omitempty"`
+}
+
+// snapshot collects the results of a run. It is safe for concurrent use.
+type snapshot struct {
+	mu      sync.Mutex
+	entries []snapshotEntry
+}
+
+func (s *snapshot) add(input string, rec record, err error) {
+	e := snapshotEntry{Input: input, record: rec}
+	if err != nil {
+		e.Error, e.NotFound = err.Error(), isNotFoundError(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+}
+
+func writeSnapshot(w io.Writer, entries []snapshotEntry) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func writeSnapshotFile(path string, entries []snapshotEntry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshot(f, entries); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readSnapshotFile(path string) ([]snapshotEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []snapshotEntry
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for n := 1; s.Scan(); n++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		var e snapshotEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, s.Err()
+}
+
+// snapshotChange is one difference between two snapshots. Kind is added,
+// removed, changed (Field went from Old to New), failed (the lookup now
+// errors; New is the error) or recovered.
+type snapshotChange struct {
+	Input  string `json:"input"`
+	Bundle string `json:"bundle"`
+	Kind   string `json:"change"`
+	Field  string `json:"field,omitempty"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// diffSnapshots compares two snapshots by input id, in the order of cur
+// followed by the ids only old has. Fields are compared by their JSON
+// names, nested values flattened as "privacy.linked"; an object present on
+// one side only (say, a privacy label one run didn't fetch) is ignored.
+func diffSnapshots(old, cur []snapshotEntry) []snapshotChange {
+	oldByInput := make(map[string]snapshotEntry, len(old))
+	for _, e := range old {
+		oldByInput[e.Input] = e
+	}
+	var changes []snapshotChange
+	seen := map[string]bool{}
+	for _, e := range cur {
+		if seen[e.Input] {
+			continue
+		}
+		seen[e.Input] = true
+		o, ok := oldByInput[e.Input]
+		switch {
+		case !ok:
+			changes = append(changes, snapshotChange{Input: e.Input, Bundle: e.Bundle, Kind: "added"})
+		case o.Error == "" && e.Error != "":
+			changes = append(changes, snapshotChange{Input: e.Input, Bundle: o.Bundle, Kind: "failed", New: e.Error})
+		case o.Error != "" && e.Error == "":
+			changes = append(changes, snapshotChange{Input: e.Input, Bundle: e.Bundle, Kind: "recovered", Old: o.Error})
+		case e.Error == "":
+			changes = append(changes, fieldChanges(o, e)...)
+		}
+	}
+	for _, o := range old {
+		if !seen[o.Input] {
+			seen[o.Input] = true
+			changes = append(changes, snapshotChange{Input: o.Input, Bundle: o.Bundle, Kind: "removed"})
+		}
+	}
+	return changes
+}
+
+func fieldChanges(old, cur snapshotEntry) []snapshotChange {
+	ov, cv := flattenRecord(old.record), flattenRecord(cur.record)
+	keys := map[string]bool{}
+	for k := range ov {
+		keys[k] = true
+	}
+	for k := range cv {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		group, _, nested := strings.Cut(k, ".")
+		if nested && (!hasGroup(ov, group) || !hasGroup(cv, group)) {
+			continue
+		}
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	var changes []snapshotChange
+	for _, k := range sorted {
+		if ov[k] != cv[k] {
+			changes = append(changes, snapshotChange{Input: cur.Input, Bundle: cur.Bundle, Kind: "changed", Field: k, Old: ov[k], New: cv[k]})
+		}
+	}
+	return changes
+}
+
+func hasGroup(values map[string]string, group string) bool {
+	_, ok := values[group+"."]
+	return ok
+}
+
+// flattenRecord maps the JSON names of rec's fields to their values. Lists
+// are joined with commas; each nested object also gets a "<name>." marker
+// key so its presence can be told apart from empty contents. Of the version
+// history only the current version is compared.
+func flattenRecord(rec record) map[string]string {
+	current := versionValue(rec.History, FieldVersion)
+	// Every run changes resolved_at.
+	rec.History, rec.ResolvedAt = nil, ""
+	data, _ := json.Marshal(rec)
+	var raw map[string]any
+	json.Unmarshal(data, &raw)
+	values := map[string]string{}
+	var flatten func(prefix string, v any)
+	flatten = func(prefix string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			values[prefix+"."] = ""
+			for k, e := range v {
+				flatten(prefix+"."+k, e)
+			}
+		case []any:
+			parts := make([]string, len(v))
+			for i, e := range v {
+				parts[i] = fmt.Sprint(e)
+			}
+			values[prefix] = strings.Join(parts, ",")
+		default:
+			values[prefix] = fmt.Sprint(v)
+		}
+	}
+	for k, v := range raw {
+		flatten(k, v)
+	}
+	if current != "" {
+		values["version"] = current
+	}
+	return values
+}
+
+// writeChanges writes changes as TSV rows with a header.
+func writeChanges(w io.Writer, changes []snapshotChange) error {
+	out := newRowWriter(w, nil, false, sanitizeStrip)
+	if err := out.writeValues("input", "bundle", "change", "field", "old", "new"); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if err := out.writeValues(c.Input, c.Bundle, c.Kind, c.Field, c.Old, c.New); err != nil {
+			return err
+		}
+	}
+	return out.flush()
+}
+
+// latestSnapshot returns the path and entries of the newest snapshot in
+// dir, or an empty path if there is none.
+func latestSnapshot(dir string) (string, []snapshotEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil || len(paths) == 0 {
+		return "", nil, err
+	}
+	sort.Strings(paths)
+	path := paths[len(paths)-1]
+	entries, err := readSnapshotFile(path)
+	return path, entries, err
+}
+
+// saveSnapshot writes entries to dir/<timestamp>.jsonl and, if dir holds an
+// earlier snapshot, the changes since the latest one to
+// dir/<timestamp>.changes.tsv. It returns those changes.
+func saveSnapshot(dir string, at time.Time, entries []snapshotEntry) ([]snapshotChange, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	prev, old, err := latestSnapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	stamp := at.Format("20060102T150405")
+	path := filepath.Join(dir, stamp+".jsonl")
+	if err := writeSnapshotFile(path, entries); err != nil {
+		return nil, err
+	}
+	if prev == "" {
+		logger.Info("first snapshot written", "path", path)
+		return nil, nil
+	}
+	changes := diffSnapshots(old, entries)
+	changesPath := filepath.Join(dir, stamp+".changes.tsv")
+	f, err := os.Create(changesPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeChanges(f, changes); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	logMsg := logger.Info
+	if len(changes) > 0 {
+		logMsg = logger.Warn
+	}
+	logMsg("snapshot compared", "path", path, "previous", prev, "changes", len(changes), "report", changesPath)
+	return changes, nil
+}
+
+func setupDiff(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+	var savePath string
+	var concurrency int
+	fs.StringVar(&savePath, "save", "", "With a single snapshot, also write the fresh results as a snapshot to this file")
+	fs.IntVar(&concurrency, "concurrency", 4, "Number of lookups run in parallel")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) < 1 || len(args) > 2 {
+			return errors.New("diff requires an old snapshot and optionally a new one")
+		}
+		old, err := readSnapshotFile(args[0])
+		if err != nil {
+			return err
+		}
+		var cur []snapshotEntry
+		if len(args) == 2 {
+			if cur, err = readSnapshotFile(args[1]); err != nil {
+				return err
+			}
+			return writeChanges(os.Stdout, diffSnapshots(old, cur))
+		}
+
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		if concurrency < 1 {
+			return fmt.Errorf("invalid --concurrency %d (must be at least 1)", concurrency)
+		}
+		cur, err = resnapshot(old, concurrency)
+		if err != nil {
+			return err
+		}
+		if savePath != "" {
+			if err := writeSnapshotFile(savePath, cur); err != nil {
+				return err
+			}
+		}
+		return writeChanges(os.Stdout, diffSnapshots(old, cur))
+	}
+}
+
+// resnapshot looks up the inputs of old again, fetching the extra pages for
+// privacy labels and Data safety only if old has them.
+func resnapshot(old []snapshotEntry, concurrency int) ([]snapshotEntry, error) {
+	fields := []Field{FieldBundle, FieldName, FieldPublisher, FieldURL}
+	var ids strings.Builder
+	for _, e := range old {
+		ids.WriteString(e.Input + "\n")
+		if e.Privacy != nil && !hasField(fields, FieldPrivacyTracking) {
+			fields = append(fields, appStorePageFields...)
+		}
+		if e.DataSafety != nil && !hasField(fields, FieldDataShared) {
+			fields = append(fields, dataSafetyFields...)
+		}
+	}
+	selectFields(fields)
+	snap := &snapshot{}
+	opts := options{Fields: fields, Concurrency: concurrency, Snapshot: snap}
+	if err := process(strings.NewReader(ids.String()), io.Discard, opts); err != nil {
+		return nil, err
+	}
+	return snap.entries, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	old := []snapshotEntry{
+		{Input: "1", record: record{Bundle: "1", Name: "Old Name", Publisher: "Acme"}},
+		{Input: "2", record: record{Bundle: "2", Name: "Same", Privacy: &appPrivacy{Tracking: []string{"Location"}}}},
+		{Input: "3", record: record{Bundle: "3", Name: "Delisted"}},
+		{Input: "4", record: record{Bundle: "4", Name: "Broken"}},
+	}
+	cur := []snapshotEntry{
+		{Input: "1", record: record{Bundle: "1", Name: "New Name", Publisher: "Globex"}},
+		{Input: "2", record: record{Bundle: "2", Name: "Same"}},
+		{Input: "4", Error: "lookup failed"},
+		{Input: "5", record: record{Bundle: "5", Name: "Fresh"}},
+	}
+	want := []snapshotChange{
+		{Input: "1", Bundle: "1", Kind: "changed", Field: "name", Old: "Old Name", New: "New Name"},
+		{Input: "1", Bundle: "1", Kind: "changed", Field: "publisher", Old: "Acme", New: "Globex"},
+		{Input: "4", Bundle: "4", Kind: "failed", New: "lookup failed"},
+		{Input: "5", Bundle: "5", Kind: "added"},
+		{Input: "3", Bundle: "3", Kind: "removed"},
+	}
+	if got := diffSnapshots(old, cur); !reflect.DeepEqual(got, want) {
+		t.Errorf("diff =\n%+v\nwant\n%+v", got, want)
+	}
+
+	cur[1].Privacy = &appPrivacy{Tracking: []string{"Location", "Contact Info"}}
+	got := diffSnapshots(old[1:2], cur[1:2])
+	want = []snapshotChange{{Input: "2", Bundle: "2", Kind: "changed", Field: "privacy.tracking", Old: "Location", New: "Location,Contact Info"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nested diff = %+v, want %+v", got, want)
+	}
+}
+
+func TestSaveSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	first := []snapshotEntry{{Input: "1", record: record{Bundle: "1", Name: "A"}}}
+	if _, err := saveSnapshot(dir, at, first); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readSnapshotFile(filepath.Join(dir, "20240501T030000.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, first) {
+		t.Errorf("read back %+v, want %+v", got, first)
+	}
+
+	second := []snapshotEntry{{Input: "1", record: record{Bundle: "1", Name: "B"}}}
+	if _, err := saveSnapshot(dir, at.Add(24*time.Hour), second); err != nil {
+		t.Fatal(err)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, "20240502T030000.changes.tsv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "input\tbundle\tchange\tfield\told\tnew\n1\t1\tchanged\tname\tA\tB\n"
+	if string(report) != want {
+		t.Errorf("report = %q, want %q", report, want)
+	}
+}
+
+func TestProcessSnapshot(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		if id != "1" {
+			return record{}, errors.New("not found")
+		}
+		return record{Bundle: id, Name: "One"}, nil
+	}
+	snap := &snapshot{}
+	opts := options{Fields: []Field{FieldBundle}, SkipErrors: true, Snapshot: snap}
+	if err := process(strings.NewReader("1\n\nbad\n"), &bytes.Buffer{}, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.entries) != 2 || snap.entries[0].Name != "One" || snap.entries[1].Error == "" {
+		t.Errorf("snapshot = %+v, want one record and one failure", snap.entries)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// More values of the source field, for the sources of --sources.
+const (
+	sourceAPI   = "api"
+	sourceCache = "cache"
+)
+
+// dataSources are the sources --sources chains, in their default order,
+// with the platforms each can look up.
+var dataSources = []struct {
+	name      string
+	platforms []string
+}{
+	{sourceAPI, []string{platformAndroid}},
+	{sourceStore, []string{platformIOS, platformAndroid}},
+	{sourceMirror, []string{platformIOS, platformAndroid}},
+	{sourceDump, []string{platformAndroid}},
+	{sourceArchive, []string{platformIOS, platformAndroid}},
+	{sourceCache, []string{platformIOS, platformAndroid}},
+}
+
+// sourceOrder, set by --sources, lists the data sources lookups try, in
+// order. Empty, lookups use the store, or the --android-source dump for
+// Android ids, then the archive with --archive.
+var sourceOrder []string
+
+// errNotInPlayAPI reports a package the Play Developer API does not give
+// access to, as a source of its own.
+var errNotInPlayAPI = errors.New("not found through the Play Developer API")
+
+// parseSources reads a comma-separated --sources chain.
+func parseSources(csv string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(csv, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, s := range dataSources {
+			known = known || s.name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown source %q (want api, store, mirror, dump, archive or cache)", name)
+		}
+		if containsString(names, name) {
+			return nil, fmt.Errorf("source %q listed twice", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// sourcesFor returns the chain ids of platform p go through.
+func sourcesFor(p string) []string {
+	if len(sourceOrder) == 0 {
+		chain := []string{sourceStore}
+		if p == platformAndroid && androidDump != nil {
+			chain = []string{sourceDump}
+		}
+		if archiveLookups {
+			chain = append(chain, sourceArchive)
+		}
+		return chain
+	}
+	var chain []string
+	for _, name := range sourceOrder {
+		for _, s := range dataSources {
+			if s.name == name && containsString(s.platforms, p) {
+				chain = append(chain, name)
+			}
+		}
+	}
+	return chain
+}
+
+// resolveSources looks id up in the sources of its platform's chain until
+// one has it. If none has, the most informative failure is returned (see
+// moreInformative), the first one between equals. The archive only
+// recovers ids that failure reports as not found, and a geo-blocked app,
+// which exists, ends the chain.
+func resolveSources(ctx context.Context, p, id string) (record, error) {
+	rec := record{Bundle: id, URL: storeURL(p, id)}
+	err := fmt.Errorf("--sources has no source for %s ids", p)
+	tried := false
+	for _, name := range sourcesFor(p) {
+		if name == sourceArchive {
+			if tried {
+				if archived, archiveErr := addArchived(ctx, p, id, rec, err); archiveErr == nil {
+					markPartial(&archived, name)
+					return archived, nil
+				}
+			}
+			continue
+		}
+		r, e := fetchFrom(ctx, name, p, id)
+		if e == nil {
+			markPartial(&r, name)
+			return r, nil
+		}
+		if !tried || moreInformative(e, err) {
+			rec, err = r, e
+		}
+		tried = true
+		var geoErr *geoBlockedError
+		if errors.As(e, &geoErr) || ctx.Err() != nil {
+			break
+		}
+		logger.Debug("source failed", "id", id, "source", name, "err", e)
+	}
+	return rec, err
+}
+
+// moreInformative tells whether the failure e of a later source says more
+// than err, the one kept so far: anything says more than the Play
+// Developer API's miss, which only means the account cannot see the app,
+// and a transient failure, worth a retry, more than a source lacking it.
+func moreInformative(e, err error) bool {
+	if errors.Is(err, errNotInPlayAPI) {
+		return true
+	}
+	return retryable(e) && !retryable(err)
+}
+
+// fetchFrom looks id up in one source. Records of sources other than the
+// store carry its name in Source.
+func fetchFrom(ctx context.Context, name, p, id string) (record, error) {
+	switch name {
+	case sourceAPI:
+		rec, err := addPlayAPIFields(ctx, id, record{Bundle: id, URL: buildPlayStoreURL(id)}, errNotInPlayAPI)
+		if err != nil {
+			return rec, err
+		}
+		rec.Source = sourceAPI
+		if containsString(sourcesFor(p), sourceStore) {
+			rec = fillFromStore(ctx, rec)
+		}
+		return rec, nil
+	case sourceMirror:
+		return fetchMirror(ctx, p, id)
+	case sourceDump:
+		if androidDump == nil {
+			return record{Bundle: id, URL: buildPlayStoreURL(id)}, errors.New("no --android-source dump")
+		}
+		rec, err := androidDump.lookup(id)
+		return completeFromPlayAPI(ctx, id, rec, err)
+	case sourceCache:
+		fetch := fetchAndroidPage
+		if p == platformIOS {
+			fetch = fetchIOS
+		}
+		rec, err := fetch(withCacheOnly(ctx), id)
+		if err == nil {
+			rec.Source = sourceCache
+		}
+		return rec, err
+	}
+	return fetchStore(ctx, p, id)
+}
+
+// fetchStore looks id up in its store, within the store's limiter and
+// circuit breaker.
+func fetchStore(ctx context.Context, p, id string) (record, error) {
+	release, err := storeLimiters[p].acquire(ctx)
+	if err != nil {
+		return record{Bundle: id, URL: storeURL(p, id)}, err
+	}
+	defer release()
+	b := storeBreakers[p]
+	if err := b.allow(); err != nil {
+		return record{Bundle: id, URL: storeURL(p, id)}, err
+	}
+	fetch := fetchAndroid
+	if p == platformIOS {
+		fetch = fetchIOS
+	}
+	rec, err := fetch(ctx, id)
+	b.done(err)
+	return rec, err
+}
+
+// fillFromStore completes a Play Developer API record, which has no
+// publisher or category, with the rest of the Play listing when the store
+// is also a source. The API's fields win; a failed page leaves the record
+// as it is.
+func fillFromStore(ctx context.Context, rec record) record {
+	page, err := fetchStore(ctx, platformAndroid, rec.Bundle)
+	if err != nil {
+		logger.Debug("store page failed, keeping the Play Developer API record", "id", rec.Bundle, "err", err)
+		return rec
+	}
+	page.Name = cmp.Or(rec.Name, page.Name)
+	page.Website = cmp.Or(rec.Website, page.Website)
+	page.DeveloperEmail = cmp.Or(rec.DeveloperEmail, page.DeveloperEmail)
+	page.PlayReleases = rec.PlayReleases
+	page.Source = sourceAPI
+	return page
+}
+
+// completeFromPlayAPI completes an Android lookup with the Play Developer
+// API, unless the API is a source of its own in --sources.
+func completeFromPlayAPI(ctx context.Context, pkg string, rec record, err error) (record, error) {
+	if containsString(sourceOrder, sourceAPI) {
+		return rec, err
+	}
+	return addPlayAPIFields(ctx, pkg, rec, err)
+}
+
+// cacheOnlyKey marks the context of lookups of the cache source.
+type cacheOnlyKey struct{}
+
+// withCacheOnly makes the requests made with ctx answered by --cache-dir
+// alone, however old its entries, and fail if it has none.
+func withCacheOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheOnlyKey{}, true)
+}
+
+func cacheOnly(ctx context.Context) bool {
+	only, _ := ctx.Value(cacheOnlyKey{}).(bool)
+	return only
+}
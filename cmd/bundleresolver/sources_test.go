@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseSources(t *testing.T) {
+	got, err := parseSources("API, store,,cache")
+	if err != nil || !reflect.DeepEqual(got, []string{"api", "store", "cache"}) {
+		t.Errorf("parseSources = %v, %v", got, err)
+	}
+	for _, csv := range []string{"store,scrape", "store,store"} {
+		if _, err := parseSources(csv); err == nil {
+			t.Errorf("parseSources(%q) accepted", csv)
+		}
+	}
+}
+
+func TestSourcesFor(t *testing.T) {
+	originalOrder, originalArchive, originalDump := sourceOrder, archiveLookups, androidDump
+	defer func() {
+		sourceOrder, archiveLookups, androidDump = originalOrder, originalArchive, originalDump
+	}()
+
+	sourceOrder, archiveLookups, androidDump = nil, true, &playDump{}
+	if got := sourcesFor(platformAndroid); !reflect.DeepEqual(got, []string{"dump", "archive"}) {
+		t.Errorf("default android chain = %v", got)
+	}
+	if got := sourcesFor(platformIOS); !reflect.DeepEqual(got, []string{"store", "archive"}) {
+		t.Errorf("default ios chain = %v", got)
+	}
+	sourceOrder = []string{"api", "dump", "store", "cache"}
+	if got := sourcesFor(platformIOS); !reflect.DeepEqual(got, []string{"store", "cache"}) {
+		t.Errorf("ios chain = %v, want the Android-only sources left out", got)
+	}
+}
+
+func TestResolveSourcesCache(t *testing.T) {
+	originalClient, originalOrder := httpClient, sourceOrder
+	defer func() {
+		httpClient, sourceOrder = originalClient, originalOrder
+	}()
+	online := true
+	store := fakeTransport{"https://itunes.apple.com/lookup?id=123": `{"resultCount":1,"results":[{"trackId":123,"trackName":"App","sellerName":"Dev"}]}`}
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if !online {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Body: http.NoBody, Request: req}, nil
+		}
+		return store.RoundTrip(req)
+	})
+	cache, err := newCacheTransport(base, t.TempDir(), 0, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpClient = &http.Client{Transport: cache}
+	sourceOrder = []string{sourceStore, sourceCache}
+
+	rec, err := resolve(context.Background(), "123")
+	if err != nil || rec.Name != "App" || rec.sourceValue() != sourceStore {
+		t.Fatalf("online = %+v, %v", rec, err)
+	}
+
+	// With the store down, the cached lookup answers.
+	online = false
+	rec, err = resolve(context.Background(), "123")
+	if err != nil || rec.Name != "App" || rec.sourceValue() != sourceCache {
+		t.Errorf("offline = %+v, %v; want the cached record", rec, err)
+	}
+	// An id never cached keeps the store's error.
+	if _, err := resolve(context.Background(), "456"); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("uncached err = %v, want the store's 503", err)
+	}
+}
+
+func TestMoreInformative(t *testing.T) {
+	unavailable := statusError(&http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"})
+	gone := statusError(&http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found"})
+	for _, c := range []struct {
+		e, err error
+		want   bool
+	}{
+		{gone, errNotInPlayAPI, true},
+		{unavailable, errNotInPlayAPI, true},
+		{unavailable, gone, true},
+		{gone, unavailable, false},
+		{errors.New("not cached"), gone, false},
+	} {
+		if got := moreInformative(c.e, c.err); got != c.want {
+			t.Errorf("moreInformative(%v, %v) = %v, want %v", c.e, c.err, got, c.want)
+		}
+	}
+}
+
+func TestResolveSourcesMirror(t *testing.T) {
+	originalClient, originalOrder, originalMirror := httpClient, sourceOrder, mirrorSource
+	defer func() {
+		httpClient, sourceOrder, mirrorSource = originalClient, originalOrder, originalMirror
+	}()
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://mirror.example/apps/123.json": `{"bundle":"com.example.app","title":"Mirrored","developer":"Dev","genre":"Games"}`,
+	}}
+	mirrorSource = "https://mirror.example/apps/{id}.json"
+	sourceOrder = []string{sourceStore, sourceMirror}
+
+	rec, err := resolve(context.Background(), "123")
+	if err != nil || rec.Name != "Mirrored" || rec.Publisher != "Dev" || rec.Category != "Games" || rec.sourceValue() != sourceMirror {
+		t.Fatalf("mirrored = %+v, %v", rec, err)
+	}
+	if rec.Bundle != "123" {
+		t.Errorf("bundle = %q, want the input id", rec.Bundle)
+	}
+	var se *httpStatusError
+	if _, err := resolve(context.Background(), "456"); !errors.As(err, &se) || se.Code != http.StatusNotFound {
+		t.Errorf("unmirrored err = %v, want a 404", err)
+	}
+}
+
+func TestResolveSourcesAPIThenStore(t *testing.T) {
+	originalClient, originalOrder, originalAPI, originalBase, originalLang := httpClient, sourceOrder, playAPI, playAPIBase, urlLang
+	defer func() {
+		httpClient, sourceOrder, playAPI, playAPIBase, urlLang = originalClient, originalOrder, originalAPI, originalBase, originalLang
+	}()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deleted atomic.Int32
+	srv := fakePlayAPI(key, &deleted)
+	defer srv.Close()
+	storeUp := true
+	page := `<html><body><h1><span>Own App on Play</span></h1>
+<a href="/store/apps/dev?id=1"><span>Own Dev</span></a>
+<a itemprop="genre" href="/store/apps/category/TOOLS">Tools</a></body></html>`
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host != "play.google.com" {
+			return srv.Client().Transport.RoundTrip(req)
+		}
+		if !storeUp || req.URL.Query().Get("id") != "com.example.own" {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Body: http.NoBody, Request: req}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: io.NopCloser(strings.NewReader(page)), Request: req}, nil
+	})}
+	playAPIBase, urlLang = srv.URL+"/apps/", "en"
+	if playAPI, err = loadPlayServiceAccount(writeServiceAccount(t, key, srv.URL+"/token")); err != nil {
+		t.Fatal(err)
+	}
+	sourceOrder = []string{sourceAPI, sourceStore}
+
+	// The API's listing, completed with the store's publisher and category.
+	rec, err := resolve(context.Background(), "com.example.own")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Name != "Own App" || rec.Publisher != "Own Dev" || rec.Category != "Tools" || rec.DeveloperEmail != "dev@own.example" || rec.Partial || rec.sourceValue() != sourceAPI {
+		t.Errorf("api record = %+v", rec)
+	}
+
+	// The store's 503 for an app the API cannot see is the error kept, so
+	// that the row is retried rather than reported as not found.
+	storeUp = false
+	_, err = resolve(context.Background(), "com.other.app")
+	if err == nil || errors.Is(err, errNotInPlayAPI) || !retryable(err) {
+		t.Errorf("err = %v, want the store's 503", err)
+	}
+}
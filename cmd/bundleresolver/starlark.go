@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// starlarkMaxSteps bounds the work of one call into a --script Starlark
+// file, so that a runaway loop fails the run instead of hanging it.
+const starlarkMaxSteps = 10_000_000
+
+// starlarkScript is a --script Starlark file, run by the embedded
+// interpreter. It defines
+//
+//	def transform(row, record): ...
+//
+// called for every row with the selected fields by name in row and the
+// whole record (as in snapshots) in record, both dicts. It returns the
+// output row as a dict by column, or None to leave the row out. An
+// optional
+//
+//	def columns(fields): ...
+//
+// returns the output columns for the list of selected fields; without it
+// the columns are the fields. Scripts have no file, network or clock
+// access: json is the only module predeclared, and load is not supported.
+type starlarkScript struct {
+	mu        sync.Mutex
+	path      string
+	thread    *starlark.Thread
+	transform starlark.Callable
+}
+
+// startStarlarkScript runs the Starlark file at path and agrees on the
+// output columns for rows of fields.
+func startStarlarkScript(fields []Field, path string) (*rowScript, error) {
+	s := &starlarkScript{path: path, thread: &starlark.Thread{Name: path}}
+	s.thread.SetMaxExecutionSteps(starlarkMaxSteps)
+	predeclared := starlark.StringDict{"json": starlarkjson.Module}
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, s.thread, path, nil, predeclared)
+	if err != nil {
+		return nil, starlarkError(err)
+	}
+	transform, ok := globals["transform"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("%s defines no transform(row, record) function", path)
+	}
+	s.transform = transform
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = string(f)
+	}
+	if fn, ok := globals["columns"].(starlark.Callable); ok {
+		list := make([]starlark.Value, len(columns))
+		for i, c := range columns {
+			list[i] = starlark.String(c)
+		}
+		v, err := s.call(fn, starlark.NewList(list))
+		if err != nil {
+			return nil, err
+		}
+		if columns, err = starlarkStrings(v); err != nil {
+			return nil, fmt.Errorf("%s: columns: %w", path, err)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("the script answered with no columns")
+	}
+	return &rowScript{columns: columns, rewrite: s.rewrite, close: func() error { return nil }}, nil
+}
+
+// rewrite calls transform for the row of req.
+func (s *starlarkScript) rewrite(req scriptRequest) (scriptResponse, error) {
+	row := starlark.NewDict(len(req.Row))
+	for k, v := range req.Row {
+		row.SetKey(starlark.String(k), starlark.String(v))
+	}
+	data, err := json.Marshal(req.Record)
+	if err != nil {
+		return scriptResponse{}, err
+	}
+	// The record, through its JSON form.
+	rec, err := s.call(starlarkjson.Module.Members["decode"], starlark.String(data))
+	if err != nil {
+		return scriptResponse{}, err
+	}
+	v, err := s.call(s.transform, row, rec)
+	if err != nil {
+		return scriptResponse{}, err
+	}
+	if v == starlark.None {
+		return scriptResponse{Drop: true}, nil
+	}
+	out, ok := v.(*starlark.Dict)
+	if !ok {
+		return scriptResponse{}, fmt.Errorf("%s: transform returned a %s, want a dict or None", s.path, v.Type())
+	}
+	resp := scriptResponse{Row: make(map[string]string, out.Len())}
+	for _, item := range out.Items() {
+		k, ok := starlark.AsString(item[0])
+		if !ok {
+			return scriptResponse{}, fmt.Errorf("%s: transform returned a %s column name", s.path, item[0].Type())
+		}
+		resp.Row[k] = starlarkValue(item[1])
+	}
+	return resp, nil
+}
+
+// call calls fn with args within the step budget.
+func (s *starlarkScript) call(fn starlark.Value, args ...starlark.Value) (starlark.Value, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.thread.SetMaxExecutionSteps(s.thread.ExecutionSteps() + starlarkMaxSteps)
+	v, err := starlark.Call(s.thread, fn, starlark.Tuple(args), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, starlarkError(err))
+	}
+	return v, nil
+}
+
+// starlarkValue renders a value transform returned for a column: strings
+// as they are, None as empty and booleans as the output writes them.
+func starlarkValue(v starlark.Value) string {
+	switch v := v.(type) {
+	case starlark.String:
+		return string(v)
+	case starlark.NoneType:
+		return ""
+	case starlark.Bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	return v.String()
+}
+
+func starlarkStrings(v starlark.Value) ([]string, error) {
+	iter, ok := v.(starlark.Iterable)
+	if !ok {
+		return nil, fmt.Errorf("got a %s, want a list of strings", v.Type())
+	}
+	var out []string
+	it := iter.Iterate()
+	defer it.Done()
+	var x starlark.Value
+	for it.Next(&x) {
+		s, ok := starlark.AsString(x)
+		if !ok {
+			return nil, fmt.Errorf("got a %s in the list, want strings", x.Type())
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// starlarkError keeps the backtrace of a Starlark failure, which names the
+// line of the script.
+func starlarkError(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
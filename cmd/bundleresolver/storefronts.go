@@ -0,0 +1,63 @@
+package main
+
+import "strings"
+
+// guessStorefronts, set by --guess-storefront, tries the storefronts an iOS
+// bundle id hints at before the jp storefront when the default one misses.
+var guessStorefronts bool
+
+// storefrontTLDs maps the country-code domains bundle ids start with to
+// their storefront. Country domains mostly used as generic ones (io, co, me,
+// tv, ...) are left out, as is us, the default storefront.
+var storefrontTLDs = map[string]string{
+	"ae": "ae", "ar": "ar", "at": "at", "au": "au", "be": "be", "br": "br",
+	"ca": "ca", "ch": "ch", "cl": "cl", "cn": "cn", "cz": "cz", "de": "de",
+	"dk": "dk", "es": "es", "fi": "fi", "fr": "fr", "gr": "gr", "hk": "hk",
+	"hu": "hu", "id": "id", "il": "il", "in": "in", "it": "it", "jp": "jp",
+	"kr": "kr", "mx": "mx", "my": "my", "nl": "nl", "no": "no", "nz": "nz",
+	"ph": "ph", "pl": "pl", "pt": "pt", "ro": "ro", "ru": "ru", "sa": "sa",
+	"se": "se", "sg": "sg", "th": "th", "tr": "tr", "tw": "tw", "ua": "ua",
+	"uk": "gb", "vn": "vn", "za": "za",
+}
+
+// storefrontLanguages maps the language codes bundle ids end with, as in
+// com.example.game.ja, to the storefront of the language's main country.
+var storefrontLanguages = map[string]string{
+	"ja": "jp", "ko": "kr", "vi": "vn", "zhcn": "cn", "zhtw": "tw",
+}
+
+// storefrontHints returns the storefronts an iOS bundle id hints at, most
+// likely first: that of a reverse domain under a country's domain
+// (jp.co.example.app, kr.co.example.app), then that of a country or
+// language suffix (com.example.app.jp, com.example.app.ko).
+func storefrontHints(bundleID string) []string {
+	segments := strings.Split(strings.ToLower(bundleID), ".")
+	if len(segments) < 2 {
+		return nil
+	}
+	var hints []string
+	add := func(country string) {
+		if country != "" && !containsString(hints, country) {
+			hints = append(hints, country)
+		}
+	}
+	add(storefrontTLDs[segments[0]])
+	last := strings.NewReplacer("-", "", "_", "").Replace(segments[len(segments)-1])
+	add(storefrontTLDs[last])
+	add(storefrontLanguages[last])
+	return hints
+}
+
+// storefrontChain returns the storefronts fetchIOS tries, in order, when
+// the lookup of appID without a country misses: the hinted ones with
+// --guess-storefront, then jp.
+func storefrontChain(appID string) []string {
+	var chain []string
+	if bundleID, ok := strings.CutPrefix(appID, iosBundlePrefix); ok && guessStorefronts {
+		chain = storefrontHints(bundleID)
+	}
+	if !containsString(chain, "jp") {
+		chain = append(chain, "jp")
+	}
+	return chain
+}
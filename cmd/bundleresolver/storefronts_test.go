@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestStorefrontHints(t *testing.T) {
+	for _, tc := range []struct {
+		bundleID string
+		want     []string
+	}{
+		{"jp.co.example.game", []string{"jp"}},
+		{"kr.co.example.app", []string{"kr"}},
+		{"com.example.game.ko", []string{"kr"}},
+		{"com.example.game.zh-tw", []string{"tw"}},
+		{"de.example.app.fr", []string{"de", "fr"}},
+		{"uk.co.example.app", []string{"gb"}},
+		{"io.example.app", nil},
+		{"com.example.app", nil},
+		{"app", nil},
+	} {
+		if got := storefrontHints(tc.bundleID); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("storefrontHints(%q) = %v, want %v", tc.bundleID, got, tc.want)
+		}
+	}
+}
+
+func TestFetchIOSGuessedStorefront(t *testing.T) {
+	originalClient, originalGuess := httpClient, guessStorefronts
+	defer func() {
+		httpClient, guessStorefronts = originalClient, originalGuess
+	}()
+	httpClient = &http.Client{Transport: fakeTransport{
+		"https://itunes.apple.com/lookup?bundleId=kr.co.example.app&country=kr": `{"resultCount":1,"results":[{"trackId":123,"trackName":"App","bundleId":"kr.co.example.app"}]}`,
+	}}
+
+	for _, guess := range []bool{false, true} {
+		guessStorefronts = guess
+		retries := lookupRetries.Load()
+		rec, err := fetchIOS(context.Background(), iosBundlePrefix+"kr.co.example.app")
+		if !guess {
+			if err == nil {
+				t.Errorf("found %+v without --guess-storefront", rec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Bundle != "123" || !slices.Equal(rec.Fallbacks, []string{fallbackGuessedStorefront}) {
+			t.Errorf("record = %+v", rec)
+		}
+		// The jp storefront is not tried once kr has the app.
+		if n := lookupRetries.Load() - retries; n != 1 {
+			t.Errorf("%d retries, want 1", n)
+		}
+	}
+}
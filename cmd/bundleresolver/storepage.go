@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// selectedFields holds the output fields of the current run, so lookups make
+// the extra requests some fields need only when those fields are wanted.
+var selectedFields map[Field]bool
+
+func selectFields(fields []Field) {
+	selectedFields = make(map[Field]bool, len(fields))
+	for _, f := range fields {
+		selectedFields[f] = true
+	}
+}
+
+// appStorePageFields are filled from the App Store page, which the iTunes
+// lookup API does not need.
+var appStorePageFields = []Field{FieldResolvedURL, FieldPrivacyTracking, FieldPrivacyLinked, FieldPrivacyNotLinked, FieldBadges, FieldIAPItems, FieldController}
+
+// dataSafetyFields are filled from the Play Data safety page.
+var dataSafetyFields = []Field{FieldDataShared, FieldDataCollected, FieldSecurityPractices}
+
+func anySelected(fields []Field) bool {
+	for _, f := range fields {
+		if selectedFields[f] {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchStorePage requests a store page and returns where it ended up and,
+// if it answered 200, its parsed HTML.
+func fetchStorePage(ctx context.Context, pageURL string) (resolvedURL string, doc *goquery.Document, err error) {
+	resp, err := httpGet(ctx, pageURL)
+	if err != nil {
+		return "", nil, err
+	}
+	defer drainAndClose(resp.Body)
+	resolvedURL = finalURL(resp)
+	if resp.StatusCode != 200 {
+		return resolvedURL, nil, nil
+	}
+	doc, err = parseHTMLResponse(resp)
+	return resolvedURL, doc, err
+}
+
+// addAppStorePageFields fills the fields of an iOS record that need its App
+// Store page, if any of them is selected or --history is set.
+func addAppStorePageFields(ctx context.Context, rec *record) {
+	if !(anySelected(appStorePageFields) || historyRequested) || rec.URL == "" {
+		return
+	}
+	pageURL := appStorePageURL(rec.Bundle)
+	resolvedURL, doc, err := fetchStorePage(ctx, pageURL)
+	rec.ResolvedURL = resolvedURL
+	if err != nil {
+		logger.Debug("App Store page request failed", "url", pageURL, "err", err)
+		return
+	}
+	if doc == nil {
+		return
+	}
+	rec.Privacy = parseAppPrivacy(doc)
+	rec.Badges = parseBadges(doc)
+	rec.InAppPurchases = parseInAppPurchases(doc)
+	controller := hasLeafText(doc, controllerTexts)
+	rec.Controller = &controller
+	if historyRequested {
+		if versions := parseVersionHistory(doc); versions != nil {
+			rec.History = versions
+		}
+	}
+}
+
+// addDataSafetyFields fills the Data safety fields of an Android record if
+// any of them is selected.
+func addDataSafetyFields(ctx context.Context, rec *record) {
+	if !anySelected(dataSafetyFields) {
+		return
+	}
+	pageURL := buildDataSafetyURL(rec.Bundle)
+	_, doc, err := fetchStorePage(ctx, pageURL)
+	if err != nil {
+		logger.Debug("Data safety page request failed", "url", pageURL, "err", err)
+		return
+	}
+	if doc != nil {
+		rec.DataSafety = parseDataSafety(doc)
+	}
+}
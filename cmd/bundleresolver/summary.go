@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// slowestKept is the number of slowest lookups reported in the summary.
+const slowestKept = 5
+
+// summary accumulates end-of-run statistics. All methods are safe for
+// concurrent use.
+type summary struct {
+	mu            sync.Mutex
+	start         time.Time
+	retries       int64
+	cacheHits     int64
+	revalidations int64
+
+	Total            int                         `json:"total"`
+	Resolved         int                         `json:"resolved"`
+	Failed           int                         `json:"failed"`
+	Blank            int                         `json:"blank"`
+	Platforms        map[string]*platformSummary `json:"platforms"`
+	Retries          int64                       `json:"retries"`
+	CacheHits        int64                       `json:"cache_hits"`
+	CacheRevalidated int64                       `json:"cache_revalidated"`
+	ElapsedMS        int64                       `json:"elapsed_ms"`
+	Slowest          []lookupTiming              `json:"slowest"`
+}
+
+type platformSummary struct {
+	Resolved int `json:"resolved"`
+	Failed   int `json:"failed"`
+}
+
+type lookupTiming struct {
+	ID         string `json:"id"`
+	Platform   string `json:"platform"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+func newSummary() *summary {
+	return &summary{
+		start:   time.Now(),
+		retries: lookupRetries.Load(),
+		// The cache counters are process-wide, like lookupRetries.
+		cacheHits:     cacheHits.Load(),
+		revalidations: cacheRevalidations.Load(),
+		Platforms:     map[string]*platformSummary{},
+	}
+}
+
+func (s *summary) blank() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Total++
+	s.Blank++
+}
+
+func (s *summary) lookup(id string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Total++
+	platform := platformOf(id)
+	ps := s.Platforms[platform]
+	if ps == nil {
+		ps = &platformSummary{}
+		s.Platforms[platform] = ps
+	}
+	t := lookupTiming{ID: id, Platform: platform, DurationMS: d.Milliseconds()}
+	if err != nil {
+		s.Failed++
+		ps.Failed++
+		t.Error = err.Error()
+	} else {
+		s.Resolved++
+		ps.Resolved++
+	}
+
+	s.Slowest = append(s.Slowest, t)
+	sort.SliceStable(s.Slowest, func(i, j int) bool { return s.Slowest[i].DurationMS > s.Slowest[j].DurationMS })
+	if len(s.Slowest) > slowestKept {
+		s.Slowest = s.Slowest[:slowestKept]
+	}
+}
+
+// finish freezes elapsed time, the retry count and the cache counts.
+func (s *summary) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ElapsedMS = time.Since(s.start).Milliseconds()
+	s.Retries = lookupRetries.Load() - s.retries
+	s.CacheHits = cacheHits.Load() - s.cacheHits
+	s.CacheRevalidated = cacheRevalidations.Load() - s.revalidations
+}
+
+func (s *summary) writeText(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(w, "Summary: %d lines, %d resolved, %d failed, %d blank in %s\n",
+		s.Total, s.Resolved, s.Failed, s.Blank, time.Duration(s.ElapsedMS)*time.Millisecond)
+	platforms := make([]string, 0, len(s.Platforms))
+	for p := range s.Platforms {
+		platforms = append(platforms, p)
+	}
+	sort.Strings(platforms)
+	for _, p := range platforms {
+		ps := s.Platforms[p]
+		fmt.Fprintf(w, "  %-8s %d resolved, %d failed\n", p, ps.Resolved, ps.Failed)
+	}
+	fmt.Fprintf(w, "  retries  %d\n", s.Retries)
+	if s.CacheHits > 0 || s.CacheRevalidated > 0 {
+		fmt.Fprintf(w, "  cache    %d hits, %d revalidated\n", s.CacheHits, s.CacheRevalidated)
+	}
+	if len(s.Slowest) > 0 {
+		fmt.Fprintf(w, "  slowest lookups:\n")
+		for _, t := range s.Slowest {
+			fmt.Fprintf(w, "    %6dms  %s\n", t.DurationMS, t.ID)
+		}
+	}
+}
+
+func (s *summary) writeJSONFile(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummaryCounts(t *testing.T) {
+	originalResolve := resolveFunc
+	defer func() {
+		resolveFunc = originalResolve
+	}()
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		if id == "com.missing.app" {
+			return record{Bundle: id}, errors.New("not found")
+		}
+		return record{Bundle: id, Name: "App"}, nil
+	}
+
+	sum := newSummary()
+	input := strings.NewReader("123\n\ncom.example.app\ncom.missing.app\n")
+	var out strings.Builder
+	if err := process(input, &out, options{Fields: []Field{FieldBundle}, Summary: sum}); err != nil {
+		t.Fatalf("process returned error: %v", err)
+	}
+	sum.finish()
+
+	if sum.Total != 4 || sum.Resolved != 2 || sum.Failed != 1 || sum.Blank != 1 {
+		t.Fatalf("unexpected totals: %+v", sum)
+	}
+	if got := *sum.Platforms[platformIOS]; got != (platformSummary{Resolved: 1}) {
+		t.Errorf("ios = %+v", got)
+	}
+	if got := *sum.Platforms[platformAndroid]; got != (platformSummary{Resolved: 1, Failed: 1}) {
+		t.Errorf("android = %+v", got)
+	}
+	if len(sum.Slowest) != 3 {
+		t.Errorf("slowest has %d entries, want 3", len(sum.Slowest))
+	}
+}
+
+func TestSummarySlowestBounded(t *testing.T) {
+	sum := newSummary()
+	for i := 1; i <= slowestKept+3; i++ {
+		sum.lookup("123", time.Duration(i)*time.Millisecond, nil)
+	}
+	if len(sum.Slowest) != slowestKept {
+		t.Fatalf("kept %d lookups, want %d", len(sum.Slowest), slowestKept)
+	}
+	if sum.Slowest[0].DurationMS != int64(slowestKept+3) {
+		t.Fatalf("slowest first = %dms", sum.Slowest[0].DurationMS)
+	}
+}
+
+func TestSummaryCacheCounts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "page")
+	}))
+	defer srv.Close()
+	fresh, err := newCacheTransport(http.DefaultTransport, t.TempDir(), time.Hour, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revalidated, err := newCacheTransport(http.DefaultTransport, t.TempDir(), 0, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := newSummary()
+	for _, cache := range []*cacheTransport{fresh, fresh, fresh, revalidated, revalidated} {
+		resp, err := (&http.Client{Transport: cache}).Get(srv.URL + "/lookup?id=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainAndClose(resp.Body)
+	}
+	sum.finish()
+	if sum.CacheHits != 2 || sum.CacheRevalidated != 1 {
+		t.Errorf("cache hits = %d, revalidated = %d; want 2 and 1", sum.CacheHits, sum.CacheRevalidated)
+	}
+	var out strings.Builder
+	sum.writeText(&out)
+	if !strings.Contains(out.String(), "cache    2 hits, 1 revalidated") {
+		t.Errorf("summary text lacks the cache counts:\n%s", out.String())
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+)
+
+// torCircuit is the SOCKS username of the current --tor batch. Tor puts
+// streams with different SOCKS credentials on different circuits
+// (IsolateSOCKSAuth, on by default), so each batch leaves through its own
+// exit and batches cannot be linked by it.
+var torCircuit atomic.Pointer[string]
+
+// newTorCircuit starts a new --tor batch: the requests sent from now on
+// use a fresh circuit. Connections of the previous batch are not reused, as
+// net/http keys connections by their proxy URL.
+func newTorCircuit() {
+	b := make([]byte, 8)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	torCircuit.Store(&id)
+}
+
+// torProxy returns a Proxy function for net/http sending every request to
+// the Tor SOCKS port at addr, on the circuit of the current batch. Host
+// names are resolved by Tor, so no DNS query leaves the host either.
+func torProxy(addr string) (func(*http.Request) (*url.URL, error), error) {
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		return nil, fmt.Errorf("%q is not a host:port SOCKS address", addr)
+	}
+	if torCircuit.Load() == nil {
+		newTorCircuit()
+	}
+	return func(*http.Request) (*url.URL, error) {
+		return &url.URL{Scheme: "socks5", Host: addr, User: url.UserPassword(*torCircuit.Load(), "x")}, nil
+	}, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTor is a SOCKS5 server requiring username/password authentication
+// that connects every stream to target, recording the username and
+// requested host of each.
+type fakeTor struct {
+	ln     net.Listener
+	target string
+
+	mu      sync.Mutex
+	streams []string // "username host"
+}
+
+func startFakeTor(t *testing.T, target string) *fakeTor {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeTor{ln: ln, target: target}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeTor) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	read := func(n int) []byte {
+		b := make([]byte, n)
+		io.ReadFull(r, b)
+		return b
+	}
+	greeting := read(2)
+	read(int(greeting[1]))
+	c.Write([]byte{5, 2}) // username/password
+	read(1)
+	user := string(read(int(read(1)[0])))
+	read(int(read(1)[0]))
+	c.Write([]byte{1, 0})
+	req := read(4)
+	var host string
+	switch req[3] {
+	case 3:
+		host = string(read(int(read(1)[0])))
+	case 1:
+		host = net.IP(read(4)).String()
+	}
+	read(2)
+	f.mu.Lock()
+	f.streams = append(f.streams, user+" "+host)
+	f.mu.Unlock()
+	up, err := net.Dial("tcp", f.target)
+	if err != nil {
+		return
+	}
+	defer up.Close()
+	c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go io.Copy(up, r)
+	io.Copy(c, up)
+}
+
+func TestTorProxyIsolatesBatches(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	tor := startFakeTor(t, srv.Listener.Addr().String())
+
+	transport, err := newTransport(transportOptions{ipVersion: "any", keepAlive: true, tor: tor.ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+	get := func() {
+		t.Helper()
+		resp, err := client.Get("http://itunes.example.test/lookup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainAndClose(resp.Body)
+	}
+
+	newTorCircuit()
+	get()
+	get() // reuses the batch's connection
+	newTorCircuit()
+	get()
+
+	tor.mu.Lock()
+	defer tor.mu.Unlock()
+	if len(tor.streams) != 2 {
+		t.Fatalf("streams = %q, want one per batch", tor.streams)
+	}
+	for _, s := range tor.streams {
+		if _, host, _ := strings.Cut(s, " "); host != "itunes.example.test" {
+			t.Errorf("stream %q: want the host name resolved by Tor", s)
+		}
+	}
+	if user1, _, _ := strings.Cut(tor.streams[0], " "); user1 == "" || strings.HasPrefix(tor.streams[1], user1+" ") {
+		t.Errorf("streams %q: want different SOCKS usernames per batch", tor.streams)
+	}
+}
+
+func TestTorProxyOptions(t *testing.T) {
+	for _, o := range []transportOptions{
+		{ipVersion: "any", tor: "127.0.0.1"},
+		{ipVersion: "any", tor: "127.0.0.1:9050", androidProxy: "socks5://residential.example:1080"},
+		{ipVersion: "any", tor: "127.0.0.1:9050", dnsServer: "1.1.1.1"},
+	} {
+		if _, err := newTransport(o); err == nil {
+			t.Errorf("%+v accepted", o)
+		}
+	}
+}
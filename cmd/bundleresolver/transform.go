@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fieldTransform rewrites the value of one field of every record.
+type fieldTransform struct {
+	field Field
+	fn    func(string) string
+}
+
+// transforms are the parsed --transform entries, applied in order, so
+// several entries for the same field chain.
+type transforms []fieldTransform
+
+// transformableFields are the fields a record stores as plain strings;
+// the others are derived and change with what they are derived from.
+var transformableFields = map[Field]func(rec *record) *string{
+	FieldName:           func(rec *record) *string { return &rec.Name },
+	FieldPublisher:      func(rec *record) *string { return &rec.Publisher },
+	FieldURL:            func(rec *record) *string { return &rec.URL },
+	FieldResolvedURL:    func(rec *record) *string { return &rec.ResolvedURL },
+	FieldStoreURL:       func(rec *record) *string { return &rec.StoreURL },
+	FieldWebsite:        func(rec *record) *string { return &rec.Website },
+	FieldPrice:          func(rec *record) *string { return &rec.Price },
+	FieldCategory:       func(rec *record) *string { return &rec.Category },
+	FieldDeveloperEmail: func(rec *record) *string { return &rec.DeveloperEmail },
+}
+
+// transformOps builds each transform from its argument; takesArg tells
+// whether it needs one (`trim-suffix:", Inc."`).
+var transformOps = map[string]struct {
+	takesArg bool
+	build    func(arg string) (func(string) string, error)
+}{
+	"lower": {false, func(string) (func(string) string, error) { return strings.ToLower, nil }},
+	"upper": {false, func(string) (func(string) string, error) { return strings.ToUpper, nil }},
+	"trim":  {false, func(string) (func(string) string, error) { return strings.TrimSpace, nil }},
+	"trim-prefix": {true, func(arg string) (func(string) string, error) {
+		return func(s string) string { return strings.TrimPrefix(s, arg) }, nil
+	}},
+	"trim-suffix": {true, func(arg string) (func(string) string, error) {
+		return func(s string) string { return strings.TrimSuffix(s, arg) }, nil
+	}},
+	"truncate": {true, func(arg string) (func(string) string, error) {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("truncate takes a positive length, not %q", arg)
+		}
+		return func(s string) string {
+			if r := []rune(s); len(r) > n {
+				return string(r[:n])
+			}
+			return s
+		}, nil
+	}},
+}
+
+// parseTransforms parses a --transform list such as
+// `name=lower,publisher=trim-suffix:", Inc."`. Arguments may be
+// double-quoted (Go syntax) to hold commas or leading spaces.
+func parseTransforms(src string) (transforms, error) {
+	var ts transforms
+	for _, entry := range splitTransforms(src) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not field=transform", entry)
+		}
+		f := Field(strings.TrimSpace(name))
+		if transformableFields[f] == nil {
+			return nil, fmt.Errorf("field %q cannot be transformed", f)
+		}
+		op, arg, hasArg := strings.Cut(strings.TrimSpace(spec), ":")
+		t, known := transformOps[op]
+		if !known {
+			return nil, fmt.Errorf("unknown transform %q", op)
+		}
+		if t.takesArg != hasArg {
+			if t.takesArg {
+				return nil, fmt.Errorf("transform %q needs an argument (%s:<arg>)", op, op)
+			}
+			return nil, fmt.Errorf("transform %q takes no argument", op)
+		}
+		if strings.HasPrefix(arg, `"`) {
+			unquoted, err := strconv.Unquote(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid argument %s: %w", arg, err)
+			}
+			arg = unquoted
+		}
+		fn, err := t.build(arg)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, fieldTransform{field: f, fn: fn})
+	}
+	return ts, nil
+}
+
+// splitTransforms splits src at the commas outside double quotes.
+func splitTransforms(src string) []string {
+	var parts []string
+	quoted, escaped := false, false
+	start := 0
+	for i, r := range src {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			parts = append(parts, src[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, src[start:])
+}
+
+// apply rewrites rec's fields. Empty values stay empty.
+func (ts transforms) apply(rec *record) {
+	for _, t := range ts {
+		if p := transformableFields[t.field](rec); *p != "" {
+			*p = t.fn(*p)
+		}
+	}
+}
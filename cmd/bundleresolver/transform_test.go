@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTransforms(t *testing.T) {
+	ts, err := parseTransforms(`name=trim, name=lower,publisher=trim-suffix:", Inc.",category=truncate:4`)
+	if err != nil {
+		t.Fatalf("parseTransforms returned error: %v", err)
+	}
+	rec := record{Name: "  My App ", Publisher: "Example, Inc.", Category: "Productivity", URL: "https://example.com"}
+	ts.apply(&rec)
+	want := record{Name: "my app", Publisher: "Example", Category: "Prod", URL: "https://example.com"}
+	if rec.Name != want.Name || rec.Publisher != want.Publisher || rec.Category != want.Category || rec.URL != want.URL {
+		t.Errorf("got %+v, want %+v", rec, want)
+	}
+}
+
+func TestParseTransformsErrors(t *testing.T) {
+	cases := map[string]string{
+		"name":                "not field=transform",
+		"kids=lower":          "cannot be transformed",
+		"name=shout":          "unknown transform",
+		"name=trim-suffix":    "needs an argument",
+		"name=lower:x":        "takes no argument",
+		"name=truncate:0":     "positive length",
+		`name=trim-prefix:"x`: "invalid argument",
+	}
+	for src, want := range cases {
+		if _, err := parseTransforms(src); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseTransforms(%q) error = %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestTransformsKeepEmptyValues(t *testing.T) {
+	ts, err := parseTransforms("website=upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec record
+	ts.apply(&rec)
+	if rec.Website != "" {
+		t.Errorf("website = %q", rec.Website)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// aasaFile is the applinks part of an apple-app-site-association file.
+type aasaFile struct {
+	Applinks struct {
+		Details []aasaDetail `json:"details"`
+	} `json:"applinks"`
+}
+
+type aasaDetail struct {
+	// AppID (iOS 12 and earlier) or AppIDs name apps as <team id>.<bundle id>.
+	AppID  string   `json:"appID"`
+	AppIDs []string `json:"appIDs"`
+	// Paths is the pre-iOS 13 syntax: patterns, "NOT " excluding.
+	Paths      []string        `json:"paths"`
+	Components []aasaComponent `json:"components"`
+}
+
+type aasaComponent struct {
+	Path     string          `json:"/"`
+	Query    json.RawMessage `json:"?"` // a string or a map of query items
+	Fragment string          `json:"#"`
+	Exclude  bool            `json:"exclude"`
+}
+
+// aasaRule is one path pattern of an app.
+type aasaRule struct {
+	AppID   string
+	Pattern string
+	Exclude bool
+}
+
+// rulesFor returns the patterns of the apps whose bundle identifier is
+// bundleID, in file order. The team id prefix is not checked.
+func (f *aasaFile) rulesFor(bundleID string) []aasaRule {
+	var rules []aasaRule
+	for _, d := range f.Applinks.Details {
+		ids := d.AppIDs
+		if d.AppID != "" {
+			ids = append([]string{d.AppID}, ids...)
+		}
+		for _, appID := range ids {
+			_, bundle, ok := strings.Cut(appID, ".")
+			if !ok || !strings.EqualFold(bundle, bundleID) {
+				continue
+			}
+			for _, p := range d.Paths {
+				if rest, ok := strings.CutPrefix(p, "NOT "); ok {
+					rules = append(rules, aasaRule{AppID: appID, Pattern: rest, Exclude: true})
+				} else {
+					rules = append(rules, aasaRule{AppID: appID, Pattern: p})
+				}
+			}
+			for _, c := range d.Components {
+				rules = append(rules, aasaRule{AppID: appID, Pattern: c.pattern(), Exclude: c.Exclude})
+			}
+		}
+	}
+	return rules
+}
+
+// pattern renders a component as a URL pattern such as /docs/*?lang=*#top.
+func (c aasaComponent) pattern() string {
+	p := c.Path
+	if p == "" {
+		p = "*"
+	}
+	if len(c.Query) > 0 {
+		var q string
+		var items map[string]string
+		if json.Unmarshal(c.Query, &q) != nil && json.Unmarshal(c.Query, &items) == nil {
+			keys := make([]string, 0, len(items))
+			for k := range items {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			parts := make([]string, len(keys))
+			for i, k := range keys {
+				parts[i] = k + "=" + items[k]
+			}
+			q = strings.Join(parts, "&")
+		}
+		if q != "" {
+			p += "?" + q
+		}
+	}
+	if c.Fragment != "" {
+		p += "#" + c.Fragment
+	}
+	return p
+}
+
+// errNoAASA means the domain serves no apple-app-site-association file.
+var errNoAASA = errors.New("no apple-app-site-association file")
+
+// fetchAASA fetches the AASA file of domain from /.well-known, falling back
+// to the site root. Apple only accepts it over HTTPS.
+func fetchAASA(ctx context.Context, domain string) (*aasaFile, error) {
+	var err error
+	for _, path := range []string{"/.well-known/apple-app-site-association", "/apple-app-site-association"} {
+		var f *aasaFile
+		f, err = fetchAASAURL(ctx, "https://"+domain+path)
+		if !errors.Is(err, errNoAASA) {
+			return f, err
+		}
+	}
+	return nil, err
+}
+
+func fetchAASAURL(ctx context.Context, u string) (*aasaFile, error) {
+	resp, err := httpGet(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode == 404 || resp.StatusCode == 410 {
+		return nil, errNoAASA
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s: status %s", u, resp.Status)
+	}
+	var f aasaFile
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		return nil, fmt.Errorf("%s: invalid JSON: %w", u, err)
+	}
+	return &f, nil
+}
+
+func setupUniversalLinks(fs *flag.FlagSet) func(context.Context, []string) error {
+	var common commonFlags
+	common.register(fs)
+
+	var inputPath string
+	var showHeader bool
+	fs.StringVar(&inputPath, "input", "", "Read ids from this file instead of STDIN")
+	fs.BoolVar(&showHeader, "header", true, "Print header row as first line (use --header=false to disable)")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q (ids are read from STDIN or --input)", args)
+		}
+		cleanup, err := common.setup(os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		in := io.Reader(os.Stdin)
+		if inputPath != "" {
+			f, err := os.Open(inputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		return checkUniversalLinks(ctx, in, os.Stdout, showHeader)
+	}
+}
+
+// checkUniversalLinks writes, for every iOS id, one TSV row per path
+// pattern its developer domain's AASA file maps to the app. Ids without
+// patterns get a single row whose status says why: not-listed (the file
+// does not name the app), missing, no-website, not-ios or error.
+func checkUniversalLinks(ctx context.Context, r io.Reader, w io.Writer, header bool) error {
+	out := newRowWriter(w, nil, false, sanitizeStrip)
+	if header {
+		if err := out.writeValues("input", "bundle", "domain", "status", "app_id", "path", "exclude"); err != nil {
+			return err
+		}
+	}
+
+	type domainResult struct {
+		file *aasaFile
+		err  error
+	}
+	byDomain := map[string]domainResult{}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		id := strings.TrimSpace(s.Text())
+		if id == "" {
+			continue
+		}
+		var rec record
+		var domain, status string
+		var rules []aasaRule
+		var err error
+		if platformOf(id) != platformIOS {
+			status = "not-ios"
+		} else if rec, err = resolveOne(ctx, id, ""); err == nil {
+			domain, err = developerDomain(rec.Website)
+			if err != nil {
+				status = "no-website"
+			}
+		}
+		if status == "" && err == nil {
+			res, ok := byDomain[domain]
+			if !ok {
+				res.file, res.err = fetchAASA(ctx, domain)
+				byDomain[domain] = res
+			}
+			err = res.err
+			switch {
+			case errors.Is(err, errNoAASA):
+				status = "missing"
+			case err == nil:
+				if rules = res.file.rulesFor(rec.BundleID); len(rules) == 0 {
+					status = "not-listed"
+				}
+			}
+		}
+		if err != nil {
+			logger.Warn("universal links check failed", "id", id, "domain", domain, "err", err)
+			if status == "" {
+				status = "error"
+			}
+		}
+
+		bundle := rec.Bundle
+		if bundle == "" {
+			bundle = id
+		}
+		if len(rules) == 0 {
+			if err := out.writeValues(id, bundle, domain, status, "", "", ""); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, rule := range rules {
+			exclude := "false"
+			if rule.Exclude {
+				exclude = "true"
+			}
+			if err := out.writeValues(id, bundle, domain, "ok", rule.AppID, rule.Pattern, exclude); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return out.flush()
+}
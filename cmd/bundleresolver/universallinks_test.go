@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const sampleAASA = `{
+  "applinks": {
+    "details": [
+      {
+        "appIDs": ["ABCDE12345.com.dev.app", "ABCDE12345.com.dev.other"],
+        "components": [
+          {"/": "/private/*", "exclude": true},
+          {"/": "/items/*", "?": {"ref": "?*"}},
+          {"#": "share"}
+        ]
+      },
+      {"appID": "ABCDE12345.com.dev.legacy", "paths": ["/old/*", "NOT /old/admin"]}
+    ]
+  }
+}`
+
+func TestAASARules(t *testing.T) {
+	originalResolve, originalClient := resolveFunc, httpClient
+	defer func() {
+		resolveFunc, httpClient = originalResolve, originalClient
+	}()
+	bundleIDs := map[string]string{"1": "com.dev.app", "2": "com.dev.legacy", "3": "com.dev.unlisted"}
+	resolveFunc = func(_ context.Context, id string) (record, error) {
+		return record{Bundle: id, BundleID: bundleIDs[id], Website: "https://www.dev.example"}, nil
+	}
+	// Served from the site root only, to exercise the fallback.
+	httpClient = &http.Client{Transport: fakeTransport{"https://dev.example/apple-app-site-association": sampleAASA}}
+
+	var out strings.Builder
+	if err := checkUniversalLinks(context.Background(), strings.NewReader("1\n2\n3\ncom.dev.android\n"), &out, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "input\tbundle\tdomain\tstatus\tapp_id\tpath\texclude\n" +
+		"1\t1\tdev.example\tok\tABCDE12345.com.dev.app\t/private/*\ttrue\n" +
+		"1\t1\tdev.example\tok\tABCDE12345.com.dev.app\t/items/*?ref=?*\tfalse\n" +
+		"1\t1\tdev.example\tok\tABCDE12345.com.dev.app\t*#share\tfalse\n" +
+		"2\t2\tdev.example\tok\tABCDE12345.com.dev.legacy\t/old/*\tfalse\n" +
+		"2\t2\tdev.example\tok\tABCDE12345.com.dev.legacy\t/old/admin\ttrue\n" +
+		"3\t3\tdev.example\tnot-listed\t\t\t\n" +
+		"com.dev.android\tcom.dev.android\t\tnot-ios\t\t\t\n"
+	if got := out.String(); got != want {
+		t.Errorf("output mismatch:\n got: %q\nwant: %q", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// The values of --url-style.
+const (
+	urlStyleCanonical = "canonical" // the URL built from the id
+	urlStyleStore     = "store"     // the URL the store links to
+	urlStyleShort     = "short"     // a redirect link served by `serve`
+)
+
+var urlStyles = []string{urlStyleCanonical, urlStyleStore, urlStyleShort}
+
+// urlStyle rewrites the url field of resolved records. The zero value keeps
+// the canonical URL.
+type urlStyle struct {
+	style string
+	// shortBase is the address of a `serve` instance, whose /go/<id> route
+	// redirects to the store page.
+	shortBase string
+}
+
+func parseURLStyle(style, shortBase string) (urlStyle, error) {
+	switch style {
+	case "", urlStyleCanonical, urlStyleStore:
+		if shortBase != "" {
+			return urlStyle{}, errors.New("--short-base requires --url-style short")
+		}
+		return urlStyle{style: style}, nil
+	case urlStyleShort:
+		u, err := url.Parse(shortBase)
+		if shortBase == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return urlStyle{}, errors.New("--url-style short requires --short-base, the http(s) address of a serve instance")
+		}
+		return urlStyle{style: style, shortBase: strings.TrimSuffix(shortBase, "/")}, nil
+	}
+	return urlStyle{}, fmt.Errorf("unknown --url-style %q (want %s)", style, strings.Join(urlStyles, ", "))
+}
+
+// apply rewrites rec.URL. Records without a URL are left alone, and store
+// keeps the canonical URL when the store gave none (always on Android,
+// whose page URLs have no slug).
+func (s urlStyle) apply(rec *record) {
+	if rec.URL == "" {
+		return
+	}
+	switch s.style {
+	case urlStyleStore:
+		if rec.StoreURL != "" {
+			rec.URL = rec.StoreURL
+		}
+	case urlStyleShort:
+		rec.URL = s.shortBase + "/go/" + url.PathEscape(rec.Bundle)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestURLStyle(t *testing.T) {
+	ios := record{Bundle: "123", URL: buildAppStoreURL("123"), StoreURL: "https://apps.apple.com/us/app/example/id123?uo=4"}
+	android := record{Bundle: "com.example.app", URL: buildPlayStoreURL("com.example.app")}
+
+	cases := []struct {
+		style, base string
+		rec         record
+		want        string
+	}{
+		{"canonical", "", ios, "https://apps.apple.com/app/id123"},
+		{"store", "", ios, "https://apps.apple.com/us/app/example/id123?uo=4"},
+		{"store", "", android, "https://play.google.com/store/apps/details?id=com.example.app"},
+		{"short", "https://go.example.com/", android, "https://go.example.com/go/com.example.app"},
+		{"short", "https://go.example.com", record{Bundle: "hello"}, ""},
+	}
+	for _, c := range cases {
+		s, err := parseURLStyle(c.style, c.base)
+		if err != nil {
+			t.Fatalf("parseURLStyle(%q, %q): %v", c.style, c.base, err)
+		}
+		rec := c.rec
+		s.apply(&rec)
+		if rec.URL != c.want {
+			t.Errorf("%s: url = %q, want %q", c.style, rec.URL, c.want)
+		}
+	}
+}
+
+func TestParseURLStyleErrors(t *testing.T) {
+	cases := []struct{ style, base, want string }{
+		{"tiny", "", "unknown --url-style"},
+		{"short", "", "requires --short-base"},
+		{"short", "go.example.com", "requires --short-base"},
+		{"store", "https://go.example.com", "requires --url-style short"},
+	}
+	for _, c := range cases {
+		if _, err := parseURLStyle(c.style, c.base); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("parseURLStyle(%q, %q) error = %v, want %q", c.style, c.base, err, c.want)
+		}
+	}
+}
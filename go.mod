@@ -2,9 +2,16 @@ module github.com/arimura/bundleresolver
 
 go 1.22
 
-require github.com/PuerkitoBio/goquery v1.9.2
+require (
+	github.com/PuerkitoBio/goquery v1.9.2
+	github.com/aws/aws-lambda-go v1.47.0
+	go.starlark.net v0.0.0-20240725214946-42030a7cedce
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/andybalholm/cascadia v1.3.2 // indirect
 	golang.org/x/net v0.24.0 // indirect
+	golang.org/x/sys v0.19.0 // indirect
 )